@@ -0,0 +1,68 @@
+package mpv
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/frizinak/libym/player"
+)
+
+// PropertyStringer can optionally be implemented by a Backend to read
+// string properties, required for MPV.Levels.
+type PropertyStringer interface {
+	GetPropertyString(string) (string, error)
+}
+
+const (
+	levelsLabel  = "ym-levels"
+	levelsFilter = "@" + levelsLabel + ":lavfi=[astats=metadata=1:reset=1]"
+	levelsPrefix = "af-metadata/" + levelsLabel + "/by-key/lavfi.astats."
+)
+
+// Levels implements player.LevelMeter using ffmpeg's astats filter, which
+// is added the first time levels are requested.
+func (m *MPV) Levels() (player.Levels, error) {
+	var l player.Levels
+	b, ok := m.b.(PropertyStringer)
+	if !ok {
+		return l, player.ErrNoLevels
+	}
+
+	m.sem.Lock()
+	if !m.state.levels {
+		if err := m.b.Command("af", "add", levelsFilter); err != nil {
+			m.sem.Unlock()
+			return l, err
+		}
+		m.state.levels = true
+	}
+	m.sem.Unlock()
+
+	get := func(key string) (float64, error) {
+		v, err := b.GetPropertyString(levelsPrefix + key)
+		if err != nil {
+			return 0, err
+		}
+		return strconv.ParseFloat(v, 64)
+	}
+
+	var err error
+	if l.RMS, err = get("Overall.RMS_level"); err != nil {
+		return l, err
+	}
+	if l.Peak, err = get("Overall.Peak_level"); err != nil {
+		return l, err
+	}
+	for i := 1; ; i++ {
+		v, err := get(strconv.Itoa(i) + ".RMS_level")
+		if err != nil {
+			break
+		}
+		l.Channels = append(l.Channels, v)
+	}
+	if len(l.Channels) == 0 {
+		return l, errors.New("no channel levels")
+	}
+
+	return l, nil
+}
@@ -31,8 +31,10 @@ func (m *LibMPV) Init(events chan<- wrap.Event) error {
 		return err
 	}
 
-	if err := m.mpv.ObserveProperty(0, "pause", mpv.FORMAT_FLAG); err != nil {
-		return err
+	for i, prop := range wrap.Observe {
+		if err := m.mpv.ObserveProperty(uint64(i), prop, mpv.FORMAT_FLAG); err != nil {
+			return err
+		}
 	}
 
 	ev := map[mpv.EventId]wrap.EventID{
@@ -48,7 +50,11 @@ func (m *LibMPV) Init(events chan<- wrap.Event) error {
 			}
 			e := m.mpv.WaitEvent(1)
 			if id, ok := ev[e.Event_Id]; ok {
-				events <- wrap.Event{id}
+				event := wrap.Event{ID: id}
+				if id == wrap.EventPropertyChange && e.Reply_Userdata < uint64(len(wrap.Observe)) {
+					event.Property = wrap.Observe[e.Reply_Userdata]
+				}
+				events <- event
 			}
 		}
 	}()
@@ -81,6 +87,14 @@ func (m *LibMPV) SetPropertyString(n, v string) error {
 	return m.mpv.SetPropertyString(n, v)
 }
 
+func (m *LibMPV) GetPropertyString(n string) (string, error) {
+	v, err := m.mpv.GetProperty(n, mpv.FORMAT_STRING)
+	if err != nil || v == nil {
+		return "", err
+	}
+	return v.(string), err
+}
+
 func (m *LibMPV) GetPropertyBool(n string) (bool, error) {
 	v, err := m.mpv.GetProperty(n, mpv.FORMAT_FLAG)
 	if err != nil || v == nil {
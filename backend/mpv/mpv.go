@@ -3,10 +3,13 @@
 package mpv
 
 import (
+	"fmt"
 	"io"
 	"log"
 	"sync"
 	"time"
+
+	"github.com/frizinak/libym/player"
 )
 
 // Backend is a generic mpv interface. Applicable to both libmpv and
@@ -26,6 +29,12 @@ type Backend interface {
 	Command(...string) error
 }
 
+// Redialer can optionally be implemented by a Backend that talks to mpv
+// over a connection that might not survive a system suspend.
+type Redialer interface {
+	Redial() error
+}
+
 // EventID represents an mpv event type.
 type EventID byte
 
@@ -35,9 +44,18 @@ const (
 	EventPropertyChange
 )
 
+// Observe are the flag properties a Backend must observe and report with
+// EventPropertyChange.
+var Observe = []string{"pause", "paused-for-cache"}
+
 // Event represents an mpv event.
 type Event struct {
 	ID EventID
+	// Err is set if an EventEndFile was caused by an error.
+	Err error
+	// Property is the name of the property of an EventPropertyChange,
+	// one of Observe. Empty means pause.
+	Property string
 }
 
 // New creates a new mpv wrapper that interfaces with any Backend
@@ -60,6 +78,16 @@ type MPV struct {
 
 		paused bool
 		events chan Event
+
+		errSem sync.Mutex
+		endErr error
+
+		levels bool
+
+		bufSem      sync.Mutex
+		buffering   bool
+		bufConfig   *player.BufferConfig
+		onBuffering []func(bool)
 	}
 
 	b Backend
@@ -91,6 +119,9 @@ func (m *MPV) Init() error {
 		for e := range m.state.events {
 			switch e.ID {
 			case EventEndFile:
+				m.state.errSem.Lock()
+				m.state.endErr = e.Err
+				m.state.errSem.Unlock()
 				m.state.dones[0] <- struct{}{}
 				m.state.dones = m.state.dones[1:]
 			case EventStartFile:
@@ -101,6 +132,10 @@ func (m *MPV) Init() error {
 				m.state.dones = append(m.state.dones, done)
 				m.state.starts <- done
 			case EventPropertyChange:
+				if e.Property == "paused-for-cache" {
+					m.buffered()
+					continue
+				}
 				paused, err := m.b.GetPropertyBool("pause")
 				m.l(err, "pause")
 				actualPause = paused
@@ -112,6 +147,35 @@ func (m *MPV) Init() error {
 	return nil
 }
 
+// Reconnect redials the Backend if it implements Redialer and restores the
+// volume and pause state.
+func (m *MPV) Reconnect() error {
+	if r, ok := m.b.(Redialer); ok {
+		if err := r.Redial(); err != nil {
+			return err
+		}
+	}
+	m.SetVolume(m.state.volume)
+	m.state.bufSem.Lock()
+	conf := m.state.bufConfig
+	m.state.bufSem.Unlock()
+	if conf != nil {
+		if err := m.SetBufferConfig(*conf); err != nil {
+			return err
+		}
+	}
+	m.sem.Lock()
+	// a new mpv process does not have our filters.
+	m.state.levels = false
+	m.sem.Unlock()
+	paused, err := m.b.GetPropertyBool("pause")
+	if err != nil {
+		return err
+	}
+	m.state.paused = paused
+	return nil
+}
+
 func (m *MPV) Close() error {
 	err := m.b.Close()
 	close(m.state.events)
@@ -135,6 +199,65 @@ func (m *MPV) Play(file string) (chan struct{}, error) {
 
 func (m *MPV) Paused() bool { return m.state.paused }
 
+// PlaybackErr returns the error that ended the last file, if the Backend
+// reports it.
+func (m *MPV) PlaybackErr() error {
+	m.state.errSem.Lock()
+	defer m.state.errSem.Unlock()
+	return m.state.endErr
+}
+
+// Buffering reports whether mpv paused playback to fill its cache.
+func (m *MPV) Buffering() bool {
+	b, err := m.b.GetPropertyBool("paused-for-cache")
+	return err == nil && b
+}
+
+// OnBuffering registers a callback that is called when mpv starts or stops
+// waiting for its cache. Callbacks must not block.
+func (m *MPV) OnBuffering(cb func(buffering bool)) {
+	m.state.bufSem.Lock()
+	m.state.onBuffering = append(m.state.onBuffering, cb)
+	m.state.bufSem.Unlock()
+}
+
+func (m *MPV) buffered() {
+	b := m.Buffering()
+	m.state.bufSem.Lock()
+	changed := b != m.state.buffering
+	m.state.buffering = b
+	obs := m.state.onBuffering
+	m.state.bufSem.Unlock()
+	if !changed {
+		return
+	}
+	for _, cb := range obs {
+		cb(b)
+	}
+}
+
+// SetBufferConfig sets the mpv cache-secs and network-timeout options,
+// they are restored by Reconnect.
+func (m *MPV) SetBufferConfig(c player.BufferConfig) error {
+	if c.Cache > 0 {
+		if err := m.b.SetPropertyString("cache", "yes"); err != nil {
+			return err
+		}
+		if err := m.b.SetPropertyString("cache-secs", fmt.Sprintf("%g", c.Cache.Seconds())); err != nil {
+			return err
+		}
+	}
+	if c.NetworkTimeout > 0 {
+		if err := m.b.SetPropertyString("network-timeout", fmt.Sprintf("%g", c.NetworkTimeout.Seconds())); err != nil {
+			return err
+		}
+	}
+	m.state.bufSem.Lock()
+	m.state.bufConfig = &c
+	m.state.bufSem.Unlock()
+	return nil
+}
+
 func (m *MPV) Pause(pause bool) {
 	m.l(m.b.SetPropertyBool("pause", pause), "pause")
 }
@@ -198,7 +321,7 @@ func (m *MPV) seek(to float64) {
 }
 
 func (m *MPV) SeekTo(to float64) {
-	m.l(m.b.SetPropertyDouble("percent-pos", to), "percent-pos")
+	m.l(m.b.SetPropertyDouble("percent-pos", to*100), "percent-pos")
 }
 
 func (m *MPV) Position() time.Duration { return m.duration("time-pos") }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
@@ -12,14 +13,21 @@ import (
 	"time"
 
 	"github.com/frizinak/libym/backend/mpv"
+	"github.com/frizinak/libym/proc"
 )
 
 func New(log *log.Logger, ipcPath string, flags []string) *mpv.MPV {
+	return NewLimited(log, ipcPath, flags, proc.Limits{})
+}
+
+// NewLimited is New but spawns mpv within the given resource limits.
+func NewLimited(log *log.Logger, ipcPath string, flags []string, limits proc.Limits) *mpv.MPV {
 	return mpv.New(
 		log,
 		&RPC{
 			cmd:       "mpv",
 			flags:     flags,
+			limits:    limits,
 			ipc:       Pipe(ipcPath),
 			responses: make(chan response, 1024),
 		},
@@ -46,6 +54,9 @@ func newCommand(id uint16, cmd string, args ...interface{}) command {
 
 type response struct {
 	Event     string      `json:"event"`
+	Reason    string      `json:"reason"`
+	FileError string      `json:"file_error"`
+	Name      string      `json:"name"`
 	Error     string      `json:"error"`
 	Data      interface{} `json:"data"`
 	RequestID uint16      `json:"request_id,omitempty"`
@@ -54,11 +65,15 @@ type response struct {
 type RPC struct {
 	sem sync.Mutex
 
-	cmd   string
-	flags []string
-	ipc   string
+	cmd    string
+	flags  []string
+	limits proc.Limits
+	ipc    string
 
 	command *exec.Cmd
+	events  chan<- mpv.Event
+
+	connSem sync.Mutex
 	conn    Conn
 	w       *json.Encoder
 
@@ -77,30 +92,54 @@ func (m *RPC) Init(events chan<- mpv.Event) error {
 	}
 	f = append(f, m.flags...)
 
-	m.command = exec.Command(m.cmd, f...)
+	m.command = m.limits.Command(m.cmd, f...)
 
 	if err := m.command.Start(); err != nil {
 		return err
 	}
 
+	m.events = events
+	if err := m.dial(); err != nil {
+		m.command.Process.Kill()
+		return err
+	}
+
+	return nil
+}
+
+// Redial closes the current connection to mpv and dials its ipc socket
+// again.
+func (m *RPC) Redial() error {
+	m.connSem.Lock()
+	if m.conn != nil {
+		m.conn.Close()
+	}
+	m.connSem.Unlock()
+	return m.dial()
+}
+
+func (m *RPC) dial() error {
+	var conn Conn
 	n := 0
 	for {
 		time.Sleep(time.Millisecond * 25)
 
 		var err error
-		m.conn, err = Dial(m.ipc)
+		conn, err = Dial(m.ipc)
 		if err == nil {
 			break
 		}
 		n++
 		if n > 100 {
-			m.command.Process.Kill()
 			return err
 		}
 	}
 
-	m.w = json.NewEncoder(m.conn)
-	d := json.NewDecoder(m.conn)
+	m.connSem.Lock()
+	m.conn = conn
+	m.w = json.NewEncoder(conn)
+	m.connSem.Unlock()
+	d := json.NewDecoder(conn)
 
 	ev := map[string]mpv.EventID{
 		"end-file":        mpv.EventEndFile,
@@ -112,6 +151,12 @@ func (m *RPC) Init(events chan<- mpv.Event) error {
 		for {
 			r := response{}
 			if err := d.Decode(&r); err != nil {
+				m.connSem.Lock()
+				replaced := m.conn != conn
+				m.connSem.Unlock()
+				if replaced || errors.Is(err, io.EOF) {
+					return
+				}
 				continue
 			}
 
@@ -121,27 +166,38 @@ func (m *RPC) Init(events chan<- mpv.Event) error {
 			}
 
 			if id, ok := ev[r.Event]; ok {
-				events <- mpv.Event{id}
+				e := mpv.Event{ID: id}
+				if id == mpv.EventEndFile && r.Reason == "error" {
+					e.Err = errors.New(r.FileError)
+				}
+				if id == mpv.EventPropertyChange {
+					e.Property = r.Name
+				}
+				m.events <- e
 			}
 
 		}
 	}()
 
-	req := m.req()
-	if err := m.send(newCommand(req, "observe_property", 1, "pause")); err != nil {
-		return err
-	}
-	if _, err := m.waitReq(req); err != nil {
-		return err
+	for i, prop := range mpv.Observe {
+		req := m.req()
+		if err := m.send(newCommand(req, "observe_property", i+1, prop)); err != nil {
+			return err
+		}
+		if _, err := m.waitReq(req); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
 func (m *RPC) Close() error {
+	m.connSem.Lock()
 	if m.conn != nil {
 		m.conn.Close()
 	}
+	m.connSem.Unlock()
 
 	return m.command.Process.Kill()
 }
@@ -196,6 +252,20 @@ func (m *RPC) GetPropertyBool(n string) (bool, error) {
 	}
 }
 
+func (m *RPC) GetPropertyString(n string) (string, error) {
+	_v, err := m.GetProperty(n)
+	if err != nil || _v == nil {
+		return "", err
+	}
+
+	switch v := _v.(type) {
+	case string:
+		return v, nil
+	default:
+		return "", fmt.Errorf("%s is not a string: %v", n, _v)
+	}
+}
+
 func (m *RPC) GetPropertyDouble(n string) (float64, error) {
 	_v, err := m.GetProperty(n)
 	if err != nil || _v == nil {
@@ -235,4 +305,8 @@ func (m *RPC) Command(v ...string) error {
 	return m.send(command{Command: n})
 }
 
-func (m *RPC) send(cmd command) error { return m.w.Encode(cmd) }
+func (m *RPC) send(cmd command) error {
+	m.connSem.Lock()
+	defer m.connSem.Unlock()
+	return m.w.Encode(cmd)
+}
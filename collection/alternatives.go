@@ -0,0 +1,62 @@
+package collection
+
+import (
+	"errors"
+)
+
+// AddAlternative registers alt as another source of s, e.g.: a different
+// upload of the same song. Alternatives are tried in order when
+// downloading or streaming s fails.
+func (c *Collection) AddAlternative(s, alt Song) error {
+	gid, agid := GlobalID(s), GlobalID(alt)
+	if gid == agid {
+		return errors.New("a song can not be its own alternative")
+	}
+
+	c.altsem.Lock()
+	for _, a := range c.alts[gid] {
+		if GlobalID(a) == agid {
+			c.altsem.Unlock()
+			return errors.New("alternative already exists")
+		}
+	}
+	c.alts[gid] = append(c.alts[gid], alt)
+	c.altsem.Unlock()
+	c.changed()
+	return nil
+}
+
+// DelAlternative removes alt as a source of s.
+// Returns false if it was not an alternative of s.
+func (c *Collection) DelAlternative(s, alt IDer) bool {
+	gid, agid := GlobalID(s), GlobalID(alt)
+	c.altsem.Lock()
+	l := c.alts[gid]
+	for i, a := range l {
+		if GlobalID(a) != agid {
+			continue
+		}
+		n := make([]Song, 0, len(l)-1)
+		n = append(append(n, l[:i]...), l[i+1:]...)
+		c.alts[gid] = n
+		if len(n) == 0 {
+			delete(c.alts, gid)
+		}
+		c.altsem.Unlock()
+		c.changed()
+		return true
+	}
+	c.altsem.Unlock()
+	return false
+}
+
+// Alternatives returns the alternative sources of s in the order they
+// are tried.
+func (c *Collection) Alternatives(s IDer) []Song {
+	c.altsem.RLock()
+	l := c.alts[GlobalID(s)]
+	n := make([]Song, len(l))
+	copy(n, l)
+	c.altsem.RUnlock()
+	return n
+}
@@ -0,0 +1,103 @@
+package collection
+
+import (
+	"fmt"
+	"time"
+)
+
+// ArchivePlaylist is the default playlist Archive moves stale songs to.
+const ArchivePlaylist = "archive"
+
+// ArchivePolicy configures Archive.
+type ArchivePolicy struct {
+	// Playlists are the playlists stale songs are moved out of.
+	Playlists []string
+	// Archive is the playlist stale songs are moved to, defaults to
+	// ArchivePlaylist.
+	Archive string
+	// Months is how long a song must not have been played to be stale.
+	Months int
+}
+
+// ArchiveReport lists the songs Archive moved per playlist.
+type ArchiveReport struct {
+	Archive string
+	Moved   map[string][]Song
+}
+
+// Total is the amount of songs moved.
+func (r ArchiveReport) Total() int {
+	n := 0
+	for _, l := range r.Moved {
+		n += len(l)
+	}
+	return n
+}
+
+// Archive moves songs that were last played longer than p.Months ago from
+// p.Playlists to p.Archive.
+// lastPlayed returns when a song was last played, e.g.: from the stats log.
+// Songs that were never played are kept as there is no record of when they
+// were added. Pinned songs are kept as well.
+func (c *Collection) Archive(p ArchivePolicy, lastPlayed func(Song) (time.Time, bool), now time.Time) (ArchiveReport, error) {
+	if p.Archive == "" {
+		p.Archive = ArchivePlaylist
+	}
+	p.Archive = c.clean(p.Archive)
+	r := ArchiveReport{Archive: p.Archive, Moved: make(map[string][]Song)}
+	if p.Months <= 0 {
+		return r, fmt.Errorf("archive requires a positive amount of months")
+	}
+	cutoff := now.AddDate(0, -p.Months, 0)
+
+	stale := func(s Song) bool {
+		if c.Pinned(s) {
+			return false
+		}
+		t, ok := lastPlayed(s)
+		return ok && t.Before(cutoff)
+	}
+
+	for _, n := range p.Playlists {
+		n = c.clean(n)
+		if n == p.Archive {
+			continue
+		}
+		pl, err := c.get(n)
+		if err != nil {
+			return r, err
+		}
+		moved := pl.removeFunc(stale)
+		if len(moved) == 0 {
+			continue
+		}
+		if err := c.Create(p.Archive); err != nil && !IsErrExists(err) {
+			return r, err
+		}
+		if err := c.AddSongs(p.Archive, moved, false, nil); err != nil {
+			return r, err
+		}
+		r.Moved[n] = moved
+		c.changed()
+		c.playlistChanged(n)
+	}
+
+	return r, nil
+}
+
+// removeFunc removes and returns all songs for which rm returns true.
+func (p *Playlist) removeFunc(rm func(Song) bool) []Song {
+	p.sem.Lock()
+	defer p.sem.Unlock()
+	removed := make([]Song, 0)
+	keep := p.songs[:0]
+	for _, s := range p.songs {
+		if rm(s) {
+			removed = append(removed, s)
+			continue
+		}
+		keep = append(keep, s)
+	}
+	p.songs = keep
+	return removed
+}
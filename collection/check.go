@@ -0,0 +1,117 @@
+package collection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/frizinak/libym/failure"
+	"github.com/frizinak/libym/youtube"
+)
+
+// DeadSong is a song that can no longer be played.
+type DeadSong struct {
+	Song   Song
+	Reason error
+}
+
+// CheckReport is the result of CheckPlaylist.
+type CheckReport struct {
+	Playlist string
+	// Checked is the amount of songs checked.
+	Checked int
+	// Dead are the songs that failed permanently.
+	Dead []DeadSong
+	// Failed is the amount of songs that failed for a reason that might be
+	// temporary, e.g.: rate limiting, they are not in Dead.
+	Failed int
+}
+
+// errEmptyDownload is the reason of a download that is an empty file.
+var errEmptyDownload = errors.New("download is empty")
+
+// CheckSong returns an error if s can not be played: its download is empty
+// or, if it was not downloaded, its stream can not be resolved.
+func CheckSong(ctx context.Context, s Song) error {
+	if s.Local() {
+		f, err := s.File()
+		if err != nil {
+			return err
+		}
+		st, err := os.Stat(f)
+		if err != nil {
+			return err
+		}
+		if st.Size() == 0 {
+			return failure.Wrap(failure.NotFound, errEmptyDownload)
+		}
+		return nil
+	}
+	_, err := s.URLContext(ctx)
+	return err
+}
+
+// CheckPlaylist runs CheckSong for every song in playlist and reports the
+// ones that no longer resolve. progress can be nil.
+func (c *Collection) CheckPlaylist(ctx context.Context, playlist string, progress ProgressFunc) (CheckReport, error) {
+	r := CheckReport{Playlist: playlist}
+	songs, err := c.PlaylistSongs(playlist)
+	if err != nil {
+		return r, err
+	}
+
+	for i, s := range songs {
+		if err := ctx.Err(); err != nil {
+			return r, err
+		}
+		r.Checked++
+		err := CheckSong(ctx, s)
+		switch {
+		case err == nil:
+		case failure.KindOf(err).Temporary():
+			r.Failed++
+		default:
+			r.Dead = append(r.Dead, DeadSong{Song: s, Reason: err})
+		}
+		if progress != nil {
+			progress(i+1, len(songs))
+		}
+	}
+
+	return r, nil
+}
+
+// ResolveMinSimilarity is the minimum title similarity of a search result
+// for Resolve to use it.
+const ResolveMinSimilarity = 0.6
+
+// Resolve searches youtube for the title of s and replaces it in playlist
+// with the most similar result, e.g.: a re-upload of a removed video.
+func (c *Collection) Resolve(ctx context.Context, playlist string, s Song) (Song, error) {
+	title := s.Title()
+	if title == "" {
+		return nil, fmt.Errorf("%s-%s has no title to search for", s.NS(), s.ID())
+	}
+	results, err := youtube.SearchContext(ctx, title)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *youtube.Result
+	var sim float64
+	for _, r := range results {
+		if r.ID() == s.ID() {
+			continue
+		}
+		if n := TitleSimilarity(title, r.Title()); n > sim {
+			best, sim = r, n
+		}
+	}
+	if best == nil || sim < ResolveMinSimilarity {
+		return nil, failure.Wrap(failure.NotFound, fmt.Errorf("no replacement found for '%s'", title))
+	}
+
+	song := c.FromYoutube(best)
+	return song, c.ReplaceSong(playlist, s, song)
+}
@@ -1,6 +1,7 @@
 package collection
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"errors"
@@ -15,6 +16,8 @@ import (
 	"time"
 
 	"github.com/frizinak/binary"
+	"github.com/frizinak/libym/jobs"
+	"github.com/frizinak/libym/subsonic"
 	"github.com/frizinak/libym/youtube"
 )
 
@@ -32,6 +35,7 @@ func IsErrExists(err error) bool    { return errors.Is(err, ErrExists) }
 type Collection struct {
 	sem       sync.RWMutex
 	dir       string
+	dbDir     string
 	playlists map[string]*Playlist
 	q         *Queue
 
@@ -41,17 +45,74 @@ type Collection struct {
 	needsSave chan struct{}
 	autoSave  bool
 
+	lockMode  LockMode
+	readOnly  bool
+	ephemeral bool
+	tmpDir    string
+	lockf     *os.File
+
 	unmarshalers map[string]Unmarshaler
 
-	newSong chan Song
-	running bool
+	newSong       chan Song
+	running       bool
+	taskDownloads *nsTasks
+	taskMeta      *nsTasks
+	taskLimits    map[string]TaskLimits
+
+	// startedDownload are the songs queued or being downloaded.
+	dlsem           sync.Mutex
+	startedDownload map[string]Song
+
+	cleaner *TitleCleaner
 
 	problematics *Problematics
+	jobs         *jobs.Manager
+
+	titleTTL     time.Duration
+	titleRetry   time.Duration
+	checkedSem   sync.Mutex
+	titleChecked map[string]titleCheck
+
+	obsem     sync.RWMutex
+	observers []func(playlist string)
+
+	bansem sync.RWMutex
+	banned map[string]Song
+
+	pinsem sync.RWMutex
+	pins   map[string]Song
+
+	marksem sync.RWMutex
+	markers map[string]map[string]time.Duration
+
+	altsem sync.RWMutex
+	alts   map[string][]Song
+
+	trim *SilenceTrim
+
+	disk diskGuard
+
+	subsonic      *subsonic.Client
+	subsonicCache bool
 }
 
+// DefaultTitleTTL is the minimum time between two title lookups of the same
+// song.
+const DefaultTitleTTL = time.Hour * 24
+
+// DefaultTitleRetry is the minimum time between a failed title lookup and
+// the next attempt.
+const DefaultTitleRetry = time.Minute * 10
+
+const (
+	metaTimeout     = time.Minute
+	downloadTimeout = time.Minute * 30
+)
+
 func New(l *log.Logger, dir string, queue *Queue, concurrentDownloads int, autoSave bool) *Collection {
 	return &Collection{
 		dir:       dir,
+		dbDir:     dir,
 		playlists: make(map[string]*Playlist),
 		q:         queue,
 
@@ -63,10 +124,97 @@ func New(l *log.Logger, dir string, queue *Queue, concurrentDownloads int, autoS
 
 		unmarshalers: make(map[string]Unmarshaler),
 		problematics: NewProblematics(),
+		jobs:         jobs.NewManager(),
+
+		titleTTL:     DefaultTitleTTL,
+		titleRetry:   DefaultTitleRetry,
+		titleChecked: make(map[string]titleCheck),
+
+		startedDownload: make(map[string]Song),
+
+		banned:  make(map[string]Song),
+		pins:    make(map[string]Song),
+		markers: make(map[string]map[string]time.Duration),
+		alts:    make(map[string][]Song),
+	}
+}
+
+// SetDBDir stores the database in dir instead of next to the downloaded
+// songs, e.g.: to keep it out of a cache directory. Must be called before Init.
+func (c *Collection) SetDBDir(dir string) { c.dbDir = dir }
+
+// SetTrimSilence enables trimming leading and trailing silence from
+// downloads, nil disables it. Must be called before Run.
+func (c *Collection) SetTrimSilence(cfg *SilenceTrim) { c.trim = cfg }
+
+// SetTaskLimits overrides the download and title lookup concurrency and
+// ratelimits for songs of the given namespace, e.g.: to not ratelimit
+// local files. Must be called before Run.
+func (c *Collection) SetTaskLimits(ns string, l TaskLimits) {
+	if c.taskLimits == nil {
+		c.taskLimits = make(map[string]TaskLimits)
 	}
+	c.taskLimits[ns] = l
 }
 
-func (c *Collection) pathDB() string    { return filepath.Join(c.dir, "db") }
+// SetJobs sets the job manager downloads are registered with.
+// Must be called before Run.
+func (c *Collection) SetJobs(m *jobs.Manager) { c.jobs = m }
+
+// Jobs returns the job manager downloads are registered with.
+func (c *Collection) Jobs() *jobs.Manager { return c.jobs }
+
+// SetTitleTTL sets the minimum time between two title lookups of the same
+// song. Must be called before Run.
+func (c *Collection) SetTitleTTL(ttl time.Duration) { c.titleTTL = ttl }
+
+// SetTitleRetry sets the minimum time between a failed title lookup and the
+// next attempt. Must be called before Run.
+func (c *Collection) SetTitleRetry(d time.Duration) { c.titleRetry = d }
+
+// titleCheck is the last title lookup of a song, stored in the db.
+type titleCheck struct {
+	at     time.Time
+	failed bool
+	// manual titles, e.g.: renamed songs, are never looked up again.
+	manual bool
+}
+
+// titleExpired reports whether the title of s should be looked up.
+// Songs that already have a title are first checked after TitleTTL.
+func (c *Collection) titleExpired(s Song) bool {
+	gid := GlobalID(s)
+	c.checkedSem.Lock()
+	defer c.checkedSem.Unlock()
+	last, ok := c.titleChecked[gid]
+	switch {
+	case !ok && s.Title() == "":
+		return true
+	case !ok:
+		c.titleChecked[gid] = titleCheck{at: time.Now()}
+		return false
+	case last.manual:
+		return false
+	case last.failed:
+		return time.Since(last.at) >= c.titleRetry
+	}
+	return time.Since(last.at) >= c.titleTTL
+}
+
+func (c *Collection) checkedTitle(s Song, failed bool) {
+	c.checkedSem.Lock()
+	c.titleChecked[GlobalID(s)] = titleCheck{at: time.Now(), failed: failed}
+	c.checkedSem.Unlock()
+}
+
+// titleManual stops looking up the title of s.
+func (c *Collection) titleManual(s Song) {
+	c.checkedSem.Lock()
+	c.titleChecked[GlobalID(s)] = titleCheck{at: time.Now(), manual: true}
+	c.checkedSem.Unlock()
+}
+
+func (c *Collection) pathDB() string    { return filepath.Join(c.dbDir, "db") }
 func (c *Collection) pathSongs() string { return filepath.Join(c.dir, "songs") }
 func (c *Collection) globSongs() string {
 	return filepath.Join(c.pathSongs(), "*", "*", "*", "*")
@@ -83,6 +231,12 @@ func (c *Collection) SongPath(id IDer) string {
 
 func (c *Collection) Init() error {
 	os.MkdirAll(c.dir, 0o755)
+	os.MkdirAll(c.dbDir, 0o755)
+	if !c.ephemeral {
+		if err := c.lock(); err != nil {
+			return err
+		}
+	}
 	c.newSong = make(chan Song, c.concurrent)
 	done := make(chan struct{}, 1)
 	go func() {
@@ -116,7 +270,7 @@ func (c *Collection) Init() error {
 				needs = true
 			case <-after:
 				after = newAfter()
-				if !c.autoSave {
+				if !c.autoSave || c.readOnly || c.ephemeral {
 					continue
 				}
 				ix := c.q.CurrentIndex()
@@ -136,8 +290,10 @@ func (c *Collection) Init() error {
 		}
 	}()
 
-	if err := c.Load(); err != nil {
-		return err
+	if !c.ephemeral {
+		if err := c.Load(); err != nil {
+			return err
+		}
 	}
 	loading = false
 	close(c.newSong)
@@ -162,39 +318,79 @@ func (c *Collection) Run(ratelimitDownloads, ratelimitMeta <-chan struct{}) {
 		os.Remove(p)
 	}
 
-	var mapsem sync.Mutex
-	startedDownload := make(map[string]struct{})
+	newTasks := func(
+		rate <-chan struct{},
+		nsRate func(TaskLimits) <-chan struct{},
+		filter func(Song) bool,
+		cb func(context.Context, Song) error,
+	) *nsTasks {
+		t := &nsTasks{
+			def: NewSongTasks(c.concurrent, rate, filter, cb),
+			ns:  make(map[string]*SongTasks, len(c.taskLimits)),
+		}
+		for ns, l := range c.taskLimits {
+			n, r := l.Concurrency, nsRate(l)
+			if n <= 0 {
+				n = c.concurrent
+			}
+			if r == nil {
+				r = rate
+			}
+			t.ns[ns] = NewSongTasks(n, r, filter, cb)
+		}
+		return t
+	}
 
-	taskDownloads := NewSongTasks(
-		c.concurrent,
+	taskDownloads := newTasks(
 		ratelimitDownloads,
+		func(l TaskLimits) <-chan struct{} { return l.RatelimitDownloads },
 		func(s Song) bool {
+			if cacher, ok := s.(Cacher); ok && !cacher.Cache() && !c.Pinned(s) {
+				return false
+			}
 			id := GlobalID(s)
 			if s.Local() {
-				mapsem.Lock()
-				delete(startedDownload, id)
-				mapsem.Unlock()
+				c.dlsem.Lock()
+				delete(c.startedDownload, id)
+				c.dlsem.Unlock()
 				return false
 			}
 
-			mapsem.Lock()
-			if _, ok := startedDownload[id]; ok {
-				mapsem.Unlock()
+			c.dlsem.Lock()
+			if _, ok := c.startedDownload[id]; ok {
+				c.dlsem.Unlock()
 				return false
 			}
 
-			startedDownload[id] = struct{}{}
-			mapsem.Unlock()
+			c.startedDownload[id] = s
+			c.dlsem.Unlock()
 			return true
 		},
-		func(s Song) {
+		func(ctx context.Context, s Song) error {
+			job := c.jobs.AddContext(
+				ctx,
+				jobs.KindDownload,
+				fmt.Sprintf("download: %s-%s %s", s.NS(), s.ID(), s.Title()),
+			)
 			do := func() error {
+				ctx, cancel := context.WithTimeout(job.Context(), downloadTimeout)
+				defer cancel()
+
 				file, err := s.File()
 				os.MkdirAll(filepath.Dir(file), 0o755)
 				if err != nil {
 					return err
 				}
-				u, err := s.URL()
+				job.SetStage("resolving", "")
+				u, err := s.URLContext(ctx)
+				if err != nil {
+					for _, alt := range c.Alternatives(s) {
+						job.Logf("%s, trying alternative %s-%s", err, alt.NS(), alt.ID())
+						if u, err = alt.URLContext(ctx); err == nil {
+							break
+						}
+					}
+				}
 				if err != nil {
 					return err
 				}
@@ -204,52 +400,93 @@ func (c *Collection) Run(ratelimitDownloads, ratelimitMeta <-chan struct{}) {
 					return err
 				}
 
-				err = DownloadAudio(f, u)
+				var expect time.Duration
+				if d, ok := s.(SourceDurationer); ok {
+					expect = d.SourceDuration()
+				}
+
+				job.SetStage("downloading", "")
+				job.Logf("downloading to %s", file)
+				err = DownloadAudioExpectContext(ctx, f, u, expect)
 				f.Close()
 				if err != nil {
+					// a truncated download is retried once its backoff
+					// expired, see VerifyDownloads.
 					os.Remove(tmp)
 					return err
 				}
+				if c.trim != nil {
+					job.SetStage("trimming", "")
+					if err := TrimSilenceContext(ctx, tmp, *c.trim); err != nil {
+						// an untrimmed song is better than none.
+						job.Logf("trimming silence failed: %s", err)
+					}
+				}
 				return os.Rename(tmp, file)
 			}
 
 			c.l.Printf("Downloading %s:%s %s", s.NS(), s.ID(), s.Title())
-			if err := do(); err != nil {
+			err := do()
+			c.jobs.Finish(job, nil, err)
+			if err != nil {
+				// allow restarting failed or canceled downloads.
+				c.dlsem.Lock()
+				delete(c.startedDownload, GlobalID(s))
+				c.dlsem.Unlock()
 				c.problematics.Add(s, err)
+				c.changed()
 				c.l.Println("Download err:", err.Error(), s.NS(), s.ID(), s.Title())
-				return
+				return err
+			}
+			if _, ok := c.problematics.Get(s); ok {
+				c.problematics.Del(s)
+				c.changed()
 			}
 			c.l.Printf("Downloaded %s:%s %s", s.NS(), s.ID(), s.Title())
+			return nil
 		},
 	)
-	taskMeta := NewSongTasks(
-		c.concurrent,
+	taskMeta := newTasks(
 		ratelimitMeta,
+		func(l TaskLimits) <-chan struct{} { return l.RatelimitMeta },
 		func(s Song) bool {
-			return s.Title() == ""
+			return c.titleExpired(s)
 		},
-		func(s Song) {
-			if err := s.UpdateTitle(); err != nil {
+		func(ctx context.Context, s Song) error {
+			c.checkedTitle(s, false)
+			ctx, cancel := context.WithTimeout(ctx, metaTimeout)
+			err := s.UpdateTitleContext(ctx)
+			cancel()
+			if err != nil {
+				c.checkedTitle(s, true)
 				c.problematics.Add(s, err)
+				c.changed()
 				c.l.Println("Title err:", err)
-				return
+				return err
 			}
+			c.cleanTitle(s)
 			c.l.Printf("Updated title: %s:%s %s", s.NS(), s.ID(), s.Title())
 			c.changed()
+			c.playlistChanged("")
+			return nil
 		},
 	)
 
+	taskDownloads.SetGate(func() bool {
+		return c.jobs.Allowed(jobs.KindDownload) && c.diskOK()
+	})
+	taskDownloads.SetCanceled(func(s Song) {
+		// allow restarting downloads canceled before they started.
+		c.dlsem.Lock()
+		delete(c.startedDownload, GlobalID(s))
+		c.dlsem.Unlock()
+	})
 	taskDownloads.Start()
 	taskMeta.Start()
 
-	eachSong := func(cb func(s Song)) {
-		for _, s := range c.q.Slice() {
-			cb(s)
-		}
-		for _, s := range c.Songs() {
-			cb(s)
-		}
-	}
+	c.sem.Lock()
+	c.taskDownloads, c.taskMeta = taskDownloads, taskMeta
+	c.sem.Unlock()
 
 	go func() {
 		for s := range c.newSong {
@@ -266,7 +503,7 @@ func (c *Collection) Run(ratelimitDownloads, ratelimitMeta <-chan struct{}) {
 				continue
 			}
 			since = time.Now()
-			eachSong(taskMeta.Add)
+			c.RefreshTitles()
 		}
 	}()
 
@@ -278,11 +515,126 @@ func (c *Collection) Run(ratelimitDownloads, ratelimitMeta <-chan struct{}) {
 				continue
 			}
 			since = time.Now()
-			eachSong(taskDownloads.Add)
+			c.VerifyDownloads()
 		}
 	}()
 }
 
+func (c *Collection) tasks() (downloads, meta *nsTasks) {
+	c.sem.RLock()
+	downloads, meta = c.taskDownloads, c.taskMeta
+	c.sem.RUnlock()
+	return
+}
+
+func (c *Collection) eachSong(cb func(s Song)) {
+	for _, s := range c.q.Slice() {
+		cb(s)
+	}
+	for _, s := range c.Songs() {
+		cb(s)
+	}
+	for _, s := range c.PinnedSongs() {
+		cb(s)
+	}
+}
+
+// TaskStatus is a snapshot of the background download and title lookup
+// queues.
+type TaskStatus struct {
+	Downloads TaskQueueStatus
+	Meta      TaskQueueStatus
+}
+
+// TaskStatus returns the current state of the background download and
+// title lookup queues. Both are empty if the collection is not running.
+func (c *Collection) TaskStatus() TaskStatus {
+	var st TaskStatus
+	downloads, meta := c.tasks()
+	if downloads != nil {
+		st.Downloads = downloads.Status()
+	}
+	if meta != nil {
+		st.Meta = meta.Status()
+	}
+	return st
+}
+
+// Download queues a download of the given song, this also restarts
+// previously failed or canceled downloads.
+// Noop if the collection is not running or the song is available locally.
+func (c *Collection) Download(s Song) {
+	downloads, _ := c.tasks()
+	if downloads == nil {
+		return
+	}
+	downloads.Add(s)
+}
+
+// CancelDownload cancels a queued or in-flight download.
+// Returns false if the song was not being downloaded.
+func (c *Collection) CancelDownload(s IDer) bool {
+	downloads, _ := c.tasks()
+	if downloads == nil {
+		return false
+	}
+	return downloads.Cancel(s)
+}
+
+// VerifyDownloads queues a download for every song in the queue and all
+// playlists that is not available locally, skipping songs that failed
+// before until their backoff expired, see Problematics.Retry.
+// Noop if the collection is not running.
+func (c *Collection) VerifyDownloads() {
+	downloads, _ := c.tasks()
+	if downloads == nil {
+		return
+	}
+	now := time.Now()
+	c.eachSong(func(s Song) {
+		if c.problematics.Retry(s, now) {
+			downloads.Add(s)
+		}
+	})
+}
+
+// RefreshTitles queues a title lookup for every song whose TitleTTL, or
+// TitleRetry after a failed lookup, expired.
+// Noop if the collection is not running.
+func (c *Collection) RefreshTitles() {
+	_, meta := c.tasks()
+	if meta == nil {
+		return
+	}
+	c.eachSong(func(s Song) {
+		if c.titleExpired(s) {
+			meta.Add(s)
+		}
+	})
+}
+
+// OnPlaylistChanged registers a callback that is called after a playlist
+// was created, deleted or modified. playlist is empty if songs in possibly
+// multiple playlists changed, e.g.: a title update.
+// Callbacks must not block.
+func (c *Collection) OnPlaylistChanged(cb func(playlist string)) {
+	c.obsem.Lock()
+	c.observers = append(c.observers, cb)
+	c.obsem.Unlock()
+}
+
+// OnQueueChanged is a shortcut for Queue.OnChange.
+func (c *Collection) OnQueueChanged(cb func()) { c.q.OnChange(cb) }
+
+func (c *Collection) playlistChanged(playlist string) {
+	c.obsem.RLock()
+	obs := c.observers
+	c.obsem.RUnlock()
+	for _, cb := range obs {
+		cb(playlist)
+	}
+}
+
 func (c *Collection) changed() {
 	c.needsSave <- struct{}{}
 }
@@ -293,6 +645,74 @@ func (c *Collection) clean(playlist string) string {
 
 func (c *Collection) Problematics() *Problematics { return c.problematics }
 
+// Ban marks a song as never to be queued automatically.
+func (c *Collection) Ban(s Song) {
+	c.bansem.Lock()
+	c.banned[GlobalID(s)] = s
+	c.bansem.Unlock()
+	c.changed()
+}
+
+// Unban reverts Ban.
+func (c *Collection) Unban(s IDer) {
+	c.bansem.Lock()
+	delete(c.banned, GlobalID(s))
+	c.bansem.Unlock()
+	c.changed()
+}
+
+// Banned reports whether the given song was banned.
+func (c *Collection) Banned(s IDer) bool {
+	c.bansem.RLock()
+	_, ok := c.banned[GlobalID(s)]
+	c.bansem.RUnlock()
+	return ok
+}
+
+// BannedSongs returns all banned songs sorted by title.
+func (c *Collection) BannedSongs() []Song {
+	c.bansem.RLock()
+	l := make([]Song, 0, len(c.banned))
+	for _, s := range c.banned {
+		l = append(l, s)
+	}
+	c.bansem.RUnlock()
+	sort.Slice(l, func(i, j int) bool {
+		return strings.ToLower(l[i].Title()) < strings.ToLower(l[j].Title())
+	})
+	return l
+}
+
+// Favorites is the name of the playlist Favorite adds songs to.
+const Favorites = "favorites"
+
+// Favorite adds a song to the Favorites playlist, creating it if needed.
+func (c *Collection) Favorite(s Song) error {
+	if err := c.Create(Favorites); err != nil && !IsErrExists(err) {
+		return err
+	}
+	return c.AddSong(Favorites, s, false)
+}
+
+// Unfavorite removes a song from the Favorites playlist.
+func (c *Collection) Unfavorite(s Song) error {
+	err := c.DelSong(Favorites, s)
+	if IsErrNotExists(err) {
+		return nil
+	}
+	return err
+}
+
+// IsFavorite reports whether a song is in the Favorites playlist.
+func (c *Collection) IsFavorite(s IDer) bool {
+	p, err := c.get(Favorites)
+	if err != nil {
+		return false
+	}
+	_, err = p.Find(s.NS(), s.ID())
+	return err == nil
+}
+
 func (c *Collection) Create(n string) error {
 	n = c.clean(n)
 	if n == "" {
@@ -300,14 +720,15 @@ func (c *Collection) Create(n string) error {
 	}
 
 	c.sem.Lock()
-	defer c.sem.Unlock()
-
 	if _, ok := c.playlists[n]; ok {
+		c.sem.Unlock()
 		return ErrExists
 	}
 
 	c.playlists[n] = NewPlaylist(n)
 	c.changed()
+	c.sem.Unlock()
+	c.playlistChanged(n)
 
 	return nil
 }
@@ -323,13 +744,15 @@ func (c *Collection) Delete(n string) error {
 	n = c.clean(n)
 
 	c.sem.Lock()
-	defer c.sem.Unlock()
 	if _, ok := c.playlists[n]; !ok {
+		c.sem.Unlock()
 		return ErrNotExists
 	}
 
 	delete(c.playlists, n)
 	c.changed()
+	c.sem.Unlock()
+	c.playlistChanged(n)
 	return nil
 }
 
@@ -347,38 +770,50 @@ func (c *Collection) get(n string) (*Playlist, error) {
 type SearchResult struct {
 	Song
 	Playlists []string
+	// Queued is true if the song is in the queue.
+	Queued bool
 }
 
+// Sources returns the names of the playlists the song is in and "queue" if it
+// is queued.
+func (s *SearchResult) Sources() []string {
+	if !s.Queued {
+		return s.Playlists
+	}
+	return append(append(make([]string, 0, len(s.Playlists)+1), s.Playlists...), "queue")
+}
+
+// Search searches for songs in all playlists and the queue.
 func (c *Collection) Search(q string) []*SearchResult {
 	byS := make(map[string]*SearchResult)
 	a := make([]Song, 0)
+	result := func(s Song) *SearchResult {
+		gid := GlobalID(s)
+		if _, ok := byS[gid]; !ok {
+			byS[gid] = &SearchResult{Song: s, Playlists: make([]string, 0, 1)}
+			a = append(a, s)
+		}
+		return byS[gid]
+	}
 
 	c.sem.RLock()
 	for name, p := range c.playlists {
-		res := p.Search(q)
-		if len(res) == 0 {
-			continue
+		for _, s := range p.Search(q) {
+			r := result(s)
+			r.Playlists = append(r.Playlists, name)
 		}
-		for _, s := range res {
-			gid := GlobalID(s)
-			if _, ok := byS[gid]; !ok {
-				byS[gid] = &SearchResult{s, make([]string, 0, 1)}
-			}
-			byS[gid].Playlists = append(byS[gid].Playlists, name)
-		}
-
-		a = append(a, res...)
 	}
 	c.sem.RUnlock()
 
-	uniq := make(map[string]struct{}, len(a))
+	for _, s := range searchSongs(c.q.Slice(), q) {
+		result(s).Queued = true
+	}
+
 	list := make([]*SearchResult, 0, len(a))
 	for _, s := range a {
-		gid := GlobalID(s)
-		if _, ok := uniq[gid]; !ok {
-			uniq[gid] = struct{}{}
-			list = append(list, byS[gid])
-		}
+		r := byS[GlobalID(s)]
+		sort.Strings(r.Playlists)
+		list = append(list, r)
 	}
 
 	return list
@@ -428,6 +863,24 @@ func (c *Collection) FindAll(ns, id string) (Song, []string, error) {
 	return song, pls, nil
 }
 
+// Membership returns the sorted names of the playlists each song is in by
+// GlobalID, e.g.: to mark search results that are already in the library.
+func (c *Collection) Membership() map[string][]string {
+	m := make(map[string][]string)
+	c.sem.RLock()
+	for name, p := range c.playlists {
+		for _, s := range p.List() {
+			gid := GlobalID(s)
+			m[gid] = append(m[gid], name)
+		}
+	}
+	c.sem.RUnlock()
+	for _, l := range m {
+		sort.Strings(l)
+	}
+	return m
+}
+
 func (c *Collection) Find(ns, id string) (Song, error) {
 	var song Song
 	c.sem.RLock()
@@ -464,6 +917,52 @@ func (c *Collection) AddSong(playlist string, s Song, reappend bool) error {
 		c.newSong <- s
 	}
 	c.changed()
+	c.playlistChanged(p.name)
+	return nil
+}
+
+// ProgressFunc is called after each processed song of a batch operation.
+type ProgressFunc func(done, total int)
+
+func (c *Collection) notifyNew(songs []Song, progress ProgressFunc) {
+	for i, s := range songs {
+		if c.newSong != nil {
+			c.newSong <- s
+		}
+		if progress != nil {
+			progress(i+1, len(songs))
+		}
+	}
+}
+
+// AddSongs is the batch variant of AddSong, it locks the playlist and marks
+// the collection as changed only once. progress can be nil.
+func (c *Collection) AddSongs(playlist string, songs []Song, reappend bool, progress ProgressFunc) error {
+	p, err := c.get(playlist)
+	if err != nil {
+		return err
+	}
+	p.AddSlice(songs, reappend)
+	c.notifyNew(songs, progress)
+	c.changed()
+	c.playlistChanged(p.name)
+	return nil
+}
+
+// SetPlaylistSongs replaces the songs of a playlist, creating it if needed.
+// The playlist settings are kept.
+func (c *Collection) SetPlaylistSongs(playlist string, songs []Song) error {
+	if err := c.Create(playlist); err != nil && !IsErrExists(err) {
+		return err
+	}
+	p, err := c.get(playlist)
+	if err != nil {
+		return err
+	}
+	p.Set(songs)
+	c.notifyNew(songs, nil)
+	c.changed()
+	c.playlistChanged(p.name)
 	return nil
 }
 
@@ -474,6 +973,7 @@ func (c *Collection) DelSong(playlist string, s Song) error {
 	}
 	p.Del(s)
 	c.changed()
+	c.playlistChanged(p.name)
 	return nil
 }
 
@@ -484,16 +984,23 @@ func (c *Collection) DelSongIndexes(playlist string, ix []int) error {
 	}
 	p.DelIndexes(ix)
 	c.changed()
+	c.playlistChanged(p.name)
 	return nil
 }
 
+// MoveSongIndex moves songs in a playlist.
+// Returns ErrAutoSorted if the playlist is not sorted manually.
 func (c *Collection) MoveSongIndex(playlist string, from []int, to int) error {
 	p, err := c.get(playlist)
 	if err != nil {
 		return err
 	}
+	if p.Settings().Sort != SortManual {
+		return ErrAutoSorted
+	}
 	p.MoveIndex(from, to)
 	c.changed()
+	c.playlistChanged(p.name)
 	return nil
 }
 
@@ -507,6 +1014,27 @@ func (c *Collection) Queue(ix int, n string) error {
 	return nil
 }
 
+// PlaylistSettings returns the settings of the given playlist.
+func (c *Collection) PlaylistSettings(n string) (PlaylistSettings, error) {
+	p, err := c.get(n)
+	if err != nil {
+		return PlaylistSettings{}, err
+	}
+	return p.Settings(), nil
+}
+
+// SetPlaylistSettings updates the settings of the given playlist.
+func (c *Collection) SetPlaylistSettings(n string, s PlaylistSettings) error {
+	p, err := c.get(n)
+	if err != nil {
+		return err
+	}
+	p.SetSettings(s)
+	c.changed()
+	c.playlistChanged(p.name)
+	return nil
+}
+
 func (c *Collection) QueueSong(ix int, s Song) {
 	c.q.Add(ix, s)
 	if c.newSong != nil {
@@ -515,9 +1043,33 @@ func (c *Collection) QueueSong(ix int, s Song) {
 	c.changed()
 }
 
+// QueueSongs is the batch variant of QueueSong, it locks the queue and
+// marks the collection as changed only once. progress can be nil.
+func (c *Collection) QueueSongs(ix int, songs []Song, progress ProgressFunc) {
+	c.QueueSongsFrom(ix, songs, Origin{}, progress)
+}
+
+// QueueSongsFrom is QueueSongs but records the origin of the songs.
+func (c *Collection) QueueSongsFrom(ix int, songs []Song, o Origin, progress ProgressFunc) {
+	c.q.AddSliceFrom(ix, songs, o)
+	c.notifyNew(songs, progress)
+	c.changed()
+}
+
+// Unqueue removes all songs that were queued from the given origin.
+func (c *Collection) Unqueue(o Origin) int {
+	n := c.q.RemoveFrom(o)
+	if n != 0 {
+		c.changed()
+	}
+	return n
+}
+
 func (c *Collection) RenameSong(s Song, name string) {
 	s.SetTitle(name)
+	c.titleManual(s)
 	c.changed()
+	c.playlistChanged("")
 }
 
 func (c *Collection) FromYoutube(r *youtube.Result) *YoutubeSong {
@@ -535,6 +1087,9 @@ func (c *Collection) FromYoutubeURL(url, title string) (*YoutubeSong, error) {
 	return c.FromYoutube(y), nil
 }
 
+// UnreferencedDownloads returns the downloaded files of songs that are in
+// neither a playlist, the queue nor pinned. Temporary files and songs that
+// are queued for or being downloaded are skipped.
 func (c *Collection) UnreferencedDownloads() []string {
 	g, err := filepath.Glob(c.globSongs())
 	if err != nil {
@@ -543,11 +1098,21 @@ func (c *Collection) UnreferencedDownloads() []string {
 
 	gm := make(map[string]struct{}, len(g))
 	for _, p := range g {
+		if strings.HasSuffix(p, ".tmp") {
+			// written by an ongoing download, trim or save.
+			continue
+		}
 		gm[p] = struct{}{}
 	}
 
 	songs := c.Songs()
 	songs = append(songs, c.q.Slice()...)
+	songs = append(songs, c.PinnedSongs()...)
+	c.dlsem.Lock()
+	for _, s := range c.startedDownload {
+		songs = append(songs, s)
+	}
+	c.dlsem.Unlock()
 	for _, s := range songs {
 		delete(gm, c.SongPath(s))
 	}
@@ -559,3 +1124,38 @@ func (c *Collection) UnreferencedDownloads() []string {
 
 	return list
 }
+
+// DiskUsage returns the total size of all downloaded songs in bytes.
+func (c *Collection) DiskUsage() (int64, error) {
+	g, err := filepath.Glob(c.globSongs())
+	if err != nil {
+		panic(err) // filepath.Glob only returns pattern errors
+	}
+
+	var n int64
+	for _, p := range g {
+		st, err := os.Stat(p)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return n, err
+		}
+		n += st.Size()
+	}
+
+	return n, nil
+}
+
+// RemoveUnreferencedDownloads deletes all UnreferencedDownloads and returns
+// the amount of deleted files.
+func (c *Collection) RemoveUnreferencedDownloads() (int, error) {
+	n := 0
+	for _, p := range c.UnreferencedDownloads() {
+		if err := os.Remove(p); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,110 @@
+package collection
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// DailyPlaylist is the default playlist GenerateDaily writes to.
+const DailyPlaylist = "daily"
+
+// DefaultDailySize is the default amount of songs in the daily mix.
+const DefaultDailySize = 30
+
+// DailyMix configures GenerateDaily.
+type DailyMix struct {
+	// Playlist is replaced with the mix, defaults to DailyPlaylist.
+	Playlist string
+	// Size is the amount of songs, defaults to DefaultDailySize.
+	Size int
+	// Recent is the fraction of songs picked from recent additions, the
+	// rest are under-played favorites. Defaults to a third.
+	Recent float64
+}
+
+func (d DailyMix) withDefaults() DailyMix {
+	if d.Playlist == "" {
+		d.Playlist = DailyPlaylist
+	}
+	if d.Size <= 0 {
+		d.Size = DefaultDailySize
+	}
+	if d.Recent <= 0 || d.Recent > 1 {
+		d.Recent = 1.0 / 3
+	}
+	return d
+}
+
+// GenerateDaily replaces the playlist d.Playlist with a shuffled mix of the
+// least played Favorites and the most recently added songs of all other
+// playlists, i.e.: the last ones. Banned songs are skipped.
+// plays returns how often a song was played, e.g.: from the stats log.
+func (c *Collection) GenerateDaily(r *rand.Rand, d DailyMix, plays func(Song) int) ([]Song, error) {
+	d = d.withDefaults()
+	d.Playlist = c.clean(d.Playlist)
+
+	seen := make(map[string]struct{})
+	pick := func(l []Song, n int) []Song {
+		sel := make([]Song, 0, n)
+		for _, s := range l {
+			if len(sel) >= n {
+				break
+			}
+			id := GlobalID(s)
+			if _, ok := seen[id]; ok || c.Banned(s) {
+				continue
+			}
+			seen[id] = struct{}{}
+			sel = append(sel, s)
+		}
+		return sel
+	}
+
+	var favs []Song
+	if l, err := c.PlaylistSongs(Favorites); err == nil {
+		favs = l
+	}
+	r.Shuffle(len(favs), func(i, j int) { favs[i], favs[j] = favs[j], favs[i] })
+	counts := make(map[string]int, len(favs))
+	for _, s := range favs {
+		counts[GlobalID(s)] = plays(s)
+	}
+	sort.SliceStable(favs, func(i, j int) bool {
+		return counts[GlobalID(favs[i])] < counts[GlobalID(favs[j])]
+	})
+
+	// interleave the tails of all playlists, newest first.
+	var tails [][]Song
+	for _, n := range c.List() {
+		if n == d.Playlist || n == Favorites {
+			continue
+		}
+		if l, err := c.PlaylistSongs(n); err == nil && len(l) != 0 {
+			tails = append(tails, l)
+		}
+	}
+	r.Shuffle(len(tails), func(i, j int) { tails[i], tails[j] = tails[j], tails[i] })
+	recent := make([]Song, 0)
+	for i := 1; len(recent) < d.Size*len(tails); i++ {
+		more := false
+		for _, l := range tails {
+			if i <= len(l) {
+				recent = append(recent, l[len(l)-i])
+				more = true
+			}
+		}
+		if !more {
+			break
+		}
+	}
+
+	nRecent := int(float64(d.Size)*d.Recent + 0.5)
+	mix := pick(recent, nRecent)
+	mix = append(mix, pick(favs, d.Size-len(mix))...)
+	if len(mix) < d.Size {
+		mix = append(mix, pick(recent, d.Size-len(mix))...)
+	}
+	r.Shuffle(len(mix), func(i, j int) { mix[i], mix[j] = mix[j], mix[i] })
+
+	return mix, c.SetPlaylistSongs(d.Playlist, mix)
+}
@@ -0,0 +1,93 @@
+package collection
+
+import (
+	"sync"
+	"time"
+)
+
+// DiskCheckInterval is the minimum time between two free space checks of
+// the download volume.
+const DiskCheckInterval = time.Second * 30
+
+type diskGuard struct {
+	sem       sync.Mutex
+	min       uint64
+	low       bool
+	free      uint64
+	checked   time.Time
+	observers []func(low bool, free uint64)
+}
+
+// SetMinFreeSpace pauses downloads while the volume holding the downloads
+// has less than n bytes available, see OnLowDisk. 0 disables the check,
+// which is the default.
+func (c *Collection) SetMinFreeSpace(n uint64) {
+	c.disk.sem.Lock()
+	c.disk.min = n
+	c.disk.checked = time.Time{}
+	if n == 0 {
+		c.disk.low = false
+	}
+	c.disk.sem.Unlock()
+}
+
+// FreeSpace returns the amount of bytes available on the volume holding
+// the downloads.
+func (c *Collection) FreeSpace() (uint64, error) { return freeSpace(c.dir) }
+
+// LowDisk reports whether downloads are paused because of low disk space.
+func (c *Collection) LowDisk() bool {
+	c.disk.sem.Lock()
+	defer c.disk.sem.Unlock()
+	return c.disk.low
+}
+
+// OnLowDisk registers a callback that is called when downloads are paused
+// because free space dropped below the minimum, or resumed.
+// Callbacks must not block.
+func (c *Collection) OnLowDisk(cb func(low bool, free uint64)) {
+	c.disk.sem.Lock()
+	c.disk.observers = append(c.disk.observers, cb)
+	c.disk.sem.Unlock()
+}
+
+// diskOK reports whether there is enough free space to download,
+// checking at most every DiskCheckInterval.
+func (c *Collection) diskOK() bool {
+	d := &c.disk
+	d.sem.Lock()
+	if d.min == 0 {
+		d.sem.Unlock()
+		return true
+	}
+	if time.Since(d.checked) < DiskCheckInterval {
+		low := d.low
+		d.sem.Unlock()
+		return !low
+	}
+	d.checked = time.Now()
+	free, err := c.FreeSpace()
+	if err != nil {
+		d.sem.Unlock()
+		c.l.Println("Free space err:", err)
+		return true
+	}
+
+	low := free < d.min
+	changed := low != d.low
+	d.low, d.free = low, free
+	obs := d.observers
+	d.sem.Unlock()
+
+	if changed {
+		if low {
+			c.l.Printf("Pausing downloads, only %d MiB free", free>>20)
+		} else {
+			c.l.Printf("Resuming downloads, %d MiB free", free>>20)
+		}
+		for _, cb := range obs {
+			cb(low, free)
+		}
+	}
+	return !low
+}
@@ -2,6 +2,7 @@ package collection
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
@@ -9,28 +10,86 @@ import (
 	"net/http"
 	"net/url"
 	"os/exec"
+	"regexp"
 	"strconv"
+	"sync"
 	"time"
+
+	"github.com/frizinak/libym/failure"
+	"github.com/frizinak/libym/proc"
 )
 
+var ffmpegLimits struct {
+	sync.RWMutex
+	l proc.Limits
+}
+
+// SetFFmpegLimits sets the resource limits of the ffmpeg processes used for
+// downloads, silence trimming and exports, e.g.: a high nice level so
+// transcoding does not starve playback.
+func SetFFmpegLimits(l proc.Limits) {
+	ffmpegLimits.Lock()
+	ffmpegLimits.l = l
+	ffmpegLimits.Unlock()
+}
+
+func ffmpeg(ctx context.Context, args ...string) *exec.Cmd {
+	ffmpegLimits.RLock()
+	l := ffmpegLimits.l
+	ffmpegLimits.RUnlock()
+	return l.CommandContext(ctx, "ffmpeg", args...)
+}
+
+// SourceDurationer can optionally be implemented by a Song that knows the
+// length reported by its source, downloads that end up shorter are
+// considered truncated if the source itself does not report it.
+type SourceDurationer interface {
+	SourceDuration() time.Duration
+}
+
+// Download calls DownloadContext without context.
 func Download(w io.Writer, src *url.URL) error {
-	req, err := http.NewRequest("GET", src.String(), nil)
+	return DownloadContext(context.Background(), w, src)
+}
+
+// DownloadContext writes the contents of src to w.
+func DownloadContext(ctx context.Context, w io.Writer, src *url.URL) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", src.String(), nil)
 	if err != nil {
 		return err
 	}
 
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return err
+		return failure.Wrap(failure.KindOf(err), err)
 	}
 	defer res.Body.Close()
+	if err := failure.HTTPStatus(res.StatusCode); err != nil {
+		return err
+	}
 	_, err = io.Copy(w, res.Body)
-	return err
+	return failure.Wrap(failure.KindOf(err), err)
 }
 
+// DownloadAudio calls DownloadAudioContext without context.
 func DownloadAudio(w io.Writer, src *url.URL) error {
-	ff := exec.Command(
-		"ffmpeg",
+	return DownloadAudioContext(context.Background(), w, src)
+}
+
+// DownloadAudioContext downloads src and writes its audio stream
+// as adts to w.
+// Returns a failure.Truncated error if less audio was written than the
+// duration src reports, see TruncationTolerance.
+func DownloadAudioContext(ctx context.Context, w io.Writer, src *url.URL) error {
+	return DownloadAudioExpectContext(ctx, w, src, 0)
+}
+
+// DownloadAudioExpectContext is DownloadAudioContext but falls back to
+// comparing against expect if src does not report its duration.
+// 0 means unknown.
+func DownloadAudioExpectContext(ctx context.Context, w io.Writer, src *url.URL, expect time.Duration) error {
+	ff := ffmpeg(
+		ctx,
 		"-i",
 		"-",
 		"-vn",
@@ -54,13 +113,59 @@ func DownloadAudio(w io.Writer, src *url.URL) error {
 		errs <- nil
 	}()
 
-	err = Download(pipe, src)
+	err = DownloadContext(ctx, pipe, src)
 	pipe.Close()
 	if err != nil {
 		return err
 	}
 
-	return <-errs
+	if err := <-errs; err != nil {
+		return err
+	}
+
+	return truncated(bufe.Bytes(), expect)
+}
+
+// TruncationTolerance is how much shorter than its source a download may be
+// before it is considered truncated.
+const TruncationTolerance = time.Second * 3
+
+var (
+	ffmpegDuration = regexp.MustCompile(`Duration: (\d+:\d\d:\d\d(?:\.\d+)?)`)
+	ffmpegTime     = regexp.MustCompile(`time=(\d+:\d\d:\d\d(?:\.\d+)?)`)
+)
+
+// truncated compares the input duration ffmpeg reported in stderr, or
+// expect if it did not, against the last progress time.
+func truncated(stderr []byte, expect time.Duration) error {
+	if m := ffmpegDuration.FindSubmatch(stderr); m != nil {
+		if d, ok := parseFFmpegTime(string(m[1])); ok {
+			expect = d
+		}
+	}
+	l := ffmpegTime.FindAllSubmatch(stderr, -1)
+	if expect <= 0 || len(l) == 0 {
+		return nil
+	}
+	got, ok := parseFFmpegTime(string(l[len(l)-1][1]))
+	if !ok || got >= expect-TruncationTolerance {
+		return nil
+	}
+
+	return failure.Wrap(
+		failure.Truncated,
+		fmt.Errorf("truncated download: %s of %s", got.Round(time.Second), expect.Round(time.Second)),
+	)
+}
+
+// parseFFmpegTime parses a hh:mm:ss.ms timestamp.
+func parseFFmpegTime(s string) (time.Duration, bool) {
+	var h, m int
+	var sec float64
+	if _, err := fmt.Sscanf(s, "%d:%d:%f", &h, &m, &sec); err != nil {
+		return 0, false
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec*float64(time.Second)), true
 }
 
 func TempFile(file string) string {
@@ -0,0 +1,142 @@
+package collection
+
+import (
+	"strings"
+	"unicode"
+)
+
+// DefaultDuplicateThreshold is the TitleSimilarity above which two songs
+// are considered duplicates.
+const DefaultDuplicateThreshold = 0.85
+
+// Duplicate is a song that is about to be added whose title closely
+// matches a song that already exists.
+type Duplicate struct {
+	Song       Song
+	Existing   Song
+	Similarity float64
+}
+
+// normalizeTitle strips noise, case and punctuation from a title.
+func normalizeTitle(title string) string {
+	for _, r := range DefaultTitleRules {
+		title = r.Match.ReplaceAllString(title, r.Replace)
+	}
+	return strings.Join(strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// TitleSimilarity returns how alike two titles are ignoring case,
+// punctuation and noise like "(Official Video)", from 0 to 1.
+func TitleSimilarity(a, b string) float64 {
+	return similarity([]rune(normalizeTitle(a)), []rune(normalizeTitle(b)))
+}
+
+func similarity(a, b []rune) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	max := len(a)
+	if len(b) > max {
+		max = len(b)
+	}
+	return 1 - float64(levenshtein(a, b))/float64(max)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if v := prev[j] + 1; v < cur[j] {
+				cur[j] = v
+			}
+			if v := cur[j-1] + 1; v < cur[j] {
+				cur[j] = v
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// Duplicates splits songs that are about to be added to the given playlist
+// in those whose title matches a different song in that playlist with at
+// least the given TitleSimilarity and those that do not.
+// Songs without a title never match.
+func (c *Collection) Duplicates(playlist string, songs []Song, threshold float64) ([]Duplicate, []Song, error) {
+	p, err := c.get(playlist)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	type title struct {
+		s Song
+		t []rune
+	}
+	existing := make([]title, 0)
+	gids := make(map[string]struct{})
+	for _, s := range p.List() {
+		gids[GlobalID(s)] = struct{}{}
+		if t := s.Title(); t != "" {
+			existing = append(existing, title{s, []rune(normalizeTitle(t))})
+		}
+	}
+
+	dups := make([]Duplicate, 0)
+	rest := make([]Song, 0, len(songs))
+	for _, s := range songs {
+		if _, ok := gids[GlobalID(s)]; ok || s.Title() == "" {
+			rest = append(rest, s)
+			continue
+		}
+
+		t := []rune(normalizeTitle(s.Title()))
+		best := Duplicate{Song: s}
+		for _, e := range existing {
+			// the similarity can't exceed the ratio of both lengths.
+			l, el := float64(len(t)), float64(len(e.t))
+			if l > el {
+				l, el = el, l
+			}
+			if el == 0 || l/el < threshold {
+				continue
+			}
+			if sim := similarity(t, e.t); sim > best.Similarity {
+				best.Existing, best.Similarity = e.s, sim
+			}
+		}
+
+		if best.Existing != nil && best.Similarity >= threshold {
+			dups = append(dups, best)
+			continue
+		}
+		rest = append(rest, s)
+	}
+
+	return dups, rest, nil
+}
+
+// ReplaceSong replaces old with s in the given playlist keeping its
+// position.
+func (c *Collection) ReplaceSong(playlist string, old, s Song) error {
+	p, err := c.get(playlist)
+	if err != nil {
+		return err
+	}
+	p.Replace(old, s)
+	c.notifyNew([]Song{s}, nil)
+	c.changed()
+	c.playlistChanged(p.name)
+	return nil
+}
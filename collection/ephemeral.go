@@ -0,0 +1,44 @@
+package collection
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+// NewEphemeral creates a collection that only lives in memory, e.g.: for
+// bots and demos. Its downloads are stored in a new temporary directory,
+// see Dir, which is removed by Close.
+func NewEphemeral(l *log.Logger, queue *Queue, concurrentDownloads int) (*Collection, error) {
+	dir, err := ioutil.TempDir("", "libym-")
+	if err != nil {
+		return nil, err
+	}
+	c := New(l, dir, queue, concurrentDownloads, false)
+	c.ephemeral = true
+	c.tmpDir = dir
+	return c, nil
+}
+
+// SetEphemeral keeps the collection in memory only, the store is never
+// loaded, locked or saved. Downloads are still written to the directory
+// passed to New and kept, use NewEphemeral for a temporary one.
+// Must be called before Init.
+func (c *Collection) SetEphemeral(e bool) { c.ephemeral = e }
+
+// Ephemeral returns true if the collection is never persisted.
+func (c *Collection) Ephemeral() bool { return c.ephemeral }
+
+// Dir returns the directory downloads are stored in.
+func (c *Collection) Dir() string { return c.dir }
+
+// removeTemp removes the directory created by NewEphemeral, never one
+// passed in by the caller.
+func (c *Collection) removeTemp() error {
+	if c.tmpDir == "" {
+		return nil
+	}
+	err := os.RemoveAll(c.tmpDir)
+	c.tmpDir = ""
+	return err
+}
@@ -0,0 +1,174 @@
+package collection
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ExportPlaylistDir is the directory, relative to the export root, that
+// holds the exported m3u playlists.
+const ExportPlaylistDir = "Playlists"
+
+// exportExt is the extension of exported files, the downloaded adts
+// streams are remuxed into mp4 so they can carry tags.
+const exportExt = ".m4a"
+
+// ExportProgress is called before each song is exported.
+type ExportProgress func(item, total int, s Song)
+
+// ExportResult summarizes an Export.
+type ExportResult struct {
+	// Written is the amount of songs that were (re)written.
+	Written int
+	// Unchanged is the amount of songs that were already up to date.
+	Unchanged int
+	// Skipped is the amount of songs that were not downloaded yet.
+	Skipped int
+	// Playlists is the amount of m3u playlists written.
+	Playlists int
+}
+
+// Export writes all downloaded songs in all playlists to dir as tagged
+// files named by t, and an m3u playlist per playlist to
+// dir/ExportPlaylistDir. The result can be imported by e.g.: beets or served
+// by Navidrome. Files that are newer than their download are left untouched
+// so repeated exports only write what changed.
+func (c *Collection) Export(ctx context.Context, dir string, t *NameTemplate, progress ExportProgress) (ExportResult, error) {
+	return c.ExportProfile(ctx, dir, t, DefaultTranscodeProfile, progress)
+}
+
+// ExportProfile is Export but encodes songs using the given profile.
+// Files are not rewritten when only the profile changed, export each
+// profile to its own dir.
+func (c *Collection) ExportProfile(ctx context.Context, dir string, t *NameTemplate, profile TranscodeProfile, progress ExportProgress) (ExportResult, error) {
+	var res ExportResult
+	if err := profile.Validate(); err != nil {
+		return res, err
+	}
+	names := c.List()
+	playlists := make(map[string][]Song, len(names))
+	index := make(map[string]Song)
+	for _, n := range names {
+		l, err := c.PlaylistSongs(n)
+		if err != nil {
+			return res, err
+		}
+		playlists[n] = l
+		for _, s := range l {
+			index[GlobalID(s)] = s
+		}
+	}
+
+	// stable order so collisions are numbered the same on every export.
+	gids := make([]string, 0, len(index))
+	for gid := range index {
+		gids = append(gids, gid)
+	}
+	sort.Strings(gids)
+
+	namer := t.Namer()
+	paths := make(map[string]string, len(gids))
+	for i, gid := range gids {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		s := index[gid]
+		if progress != nil {
+			progress(i, len(gids), s)
+		}
+		if !s.Local() {
+			res.Skipped++
+			continue
+		}
+
+		rel := namer.Name(SongNameFields(s), profile.Ext)
+		paths[gid] = rel
+		written, err := exportSong(ctx, s, filepath.Join(dir, filepath.FromSlash(rel)), profile)
+		if err != nil {
+			return res, fmt.Errorf("%s-%s: %w", s.NS(), s.ID(), err)
+		}
+		if written {
+			res.Written++
+			continue
+		}
+		res.Unchanged++
+	}
+
+	pdir := filepath.Join(dir, ExportPlaylistDir)
+	if err := os.MkdirAll(pdir, 0o755); err != nil {
+		return res, err
+	}
+	for _, n := range names {
+		name := Sanitize(n)
+		if name == "" {
+			continue
+		}
+		var b strings.Builder
+		b.WriteString("#EXTM3U\n")
+		for _, s := range playlists[n] {
+			rel, ok := paths[GlobalID(s)]
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(&b, "#EXTINF:-1,%s\n../%s\n", strings.ReplaceAll(s.Title(), "\n", " "), rel)
+		}
+		file := filepath.Join(pdir, name+".m3u")
+		tmp := TempFile(file)
+		if err := ioutil.WriteFile(tmp, []byte(b.String()), 0o644); err != nil {
+			return res, err
+		}
+		if err := os.Rename(tmp, file); err != nil {
+			os.Remove(tmp)
+			return res, err
+		}
+		res.Playlists++
+	}
+
+	return res, nil
+}
+
+// exportSong encodes the download of s to dst, tagging it with its title,
+// artist and id. Returns false if dst was already up to date.
+func exportSong(ctx context.Context, s Song, dst string, p TranscodeProfile) (bool, error) {
+	src, err := s.File()
+	if err != nil {
+		return false, err
+	}
+	si, err := os.Stat(src)
+	if err != nil {
+		return false, err
+	}
+	if di, err := os.Stat(dst); err == nil && !di.ModTime().Before(si.ModTime()) {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return false, err
+	}
+
+	f := SongNameFields(s)
+	tmp := TempFile(dst)
+	args := []string{"-nostats", "-y", "-i", src, "-vn"}
+	args = append(args, p.args()...)
+	args = append(
+		args,
+		"-metadata", "title="+f.Title,
+		"-metadata", "artist="+f.Artist,
+		"-metadata", "comment="+s.NS()+"-"+s.ID(),
+		tmp,
+	)
+	ff := ffmpeg(ctx, args...)
+	bufe := bytes.NewBuffer(nil)
+	ff.Stderr = bufe
+	if err := ff.Run(); err != nil {
+		os.Remove(tmp)
+		return false, fmt.Errorf("%w: %s", err, bufe.String())
+	}
+
+	return true, os.Rename(tmp, dst)
+}
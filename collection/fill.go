@@ -0,0 +1,53 @@
+package collection
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Fill selects a random subset of songs whose lengths sum to roughly target.
+// length returns the (estimated) length of a song.
+func Fill(r *rand.Rand, songs []Song, target time.Duration, length func(Song) time.Duration) []Song {
+	return FillWeighted(r, songs, target, length, nil)
+}
+
+// FillWeighted is Fill but songs with a higher weight are more likely to be
+// selected, see WeightedShuffle. A nil weight selects uniformly.
+func FillWeighted(r *rand.Rand, songs []Song, target time.Duration, length func(Song) time.Duration, weight func(Song) float64) []Song {
+	var l []Song
+	if weight != nil {
+		l = WeightedShuffle(r, songs, weight)
+	} else {
+		l = make([]Song, len(songs))
+		copy(l, songs)
+		r.Shuffle(len(l), func(i, j int) { l[i], l[j] = l[j], l[i] })
+	}
+
+	var total time.Duration
+	sel := make([]Song, 0)
+	rest := make([]Song, 0)
+	for _, s := range l {
+		d := length(s)
+		if total+d > target {
+			rest = append(rest, s)
+			continue
+		}
+		total += d
+		sel = append(sel, s)
+	}
+
+	// Overshoot with one more song if that ends closer to target.
+	var best Song
+	bestDiff := target - total
+	for _, s := range rest {
+		diff := total + length(s) - target
+		if diff < bestDiff {
+			best, bestDiff = s, diff
+		}
+	}
+	if best != nil {
+		sel = append(sel, best)
+	}
+
+	return sel
+}
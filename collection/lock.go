@@ -0,0 +1,85 @@
+package collection
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrLocked is returned by Init if another process uses the store and
+	// the LockMode is LockRefuse.
+	ErrLocked = errors.New("store is in use by another process")
+	// ErrReadOnly is returned by Save if the store was opened read-only.
+	ErrReadOnly = errors.New("store is opened read-only")
+)
+
+// LockMode determines what Init does when another process holds the lock
+// on the store.
+type LockMode byte
+
+const (
+	// LockRefuse makes Init fail with ErrLocked.
+	LockRefuse LockMode = iota
+	// LockReadOnly loads the store but never saves it.
+	LockReadOnly
+)
+
+// SetLockMode sets what happens when another process uses the same store.
+// Defaults to LockRefuse. Must be called before Init.
+func (c *Collection) SetLockMode(m LockMode) { c.lockMode = m }
+
+// ReadOnly returns true if the store is used by another process and was
+// opened read-only, see LockReadOnly.
+func (c *Collection) ReadOnly() bool { return c.readOnly }
+
+func (c *Collection) pathLock() string { return filepath.Join(c.dbDir, "db.lock") }
+
+// lock takes an advisory lock on the store that is held until Close or the
+// process exits.
+func (c *Collection) lock() error {
+	path := c.pathLock()
+	f, err := lockFile(path)
+	if err == nil {
+		f.Truncate(0)
+		f.WriteString(strconv.Itoa(os.Getpid()))
+		f.Sync()
+		c.lockf = f
+		return nil
+	}
+	if !errors.Is(err, errLockHeld) {
+		return err
+	}
+
+	if c.lockMode == LockReadOnly {
+		c.readOnly = true
+		c.l.Printf("%s, opening read-only", ErrLocked)
+		return nil
+	}
+
+	pid, _ := ioutil.ReadFile(path)
+	if p := strings.TrimSpace(string(pid)); p != "" {
+		return fmt.Errorf("%w (pid %s): %s", ErrLocked, p, c.dbDir)
+	}
+	return fmt.Errorf("%w: %s", ErrLocked, c.dbDir)
+}
+
+// Close releases the lock on the store.
+// Collections created with NewEphemeral remove their directory instead.
+func (c *Collection) Close() error {
+	c.sem.Lock()
+	defer c.sem.Unlock()
+	if c.ephemeral {
+		return c.removeTemp()
+	}
+	if c.lockf == nil {
+		return nil
+	}
+	err := c.lockf.Close()
+	c.lockf = nil
+	return err
+}
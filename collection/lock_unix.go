@@ -0,0 +1,26 @@
+// +build !windows
+
+package collection
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var errLockHeld = errors.New("lock held")
+
+func lockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, errLockHeld
+		}
+		return nil, err
+	}
+	return f, nil
+}
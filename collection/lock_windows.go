@@ -0,0 +1,38 @@
+// +build windows
+
+package collection
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var errLockHeld = errors.New("lock held")
+
+const errSharingViolation syscall.Errno = 32
+
+// lockFile opens path without sharing, which fails while another process
+// has it open.
+func lockFile(path string) (*os.File, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	h, err := syscall.CreateFile(
+		p,
+		syscall.GENERIC_READ|syscall.GENERIC_WRITE,
+		0,
+		nil,
+		syscall.OPEN_ALWAYS,
+		syscall.FILE_ATTRIBUTE_NORMAL,
+		0,
+	)
+	if err != nil {
+		if err == errSharingViolation {
+			return nil, errLockHeld
+		}
+		return nil, err
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
@@ -0,0 +1,82 @@
+package collection
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/frizinak/libym/lyrics"
+)
+
+// LyricsPath returns the path of the .lrc file of the given song.
+func (c *Collection) LyricsPath(id IDer) string {
+	sum := sha256.Sum256([]byte(id.ID()))
+	l := base64.RawURLEncoding.EncodeToString(sum[:])
+	ns := nsRE.ReplaceAllString(id.NS(), "-")
+	return filepath.Join(c.dbDir, "lyrics", ns, l+".lrc")
+}
+
+// Lyrics loads the lyrics of the given song.
+// Returns an error satisfying os.IsNotExist if there are none.
+func (c *Collection) Lyrics(s IDer) (*lyrics.Lyrics, error) {
+	f, err := os.Open(c.LyricsPath(s))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return lyrics.Parse(f)
+}
+
+// SetLyrics stores LRC (or plain) lyrics for the given song, replacing
+// existing ones.
+func (c *Collection) SetLyrics(s IDer, r io.Reader) (*lyrics.Lyrics, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	l, err := lyrics.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(l.Lines) == 0 {
+		return nil, errors.New("no lyrics found")
+	}
+
+	p := c.LyricsPath(s)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return nil, err
+	}
+	tmp := TempFile(p)
+	if err := ioutil.WriteFile(tmp, data, 0o644); err != nil {
+		return nil, err
+	}
+	return l, os.Rename(tmp, p)
+}
+
+// DelLyrics removes the lyrics of the given song.
+func (c *Collection) DelLyrics(s IDer) error {
+	err := os.Remove(c.LyricsPath(s))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// FetchLyrics looks up the lyrics of the given song and stores them.
+// duration is passed to the Fetcher to pick the right version, 0 if
+// unknown.
+func (c *Collection) FetchLyrics(ctx context.Context, f lyrics.Fetcher, s Song, duration time.Duration) (*lyrics.Lyrics, error) {
+	raw, err := f.Fetch(ctx, s.Title(), duration)
+	if err != nil {
+		return nil, err
+	}
+	return c.SetLyrics(s, strings.NewReader(raw))
+}
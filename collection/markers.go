@@ -0,0 +1,67 @@
+package collection
+
+import (
+	"sort"
+	"time"
+)
+
+// Marker is a named position in a song.
+type Marker struct {
+	Name string
+	At   time.Duration
+}
+
+// SetMarker saves a named position in a song, replacing an existing marker
+// with the same name.
+func (c *Collection) SetMarker(s IDer, name string, at time.Duration) {
+	gid := GlobalID(s)
+	c.marksem.Lock()
+	if _, ok := c.markers[gid]; !ok {
+		c.markers[gid] = make(map[string]time.Duration, 1)
+	}
+	c.markers[gid][name] = at
+	c.marksem.Unlock()
+	c.changed()
+}
+
+// DelMarker removes a marker, returns false if it did not exist.
+func (c *Collection) DelMarker(s IDer, name string) bool {
+	gid := GlobalID(s)
+	c.marksem.Lock()
+	_, ok := c.markers[gid][name]
+	delete(c.markers[gid], name)
+	if len(c.markers[gid]) == 0 {
+		delete(c.markers, gid)
+	}
+	c.marksem.Unlock()
+	if ok {
+		c.changed()
+	}
+	return ok
+}
+
+// Marker returns the position of a named marker in a song.
+func (c *Collection) Marker(s IDer, name string) (time.Duration, bool) {
+	c.marksem.RLock()
+	at, ok := c.markers[GlobalID(s)][name]
+	c.marksem.RUnlock()
+	return at, ok
+}
+
+// Markers returns all markers of a song ordered by position.
+func (c *Collection) Markers(s IDer) []Marker {
+	c.marksem.RLock()
+	m := c.markers[GlobalID(s)]
+	l := make([]Marker, 0, len(m))
+	for name, at := range m {
+		l = append(l, Marker{name, at})
+	}
+	c.marksem.RUnlock()
+	sort.Slice(l, func(i, j int) bool {
+		if l[i].At != l[j].At {
+			return l[i].At < l[j].At
+		}
+		return l[i].Name < l[j].Name
+	})
+	return l
+}
@@ -0,0 +1,135 @@
+package collection
+
+import "fmt"
+
+// MergeStrategy decides how imported songs are combined with the songs
+// already in a playlist.
+type MergeStrategy uint8
+
+const (
+	// MergeAppend adds all songs to the end of the playlist, songs that are
+	// already in it are moved to the end.
+	MergeAppend MergeStrategy = iota
+	// MergeReplace replaces the songs of the playlist.
+	MergeReplace
+	// MergeUnion only adds songs that are not in the playlist yet, neither
+	// by id nor by a title with at least DefaultDuplicateThreshold
+	// similarity, and keeps the existing order.
+	MergeUnion
+)
+
+var mergeStrategyNames = map[MergeStrategy]string{
+	MergeAppend:  "append",
+	MergeReplace: "replace",
+	MergeUnion:   "union",
+}
+
+func (m MergeStrategy) String() string { return mergeStrategyNames[m] }
+
+// ParseMergeStrategy parses the name of a MergeStrategy.
+func ParseMergeStrategy(s string) (MergeStrategy, error) {
+	for m, n := range mergeStrategyNames {
+		if n == s {
+			return m, nil
+		}
+	}
+	return MergeAppend, fmt.Errorf("invalid merge strategy '%s', use append, replace or union", s)
+}
+
+// MergeSummary describes the changes a Merge made or, if it was a dry run,
+// would make.
+type MergeSummary struct {
+	Playlist string
+	Strategy MergeStrategy
+	DryRun   bool
+
+	// Added are the songs that were not in the playlist.
+	Added []Song
+	// Moved are existing songs moved to the end by MergeAppend.
+	Moved []Song
+	// Removed are existing songs dropped by MergeReplace.
+	Removed []Song
+	// Skipped are songs MergeUnion did not add.
+	Skipped []Song
+}
+
+// Merge imports songs in the given playlist, creating it if needed.
+// If dryRun is true the playlist is left untouched.
+func (c *Collection) Merge(playlist string, songs []Song, m MergeStrategy, dryRun bool) (MergeSummary, error) {
+	sum := MergeSummary{Playlist: playlist, Strategy: m, DryRun: dryRun}
+
+	var existing []Song
+	if p, err := c.get(playlist); err == nil {
+		existing = p.List()
+	}
+	have := make(map[string]struct{}, len(existing))
+	for _, s := range existing {
+		have[GlobalID(s)] = struct{}{}
+	}
+	incoming := make(map[string]struct{}, len(songs))
+	unique := make([]Song, 0, len(songs))
+	for _, s := range songs {
+		id := GlobalID(s)
+		if _, ok := incoming[id]; ok {
+			continue
+		}
+		incoming[id] = struct{}{}
+		unique = append(unique, s)
+	}
+
+	add := unique
+	switch m {
+	case MergeAppend, MergeReplace:
+		for _, s := range unique {
+			if _, ok := have[GlobalID(s)]; ok {
+				if m == MergeAppend {
+					sum.Moved = append(sum.Moved, s)
+				}
+				continue
+			}
+			sum.Added = append(sum.Added, s)
+		}
+		if m == MergeReplace {
+			for _, s := range existing {
+				if _, ok := incoming[GlobalID(s)]; !ok {
+					sum.Removed = append(sum.Removed, s)
+				}
+			}
+		}
+	case MergeUnion:
+		rest := unique
+		if existing != nil {
+			dups, r, err := c.Duplicates(playlist, unique, DefaultDuplicateThreshold)
+			if err != nil {
+				return sum, err
+			}
+			for _, d := range dups {
+				sum.Skipped = append(sum.Skipped, d.Song)
+			}
+			rest = r
+		}
+		add = make([]Song, 0, len(rest))
+		for _, s := range rest {
+			if _, ok := have[GlobalID(s)]; ok {
+				sum.Skipped = append(sum.Skipped, s)
+				continue
+			}
+			add = append(add, s)
+		}
+		sum.Added = add
+	default:
+		return sum, fmt.Errorf("invalid merge strategy %d", m)
+	}
+
+	if dryRun {
+		return sum, nil
+	}
+
+	if m == MergeReplace {
+		return sum, c.SetPlaylistSongs(playlist, unique)
+	}
+	if err := c.Create(playlist); err != nil && !IsErrExists(err) {
+		return sum, err
+	}
+	return sum, c.AddSongs(playlist, add, m == MergeAppend, nil)
+}
@@ -0,0 +1,288 @@
+package collection
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultNameTemplate is the NameTemplate used when none is configured.
+const DefaultNameTemplate = "%{artist? % - :}%{title}"
+
+// maxNameLength is the maximum length in bytes of a single path segment
+// produced by a NameTemplate, most filesystems allow 255.
+const maxNameLength = 200
+
+// NameFields are the values available in a NameTemplate.
+type NameFields struct {
+	Artist string
+	Album  string
+	Title  string
+	ID     string
+	NS     string
+	// Original is the full title the song was uploaded with, see
+	// OriginalTitler. Equal to the full title if unknown.
+	Original string
+}
+
+// SongNameFields guesses the artist from the common "Artist - Title" song
+// title format.
+func SongNameFields(s Song) NameFields {
+	f := NameFields{Title: strings.TrimSpace(s.Title()), ID: s.ID(), NS: s.NS()}
+	f.Original = f.Title
+	if o, ok := s.(OriginalTitler); ok {
+		f.Original = strings.TrimSpace(o.OriginalTitle())
+	}
+	if p := strings.SplitN(f.Title, " - ", 2); len(p) == 2 {
+		f.Artist, f.Title = strings.TrimSpace(p[0]), strings.TrimSpace(p[1])
+	}
+	if f.Title == "" {
+		f.Title = f.ID
+	}
+	return f
+}
+
+func (f NameFields) get(key string) (string, bool) {
+	switch key {
+	case "artist":
+		return f.Artist, true
+	case "album":
+		return f.Album, true
+	case "title":
+		return f.Title, true
+	case "id":
+		return f.ID, true
+	case "ns":
+		return f.NS, true
+	case "original":
+		return f.Original, true
+	}
+	return "", false
+}
+
+type namePart struct {
+	lit string
+
+	key         string
+	cond        bool
+	set, notSet string
+}
+
+// NameTemplate turns NameFields into a relative, filesystem-safe path, e.g.:
+// "%{artist? %/:}%{album? %/:}%{title}" results in "artist/album/title".
+//
+// %{key} is replaced with the value of key, %{key?set:unset} with set if key
+// is not empty, in which case % in set is replaced by its value, or unset
+// otherwise. Use \ to escape %, :, } and \.
+// Keys: artist, album, title, id, ns and original, the full title before
+// localization, see OriginalTitler.
+type NameTemplate struct {
+	parts []namePart
+}
+
+// ParseNameTemplate parses a NameTemplate, see its documentation.
+func ParseNameTemplate(tpl string) (*NameTemplate, error) {
+	t := &NameTemplate{}
+	var lit strings.Builder
+	for i := 0; i < len(tpl); i++ {
+		switch {
+		case tpl[i] == '\\' && i+1 < len(tpl):
+			i++
+			lit.WriteByte(tpl[i])
+		case strings.HasPrefix(tpl[i:], "%{"):
+			end, p, err := parseNamePart(tpl, i+2)
+			if err != nil {
+				return nil, err
+			}
+			if lit.Len() != 0 {
+				t.parts = append(t.parts, namePart{lit: lit.String()})
+				lit.Reset()
+			}
+			t.parts = append(t.parts, p)
+			i = end
+		default:
+			lit.WriteByte(tpl[i])
+		}
+	}
+	if lit.Len() != 0 {
+		t.parts = append(t.parts, namePart{lit: lit.String()})
+	}
+	if len(t.parts) == 0 {
+		return nil, errors.New("empty name template")
+	}
+
+	return t, nil
+}
+
+func parseNamePart(tpl string, start int) (int, namePart, error) {
+	var p namePart
+	var buf strings.Builder
+	phase := 0
+	for i := start; i < len(tpl); i++ {
+		c := tpl[i]
+		if c == '\\' && i+1 < len(tpl) {
+			i++
+			if phase == 1 {
+				// unescaped by expandSet to keep \% distinguishable
+				// from the value placeholder.
+				buf.WriteByte('\\')
+			}
+			buf.WriteByte(tpl[i])
+			continue
+		}
+
+		switch {
+		case c == '}':
+			switch phase {
+			case 0:
+				p.key = buf.String()
+			case 1:
+				p.set = buf.String()
+			case 2:
+				p.notSet = buf.String()
+			}
+			if _, ok := (NameFields{}).get(p.key); !ok {
+				return 0, p, fmt.Errorf("unknown name template key '%s'", p.key)
+			}
+			return i, p, nil
+		case c == '?' && phase == 0:
+			p.key, p.cond, phase = buf.String(), true, 1
+			buf.Reset()
+		case c == ':' && phase == 1:
+			p.set, phase = buf.String(), 2
+			buf.Reset()
+		default:
+			buf.WriteByte(c)
+		}
+	}
+
+	return 0, p, errors.New("unterminated %{ in name template")
+}
+
+// Execute returns the relative path for f, without extension.
+// Values are sanitized so they never introduce path separators.
+func (t *NameTemplate) Execute(f NameFields) string {
+	var b strings.Builder
+	for _, p := range t.parts {
+		if p.key == "" {
+			b.WriteString(p.lit)
+			continue
+		}
+		v, _ := f.get(p.key)
+		v = strings.Map(sanitizeRune, v)
+		switch {
+		case !p.cond:
+			b.WriteString(v)
+		case v != "":
+			b.WriteString(expandSet(p.set, v))
+		default:
+			b.WriteString(p.notSet)
+		}
+	}
+
+	segs := strings.Split(path.Clean("/"+b.String()), "/")
+	l := make([]string, 0, len(segs))
+	for _, s := range segs {
+		if s = Sanitize(s); s != "" {
+			l = append(l, s)
+		}
+	}
+	if len(l) == 0 {
+		return "_"
+	}
+	return strings.Join(l, "/")
+}
+
+func expandSet(set, v string) string {
+	var b strings.Builder
+	for i := 0; i < len(set); i++ {
+		switch {
+		case set[i] == '\\' && i+1 < len(set):
+			i++
+			b.WriteByte(set[i])
+		case set[i] == '%':
+			b.WriteString(v)
+		default:
+			b.WriteByte(set[i])
+		}
+	}
+	return b.String()
+}
+
+func sanitizeRune(r rune) rune {
+	if unicode.IsControl(r) || r == utf8.RuneError {
+		return -1
+	}
+	switch r {
+	case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+		return '_'
+	}
+	return r
+}
+
+var reservedNames = map[string]struct{}{
+	"CON": {}, "PRN": {}, "AUX": {}, "NUL": {},
+	"COM1": {}, "COM2": {}, "COM3": {}, "COM4": {}, "COM5": {},
+	"COM6": {}, "COM7": {}, "COM8": {}, "COM9": {},
+	"LPT1": {}, "LPT2": {}, "LPT3": {}, "LPT4": {}, "LPT5": {},
+	"LPT6": {}, "LPT7": {}, "LPT8": {}, "LPT9": {},
+}
+
+// Sanitize makes name safe to use as a single path segment on all common
+// filesystems. Returns an empty string if nothing usable is left.
+func Sanitize(name string) string {
+	name = strings.Map(sanitizeRune, name)
+	name = strings.Trim(name, " .")
+	if len(name) > maxNameLength {
+		n := maxNameLength
+		for n > 0 && !utf8.RuneStart(name[n]) {
+			n--
+		}
+		name = strings.TrimRight(name[:n], " .")
+	}
+
+	base := name
+	if ix := strings.IndexByte(base, '.'); ix != -1 {
+		base = base[:ix]
+	}
+	if _, ok := reservedNames[strings.ToUpper(base)]; ok {
+		name = "_" + name
+	}
+
+	return name
+}
+
+// Namer hands out unique names from a NameTemplate, e.g.: for a single
+// export. Names are compared case-insensitively as not all filesystems
+// are case sensitive.
+type Namer struct {
+	t    *NameTemplate
+	used map[string]struct{}
+}
+
+// Namer creates a new Namer that has not handed out any names yet.
+func (t *NameTemplate) Namer() *Namer {
+	return &Namer{t: t, used: make(map[string]struct{})}
+}
+
+// Name returns a unique relative path for f with the given extension
+// (e.g.: ".mp3"), a number is appended on collision: "title (2).mp3".
+func (n *Namer) Name(f NameFields, ext string) string {
+	name := n.t.Execute(f)
+	for i := 1; ; i++ {
+		try := name
+		if i > 1 {
+			try += " (" + strconv.Itoa(i) + ")"
+		}
+		try += ext
+		k := strings.ToLower(try)
+		if _, ok := n.used[k]; !ok {
+			n.used[k] = struct{}{}
+			return try
+		}
+	}
+}
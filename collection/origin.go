@@ -0,0 +1,42 @@
+package collection
+
+import "fmt"
+
+// OriginKind describes how a song ended up in the queue.
+type OriginKind uint8
+
+const (
+	// OriginUnknown is used for songs queued by id or url.
+	OriginUnknown OriginKind = iota
+	// OriginPlaylist songs were queued from the playlist Origin.Name.
+	OriginPlaylist
+	// OriginSearch songs were queued from the results of query Origin.Name.
+	OriginSearch
+	// OriginFill songs were picked automatically, e.g. by fill or daily.
+	OriginFill
+	// OriginRemote songs were queued by the remote user Origin.Name.
+	OriginRemote
+)
+
+// Origin records where a QueueItem came from.
+type Origin struct {
+	Kind OriginKind
+	Name string
+}
+
+func (o Origin) String() string {
+	switch o.Kind {
+	case OriginPlaylist:
+		return fmt.Sprintf("playlist %s", o.Name)
+	case OriginSearch:
+		return fmt.Sprintf("search '%s'", o.Name)
+	case OriginFill:
+		if o.Name == "" {
+			return "fill"
+		}
+		return fmt.Sprintf("fill %s", o.Name)
+	case OriginRemote:
+		return fmt.Sprintf("remote %s", o.Name)
+	}
+	return ""
+}
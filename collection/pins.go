@@ -0,0 +1,46 @@
+package collection
+
+import (
+	"sort"
+	"strings"
+)
+
+// Pin marks a song to always be kept downloaded, even if it is not in any
+// playlist, the queue or would only be streamed, see Cacher.
+func (c *Collection) Pin(s Song) {
+	c.pinsem.Lock()
+	c.pins[GlobalID(s)] = s
+	c.pinsem.Unlock()
+	c.changed()
+	c.Download(s)
+}
+
+// Unpin reverts Pin.
+func (c *Collection) Unpin(s IDer) {
+	c.pinsem.Lock()
+	delete(c.pins, GlobalID(s))
+	c.pinsem.Unlock()
+	c.changed()
+}
+
+// Pinned reports whether the given song was pinned.
+func (c *Collection) Pinned(s IDer) bool {
+	c.pinsem.RLock()
+	_, ok := c.pins[GlobalID(s)]
+	c.pinsem.RUnlock()
+	return ok
+}
+
+// PinnedSongs returns all pinned songs sorted by title.
+func (c *Collection) PinnedSongs() []Song {
+	c.pinsem.RLock()
+	l := make([]Song, 0, len(c.pins))
+	for _, s := range c.pins {
+		l = append(l, s)
+	}
+	c.pinsem.RUnlock()
+	sort.Slice(l, func(i, j int) bool {
+		return strings.ToLower(l[i].Title()) < strings.ToLower(l[j].Title())
+	})
+	return l
+}
@@ -1,6 +1,7 @@
 package collection
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"strings"
@@ -13,14 +14,25 @@ type Song interface {
 	IDer
 	Title() string
 	UpdateTitle() error
+	UpdateTitleContext(context.Context) error
 	SetTitle(string)
 	Local() bool
 	URL() (*url.URL, error)
+	URLContext(context.Context) (*url.URL, error)
 	File() (string, error)
 	Marshal(*binary.Writer) error
 	PageURL() (*url.URL, error)
 }
 
+// OriginalTitler can optionally be implemented by a Song whose source
+// offers localized titles, e.g.: YoutubeSong after youtube.SetLanguage.
+type OriginalTitler interface {
+	// OriginalTitle returns the title the song was uploaded with or Title
+	// if it is not localized.
+	OriginalTitle() string
+	SetOriginalTitle(string)
+}
+
 type IDer interface {
 	NS() string
 	ID() string
@@ -53,10 +65,22 @@ func GlobalID(i IDer) string {
 	return gid
 }
 
+// PlaylistSettings is the preferred playback behavior of a playlist, applied
+// whenever it is queued.
+type PlaylistSettings struct {
+	// Shuffle shuffles the songs of the playlist when queued.
+	Shuffle bool
+	// Repeat is the amount of additional times the playlist is queued.
+	Repeat uint8
+	// Sort keeps the playlist sorted, SortManual allows moving songs.
+	Sort SortKey
+}
+
 type Playlist struct {
-	sem   sync.RWMutex
-	name  string
-	songs []Song
+	sem      sync.RWMutex
+	name     string
+	songs    []Song
+	settings PlaylistSettings
 }
 
 func NewPlaylist(name string) *Playlist {
@@ -79,8 +103,10 @@ func (p *Playlist) Find(ns, id string) (Song, error) {
 	return song, nil
 }
 
-func (p *Playlist) Search(q string) []Song {
-	l := p.List()
+func (p *Playlist) Search(q string) []Song { return searchSongs(p.List(), q) }
+
+// searchSongs returns the songs whose title contains all words in q.
+func searchSongs(l []Song, q string) []Song {
 	qs := strings.Fields(strings.ToLower(q))
 	a := make([]Song, 0)
 	var all bool
@@ -101,10 +127,11 @@ func (p *Playlist) Search(q string) []Song {
 }
 
 func (p *Playlist) List() []Song {
-	p.sem.RLock()
+	p.sem.Lock()
+	p.sort()
 	n := make([]Song, len(p.songs))
 	copy(n, p.songs)
-	p.sem.RUnlock()
+	p.sem.Unlock()
 	return n
 }
 
@@ -126,6 +153,57 @@ func (p *Playlist) Add(s Song, reappend bool) {
 	p.songs = append(p.songs, s)
 }
 
+// AddSlice adds multiple songs at once, see Add.
+func (p *Playlist) AddSlice(songs []Song, reappend bool) {
+	p.sem.Lock()
+	defer p.sem.Unlock()
+	all := make([]Song, len(p.songs), len(p.songs)+len(songs))
+	copy(all, p.songs)
+	dead := make(map[int]struct{})
+	index := make(map[string]int, len(all)+len(songs))
+	for i, song := range all {
+		index[GlobalID(song)] = i
+	}
+
+	for _, s := range songs {
+		id := GlobalID(s)
+		ix, ok := index[id]
+		if ok && !reappend {
+			continue
+		}
+		if ok {
+			dead[ix] = struct{}{}
+			s = all[ix]
+		}
+		index[id] = len(all)
+		all = append(all, s)
+	}
+
+	p.songs = make([]Song, 0, len(all)-len(dead))
+	for i, s := range all {
+		if _, ok := dead[i]; !ok {
+			p.songs = append(p.songs, s)
+		}
+	}
+}
+
+// Set replaces all songs of the playlist, duplicates are skipped.
+func (p *Playlist) Set(songs []Song) {
+	l := make([]Song, 0, len(songs))
+	seen := make(map[string]struct{}, len(songs))
+	for _, s := range songs {
+		id := GlobalID(s)
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		l = append(l, s)
+	}
+	p.sem.Lock()
+	p.songs = l
+	p.sem.Unlock()
+}
+
 func (p *Playlist) Del(s Song) {
 	p.sem.Lock()
 	defer p.sem.Unlock()
@@ -143,6 +221,31 @@ func (p *Playlist) Del(s Song) {
 	p.songs = append(p.songs[:ix], p.songs[ix+1:]...)
 }
 
+// Replace replaces old with s keeping its position, old is only removed if
+// s is already in the playlist.
+func (p *Playlist) Replace(old, s Song) {
+	p.sem.Lock()
+	defer p.sem.Unlock()
+	oid, id := GlobalID(old), GlobalID(s)
+	ix, exists := -1, false
+	for i, song := range p.songs {
+		switch GlobalID(song) {
+		case oid:
+			ix = i
+		case id:
+			exists = true
+		}
+	}
+	if ix == -1 {
+		return
+	}
+	if exists {
+		p.songs = append(p.songs[:ix], p.songs[ix+1:]...)
+		return
+	}
+	p.songs[ix] = s
+}
+
 func (p *Playlist) DelIndexes(ix []int) {
 	songs := make([]Song, 0, len(ix))
 	p.sem.RLock()
@@ -205,9 +308,37 @@ func (p *Playlist) MoveIndex(from []int, to int) {
 	}
 }
 
-func (p *Playlist) Queue(q *Queue, ix int) {
+// Settings returns the playlist settings.
+func (p *Playlist) Settings() PlaylistSettings {
 	p.sem.RLock()
 	defer p.sem.RUnlock()
+	return p.settings
+}
+
+// SetSettings updates the playlist settings.
+func (p *Playlist) SetSettings(s PlaylistSettings) {
+	p.sem.Lock()
+	p.settings = s
+	p.sort()
+	p.sem.Unlock()
+}
+
+// Queue adds the songs of this playlist to q according to its settings.
+func (p *Playlist) Queue(q *Queue, ix int) {
+	p.sem.Lock()
+	p.sort()
+	p.sem.Unlock()
+	p.sem.RLock()
+	settings := p.settings
+	songs := make([]Song, 0, len(p.songs)*(int(settings.Repeat)+1))
+	for i := 0; i <= int(settings.Repeat); i++ {
+		pass := p.songs
+		if settings.Shuffle {
+			pass = q.shuffled(pass)
+		}
+		songs = append(songs, pass...)
+	}
+	p.sem.RUnlock()
 
-	q.AddSlice(ix, p.songs)
+	q.AddSliceFrom(ix, songs, Origin{Kind: OriginPlaylist, Name: p.name})
 }
@@ -4,6 +4,9 @@ import (
 	"errors"
 	"sort"
 	"sync"
+	"time"
+
+	"github.com/frizinak/libym/failure"
 )
 
 var ErrUnknown = errors.New("unknown")
@@ -11,9 +14,13 @@ var ErrUnknown = errors.New("unknown")
 type Problematic struct {
 	s      Song
 	reason error
+
+	first, last time.Time
+	attempts    uint32
 }
 
 func (p Problematic) Song() Song { return p.s }
+
 func (p Problematic) Reason() error {
 	if p.reason == nil {
 		return ErrUnknown
@@ -21,6 +28,44 @@ func (p Problematic) Reason() error {
 	return p.reason
 }
 
+// Kind classifies the Reason.
+func (p Problematic) Kind() failure.Kind { return failure.KindOf(p.reason) }
+
+// FirstSeen is the time the song first failed.
+func (p Problematic) FirstSeen() time.Time { return p.first }
+
+// LastAttempt is the time of the most recent failure.
+func (p Problematic) LastAttempt() time.Time { return p.last }
+
+// Attempts is the amount of consecutive failures.
+func (p Problematic) Attempts() int { return int(p.attempts) }
+
+// RetryAfter returns the earliest time at which the song should be retried
+// automatically, the backoff doubles with each attempt starting at
+// RetryBackoff up to MaxRetryBackoff.
+// Returns false if the failure is permanent, see failure.Kind.Temporary.
+func (p Problematic) RetryAfter() (time.Time, bool) {
+	if !p.Kind().Temporary() {
+		return time.Time{}, false
+	}
+	backoff := RetryBackoff
+	for i := uint32(1); i < p.attempts && backoff < MaxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > MaxRetryBackoff {
+		backoff = MaxRetryBackoff
+	}
+	return p.last.Add(backoff), true
+}
+
+const (
+	// RetryBackoff is the time to wait before retrying a song that failed
+	// once.
+	RetryBackoff = time.Minute * 10
+	// MaxRetryBackoff is the maximum time to wait before retrying a song.
+	MaxRetryBackoff = time.Hour * 24
+)
+
 type problematicList []Problematic
 
 func (p problematicList) Len() int           { return len(p) }
@@ -46,9 +91,55 @@ func (p *Problematics) Reason(s IDer) string {
 	return entry.Reason().Error()
 }
 
+// Kind returns the failure.Kind of the given song's problem or
+// failure.Unknown if it is not problematic.
+func (p *Problematics) Kind(s IDer) failure.Kind {
+	p.rw.RLock()
+	entry, ok := p.m[GlobalID(s)]
+	p.rw.RUnlock()
+	if !ok {
+		return failure.Unknown
+	}
+	return entry.Kind()
+}
+
+// Add records a failed attempt for the given song.
 func (p *Problematics) Add(s Song, err error) {
+	now := time.Now()
+	gid := GlobalID(s)
+	p.rw.Lock()
+	entry, ok := p.m[gid]
+	if !ok {
+		entry.first = now
+	}
+	entry.s, entry.reason, entry.last = s, err, now
+	entry.attempts++
+	p.m[gid] = entry
+	p.rw.Unlock()
+}
+
+// Get returns the problem of the given song.
+func (p *Problematics) Get(s IDer) (Problematic, bool) {
+	p.rw.RLock()
+	entry, ok := p.m[GlobalID(s)]
+	p.rw.RUnlock()
+	return entry, ok
+}
+
+// Retry reports whether the given song should be retried automatically,
+// see Problematic.RetryAfter.
+func (p *Problematics) Retry(s IDer, now time.Time) bool {
+	entry, ok := p.Get(s)
+	if !ok {
+		return true
+	}
+	after, ok := entry.RetryAfter()
+	return ok && !now.Before(after)
+}
+
+func (p *Problematics) set(entry Problematic) {
 	p.rw.Lock()
-	p.m[GlobalID(s)] = Problematic{s, err}
+	p.m[GlobalID(entry.s)] = entry
 	p.rw.Unlock()
 }
 
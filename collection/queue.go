@@ -2,6 +2,7 @@ package collection
 
 import (
 	"math/rand"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -11,6 +12,8 @@ type QueueItem struct {
 	prev, next *QueueItem
 	Song
 
+	origin Origin
+
 	first, last bool
 }
 
@@ -19,11 +22,35 @@ func (q *QueueItem) Next() *QueueItem    { return q.next }
 func (q *QueueItem) IsBeyondFirst() bool { return q.first }
 func (q *QueueItem) IsBeyondLast() bool  { return q.last }
 
+// Origin returns how this item was added to the queue.
+func (q *QueueItem) Origin() Origin { return q.origin }
+
 type Queue struct {
 	sem     sync.RWMutex
 	root    *QueueItem
 	current *QueueItem
 	r       *rand.Rand
+
+	obsem     sync.RWMutex
+	observers []func()
+}
+
+// OnChange registers a callback that is called after songs are added,
+// removed or reordered or the current song changes.
+// Callbacks must not block.
+func (q *Queue) OnChange(cb func()) {
+	q.obsem.Lock()
+	q.observers = append(q.observers, cb)
+	q.obsem.Unlock()
+}
+
+func (q *Queue) notify() {
+	q.obsem.RLock()
+	obs := q.observers
+	q.obsem.RUnlock()
+	for _, cb := range obs {
+		cb()
+	}
 }
 
 func NewQueue() *Queue {
@@ -35,31 +62,108 @@ func NewQueue() *Queue {
 	return q
 }
 
-func (q *Queue) Add(ix int, s Song) {
+func (q *Queue) Add(ix int, s Song) { q.AddFrom(ix, s, Origin{}) }
+
+// AddFrom is Add but records the origin of the song.
+func (q *Queue) AddFrom(ix int, s Song, o Origin) {
+	defer q.notify()
 	q.sem.Lock()
 	defer q.sem.Unlock()
-	q.add(ix, s)
+	q.add(ix, s, o)
 }
 
-func (q *Queue) AddSlice(ix int, songs []Song) {
+func (q *Queue) AddSlice(ix int, songs []Song) { q.AddSliceFrom(ix, songs, Origin{}) }
+
+// AddSliceFrom is AddSlice but records the origin of the songs.
+func (q *Queue) AddSliceFrom(ix int, songs []Song, o Origin) {
+	defer q.notify()
 	q.sem.Lock()
 	defer q.sem.Unlock()
 	for _, s := range songs {
-		q.add(ix, s)
+		q.add(ix, s, o)
 		if ix >= 0 {
 			ix++
 		}
 	}
 }
 
+// RemoveFrom removes all items that were added from the given origin and
+// returns how many were removed.
+// If the current item is removed, Next continues with the item that
+// followed it.
+func (q *Queue) RemoveFrom(o Origin) int {
+	q.sem.Lock()
+	n := 0
+	c := q.root.next
+	for c != nil && !c.last {
+		next := c.next
+		if c.origin == o {
+			c.prev.next = c.next
+			c.next.prev = c.prev
+			if c == q.current {
+				q.current = c.prev
+			}
+			n++
+		}
+		c = next
+	}
+	q.sem.Unlock()
+	if n != 0 {
+		q.notify()
+	}
+	return n
+}
+
+// Origins returns the origin of each item in the queue, see Slice.
+func (q *Queue) Origins() []Origin {
+	q.sem.RLock()
+	defer q.sem.RUnlock()
+	l := make([]Origin, 0)
+	for c := q.root.next; c != nil && !c.last; c = c.next {
+		l = append(l, c.origin)
+	}
+	return l
+}
+
+// setOrigins restores the origins of the items in the queue as returned by
+// Origins.
+func (q *Queue) setOrigins(l []Origin) {
+	q.sem.Lock()
+	defer q.sem.Unlock()
+	i := 0
+	for c := q.root.next; c != nil && !c.last && i < len(l); c = c.next {
+		c.origin = l[i]
+		i++
+	}
+}
+
 // ShuffleRange shuffles items in the queue in range [start, end]
 // if start < 0: shuffle from beginning
 // if end < 0: shuffle until the end
 // thus ShuffleRange(-1, -1) shuffles the entire queue
 func (q *Queue) ShuffleRange(start, end int) {
+	q.shuffleRange(start, end, func(l []*QueueItem) {
+		q.r.Shuffle(len(l), func(i, j int) {
+			l[i], l[j] = l[j], l[i]
+		})
+	})
+}
+
+// ShuffleRangeWeighted is ShuffleRange but songs with a higher weight are
+// more likely to end up near the start of the range, see WeightedShuffle.
+func (q *Queue) ShuffleRangeWeighted(start, end int, weight func(Song) float64) {
+	q.shuffleRange(start, end, func(l []*QueueItem) {
+		q.r.Shuffle(len(l), func(i, j int) { l[i], l[j] = l[j], l[i] })
+		keys := weightedKeys(q.r, len(l), func(i int) float64 { return weight(l[i].Song) })
+		sort.Stable(byKey{keys, func(i, j int) { l[i], l[j] = l[j], l[i] }})
+	})
+}
+
+func (q *Queue) shuffleRange(start, end int, shuffle func([]*QueueItem)) {
 	if start < 0 {
 		start = 0
 	}
+	defer q.notify()
 	q.sem.Lock()
 	defer q.sem.Unlock()
 	l := make([]*QueueItem, 0, 1)
@@ -97,9 +201,7 @@ func (q *Queue) ShuffleRange(start, end int) {
 		panic("shuffle failed")
 	}
 
-	q.r.Shuffle(len(l), func(i, j int) {
-		l[i], l[j] = l[j], l[i]
-	})
+	shuffle(l)
 
 	if len(l) <= 1 {
 		return
@@ -117,6 +219,16 @@ func (q *Queue) ShuffleRange(start, end int) {
 
 func (q *Queue) Shuffle() { q.ShuffleRange(0, -1) }
 
+// shuffled returns a shuffled copy of songs.
+func (q *Queue) shuffled(songs []Song) []Song {
+	l := make([]Song, len(songs))
+	copy(l, songs)
+	q.sem.Lock()
+	q.r.Shuffle(len(l), func(i, j int) { l[i], l[j] = l[j], l[i] })
+	q.sem.Unlock()
+	return l
+}
+
 func (q *Queue) Slice() []Song {
 	q.sem.RLock()
 	defer q.sem.RUnlock()
@@ -147,7 +259,7 @@ func (q *Queue) String() string {
 	return strings.Join(l, "\n")
 }
 
-func (q *Queue) add(ix int, s Song) {
+func (q *Queue) add(ix int, s Song, o Origin) {
 	var last func(int, *QueueItem, *QueueItem)
 	last = func(index int, target, q *QueueItem) {
 		if target.last || index == ix {
@@ -160,7 +272,7 @@ func (q *Queue) add(ix int, s Song) {
 		last(index+1, target.next, q)
 	}
 
-	item := &QueueItem{Song: s}
+	item := &QueueItem{Song: s, origin: o}
 	if q.current != nil && q.current.last && q.current.prev == q.root {
 		q.current = nil
 	}
@@ -169,6 +281,7 @@ func (q *Queue) add(ix int, s Song) {
 }
 
 func (q *Queue) SetCurrentIndex(i int) {
+	defer q.notify()
 	q.sem.RLock()
 	defer q.sem.RUnlock()
 	c := q.root.next
@@ -215,6 +328,7 @@ func (q *Queue) Current() *QueueItem {
 }
 
 func (q *Queue) Prev() *QueueItem {
+	defer q.notify()
 	q.sem.RLock()
 	defer q.sem.RUnlock()
 
@@ -233,6 +347,7 @@ func (q *Queue) Prev() *QueueItem {
 }
 
 func (q *Queue) Next() *QueueItem {
+	defer q.notify()
 	q.sem.RLock()
 	defer q.sem.RUnlock()
 
@@ -250,6 +365,7 @@ func (q *Queue) Next() *QueueItem {
 }
 
 func (q *Queue) Reset() {
+	defer q.notify()
 	q.sem.Lock()
 	defer q.sem.Unlock()
 	q.root = &QueueItem{first: true, next: &QueueItem{last: true}}
@@ -0,0 +1,59 @@
+package collection
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ShareFormat is the format Share writes in.
+type ShareFormat byte
+
+const (
+	// ShareText writes "title url" lines.
+	ShareText ShareFormat = iota
+	// ShareMarkdown writes a numbered list of links.
+	ShareMarkdown
+)
+
+var mdEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`[`, `\[`,
+	`]`, `\]`,
+	`*`, `\*`,
+	`_`, `\_`,
+	"`", "\\`",
+)
+
+// Share writes the titles and page urls of the given songs to w, e.g.: to
+// paste a listening session in a chat.
+func Share(w io.Writer, songs []Song, f ShareFormat) error {
+	for i, s := range songs {
+		var page string
+		if u, err := s.PageURL(); err == nil && u != nil {
+			page = u.String()
+		}
+
+		var err error
+		switch f {
+		case ShareMarkdown:
+			title := mdEscaper.Replace(s.Title())
+			if page == "" {
+				_, err = fmt.Fprintf(w, "%d. %s\n", i+1, title)
+				break
+			}
+			_, err = fmt.Fprintf(w, "%d. [%s](%s)\n", i+1, title, page)
+		default:
+			if page == "" {
+				_, err = fmt.Fprintf(w, "%s\n", s.Title())
+				break
+			}
+			_, err = fmt.Fprintf(w, "%s %s\n", s.Title(), page)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package collection
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// weightedKeys returns a sort key for each of n items, sorting ascending
+// by key results in a random order where items with a higher weight are
+// more likely to come first (Efraimidis-Spirakis).
+// Items with a weight <= 0 sort last.
+func weightedKeys(r *rand.Rand, n int, weight func(i int) float64) []float64 {
+	keys := make([]float64, n)
+	for i := range keys {
+		w := weight(i)
+		if w <= 0 {
+			keys[i] = math.Inf(1)
+			continue
+		}
+		keys[i] = -math.Log(1-r.Float64()) / w
+	}
+	return keys
+}
+
+// WeightedShuffle returns a shuffled copy of songs where songs with a
+// higher weight are more likely to end up near the front.
+func WeightedShuffle(r *rand.Rand, songs []Song, weight func(Song) float64) []Song {
+	l := make([]Song, len(songs))
+	copy(l, songs)
+	r.Shuffle(len(l), func(i, j int) { l[i], l[j] = l[j], l[i] })
+	keys := weightedKeys(r, len(l), func(i int) float64 { return weight(l[i]) })
+	sort.Stable(byKey{keys, func(i, j int) { l[i], l[j] = l[j], l[i] }})
+	return l
+}
+
+type byKey struct {
+	keys []float64
+	swap func(i, j int)
+}
+
+func (b byKey) Len() int           { return len(b.keys) }
+func (b byKey) Less(i, j int) bool { return b.keys[i] < b.keys[j] }
+func (b byKey) Swap(i, j int) {
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+	b.swap(i, j)
+}
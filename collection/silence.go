@@ -0,0 +1,130 @@
+package collection
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// SilenceTrim configures trimming of leading and trailing silence from
+// downloads, see TrimSilenceContext.
+type SilenceTrim struct {
+	// Noise is the volume in dB below which audio is considered silent.
+	Noise float64
+	// Min is the minimum duration of silence that is trimmed.
+	Min time.Duration
+}
+
+// DefaultSilenceTrim trims at least half a second of audio below -50dB.
+var DefaultSilenceTrim = SilenceTrim{Noise: -50, Min: time.Millisecond * 500}
+
+var (
+	silenceStartRE = regexp.MustCompile(`silence_start: (-?[0-9.]+)`)
+	silenceEndRE   = regexp.MustCompile(`silence_end: (-?[0-9.]+)`)
+	durationRE     = regexp.MustCompile(`Duration: ([0-9]+):([0-9]+):([0-9.]+)`)
+)
+
+// silenceEdge is the allowed distance between a silence and the start or
+// end of a file for it to be considered leading or trailing.
+const silenceEdge = time.Millisecond * 100
+
+type silence struct {
+	start, end time.Duration
+	open       bool
+}
+
+func parseSeconds(s string) time.Duration {
+	f, _ := strconv.ParseFloat(s, 64)
+	return time.Duration(f * float64(time.Second))
+}
+
+// DetectSilence returns the leading and trailing silence in file using
+// ffmpeg's silencedetect filter. to is the position at which the trailing
+// silence starts or 0 if there is none.
+func DetectSilence(ctx context.Context, file string, cfg SilenceTrim) (from, to time.Duration, err error) {
+	ff := ffmpeg(
+		ctx,
+		"-nostats",
+		"-i", file,
+		"-af", fmt.Sprintf("silencedetect=noise=%gdB:d=%g", cfg.Noise, cfg.Min.Seconds()),
+		"-f", "null",
+		"-",
+	)
+	bufe := bytes.NewBuffer(nil)
+	ff.Stderr = bufe
+	if err := ff.Run(); err != nil {
+		return 0, 0, fmt.Errorf("%w: %s", err, bufe.String())
+	}
+
+	var duration time.Duration
+	if m := durationRE.FindSubmatch(bufe.Bytes()); m != nil {
+		h, _ := strconv.Atoi(string(m[1]))
+		min, _ := strconv.Atoi(string(m[2]))
+		duration = time.Duration(h)*time.Hour +
+			time.Duration(min)*time.Minute +
+			parseSeconds(string(m[3]))
+	}
+
+	var l []silence
+	for _, line := range bytes.Split(bufe.Bytes(), []byte("\n")) {
+		if m := silenceStartRE.FindSubmatch(line); m != nil {
+			l = append(l, silence{start: parseSeconds(string(m[1])), open: true})
+			continue
+		}
+		if m := silenceEndRE.FindSubmatch(line); m != nil && len(l) != 0 {
+			l[len(l)-1].end = parseSeconds(string(m[1]))
+			l[len(l)-1].open = false
+		}
+	}
+	if len(l) == 0 {
+		return 0, 0, nil
+	}
+
+	if first := l[0]; first.start <= silenceEdge && !first.open {
+		from = first.end
+	}
+	last := l[len(l)-1]
+	if last.open || (duration > 0 && last.end >= duration-silenceEdge) {
+		if last.start > from {
+			to = last.start
+		}
+	}
+
+	return from, to, nil
+}
+
+// TrimSilenceContext removes leading and trailing silence from the adts
+// file in place. The file is left untouched if there is nothing to trim.
+func TrimSilenceContext(ctx context.Context, file string, cfg SilenceTrim) error {
+	from, to, err := DetectSilence(ctx, file, cfg)
+	if err != nil {
+		return err
+	}
+	if from == 0 && to == 0 {
+		return nil
+	}
+
+	args := []string{"-nostats", "-i", file, "-vn", "-c", "copy"}
+	if from > 0 {
+		args = append(args, "-ss", strconv.FormatFloat(from.Seconds(), 'f', 3, 64))
+	}
+	if to > 0 {
+		args = append(args, "-to", strconv.FormatFloat(to.Seconds(), 'f', 3, 64))
+	}
+
+	tmp := TempFile(file)
+	args = append(args, "-f", "adts", tmp)
+	ff := ffmpeg(ctx, args...)
+	bufe := bytes.NewBuffer(nil)
+	ff.Stderr = bufe
+	if err := ff.Run(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("%w: %s", err, bufe.String())
+	}
+
+	return os.Rename(tmp, file)
+}
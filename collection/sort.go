@@ -0,0 +1,72 @@
+package collection
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrAutoSorted is returned when moving songs in an automatically sorted
+// playlist.
+var ErrAutoSorted = errors.New("playlist is sorted automatically")
+
+// SortKey is the order of a playlist.
+type SortKey uint8
+
+const (
+	// SortManual keeps songs in the order they were added or moved to.
+	SortManual SortKey = iota
+	// SortTitle sorts by the full title.
+	SortTitle
+	// SortArtist sorts by artist and then title, see SongNameFields.
+	SortArtist
+)
+
+var sortKeyNames = map[SortKey]string{
+	SortManual: "manual",
+	SortTitle:  "title",
+	SortArtist: "artist",
+}
+
+func (k SortKey) String() string { return sortKeyNames[k] }
+
+// ParseSortKey parses the name of a SortKey.
+func ParseSortKey(s string) (SortKey, error) {
+	for k, n := range sortKeyNames {
+		if n == s {
+			return k, nil
+		}
+	}
+	return SortManual, fmt.Errorf("invalid sort key '%s', use manual, title or artist", s)
+}
+
+func (k SortKey) less(a, b Song) bool {
+	switch k {
+	case SortTitle:
+		return strings.ToLower(a.Title()) < strings.ToLower(b.Title())
+	case SortArtist:
+		fa, fb := SongNameFields(a), SongNameFields(b)
+		la, lb := strings.ToLower(fa.Artist), strings.ToLower(fb.Artist)
+		if la != lb {
+			return la < lb
+		}
+		return strings.ToLower(fa.Title) < strings.ToLower(fb.Title)
+	}
+	return false
+}
+
+// sort sorts the songs according to the playlist settings, the caller
+// must hold the write lock.
+// Titles are looked up after songs are added so this happens lazily
+// whenever the songs are listed.
+func (p *Playlist) sort() {
+	k := p.settings.Sort
+	if k == SortManual {
+		return
+	}
+	less := func(i, j int) bool { return k.less(p.songs[i], p.songs[j]) }
+	if !sort.SliceIsSorted(p.songs, less) {
+		sort.SliceStable(p.songs, less)
+	}
+}
@@ -1,23 +1,130 @@
 package collection
 
 import (
+	"bufio"
 	"compress/gzip"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/frizinak/binary"
+	"github.com/frizinak/libym/failure"
 )
 
 const (
-	storeQueue = "__QUEUE\x00\x01\x08"
-	eos        = "__eos\x00\x01\x08"
+	storeQueue    = "__QUEUE\x00\x01\x08"
+	storeBanned   = "__BANNED\x00\x01\x08"
+	storeSettings = "__SETTINGS\x00\x01\x08"
+	storeMarkers  = "__MARKERS\x00\x01\x08"
+	storeAlts     = "__ALTS\x00\x01\x08"
+	storeProblems = "__PROBLEMS\x00\x01\x08"
+	storePins     = "__PINS\x00\x01\x08"
+	storeSort     = "__SORT\x00\x01\x08"
+	storeOrigins  = "__ORIGINS\x00\x01\x08"
+	storeOriginal = "__ORIGINALS\x00\x01\x08"
+	storeChecked  = "__CHECKED\x00\x01\x08"
+	eos           = "__eos\x00\x01\x08"
 )
 
+const storeBufSize = 1 << 16
+
+// maxPrealloc limits the capacity preallocated from counts read from the
+// store, so a corrupt store fails to load instead of exhausting memory.
+const maxPrealloc = 1 << 16
+
+func prealloc(n uint64) int {
+	if n > maxPrealloc {
+		return maxPrealloc
+	}
+	return int(n)
+}
+
+// maxReasonLength limits the stored reason of a Problematic, these can
+// include the entire output of external commands.
+const maxReasonLength = 4096
+
 type Unmarshaler func(dec *binary.Reader) (Song, error)
 
+// storeReader wraps a binary.Reader and reuses a single buffer for
+// length-prefixed data, avoiding an allocation per read where the result
+// does not need to outlive the next read.
+// Unmarshalers are passed the underlying binary.Reader.
+type storeReader struct {
+	*binary.Reader
+	buf []byte
+	err error
+}
+
+func newStoreReader(r io.Reader) *storeReader {
+	return &storeReader{Reader: binary.NewReader(r), buf: make([]byte, 0, 256)}
+}
+
+func (r *storeReader) read(n uint64) []byte {
+	if r.Err() != nil {
+		return r.buf[:0]
+	}
+	if uint64(cap(r.buf)) < n {
+		r.buf = make([]byte, n)
+	}
+	r.buf = r.buf[:n]
+	if _, err := io.ReadFull(r.Reader.Reader(), r.buf); err != nil {
+		r.err = err
+		return r.buf[:0]
+	}
+	return r.buf
+}
+
+// Err returns the first error encountered.
+func (r *storeReader) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.Reader.Err()
+}
+
+// ReadKey reads length-prefixed bytes into the shared buffer.
+// The returned slice is only valid until the next read.
+func (r *storeReader) ReadKey(bitSize byte) []byte {
+	return r.read(r.ReadUint(bitSize))
+}
+
+// ReadString reads a length-prefixed string with a single allocation.
+func (r *storeReader) ReadString(bitSize byte) string {
+	return string(r.ReadKey(bitSize))
+}
+
+// storeWriter wraps a binary.Writer and writes strings without converting
+// them to a byte slice first.
+type storeWriter struct {
+	*binary.Writer
+	err error
+}
+
+// Err returns the first error encountered.
+func (w *storeWriter) Err() error {
+	if w.err != nil {
+		return w.err
+	}
+	return w.Writer.Err()
+}
+
+// WriteString is binary.Writer.WriteString without the copy.
+func (w *storeWriter) WriteString(str string, bitSize byte) {
+	if max := uint64(1)<<bitSize - 1; uint64(len(str)) > max {
+		str = str[:max]
+	}
+	w.WriteUint(uint64(len(str)), bitSize)
+	if w.Err() != nil {
+		return
+	}
+	if _, err := io.WriteString(w.Writer.Writer(), str); err != nil {
+		w.err = err
+	}
+}
+
 func (c *Collection) RegisterUnmarshaler(ns string, unmarshaler Unmarshaler) {
 	c.unmarshalers[ns] = unmarshaler
 }
@@ -37,37 +144,54 @@ func (c *Collection) Load() error {
 	}
 	defer reader.Close()
 
-	dec := binary.NewReader(reader)
-	songs := make(map[string]Song)
+	dec := newStoreReader(bufio.NewReaderSize(reader, storeBufSize))
 
 	nsongs := dec.ReadUint64()
+	if err := dec.Err(); err != nil {
+		return err
+	}
+	songs := make(map[string]Song, prealloc(nsongs))
 
 	var i uint64
 	for ; i < nsongs; i++ {
-		ns := dec.ReadString(8)
-		if _, ok := c.unmarshalers[ns]; !ok {
+		ns := dec.ReadKey(8)
+		unmarshal, ok := c.unmarshalers[string(ns)]
+		if !ok {
 			return fmt.Errorf("no unmarshaler for namespace '%s'", ns)
 		}
 
-		song, err := c.unmarshalers[ns](dec)
+		song, err := unmarshal(dec.Reader)
 		if err != nil {
 			return err
 		}
 		songs[GlobalID(song)] = song
 	}
 
-	getSongs := func() ([]Song, error) {
+	getSongs := func(uniq bool) ([]Song, error) {
 		errs := make([]string, 0)
 		nq := dec.ReadUint32()
-		l := make([]Song, 0, nq)
+		l := make([]Song, 0, prealloc(uint64(nq)))
+		var seen map[Song]struct{}
+		if uniq {
+			seen = make(map[Song]struct{}, prealloc(uint64(nq)))
+		}
 		var i uint32
 		for ; i < nq; i++ {
-			g := dec.ReadString(8)
-			song, ok := songs[g]
+			g := dec.ReadKey(8)
+			if err := dec.Err(); err != nil {
+				return l, err
+			}
+			song, ok := songs[string(g)]
 			if !ok {
 				errs = append(errs, fmt.Sprintf("could not find song with id %s", g))
 				continue
 			}
+			if uniq {
+				if _, ok := seen[song]; ok {
+					continue
+				}
+				seen[song] = struct{}{}
+			}
 			l = append(l, song)
 		}
 
@@ -91,12 +215,193 @@ func (c *Collection) Load() error {
 		}
 
 		if playlist == storeQueue {
-			l, err := getSongs()
+			l, err := getSongs(false)
+			if err != nil {
+				return err
+			}
+			c.q.AddSlice(-1, l)
+			continue
+		}
+
+		if playlist == storeBanned {
+			l, err := getSongs(true)
 			if err != nil {
 				return err
 			}
+			c.bansem.Lock()
 			for _, s := range l {
-				c.QueueSong(-1, s)
+				c.banned[GlobalID(s)] = s
+			}
+			c.bansem.Unlock()
+			continue
+		}
+
+		if playlist == storePins {
+			l, err := getSongs(true)
+			if err != nil {
+				return err
+			}
+			c.pinsem.Lock()
+			for _, s := range l {
+				c.pins[GlobalID(s)] = s
+			}
+			c.pinsem.Unlock()
+			continue
+		}
+
+		if playlist == storeSettings {
+			n := dec.ReadUint32()
+			var i uint32
+			for ; i < n; i++ {
+				name := dec.ReadString(16)
+				settings := PlaylistSettings{
+					Shuffle: dec.ReadUint8() == 1,
+					Repeat:  dec.ReadUint8(),
+				}
+				if err := dec.Err(); err != nil {
+					return err
+				}
+				p, err := c.get(name)
+				if err != nil {
+					continue
+				}
+				p.SetSettings(settings)
+			}
+			continue
+		}
+
+		if playlist == storeOrigins {
+			n := dec.ReadUint32()
+			l := make([]Origin, 0, prealloc(uint64(n)))
+			var i uint32
+			for ; i < n; i++ {
+				kind := OriginKind(dec.ReadUint8())
+				name := dec.ReadString(16)
+				if err := dec.Err(); err != nil {
+					return err
+				}
+				l = append(l, Origin{Kind: kind, Name: name})
+			}
+			c.q.setOrigins(l)
+			continue
+		}
+
+		if playlist == storeOriginal {
+			n := dec.ReadUint32()
+			var i uint32
+			for ; i < n; i++ {
+				gid := dec.ReadString(8)
+				title := dec.ReadString(16)
+				if err := dec.Err(); err != nil {
+					return err
+				}
+				if o, ok := songs[gid].(OriginalTitler); ok {
+					o.SetOriginalTitle(title)
+				}
+			}
+			continue
+		}
+
+		if playlist == storeChecked {
+			n := dec.ReadUint32()
+			var i uint32
+			for ; i < n; i++ {
+				gid := dec.ReadString(8)
+				at := time.Unix(int64(dec.ReadUint64()), 0)
+				flags := dec.ReadUint8()
+				if err := dec.Err(); err != nil {
+					return err
+				}
+				if _, ok := songs[gid]; !ok {
+					continue
+				}
+				c.checkedSem.Lock()
+				c.titleChecked[gid] = titleCheck{at: at, failed: flags&1 != 0, manual: flags&2 != 0}
+				c.checkedSem.Unlock()
+			}
+			continue
+		}
+
+		if playlist == storeSort {
+			n := dec.ReadUint32()
+			var i uint32
+			for ; i < n; i++ {
+				name := dec.ReadString(16)
+				key := SortKey(dec.ReadUint8())
+				if err := dec.Err(); err != nil {
+					return err
+				}
+				p, err := c.get(name)
+				if err != nil {
+					continue
+				}
+				settings := p.Settings()
+				settings.Sort = key
+				p.SetSettings(settings)
+			}
+			continue
+		}
+
+		if playlist == storeMarkers {
+			n := dec.ReadUint32()
+			var i uint32
+			for ; i < n; i++ {
+				gid := dec.ReadString(8)
+				nm := dec.ReadUint16()
+				m := make(map[string]time.Duration, nm)
+				var j uint16
+				for ; j < nm; j++ {
+					name := dec.ReadString(8)
+					m[name] = time.Duration(dec.ReadUint64()) * time.Millisecond
+				}
+				if err := dec.Err(); err != nil {
+					return err
+				}
+				c.marksem.Lock()
+				c.markers[gid] = m
+				c.marksem.Unlock()
+			}
+			continue
+		}
+
+		if playlist == storeAlts {
+			n := dec.ReadUint32()
+			var i uint32
+			for ; i < n; i++ {
+				gid := dec.ReadString(8)
+				l, err := getSongs(true)
+				if err != nil {
+					return err
+				}
+				c.altsem.Lock()
+				c.alts[gid] = l
+				c.altsem.Unlock()
+			}
+			continue
+		}
+
+		if playlist == storeProblems {
+			n := dec.ReadUint32()
+			var i uint32
+			for ; i < n; i++ {
+				gid := dec.ReadString(8)
+				kind := failure.Kind(dec.ReadUint8())
+				reason := dec.ReadString(16)
+				entry := Problematic{
+					first:    time.Unix(0, int64(dec.ReadUint64())),
+					last:     time.Unix(0, int64(dec.ReadUint64())),
+					attempts: dec.ReadUint32(),
+				}
+				if err := dec.Err(); err != nil {
+					return err
+				}
+				song, ok := songs[gid]
+				if !ok {
+					continue
+				}
+				entry.s = song
+				entry.reason = failure.Wrap(kind, errors.New(reason))
+				c.problematics.set(entry)
 			}
 			continue
 		}
@@ -105,16 +410,18 @@ func (c *Collection) Load() error {
 			return err
 		}
 
-		l, err := getSongs()
+		l, err := getSongs(true)
 		if err != nil {
 			return err
 		}
 
-		for _, s := range l {
-			if err := c.AddSong(playlist, s, false); err != nil {
-				return err
-			}
+		p, err := c.get(playlist)
+		if err != nil {
+			return err
 		}
+		p.sem.Lock()
+		p.songs = append(p.songs, l...)
+		p.sem.Unlock()
 	}
 
 	if err := dec.Err(); err != nil {
@@ -130,6 +437,12 @@ func (c *Collection) Load() error {
 }
 
 func (c *Collection) Save() error {
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if c.ephemeral {
+		return nil
+	}
 	c.sem.Lock()
 	defer c.sem.Unlock()
 	path := c.pathDB()
@@ -144,10 +457,13 @@ func (c *Collection) Save() error {
 	defer c.q.sem.Unlock()
 
 	index := make(map[string]Song)
-	for _, p := range c.playlists {
+	// settings are read here as p.Settings would deadlock.
+	settings := make(map[string]PlaylistSettings, len(c.playlists))
+	for n, p := range c.playlists {
 		p.sem.Lock()
 		defer p.sem.Unlock()
 
+		settings[n] = p.settings
 		songs := p.songs
 		for _, s := range songs {
 			index[GlobalID(s)] = s
@@ -156,8 +472,32 @@ func (c *Collection) Save() error {
 
 	q := c.q.slice()
 	for _, s := range q {
+		index[GlobalID(s)] = s.(*QueueItem).Song
+	}
+
+	c.bansem.RLock()
+	banned := make([]Song, 0, len(c.banned))
+	for _, s := range c.banned {
+		banned = append(banned, s)
+		index[GlobalID(s)] = s
+	}
+	c.bansem.RUnlock()
+
+	c.pinsem.RLock()
+	pins := make([]Song, 0, len(c.pins))
+	for _, s := range c.pins {
+		pins = append(pins, s)
 		index[GlobalID(s)] = s
 	}
+	c.pinsem.RUnlock()
+
+	c.altsem.RLock()
+	defer c.altsem.RUnlock()
+	for _, l := range c.alts {
+		for _, s := range l {
+			index[GlobalID(s)] = s
+		}
+	}
 
 	do := func() error {
 		writer, err := gzip.NewWriterLevel(db, gzip.BestSpeed)
@@ -165,11 +505,12 @@ func (c *Collection) Save() error {
 			return err
 		}
 		defer writer.Close()
-		enc := binary.NewWriter(writer)
+		buf := bufio.NewWriterSize(writer, storeBufSize)
+		enc := &storeWriter{Writer: binary.NewWriter(buf)}
 		enc.WriteUint64(uint64(len(index)))
 		for _, s := range index {
 			enc.WriteString(s.NS(), 8)
-			if err := s.Marshal(enc); err != nil {
+			if err := s.Marshal(enc.Writer); err != nil {
 				return err
 			}
 		}
@@ -189,10 +530,139 @@ func (c *Collection) Save() error {
 			enc.WriteString(GlobalID(s), 8)
 		}
 
+		enc.WriteString(storeBanned, 16)
+		enc.WriteUint32(uint32(len(banned)))
+		for _, s := range banned {
+			enc.WriteString(GlobalID(s), 8)
+		}
+
+		enc.WriteString(storeSettings, 16)
+		enc.WriteUint32(uint32(len(settings)))
+		for i, s := range settings {
+			var shuffle uint8
+			if s.Shuffle {
+				shuffle = 1
+			}
+			enc.WriteString(i, 16)
+			enc.WriteUint8(shuffle)
+			enc.WriteUint8(s.Repeat)
+		}
+
+		sorted := make(map[string]SortKey)
+		for i, s := range settings {
+			if s.Sort != SortManual {
+				sorted[i] = s.Sort
+			}
+		}
+		enc.WriteString(storeSort, 16)
+		enc.WriteUint32(uint32(len(sorted)))
+		for i, k := range sorted {
+			enc.WriteString(i, 16)
+			enc.WriteUint8(uint8(k))
+		}
+
+		enc.WriteString(storeOrigins, 16)
+		enc.WriteUint32(uint32(len(q)))
+		for _, s := range q {
+			o := s.(*QueueItem).origin
+			enc.WriteUint8(uint8(o.Kind))
+			enc.WriteString(o.Name, 16)
+		}
+
+		originals := make(map[string]string)
+		for gid, s := range index {
+			if o, ok := s.(OriginalTitler); ok && o.OriginalTitle() != s.Title() {
+				originals[gid] = o.OriginalTitle()
+			}
+		}
+		enc.WriteString(storeOriginal, 16)
+		enc.WriteUint32(uint32(len(originals)))
+		for gid, title := range originals {
+			enc.WriteString(gid, 8)
+			enc.WriteString(title, 16)
+		}
+
+		c.checkedSem.Lock()
+		checked := make(map[string]titleCheck, len(index))
+		for gid, check := range c.titleChecked {
+			if _, ok := index[gid]; ok {
+				checked[gid] = check
+			}
+		}
+		c.checkedSem.Unlock()
+		enc.WriteString(storeChecked, 16)
+		enc.WriteUint32(uint32(len(checked)))
+		for gid, check := range checked {
+			var flags uint8
+			if check.failed {
+				flags |= 1
+			}
+			if check.manual {
+				flags |= 2
+			}
+			enc.WriteString(gid, 8)
+			enc.WriteUint64(uint64(check.at.Unix()))
+			enc.WriteUint8(flags)
+		}
+
+		c.marksem.RLock()
+		enc.WriteString(storeMarkers, 16)
+		enc.WriteUint32(uint32(len(c.markers)))
+		for gid, m := range c.markers {
+			enc.WriteString(gid, 8)
+			enc.WriteUint16(uint16(len(m)))
+			for name, at := range m {
+				enc.WriteString(name, 8)
+				enc.WriteUint64(uint64(at / time.Millisecond))
+			}
+		}
+		c.marksem.RUnlock()
+
+		enc.WriteString(storeAlts, 16)
+		enc.WriteUint32(uint32(len(c.alts)))
+		for gid, l := range c.alts {
+			enc.WriteString(gid, 8)
+			enc.WriteUint32(uint32(len(l)))
+			for _, s := range l {
+				enc.WriteString(GlobalID(s), 8)
+			}
+		}
+
+		var problems []Problematic
+		for _, p := range c.problematics.List() {
+			// forget problems of songs that are no longer referenced.
+			if _, ok := index[GlobalID(p.s)]; ok {
+				problems = append(problems, p)
+			}
+		}
+		enc.WriteString(storeProblems, 16)
+		enc.WriteUint32(uint32(len(problems)))
+		for _, p := range problems {
+			reason := p.Reason().Error()
+			if len(reason) > maxReasonLength {
+				reason = reason[:maxReasonLength]
+			}
+			enc.WriteString(GlobalID(p.s), 8)
+			enc.WriteUint8(uint8(p.Kind()))
+			enc.WriteString(reason, 16)
+			enc.WriteUint64(uint64(p.first.UnixNano()))
+			enc.WriteUint64(uint64(p.last.UnixNano()))
+			enc.WriteUint32(p.attempts)
+		}
+
+		enc.WriteString(storePins, 16)
+		enc.WriteUint32(uint32(len(pins)))
+		for _, s := range pins {
+			enc.WriteString(GlobalID(s), 8)
+		}
+
 		enc.WriteString(eos, 16)
 		enc.WriteUint32(ix)
 
-		return enc.Err()
+		if err := enc.Err(); err != nil {
+			return err
+		}
+		return buf.Flush()
 	}
 
 	if err := do(); err != nil {
@@ -0,0 +1,117 @@
+package collection
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/frizinak/binary"
+	"github.com/frizinak/libym/subsonic"
+)
+
+const NSSubsonic = "subsonic"
+
+// Cacher can optionally be implemented by a Song to opt out of being
+// downloaded, it is streamed instead.
+type Cacher interface {
+	Cache() bool
+}
+
+// SubsonicSong is a song on a Subsonic server, see SetSubsonic.
+type SubsonicSong struct {
+	sem   sync.RWMutex
+	id    string
+	title string
+
+	file   string
+	client *subsonic.Client
+	cache  bool
+}
+
+// SetSubsonic enables songs from the given server. cache determines whether
+// these songs are downloaded like all others or only ever streamed.
+// Must be called before Init.
+func (c *Collection) SetSubsonic(client *subsonic.Client, cache bool) {
+	c.subsonic, c.subsonicCache = client, cache
+	c.RegisterUnmarshaler(NSSubsonic, func(dec *binary.Reader) (Song, error) {
+		id := dec.ReadString(8)
+		title := dec.ReadString(16)
+		if err := dec.Err(); err != nil {
+			return nil, err
+		}
+		return c.newSubsonicSong(id, title), nil
+	})
+}
+
+// Subsonic returns the client set with SetSubsonic or nil.
+func (c *Collection) Subsonic() *subsonic.Client { return c.subsonic }
+
+func (c *Collection) newSubsonicSong(id, title string) *SubsonicSong {
+	s := &SubsonicSong{id: id, title: title, client: c.subsonic, cache: c.subsonicCache}
+	s.file = c.SongPath(s)
+	return s
+}
+
+// FromSubsonic creates a song from a Subsonic api result.
+// Returns an error if no server was configured with SetSubsonic.
+func (c *Collection) FromSubsonic(s *subsonic.Song) (*SubsonicSong, error) {
+	if c.subsonic == nil {
+		return nil, errors.New("no subsonic server configured")
+	}
+	return c.newSubsonicSong(s.ID, s.FullTitle()), nil
+}
+
+func (s *SubsonicSong) NS() string  { return NSSubsonic }
+func (s *SubsonicSong) ID() string  { return s.id }
+func (s *SubsonicSong) Cache() bool { return s.cache }
+
+func (s *SubsonicSong) Title() string {
+	s.sem.RLock()
+	t := s.title
+	s.sem.RUnlock()
+	return t
+}
+
+func (s *SubsonicSong) SetTitle(title string) {
+	s.sem.Lock()
+	s.title = title
+	s.sem.Unlock()
+}
+
+func (s *SubsonicSong) UpdateTitle() error {
+	return s.UpdateTitleContext(context.Background())
+}
+
+func (s *SubsonicSong) UpdateTitleContext(ctx context.Context) error {
+	song, err := s.client.Song(ctx, s.id)
+	if err != nil {
+		return err
+	}
+	s.SetTitle(song.FullTitle())
+	return nil
+}
+
+func (s *SubsonicSong) Local() bool {
+	_, err := os.Stat(s.file)
+	return err == nil
+}
+
+func (s *SubsonicSong) File() (string, error)  { return s.file, nil }
+func (s *SubsonicSong) URL() (*url.URL, error) { return s.client.StreamURL(s.id) }
+
+func (s *SubsonicSong) URLContext(ctx context.Context) (*url.URL, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.client.StreamURL(s.id)
+}
+
+func (s *SubsonicSong) PageURL() (*url.URL, error) { return s.client.Server(), nil }
+
+func (s *SubsonicSong) Marshal(w *binary.Writer) error {
+	w.WriteString(s.ID(), 8)
+	w.WriteString(s.Title(), 16)
+	return w.Err()
+}
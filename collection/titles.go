@@ -0,0 +1,242 @@
+package collection
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// TitleRule replaces all matches of a pattern in a title.
+type TitleRule struct {
+	Match *regexp.Regexp
+	// Replace may reference submatches, see regexp.Regexp.ReplaceAllString.
+	Replace string
+}
+
+// NewTitleRule compiles a TitleRule, patterns are case insensitive.
+func NewTitleRule(pattern, replace string) (TitleRule, error) {
+	re, err := regexp.Compile("(?i)" + pattern)
+	return TitleRule{re, replace}, err
+}
+
+func mustTitleRule(pattern, replace string) TitleRule {
+	r, err := NewTitleRule(pattern, replace)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// TitleCleaner strips noise from fetched titles, see SetTitleCleaner.
+type TitleCleaner struct {
+	// Rules are applied in order.
+	Rules []TitleRule
+	// FixCase title cases titles that are entirely upper or lower case.
+	FixCase bool
+}
+
+const noiseWords = `official|music|lyrics?|audio|video|visuali[sz]er|hd|hq|4k|1080p|720p|remastered|explicit|clip|officiel`
+
+// DefaultTitleRules strip common upload noise like "(Official Video)",
+// "[HD]" and channel suffixes like " - Topic".
+var DefaultTitleRules = []TitleRule{
+	mustTitleRule(`\s*[\(\[]\s*(?:(?:`+noiseWords+`)\s*)+[\)\]]`, ""),
+	mustTitleRule(`\s+(?:hd|hq|4k)\s*$`, ""),
+	mustTitleRule(`\s*\|\s*[^|]*$`, ""),
+	mustTitleRule(`\s+-\s+topic\s*$`, ""),
+	mustTitleRule(`\s{2,}`, " "),
+	mustTitleRule(`^[\s\-–|]+|[\s\-–|]+$`, ""),
+}
+
+// NewDefaultTitleCleaner returns a TitleCleaner using DefaultTitleRules that
+// fixes casing.
+func NewDefaultTitleCleaner() *TitleCleaner {
+	return &TitleCleaner{Rules: DefaultTitleRules, FixCase: true}
+}
+
+// Clean applies the rules to title. The original title is returned if
+// nothing would be left of it.
+func (t *TitleCleaner) Clean(title string) string {
+	n := title
+	for _, r := range t.Rules {
+		n = r.Match.ReplaceAllString(n, r.Replace)
+	}
+	n = strings.TrimSpace(n)
+	if t.FixCase {
+		n = fixCase(n)
+	}
+	if n == "" {
+		return title
+	}
+	return n
+}
+
+// fixCase title cases s if it is entirely upper or lower case.
+func fixCase(s string) string {
+	var upper, lower int
+	for _, r := range s {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+	if upper+lower < 4 || (upper != 0 && lower != 0) {
+		return s
+	}
+
+	rs := []rune(strings.ToLower(s))
+	start := true
+	for i, r := range rs {
+		if start && unicode.IsLetter(r) {
+			rs[i] = unicode.ToUpper(r)
+		}
+		start = unicode.IsSpace(r) || r == '(' || r == '[' || r == '-' || r == '/'
+	}
+	return string(rs)
+}
+
+// SetTitleCleaner sets the cleaner that is applied to every title update.
+// nil disables cleaning. Must be called before Run.
+func (c *Collection) SetTitleCleaner(t *TitleCleaner) { c.cleaner = t }
+
+// cleanTitle applies the TitleCleaner to s, returns true if its title
+// changed.
+func (c *Collection) cleanTitle(s Song) bool {
+	if c.cleaner == nil {
+		return false
+	}
+	title := s.Title()
+	if title == "" {
+		return false
+	}
+	n := c.cleaner.Clean(title)
+	if n == title {
+		return false
+	}
+	s.SetTitle(n)
+	return true
+}
+
+// CleanupTitles applies the TitleCleaner to all songs in the queue and all
+// playlists and returns the amount of changed titles.
+// Noop if no TitleCleaner was set.
+func (c *Collection) CleanupTitles() int {
+	changed := make(map[string]struct{})
+	c.eachSong(func(s Song) {
+		// the queue and playlists might hold different instances.
+		if c.cleanTitle(s) {
+			changed[GlobalID(s)] = struct{}{}
+		}
+	})
+	n := len(changed)
+	if n != 0 {
+		c.changed()
+		c.playlistChanged("")
+	}
+	return n
+}
+
+// ParseTitleRule parses a sed-like substitution into a TitleRule,
+// e.g.: "s/ - free download$//". Any character following s can be used as
+// delimiter, escape it with \ to use it in the pattern or replacement.
+// \1 to \9 in the replacement reference submatches like $1.
+// Flags: i for case insensitive matching. g is accepted but, like all
+// TitleRules, every match is replaced.
+func ParseTitleRule(expr string) (TitleRule, error) {
+	if len(expr) < 2 || expr[0] != 's' {
+		return TitleRule{}, fmt.Errorf("invalid substitution '%s', expected s/pattern/replacement/", expr)
+	}
+	delim := expr[1]
+	parts := make([]string, 0, 3)
+	var cur strings.Builder
+	for i := 2; i < len(expr); i++ {
+		switch {
+		case expr[i] == '\\' && i+1 < len(expr) && expr[i+1] == delim:
+			i++
+			cur.WriteByte(delim)
+		case expr[i] == delim && len(parts) < 2:
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(expr[i])
+		}
+	}
+	if len(parts) != 2 {
+		return TitleRule{}, fmt.Errorf("invalid substitution '%s', expected s/pattern/replacement/", expr)
+	}
+
+	pattern, flags := parts[0], cur.String()
+	for _, f := range flags {
+		switch f {
+		case 'i':
+			pattern = "(?i)" + pattern
+		case 'g':
+		default:
+			return TitleRule{}, fmt.Errorf("invalid substitution flag '%c'", f)
+		}
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return TitleRule{}, err
+	}
+	return TitleRule{re, submatchRE.ReplaceAllString(parts[1], "$${$1}")}, nil
+}
+
+var submatchRE = regexp.MustCompile(`\\([1-9])`)
+
+// RetitleChange is a title changed by Retitle.
+type RetitleChange struct {
+	Song     Song
+	From, To string
+}
+
+// Retitle applies r to the titles of all songs in the given playlist, or in
+// all playlists and the queue if playlist is empty, and returns the changes.
+// Titles that would end up empty are left alone.
+// If dryRun is true, nothing is changed.
+func (c *Collection) Retitle(playlist string, r TitleRule, dryRun bool) ([]RetitleChange, error) {
+	var only map[string]struct{}
+	if playlist != "" {
+		songs, err := c.PlaylistSongs(playlist)
+		if err != nil {
+			return nil, err
+		}
+		only = make(map[string]struct{}, len(songs))
+		for _, s := range songs {
+			only[GlobalID(s)] = struct{}{}
+		}
+	}
+
+	changes := make([]RetitleChange, 0)
+	seen := make(map[string]struct{})
+	c.eachSong(func(s Song) {
+		gid := GlobalID(s)
+		if _, ok := only[gid]; only != nil && !ok {
+			return
+		}
+		title := s.Title()
+		n := strings.TrimSpace(r.Match.ReplaceAllString(title, r.Replace))
+		if n == title || n == "" {
+			return
+		}
+		// the queue and playlists might hold different instances.
+		if !dryRun {
+			s.SetTitle(n)
+			c.titleManual(s)
+		}
+		if _, ok := seen[gid]; ok {
+			return
+		}
+		seen[gid] = struct{}{}
+		changes = append(changes, RetitleChange{Song: s, From: title, To: n})
+	})
+
+	if len(changes) != 0 && !dryRun {
+		c.changed()
+		c.playlistChanged("")
+	}
+	return changes, nil
+}
@@ -0,0 +1,72 @@
+package collection
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// TranscodeProfile configures how Export encodes songs.
+type TranscodeProfile struct {
+	// Codec is the ffmpeg audio encoder, e.g.: libopus, libmp3lame or aac.
+	// Empty remuxes the download without re-encoding.
+	Codec string
+	// Bitrate in kbps, 0 uses the encoder default.
+	Bitrate int
+	// Format is the ffmpeg muxer, e.g.: ogg, mp3 or mp4.
+	Format string
+	// Ext is the extension of exported files, e.g.: .opus
+	Ext string
+	// Gain normalizes the loudness to the ReplayGain reference level,
+	// requires a Codec.
+	Gain bool
+}
+
+// ReplayGainReference is the loudness in LUFS that ReplayGain 2.0
+// normalizes to.
+const ReplayGainReference = -18
+
+// DefaultTranscodeProfile remuxes downloads into tagged m4a files.
+var DefaultTranscodeProfile = TranscodeProfile{Format: "mp4", Ext: exportExt}
+
+// DefaultTranscodeProfiles are the profiles available when none are
+// configured.
+var DefaultTranscodeProfiles = map[string]TranscodeProfile{
+	"phone": {Codec: "libopus", Bitrate: 96, Format: "ogg", Ext: ".opus", Gain: true},
+	"car":   {Codec: "libmp3lame", Bitrate: 192, Format: "mp3", Ext: ".mp3"},
+}
+
+// Validate returns an error if the profile can not be used.
+func (p TranscodeProfile) Validate() error {
+	if p.Format == "" || p.Ext == "" {
+		return fmt.Errorf("transcode profile requires a format and extension")
+	}
+	if p.Gain && p.Codec == "" {
+		return fmt.Errorf("applying gain requires a codec")
+	}
+	return nil
+}
+
+// args returns the ffmpeg output arguments, excluding tags and output file.
+func (p TranscodeProfile) args() []string {
+	if p.Codec == "" {
+		args := []string{"-c", "copy"}
+		if p.Format == "mp4" {
+			args = append(args, "-bsf:a", "aac_adtstoasc")
+		}
+		return append(args, "-f", p.Format)
+	}
+
+	args := []string{"-c:a", p.Codec}
+	if p.Bitrate > 0 {
+		args = append(args, "-b:a", strconv.Itoa(p.Bitrate)+"k")
+	}
+	if p.Gain {
+		args = append(
+			args,
+			"-af", fmt.Sprintf("loudnorm=I=%d:TP=-1.5:LRA=11", ReplayGainReference),
+			// loudnorm upsamples to 192kHz.
+			"-ar", "48000",
+		)
+	}
+	return append(args, "-f", p.Format)
+}
@@ -1,7 +1,10 @@
 package collection
 
 import (
+	"context"
+	"sort"
 	"sync"
+	"time"
 )
 
 // SongTasks runs a bunch of tasks on a song concurrenly
@@ -11,34 +14,91 @@ type SongTasks struct {
 
 	rate <-chan struct{}
 
-	qrw   sync.RWMutex
-	queue chan Song
+	qrw      sync.RWMutex
+	queue    chan Song
+	list     []Song
+	inFlight map[string]context.CancelFunc
+	recent   []TaskResult
 
-	filter func(Song) bool
-	cb     func(Song)
+	filter   func(Song) bool
+	cb       func(context.Context, Song) error
+	gate     func() bool
+	canceled func(Song)
+}
+
+// MaxRecentTasks is the amount of finished tasks a SongTasks remembers.
+const MaxRecentTasks = 50
+
+// TaskResult is the outcome of a single finished task.
+type TaskResult struct {
+	Song     Song
+	Err      error
+	Finished time.Time
+}
+
+// TaskQueueStatus is a snapshot of the state of a SongTasks.
+type TaskQueueStatus struct {
+	// Pending is the amount of queued songs per namespace.
+	Pending map[string]int
+	// InFlight is the amount of songs currently being processed.
+	InFlight int
+	// Recent are the most recently finished tasks, most recent first.
+	Recent []TaskResult
+}
+
+// TotalPending returns the amount of queued songs across all namespaces.
+func (t TaskQueueStatus) TotalPending() int {
+	n := 0
+	for _, v := range t.Pending {
+		n += v
+	}
+	return n
+}
+
+// Failed returns the amount of recent tasks that failed.
+func (t TaskQueueStatus) Failed() int {
+	n := 0
+	for _, r := range t.Recent {
+		if r.Err != nil {
+			n++
+		}
+	}
+	return n
 }
 
 // NewSongTasks creates a new SongTask that will execute cb() for every item
 // that returns true when passed through filter().
 // filter will also be executed concurrenly but never ratelimited.
+// The context passed to cb is canceled by Cancel.
 func NewSongTasks(
 	concurrency int,
 	rate <-chan struct{},
 	filter func(Song) bool,
-	cb func(Song),
+	cb func(context.Context, Song) error,
 ) *SongTasks {
 	return &SongTasks{
 		concurrency: concurrency,
 		rate:        rate,
 		queue:       make(chan Song, concurrency),
+		list:        make([]Song, 0),
+		inFlight:    make(map[string]context.CancelFunc),
+		recent:      make([]TaskResult, 0),
 		filter:      filter,
 		cb:          cb,
 	}
 }
 
-func (t *SongTasks) Start() {
-	list := make([]Song, 0)
+// SetGate sets a func that is consulted before processing a queued song,
+// songs stay queued while it returns false. Must be called before Start.
+func (t *SongTasks) SetGate(gate func() bool) { t.gate = gate }
 
+// SetCanceled sets a func that is called with the songs Cancel removes from
+// the queue, e.g.: to forget state set by filter. Songs that are in flight
+// are reported to cb through its context instead. Must be called before
+// Start.
+func (t *SongTasks) SetCanceled(canceled func(Song)) { t.canceled = canceled }
+
+func (t *SongTasks) Start() {
 	for i := 0; i < t.concurrency; i++ {
 		go func() {
 			for s := range t.queue {
@@ -47,7 +107,7 @@ func (t *SongTasks) Start() {
 				}
 
 				t.qrw.Lock()
-				list = append(list, s)
+				t.list = append(t.list, s)
 				t.qrw.Unlock()
 			}
 		}()
@@ -55,22 +115,34 @@ func (t *SongTasks) Start() {
 		go func() {
 			for range t.rate {
 				t.qrw.RLock()
-				l := len(list)
+				l := len(t.list)
 				t.qrw.RUnlock()
-				if l == 0 {
+				if l == 0 || (t.gate != nil && !t.gate()) {
 					continue
 				}
 
 				t.qrw.Lock()
-				if len(list) == 0 {
+				if len(t.list) == 0 {
 					t.qrw.Unlock()
 					continue
 				}
-				s := list[0]
-				list = list[1:]
+				s := t.list[0]
+				t.list = t.list[1:]
+				id := GlobalID(s)
+				ctx, cancel := context.WithCancel(context.Background())
+				t.inFlight[id] = cancel
 				t.qrw.Unlock()
 
-				t.cb(s)
+				err := t.cb(ctx, s)
+				cancel()
+
+				t.qrw.Lock()
+				delete(t.inFlight, id)
+				t.recent = append([]TaskResult{{s, err, time.Now()}}, t.recent...)
+				if len(t.recent) > MaxRecentTasks {
+					t.recent = t.recent[:MaxRecentTasks]
+				}
+				t.qrw.Unlock()
 			}
 		}()
 	}
@@ -79,3 +151,113 @@ func (t *SongTasks) Start() {
 func (t *SongTasks) Add(s Song) {
 	t.queue <- s
 }
+
+// Cancel removes the given song from the queue or cancels the context
+// passed to cb if it is being processed.
+// Returns false if the song was neither queued nor in flight.
+func (t *SongTasks) Cancel(s IDer) bool {
+	id := GlobalID(s)
+	t.qrw.Lock()
+	if cancel, ok := t.inFlight[id]; ok {
+		cancel()
+		t.qrw.Unlock()
+		return true
+	}
+
+	var removed Song
+	for i := range t.list {
+		if GlobalID(t.list[i]) == id {
+			removed = t.list[i]
+			t.list = append(t.list[:i:i], t.list[i+1:]...)
+			break
+		}
+	}
+	t.qrw.Unlock()
+
+	if removed == nil {
+		return false
+	}
+	if t.canceled != nil {
+		t.canceled(removed)
+	}
+	return true
+}
+
+// Status returns a snapshot of the current state.
+func (t *SongTasks) Status() TaskQueueStatus {
+	t.qrw.RLock()
+	defer t.qrw.RUnlock()
+	st := TaskQueueStatus{
+		Pending:  make(map[string]int),
+		InFlight: len(t.inFlight),
+		Recent:   make([]TaskResult, len(t.recent)),
+	}
+	for _, s := range t.list {
+		st.Pending[s.NS()]++
+	}
+	copy(st.Recent, t.recent)
+	return st
+}
+
+// TaskLimits overrides the concurrency and ratelimits of the background
+// downloads and title lookups for the songs of a single namespace,
+// see Collection.SetTaskLimits.
+type TaskLimits struct {
+	// Concurrency is the amount of songs processed concurrently.
+	// 0 uses the concurrency passed to New.
+	Concurrency int
+
+	// RatelimitDownloads and RatelimitMeta are pulled from before each
+	// download or title lookup respectively.
+	// nil uses the ratelimiters passed to Collection.Run.
+	RatelimitDownloads <-chan struct{}
+	RatelimitMeta      <-chan struct{}
+}
+
+// nsTasks routes songs to a SongTasks per namespace, songs of namespaces
+// without one go to def.
+type nsTasks struct {
+	def *SongTasks
+	ns  map[string]*SongTasks
+}
+
+func (n *nsTasks) get(s IDer) *SongTasks {
+	if t, ok := n.ns[s.NS()]; ok {
+		return t
+	}
+	return n.def
+}
+
+func (n *nsTasks) each(cb func(*SongTasks)) {
+	cb(n.def)
+	for _, t := range n.ns {
+		cb(t)
+	}
+}
+
+func (n *nsTasks) SetGate(gate func() bool)  { n.each(func(t *SongTasks) { t.SetGate(gate) }) }
+func (n *nsTasks) SetCanceled(cb func(Song)) { n.each(func(t *SongTasks) { t.SetCanceled(cb) }) }
+func (n *nsTasks) Start()                    { n.each(func(t *SongTasks) { t.Start() }) }
+func (n *nsTasks) Add(s Song)                { n.get(s).Add(s) }
+func (n *nsTasks) Cancel(s IDer) bool        { return n.get(s).Cancel(s) }
+
+// Status merges the status of all namespaces.
+func (n *nsTasks) Status() TaskQueueStatus {
+	st := TaskQueueStatus{Pending: make(map[string]int)}
+	n.each(func(t *SongTasks) {
+		s := t.Status()
+		for ns, v := range s.Pending {
+			st.Pending[ns] += v
+		}
+		st.InFlight += s.InFlight
+		st.Recent = append(st.Recent, s.Recent...)
+	})
+
+	sort.SliceStable(st.Recent, func(i, j int) bool {
+		return st.Recent[i].Finished.After(st.Recent[j].Finished)
+	})
+	if len(st.Recent) > MaxRecentTasks {
+		st.Recent = st.Recent[:MaxRecentTasks]
+	}
+	return st
+}
@@ -1,8 +1,10 @@
 package collection
 
 import (
+	"context"
 	"net/url"
 	"os"
+	"time"
 
 	"github.com/frizinak/binary"
 	"github.com/frizinak/libym/youtube"
@@ -38,6 +40,14 @@ func (s *YoutubeSong) Local() bool {
 	return err == nil
 }
 
+// SourceDuration returns the length youtube reported in search results,
+// 0 if unknown.
+func (s *YoutubeSong) SourceDuration() time.Duration { return s.Meta().Duration }
+
 func (s *YoutubeSong) File() (string, error)      { return s.file, nil }
 func (s *YoutubeSong) URL() (*url.URL, error)     { return s.DownloadURL() }
 func (s *YoutubeSong) PageURL() (*url.URL, error) { return s.Result.URL(), nil }
+
+func (s *YoutubeSong) URLContext(ctx context.Context) (*url.URL, error) {
+	return s.DownloadURLContext(ctx)
+}
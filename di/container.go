@@ -1,6 +1,7 @@
 package di
 
 import (
+	"fmt"
 	"io"
 	"log"
 	"os"
@@ -13,9 +14,17 @@ import (
 	libmpv "github.com/frizinak/libym/backend/mpv/lib"
 	rpcmpv "github.com/frizinak/libym/backend/mpv/rpc"
 	"github.com/frizinak/libym/collection"
+	"github.com/frizinak/libym/jobs"
+	"github.com/frizinak/libym/lyrics"
+	"github.com/frizinak/libym/mix"
 	"github.com/frizinak/libym/player"
+	"github.com/frizinak/libym/proc"
+	"github.com/frizinak/libym/serve"
+	"github.com/frizinak/libym/stats"
+	"github.com/frizinak/libym/subsonic"
 	"github.com/frizinak/libym/ui"
 	"github.com/frizinak/libym/ui/base"
+	"github.com/frizinak/libym/youtube"
 )
 
 type Config struct {
@@ -25,20 +34,88 @@ type Config struct {
 	// Defaults to 8
 	ConcurrentDownloads int
 
-	// Defaults to ~/.cache/ym
+	// StorePath holds downloads and other cached data.
+	// Defaults to DefaultStorePath.
 	StorePath string
 
+	// DataPath holds persistent data: the database, play log, player
+	// position and last ui view. Defaults to StorePath if that is set,
+	// DefaultDataPath otherwise, in which case data found in the default
+	// StorePath is migrated, see MigrateStore.
+	DataPath string
+
+	// Portable keeps all data in PortableDir next to the executable,
+	// overriding StorePath and DataPath, so the entire library can be
+	// moved between machines, e.g.: on a USB drive.
+	// Nothing stored in the library refers to its own location.
+	Portable bool
+
+	// PortableDir is the directory, relative to the executable, used in
+	// portable mode. Defaults to DefaultPortableDir.
+	PortableDir string
+
+	// Ephemeral keeps the collection in memory, e.g.: for bots and demos.
+	// StorePath, DataPath and Portable are ignored, downloads and other
+	// data are written to a temporary directory that is removed when the
+	// collection is closed. See collection.NewEphemeral.
+	Ephemeral bool
+
 	// Default to ~/.cache/ym/mpv-ipc.sock if not compiled with libmpv
 	SocketPath string
 
 	// Extra mpv flags when using RPC
 	MPVFlags []string
 
+	// MPVLimits are the resource limits of the mpv process when using RPC,
+	// the libmpv backend runs in this process. See proc.Limits.
+	MPVLimits proc.Limits
+
+	// FFmpegLimits are the resource limits of the ffmpeg processes used for
+	// downloads, silence trimming and exports, e.g.: Nice 19 and a cpu
+	// not used by mpv on single-board computers. See proc.Limits.
+	FFmpegLimits proc.Limits
+
+	// Buffer tunes read-ahead and network timeouts of streams, e.g.: a
+	// larger cache on slow connections. See player.BufferConfig.
+	Buffer player.BufferConfig
+
 	// Defaults to os.Stderr
 	BackendLogger io.Writer
 
+	// Serve plays local songs through urls of an http server instead of
+	// their path, for backends that run on another machine, e.g.: an mpv
+	// instance on a media center. See serve.Backend.
+	// Defaults to nil, i.e.: songs are played from their path.
+	Serve *Serve
+
 	AutoSave bool
 
+	// MinFreeSpace pauses downloads while the volume of StorePath has less
+	// than the given amount of bytes available. 0 disables the check.
+	MinFreeSpace uint64
+
+	// ReadOnlyIfLocked opens the store read-only instead of panicking when
+	// another process is using it, see collection.LockReadOnly.
+	ReadOnlyIfLocked bool
+
+	// Save raw youtube responses that could not be parsed
+	// to <StorePath>/fixtures.
+	RecordFixtures bool
+
+	// Language is the preferred language of youtube titles, sent as
+	// Accept-Language, e.g.: "nl, en;q=0.8". The original titles are kept
+	// as well and available as %{original} in NameTemplate.
+	// Defaults to youtube's choice. See youtube.SetLanguage.
+	Language string
+
+	// Minimum time between two title lookups of the same song.
+	// Defaults to collection.DefaultTitleTTL
+	TitleTTL time.Duration
+
+	// Minimum time between a failed title lookup and the next attempt.
+	// Defaults to collection.DefaultTitleRetry
+	TitleRetry time.Duration
+
 	// Mutually exclusive with CustomOutput.
 	SimpleOutput io.Writer
 
@@ -47,14 +124,175 @@ type Config struct {
 
 	CustomError ui.ErrorReporter
 
+	// Reporters also receive all errors of at least their Min severity,
+	// besides CustomError or the default reporter, e.g.: a log file or a
+	// notification hook. See ui.MultiReporter.
+	Reporters []Reporter
+
 	// Ratelimit ratelimits youtube.com calls by pulling items for the given
 	// channels before each action.
 	// If nil, a default ratelimiter of 1 item every 5 seconds is used for each.
 	RatelimitDownloads <-chan struct{}
 	RatelimitMeta      <-chan struct{}
 
+	// TaskLimits overrides ConcurrentDownloads and the ratelimits above
+	// per song namespace, e.g.: collection.NSYoutube.
+	TaskLimits map[string]collection.TaskLimits
+
 	// AcoustID config
 	AcoustID acoustid.Config
+
+	// JobLimits limits the amount of concurrently running jobs per kind,
+	// excess jobs are queued.
+	// Defaults to DefaultJobLimits, set a limit <= 0 for no limit.
+	JobLimits map[jobs.Kind]int
+
+	// Schedules are commands that are run periodically.
+	Schedules []Schedule
+
+	// SeekStep is the step of the ff and rw commands.
+	// Defaults to base.DefaultSeekStep.
+	SeekStep time.Duration
+
+	// Fade is the duration of volume fades on pause, resume and manual
+	// next/prev, e.g.: 300ms. Defaults to 0, i.e.: no fading.
+	Fade time.Duration
+
+	// Subsonic enables songs from a Subsonic server, e.g.: Navidrome.
+	Subsonic *subsonic.Config
+
+	// SubsonicCache downloads Subsonic songs like all others instead of
+	// only streaming them.
+	SubsonicCache bool
+
+	// TrimSilence trims leading and trailing silence from downloads
+	// using ffmpeg, see collection.SilenceTrim.
+	// Defaults to nil, i.e.: no trimming.
+	TrimSilence *collection.SilenceTrim
+
+	// TitleCleaner strips noise from titles on every title update and
+	// when running cleanup-titles,
+	// e.g.: collection.NewDefaultTitleCleaner().
+	// Defaults to nil, i.e.: titles are kept as is.
+	TitleCleaner *collection.TitleCleaner
+
+	// Lyrics is where the lyrics fetch command looks up lyrics,
+	// e.g.: lyrics.NewLRCLib("").
+	// Defaults to nil, i.e.: lyrics can only be loaded from .lrc files.
+	Lyrics lyrics.Fetcher
+
+	// NameTemplate names files written outside of the store, e.g.: by
+	// exports or tagging. See collection.NameTemplate for the syntax.
+	// Defaults to collection.DefaultNameTemplate.
+	NameTemplate string
+
+	// ExportProfiles are the transcode profiles selectable in the export
+	// command, e.g.: export phone ~/phone.
+	// Defaults to collection.DefaultTranscodeProfiles.
+	ExportProfiles map[string]collection.TranscodeProfile
+
+	// DailyMix configures the daily command, which can be scheduled with
+	// Schedules, e.g.: {"daily", "0 6 * * *", "daily"}.
+	DailyMix collection.DailyMix
+
+	// Archive configures the archive command, which can be scheduled with
+	// Schedules, e.g.: {"archive", "@weekly", "archive"}.
+	// Defaults to nil, i.e.: the archive command is disabled.
+	Archive *collection.ArchivePolicy
+
+	// AutoMix enables beat-aligned transitions between downloaded songs
+	// of the given length in bars, 0 disables it. Requires aubio.
+	AutoMix int
+
+	// Commands are app-specific commands added to the parser and base UI.
+	Commands []Command
+
+	// Startup are commands run once the base UI is created, so headless
+	// deployments resume a sensible state, e.g.: "queue favorites; shuffle; play".
+	// Errors are reported but do not stop the remaining entries.
+	Startup []string
+
+	// IdleMaintenance only starts heavy jobs while playback is paused
+	// so they don't cause audio stutter on weak devices.
+	IdleMaintenance bool
+
+	// IdleKinds are the job kinds held back by IdleMaintenance.
+	// Defaults to DefaultIdleKinds.
+	IdleKinds []jobs.Kind
+
+	// OnEnd is what happens at the end of the queue: stop, repeat, radio,
+	// shuffle or hook. Defaults to stop. See base.EndMode.
+	OnEnd string
+
+	// EndHook is called at the end of the queue when OnEnd is hook,
+	// e.g.: to queue songs from elsewhere.
+	EndHook func() error
+
+	// Reload re-reads the configuration, e.g.: from a config file.
+	// If set, the reload command and SIGHUP apply the settings that can
+	// change without restarting playback, see DI.Reload.
+	Reload func() (Config, error)
+}
+
+// DefaultIdleKinds are the job kinds considered heavy when
+// Config.IdleMaintenance is enabled.
+var DefaultIdleKinds = []jobs.Kind{
+	jobs.KindDownload,
+	jobs.KindIdentify,
+	jobs.KindMaintenance,
+	jobs.KindExport,
+}
+
+// Reporter is an additional destination for errors, see Config.Reporters.
+type Reporter struct {
+	ui.ErrorReporter
+	Min ui.Severity
+}
+
+// Serve configures the http server of Config.Serve, see serve.New.
+type Serve struct {
+	// Addr is the listen address, e.g.: ":8686".
+	Addr string
+	// Advertise is the host the backend can reach this machine on,
+	// e.g.: its lan ip. Defaults to the host of Addr.
+	Advertise string
+	// TTL is how long urls stay valid. Defaults to serve.DefaultTTL.
+	TTL time.Duration
+}
+
+// Schedule is a command that is run periodically, e.g.:
+// {"gc", "@weekly", "gc"} or {"mix", "0 4 * * *", "scrape mix https://..."}
+// see jobs.ParseSpec for the spec syntax.
+type Schedule struct {
+	Name    string
+	Spec    string
+	Command string
+}
+
+// Command is an app-specific command, e.g.:
+//
+//	{
+//		Aliases: []string{"hello"},
+//		Args:    ui.One,
+//		Help:    "say hello",
+//		Usage:   []string{"e.g.: hello world"},
+//		Handler: func(u *base.UI, cmd ui.Command) error { ... },
+//	}
+type Command struct {
+	Aliases []string
+	Args    ui.ArgAmount
+	Help    string
+	Usage   []string
+	// ArgHelp optionally describes the arguments, see ui.HelpEntry.
+	ArgHelp []ui.ArgHelp
+	Handler func(u *base.UI, cmd ui.Command) error
+}
+
+// DefaultJobLimits are the job concurrency limits used when
+// Config.JobLimits is nil.
+var DefaultJobLimits = map[jobs.Kind]int{
+	jobs.KindScrape:   2,
+	jobs.KindIdentify: 1,
 }
 
 // MakeRateLimit creates and starts a ratelimiter that can be used in Config.
@@ -91,6 +329,7 @@ type DI struct {
 	backends []BackendBuilder
 
 	store            string
+	data             string
 	log              *log.Logger
 	backend          Backend
 	backendName      string
@@ -100,9 +339,19 @@ type DI struct {
 	collection       *collection.Collection
 	baseUI           *base.UI
 	commandParser    *ui.CommandParser
+	commandTypes     []ui.CommandType
+	guestParser      *ui.CommandParser
+	nameTemplate     *collection.NameTemplate
 	acoustid         **acoustid.Client
+	jobs             *jobs.Manager
+	scheduler        *jobs.Scheduler
+	playLog          *stats.Log
+	playRecorder     *stats.Recorder
 	rlDownload       <-chan struct{}
 	rlMeta           <-chan struct{}
+	relays           [2]*ratelimit
+	hooks            *ui.MultiReporter
+	uiReporter       ui.ErrorReporter
 }
 
 func New(c Config) *DI {
@@ -117,10 +366,19 @@ func New(c Config) *DI {
 		{
 			Name: "mpv",
 			Build: func(di *DI, log *log.Logger) (Backend, error) {
+				if err := c.MPVLimits.Validate(); err != nil {
+					return nil, err
+				}
+				if c.SocketPath == "" && c.Portable {
+					// the portable store might be on a filesystem
+					// that does not support sockets.
+					sock := fmt.Sprintf("ym-%d-mpv-ipc.sock", os.Getpid())
+					return rpcmpv.NewLimited(log, filepath.Join(os.TempDir(), sock), di.MPVFlags(), c.MPVLimits), nil
+				}
 				if c.SocketPath == "" {
-					return rpcmpv.New(log, filepath.Join(di.Store(), "mpv-ipc.sock"), di.MPVFlags()), nil
+					return rpcmpv.NewLimited(log, filepath.Join(di.Store(), "mpv-ipc.sock"), di.MPVFlags(), c.MPVLimits), nil
 				}
-				return rpcmpv.New(log, c.SocketPath, di.MPVFlags()), nil
+				return rpcmpv.NewLimited(log, c.SocketPath, di.MPVFlags(), c.MPVLimits), nil
 			},
 		},
 	}
@@ -152,12 +410,20 @@ func (di *DI) Rates() (<-chan struct{}, <-chan struct{}) {
 		if di.rlDownload == nil {
 			di.rlDownload = MakeRatelimit(1, time.Second*5)
 		}
+		if di.c.Reload != nil {
+			di.relays[0] = newRatelimit(di.rlDownload)
+			di.rlDownload = di.relays[0].out
+		}
 	}
 	if di.rlMeta == nil {
 		di.rlMeta = di.c.RatelimitMeta
 		if di.rlMeta == nil {
 			di.rlMeta = MakeRatelimit(1, time.Second*5)
 		}
+		if di.c.Reload != nil {
+			di.relays[1] = newRatelimit(di.rlMeta)
+			di.rlMeta = di.relays[1].out
+		}
 	}
 
 	return di.rlDownload, di.rlMeta
@@ -196,13 +462,14 @@ func (di *DI) BaseUI() ui.UI {
 		if err == nil {
 			err = ui.NewLogErrorReporter(log.New(w, "UI ERR: ", 0))
 		}
+		err = di.reporter(err)
 
 		col := di.Collection()
 
 		dl, meta := di.Rates()
 		col.Run(dl, meta)
 
-		di.baseUI = base.New(
+		b := base.New(
 			output,
 			err,
 			di.CommandParser(),
@@ -210,7 +477,57 @@ func (di *DI) BaseUI() ui.UI {
 			col,
 			di.Queue(),
 			di.AcoustID(),
+			di.Jobs(),
+			di.Scheduler(),
+			di.PlayLog(),
 		)
+		if di.c.SeekStep > 0 {
+			b.SetSeekStep(di.c.SeekStep)
+		}
+		b.SetNameTemplate(di.NameTemplate())
+		b.SetLyricsFetcher(di.c.Lyrics)
+		b.SetDailyMix(di.c.DailyMix)
+		b.SetArchivePolicy(di.c.Archive)
+		b.SetEndHook(di.c.EndHook)
+		if di.c.OnEnd != "" {
+			m, e := base.ParseEndMode(di.c.OnEnd)
+			if e != nil {
+				panic(e)
+			}
+			b.SetEndMode(m)
+		}
+		if di.c.ExportProfiles != nil {
+			b.SetExportProfiles(di.c.ExportProfiles)
+		}
+		for i, cmd := range di.c.Commands {
+			h := cmd.Handler
+			b.SetHandler(di.commandTypes[i], func(cmd ui.Command) error { return h(b, cmd) })
+		}
+		b.SetHandler(ui.CmdReload, func(cmd ui.Command) error { return di.Reload() })
+		for _, sc := range di.c.Schedules {
+			if err := b.Schedule(sc.Name, sc.Spec, sc.Command); err != nil {
+				panic(err)
+			}
+		}
+		di.Scheduler().Start()
+		di.PlayRecorder().Start()
+		di.Player().WatchSleep(player.DefaultWakeInterval)
+		if !di.c.Ephemeral {
+			if e := b.SetSessionFile(filepath.Join(di.Data(), "ui-session")); e != nil {
+				err.Err(e)
+			}
+		}
+		di.baseUI = b
+		di.uiReporter = err
+		if di.c.Reload != nil {
+			di.reloadOnSignal(err)
+		}
+
+		for _, input := range di.c.Startup {
+			if e := b.Exec(input); e != nil {
+				err.Err(fmt.Errorf("startup '%s': %w", input, e))
+			}
+		}
 	}
 
 	return di.baseUI
@@ -222,6 +539,7 @@ func (di *DI) CommandParser() *ui.CommandParser {
 		di.commandParser.Alias(ui.CmdHelp, ui.Zero, nil, "h", "help")
 
 		di.commandParser.Alias(ui.CmdPauseToggle, ui.Zero, nil, "p", "pause")
+		di.commandParser.Alias(ui.CmdPlay, ui.Zero, nil, "play")
 
 		di.commandParser.Alias(ui.CmdSetSongIndex, ui.One, []string{"e.g.: p 10"}, "p", "play", "goto")
 		di.commandParser.Alias(ui.CmdNext, ui.Zero, nil, ">", "next", "skip")
@@ -234,9 +552,18 @@ func (di *DI) CommandParser() *ui.CommandParser {
 				"e.g.: seek 30:00 => seek to minute 30",
 				"e.g.: seek +05:00 => seek 5 minutes further",
 				"e.g.: seek -30 => seek 30 seconds back",
+				"e.g.: seek +5% => seek 5% of the song further",
+				"e.g.: seek 50% => seek to the middle of the song",
 			},
 			"seek",
 		)
+		di.commandParser.Describe(ui.CmdSeek, ui.One, ui.ArgHelp{Name: "position", Help: "[+|-]h:m:s, m:s, s or a percentage"})
+		di.commandParser.Alias(ui.CmdScrub, ui.One, []string{"e.g.: scrub 42.5"}, "scrub")
+		di.commandParser.Describe(ui.CmdScrub, ui.One, ui.ArgHelp{Name: "pct", Help: "percentage of the song from 0 to 100"})
+		di.commandParser.Alias(ui.CmdForward, ui.Zero, nil, "ff")
+		di.commandParser.Alias(ui.CmdForward, ui.One, []string{"e.g.: ff 3 => seek 3 steps forward"}, "ff")
+		di.commandParser.Alias(ui.CmdRewind, ui.Zero, nil, "rw")
+		di.commandParser.Alias(ui.CmdRewind, ui.One, []string{"e.g.: rw 3 => seek 3 steps back"}, "rw")
 
 		di.commandParser.Alias(
 			ui.CmdScrape,
@@ -251,6 +578,61 @@ func (di *DI) CommandParser() *ui.CommandParser {
 
 		di.commandParser.Alias(ui.CmdJobs, ui.Zero, nil, "jobs")
 		di.commandParser.Alias(ui.CmdCancelJob, ui.One, nil, "cancel")
+		di.commandParser.Alias(ui.CmdJobLog, ui.Two, []string{"e.g.: job log 3"}, "job")
+		di.commandParser.Alias(ui.CmdSchedules, ui.Zero, nil, "schedules")
+		di.commandParser.Alias(
+			ui.CmdSchedule,
+			ui.Varadic,
+			[]string{
+				"schedule add <name> <spec> <command>",
+				"schedule del <name>",
+				"schedule run <name>",
+				"e.g.: schedule add nightly 0 3 * * * scrape mix https://...",
+				"or:   schedule add cleanup @every 12h gc",
+			},
+			"schedule",
+		)
+		di.commandParser.Alias(ui.CmdVerify, ui.Zero, nil, "verify")
+		di.commandParser.Alias(ui.CmdRefreshTitles, ui.Zero, nil, "refresh-titles")
+		di.commandParser.Alias(ui.CmdGC, ui.Zero, nil, "gc")
+		di.commandParser.Alias(ui.CmdDownload, ui.One, []string{"e.g.: download 1-3"}, "download")
+		di.commandParser.Alias(ui.CmdBan, ui.One, []string{"e.g.: ban 1-3"}, "ban")
+		di.commandParser.Alias(ui.CmdUnban, ui.One, []string{"e.g.: unban 1-3"}, "unban")
+		di.commandParser.Alias(ui.CmdBanned, ui.Zero, nil, "banned")
+		di.commandParser.Alias(ui.CmdFill, ui.Two, []string{"e.g.: fill commute 45m"}, "fill")
+		di.commandParser.Describe(
+			ui.CmdFill,
+			ui.Two,
+			ui.ArgHelp{Name: "playlist", Help: "playlist to pick songs from"},
+			ui.ArgHelp{Name: "duration", Help: "total length of the picked songs, e.g.: 45m"},
+		)
+		di.commandParser.Alias(
+			ui.CmdFill,
+			ui.Varadic,
+			[]string{"favor higher rated and less recently played songs, e.g.: fill weighted commute 45m"},
+			"fill",
+		)
+		di.commandParser.Alias(ui.CmdSkips, ui.Zero, nil, "skips")
+		di.commandParser.Alias(
+			ui.CmdPlaylistPrefs,
+			ui.Varadic,
+			[]string{
+				"e.g.: plset liked shuffle on",
+				"e.g.: plset album repeat 2",
+				"keep sorted (manual, title or artist), e.g.: plset liked sort artist",
+			},
+			"plset",
+		)
+		di.commandParser.Describe(
+			ui.CmdPlaylistPrefs,
+			ui.Varadic,
+			ui.ArgHelp{Name: "playlist"},
+			ui.ArgHelp{Name: "setting", Help: "shuffle, repeat or sort"},
+			ui.ArgHelp{Name: "value", Help: "on|off, 0-255 or manual|title|artist"},
+		)
+		di.commandParser.Alias(ui.CmdStats, ui.Zero, nil, "stats")
+		di.commandParser.Alias(ui.CmdStats, ui.One, []string{"e.g.: stats week (day, week, month, year or all)", "or: stats <playlist> => song count, duration, downloads and top artists"}, "stats")
+		di.commandParser.Alias(ui.CmdStats, ui.Two, []string{"export as json, e.g.: stats month ./stats.json"}, "stats")
 
 		di.commandParser.Alias(ui.CmdConfirm, ui.One, nil, "y", "confirm")
 
@@ -271,12 +653,20 @@ func (di *DI) CommandParser() *ui.CommandParser {
 		di.commandParser.Alias(ui.CmdSongDelete, ui.One, []string{"see add"}, "del", "delete")
 
 		di.commandParser.Alias(ui.CmdVolume, ui.One, nil, "v", "volume")
+		di.commandParser.Describe(ui.CmdVolume, ui.One, ui.ArgHelp{Name: "change", Help: "relative change in percent, e.g.: -5"})
 
 		di.commandParser.Alias(ui.CmdSearch, ui.Varadic, nil, "s", "search")
 		di.commandParser.Alias(ui.CmdSearchOwn, ui.Varadic, nil, "/", "find")
 
 		di.commandParser.Alias(ui.CmdQueueClear, ui.Zero, nil, "clear")
-		di.commandParser.Alias(ui.CmdQueueShuffle, ui.Varadic, nil, "shuf", "shuffle")
+		di.commandParser.Alias(ui.CmdUnqueue, ui.Varadic, []string{"e.g.: unqueue liked"}, "unqueue")
+		di.commandParser.Alias(
+			ui.CmdQueueShuffle,
+			ui.Varadic,
+			[]string{"e.g.: shuf weighted (favor higher rated and less recently played songs)"},
+			"shuf",
+			"shuffle",
+		)
 		di.commandParser.Alias(ui.CmdQueue, ui.One, []string{"see add"}, "q", "queue")
 		di.commandParser.Alias(
 			ui.CmdQueueAfter,
@@ -289,6 +679,12 @@ func (di *DI) CommandParser() *ui.CommandParser {
 			"q",
 			"queue",
 		)
+		di.commandParser.Describe(
+			ui.CmdQueueAfter,
+			ui.Two,
+			ui.ArgHelp{Name: "index", Help: "queue index or next"},
+			ui.ArgHelp{Name: "songs", Help: "range of songs in the current view"},
+		)
 		di.commandParser.Alias(ui.CmdViewQueue, ui.Zero, nil, "q", "queue")
 
 		di.commandParser.Alias(ui.CmdMove, ui.Two, nil, "mv", "move")
@@ -296,11 +692,194 @@ func (di *DI) CommandParser() *ui.CommandParser {
 		di.commandParser.Alias(ui.CmdViewPlaylist, ui.One, nil, "ls", "playlist")
 		di.commandParser.Alias(ui.CmdViewPlaylists, ui.Zero, nil, "ls", "playlists")
 		di.commandParser.Alias(ui.CmdProblematics, ui.Zero, nil, "problems", "problematics")
+		di.commandParser.Alias(ui.CmdBack, ui.Zero, nil, "back")
+		di.commandParser.Alias(ui.CmdPane, ui.Zero, nil, "swap", "pane")
+		di.commandParser.Alias(ui.CmdPane, ui.One, []string{"e.g.: pane 2"}, "pane")
+		di.commandParser.Alias(ui.CmdCopy, ui.One, []string{"e.g.: copy 1-3"}, "copy")
+		di.commandParser.Alias(ui.CmdFav, ui.Zero, nil, "fav")
+		di.commandParser.Alias(ui.CmdFav, ui.One, []string{"e.g.: fav 1-3"}, "fav")
+		di.commandParser.Alias(ui.CmdUnfav, ui.Zero, nil, "unfav")
+		di.commandParser.Alias(ui.CmdUnfav, ui.One, []string{"e.g.: unfav 1-3"}, "unfav")
+		di.commandParser.Alias(ui.CmdFavs, ui.Zero, nil, "favs")
+		di.commandParser.Alias(ui.CmdMark, ui.One, []string{"mark the current position, e.g.: mark drop"}, "mark")
+		di.commandParser.Alias(ui.CmdMark, ui.Two, []string{"e.g.: mark drop 01:23"}, "mark")
+		di.commandParser.Alias(ui.CmdUnmark, ui.One, []string{"e.g.: unmark drop"}, "unmark")
+		di.commandParser.Alias(ui.CmdMarkers, ui.Zero, nil, "marks", "markers")
+		di.commandParser.Alias(ui.CmdAlt, ui.Two, []string{"e.g.: alt 3 https://youtu.be/<id>"}, "alt")
+		di.commandParser.Alias(ui.CmdUnalt, ui.Two, []string{"e.g.: unalt 3 <id>"}, "unalt")
+		di.commandParser.Alias(ui.CmdExport, ui.One, []string{"e.g.: export /srv/music/ym"}, "export")
+		di.commandParser.Alias(
+			ui.CmdExport,
+			ui.Two,
+			[]string{"transcode using a profile, e.g.: export phone ~/phone"},
+			"export",
+		)
+		di.commandParser.Describe(
+			ui.CmdExport,
+			ui.Two,
+			ui.ArgHelp{Name: "profile", Help: "transcode profile, see Config.ExportProfiles"},
+			ui.ArgHelp{Name: "dir", Help: "destination directory"},
+		)
+		di.commandParser.Alias(ui.CmdSubsonic, ui.Varadic, []string{"e.g.: sub daft punk"}, "sub")
+		di.commandParser.Alias(ui.CmdSubPlaylists, ui.Zero, nil, "subpl")
+		di.commandParser.Alias(ui.CmdSubPlaylists, ui.Varadic, []string{"by number or name, e.g.: subpl 2"}, "subpl")
+		di.commandParser.Alias(ui.CmdShare, ui.Zero, nil, "share")
+		di.commandParser.Alias(ui.CmdShare, ui.Varadic, []string{"e.g.: share md chill"}, "share")
+		di.commandParser.Alias(ui.CmdCleanupTitles, ui.Zero, nil, "cleanup-titles")
+		di.commandParser.Alias(ui.CmdDuplicates, ui.One, []string{"add, skip or replace all, e.g.: dup skip"}, "dup")
+		di.commandParser.Alias(ui.CmdDuplicates, ui.Two, []string{"e.g.: dup replace 1-3"}, "dup")
+		di.commandParser.Alias(
+			ui.CmdImport,
+			ui.Varadic,
+			[]string{
+				"append, replace or union (skips songs already in the playlist), e.g.: import union liked",
+				"preview the changes, e.g.: import replace liked dry",
+			},
+			"import",
+		)
+		di.commandParser.Describe(
+			ui.CmdImport,
+			ui.Varadic,
+			ui.ArgHelp{Name: "strategy", Help: "append, replace or union"},
+			ui.ArgHelp{Name: "playlist"},
+			ui.ArgHelp{Name: "dry", Help: "only show what would change", Optional: true},
+		)
+		di.commandParser.Alias(ui.CmdLyrics, ui.Zero, nil, "lyrics")
+		di.commandParser.Alias(ui.CmdLyrics, ui.Varadic, []string{"fetch, clear or a path, e.g.: lyrics ~/song.lrc"}, "lyrics")
+		di.commandParser.Alias(ui.CmdPin, ui.Zero, nil, "pin")
+		di.commandParser.Alias(ui.CmdPin, ui.One, []string{"e.g.: pin 1-3"}, "pin")
+		di.commandParser.Alias(ui.CmdUnpin, ui.Zero, nil, "unpin")
+		di.commandParser.Alias(ui.CmdUnpin, ui.One, []string{"e.g.: unpin 1-3"}, "unpin")
+		di.commandParser.Alias(ui.CmdDaily, ui.Zero, nil, "daily")
+		di.commandParser.Alias(
+			ui.CmdDaily,
+			ui.One,
+			[]string{"e.g.: daily 50", "or schedule it: schedule add daily @daily daily"},
+			"daily",
+		)
+		di.commandParser.Alias(ui.CmdCheck, ui.One, []string{"e.g.: check old-mix"}, "check")
+		di.commandParser.Alias(ui.CmdDead, ui.One, []string{"remove or resolve all, e.g.: dead resolve"}, "dead")
+		di.commandParser.Alias(ui.CmdDead, ui.Two, []string{"e.g.: dead remove 1-3"}, "dead")
+		di.commandParser.Alias(
+			ui.CmdArchive,
+			ui.Zero,
+			[]string{"requires Config.Archive, schedule it: schedule add archive @weekly archive"},
+			"archive",
+		)
+		di.commandParser.Alias(
+			ui.CmdRetitle,
+			ui.Varadic,
+			[]string{
+				"all songs, e.g.: retitle s/ - free download$//i",
+				"a single playlist, e.g.: retitle s/^(.*) by (.*)$/\\2 - \\1/ scraped",
+				"preview the changes in the job log, e.g.: retitle s/ \\[hd\\]$// dry",
+			},
+			"retitle",
+		)
+		di.commandParser.Describe(
+			ui.CmdRetitle,
+			ui.Varadic,
+			ui.ArgHelp{Name: "s/pattern/replacement/", Help: "flags: i for case insensitive"},
+			ui.ArgHelp{Name: "playlist", Optional: true},
+			ui.ArgHelp{Name: "dry", Help: "only log what would change", Optional: true},
+		)
+		di.commandParser.Alias(
+			ui.CmdOnEnd,
+			ui.One,
+			[]string{"stop, repeat, radio, shuffle or hook, e.g.: onend radio"},
+			"onend",
+		)
+		di.commandParser.Alias(ui.CmdReload, ui.Zero, []string{"requires Config.Reload, also triggered by SIGHUP"}, "reload")
+		di.commandParser.Alias(ui.CmdScroll, ui.One, []string{"sent by frontends, e.g.: scroll 40"}, "scroll")
+
+		for _, cmd := range di.c.Commands {
+			t := ui.NewCommandType(cmd.Help)
+			di.commandTypes = append(di.commandTypes, t)
+			di.commandParser.Alias(t, cmd.Args, cmd.Usage, cmd.Aliases...)
+			if len(cmd.ArgHelp) != 0 {
+				di.commandParser.Describe(t, cmd.Args, cmd.ArgHelp...)
+			}
+		}
 	}
 
 	return di.commandParser
 }
 
+// GuestDenied are the commands a GuestCommandParser does not know about:
+// everything that creates, deletes or modifies playlists, songs, downloads
+// or jobs.
+var GuestDenied = []ui.CommandType{
+	ui.CmdPlaylistAdd,
+	ui.CmdPlaylistDelete,
+	ui.CmdSongAdd,
+	ui.CmdSongDelete,
+	ui.CmdMove,
+	ui.CmdScrape,
+	ui.CmdCancelJob,
+	ui.CmdMeta,
+	ui.CmdConfirm,
+	ui.CmdSchedule,
+	ui.CmdVerify,
+	ui.CmdRefreshTitles,
+	ui.CmdGC,
+	ui.CmdDownload,
+	ui.CmdBan,
+	ui.CmdUnban,
+	ui.CmdPlaylistPrefs,
+	ui.CmdCopy,
+	ui.CmdFav,
+	ui.CmdUnfav,
+	ui.CmdMark,
+	ui.CmdUnmark,
+	ui.CmdAlt,
+	ui.CmdUnalt,
+	ui.CmdExport,
+	ui.CmdCleanupTitles,
+	ui.CmdDuplicates,
+	ui.CmdLyrics,
+	ui.CmdPin,
+	ui.CmdUnpin,
+	ui.CmdDaily,
+	ui.CmdCheck,
+	ui.CmdDead,
+	ui.CmdImport,
+	ui.CmdArchive,
+	ui.CmdReload,
+	ui.CmdOnEnd,
+	ui.CmdRetitle,
+}
+
+// GuestCommandParser is a read-only variant of CommandParser for kiosk or
+// guest frontends sharing the same BaseUI, it leaves out GuestDenied and all
+// Config.Commands.
+func (di *DI) GuestCommandParser() *ui.CommandParser {
+	if di.guestParser == nil {
+		p := di.CommandParser()
+		deny := append(append([]ui.CommandType{}, GuestDenied...), di.commandTypes...)
+		di.guestParser = p.Without(deny...)
+	}
+
+	return di.guestParser
+}
+
+// NameTemplate is the parsed Config.NameTemplate shared by everything that
+// names files outside of the store.
+func (di *DI) NameTemplate() *collection.NameTemplate {
+	if di.nameTemplate == nil {
+		tpl := di.c.NameTemplate
+		if tpl == "" {
+			tpl = collection.DefaultNameTemplate
+		}
+		t, err := collection.ParseNameTemplate(tpl)
+		if err != nil {
+			panic(err)
+		}
+		di.nameTemplate = t
+	}
+
+	return di.nameTemplate
+}
+
 func (di *DI) Log() *log.Logger {
 	if di.log == nil {
 		di.log = di.c.Log
@@ -314,21 +893,62 @@ func (di *DI) Log() *log.Logger {
 
 func (di *DI) Store() string {
 	if di.store == "" {
+		if di.c.Ephemeral {
+			// the temporary directory of the collection.
+			di.store = di.Collection().Dir()
+			return di.store
+		}
+		if di.c.Portable {
+			dir, err := PortablePath(di.c.PortableDir)
+			if err != nil {
+				panic(err)
+			}
+			di.store = dir
+			return di.store
+		}
 		if di.c.StorePath != "" {
 			di.store = di.c.StorePath
 			return di.store
 		}
 
-		cache, err := os.UserCacheDir()
+		store, err := DefaultStorePath()
 		if err != nil {
 			panic(err)
 		}
-		di.store = filepath.Join(cache, "ym")
+		di.store = store
 	}
 
 	return di.store
 }
 
+func (di *DI) Data() string {
+	if di.data == "" {
+		if di.c.Portable || di.c.Ephemeral {
+			di.data = di.Store()
+			return di.data
+		}
+		if di.c.DataPath != "" {
+			di.data = di.c.DataPath
+			return di.data
+		}
+		if di.c.StorePath != "" {
+			di.data = di.c.StorePath
+			return di.data
+		}
+
+		data, err := DefaultDataPath()
+		if err != nil {
+			panic(err)
+		}
+		di.data = data
+		if err := MigrateStore(di.Store(), di.data); err != nil {
+			di.Log().Println("ERR", "migrating store:", err)
+		}
+	}
+
+	return di.data
+}
+
 func (di *DI) BackendAvailable() (string, error) {
 	di.Backend()
 	return di.backendName, di.backendAvailable
@@ -357,6 +977,22 @@ func (di *DI) Backend() Backend {
 				continue
 			}
 
+			if c := di.c.Serve; c != nil {
+				srv, err := serve.New(c.Addr, c.Advertise, c.TTL)
+				if err != nil {
+					l.Println(err)
+					be.Close()
+					di.backendAvailable = err
+					continue
+				}
+				go func() {
+					if err := srv.Serve(); err != nil {
+						l.Println(err)
+					}
+				}()
+				be = serve.Backend(be, srv).(Backend)
+			}
+
 			di.backend = be
 			di.backendAvailable = nil
 			break
@@ -366,6 +1002,71 @@ func (di *DI) Backend() Backend {
 	return di.backend
 }
 
+func (di *DI) Jobs() *jobs.Manager {
+	if di.jobs == nil {
+		di.jobs = jobs.NewManager()
+		limits := di.c.JobLimits
+		if limits == nil {
+			limits = DefaultJobLimits
+		}
+		for kind, n := range limits {
+			di.jobs.SetLimit(kind, n)
+		}
+		if di.c.IdleMaintenance {
+			kinds := di.c.IdleKinds
+			if kinds == nil {
+				kinds = DefaultIdleKinds
+			}
+			di.jobs.SetIdlePolicy(func() bool { return di.Player().Paused() }, kinds...)
+		}
+	}
+	return di.jobs
+}
+
+func (di *DI) Scheduler() *jobs.Scheduler {
+	if di.scheduler == nil {
+		di.scheduler = jobs.NewScheduler(di.Jobs())
+	}
+	return di.scheduler
+}
+
+// reporter adds Config.Reporters to r.
+func (di *DI) reporter(r ui.ErrorReporter) ui.ErrorReporter {
+	if len(di.c.Reporters) == 0 && di.c.Reload == nil {
+		return r
+	}
+	if di.hooks == nil {
+		di.hooks = ui.NewMultiReporter()
+		for _, rep := range di.c.Reporters {
+			di.hooks.Add(rep.ErrorReporter, rep.Min)
+		}
+	}
+	return ui.NewMultiReporter().Add(r, ui.SeverityInfo).Add(di.hooks, ui.SeverityInfo)
+}
+
+// PlayLog is the log of played songs at <DataPath>/plays.jsonl.
+func (di *DI) PlayLog() *stats.Log {
+	if di.playLog == nil {
+		di.playLog = stats.NewLog(filepath.Join(di.Data(), "plays.jsonl"))
+	}
+	return di.playLog
+}
+
+// PlayRecorder records the songs played and skipped by Player to PlayLog.
+// It is started by BaseUI, Close it before exiting to log the current song.
+func (di *DI) PlayRecorder() *stats.Recorder {
+	if di.playRecorder == nil {
+		err := di.c.CustomError
+		if err == nil {
+			err = ui.NewLogErrorReporter(di.Log())
+		}
+		err = di.reporter(err)
+		di.playRecorder = stats.NewRecorder(di.PlayLog(), di.Player(), err)
+		di.Player().OnSkip(di.playRecorder.Skip)
+	}
+	return di.playRecorder
+}
+
 func (di *DI) Queue() *collection.Queue {
 	if di.queue == nil {
 		di.queue = collection.NewQueue()
@@ -383,9 +1084,23 @@ func (di *DI) Player() *player.Player {
 		if err == nil {
 			err = ui.NewLogErrorReporter(log.New(w, "PLAYER ERR: ", 0))
 		}
+		err = di.reporter(err)
 
-		store := filepath.Join(di.Store(), "player-position")
+		store := filepath.Join(di.Data(), "player-position")
 		di.player = player.NewPlayer(di.Backend(), err, di.Queue(), store)
+		di.player.SetFade(di.c.Fade)
+		if di.c.Buffer != (player.BufferConfig{}) {
+			if e := di.player.SetBufferConfig(di.c.Buffer); e != nil {
+				err.Err(e)
+			}
+		}
+		if di.c.AutoMix > 0 {
+			di.player.SetMixer(mix.New(di.c.AutoMix))
+		}
+		c := di.Collection()
+		di.player.SetAlternatives(func(s collection.Song) []collection.Song {
+			return c.Alternatives(s)
+		})
 	}
 	return di.player
 }
@@ -397,7 +1112,47 @@ func (di *DI) Collection() *collection.Collection {
 		if n <= 0 {
 			n = 8
 		}
-		di.collection = collection.New(l, di.Store(), di.Queue(), n, di.c.AutoSave)
+		youtube.SetLanguage(di.c.Language)
+		if err := di.c.FFmpegLimits.Validate(); err != nil {
+			panic(err)
+		}
+		collection.SetFFmpegLimits(di.c.FFmpegLimits)
+		if di.c.Ephemeral {
+			c, err := collection.NewEphemeral(l, di.Queue(), n)
+			if err != nil {
+				panic(err)
+			}
+			di.collection = c
+		} else {
+			di.collection = collection.New(l, di.Store(), di.Queue(), n, di.c.AutoSave)
+			di.collection.SetDBDir(di.Data())
+		}
+		if di.c.RecordFixtures {
+			youtube.SetFixtureDir(filepath.Join(di.Store(), "fixtures"))
+		}
+		di.collection.SetTrimSilence(di.c.TrimSilence)
+		di.collection.SetTitleCleaner(di.c.TitleCleaner)
+		di.collection.SetMinFreeSpace(di.c.MinFreeSpace)
+		if di.c.ReadOnlyIfLocked {
+			di.collection.SetLockMode(collection.LockReadOnly)
+		}
+		for ns, l := range di.c.TaskLimits {
+			di.collection.SetTaskLimits(ns, l)
+		}
+		if di.c.Subsonic != nil {
+			client, err := subsonic.New(*di.c.Subsonic)
+			if err != nil {
+				panic(err)
+			}
+			di.collection.SetSubsonic(client, di.c.SubsonicCache)
+		}
+		di.collection.SetJobs(di.Jobs())
+		if di.c.TitleTTL > 0 {
+			di.collection.SetTitleTTL(di.c.TitleTTL)
+		}
+		if di.c.TitleRetry > 0 {
+			di.collection.SetTitleRetry(di.c.TitleRetry)
+		}
 		if err := di.collection.Init(); err != nil {
 			panic(err)
 		}
@@ -0,0 +1,97 @@
+package di
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// persistent are the files that were stored in the single store directory
+// before downloads and data were split.
+var persistent = []string{"db", "plays.jsonl", "player-position"}
+
+// DefaultPortableDir is the directory next to the executable that holds
+// all data in portable mode.
+const DefaultPortableDir = "ym-data"
+
+// PortablePath resolves dir relative to the directory of the executable.
+// An empty dir resolves to DefaultPortableDir.
+func PortablePath(dir string) (string, error) {
+	if dir == "" {
+		dir = DefaultPortableDir
+	}
+	if filepath.IsAbs(dir) {
+		return "", errors.New("portable dir must be relative to the executable")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(exe), dir), nil
+}
+
+// DefaultStorePath returns the per-OS directory for cached data, i.e.:
+// downloads. ~/.cache/ym on linux, ~/Library/Caches/ym on macOS and
+// %LocalAppData%\ym on windows.
+func DefaultStorePath() (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cache, "ym"), nil
+}
+
+// DefaultDataPath returns the per-OS directory for persistent data, i.e.:
+// the database and play log. $XDG_DATA_HOME/ym or ~/.local/share/ym on linux,
+// ~/Library/Application Support/ym on macOS and %AppData%\ym on windows.
+func DefaultDataPath() (string, error) {
+	switch runtime.GOOS {
+	case "windows", "darwin", "ios", "plan9":
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, "ym"), nil
+	}
+
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "ym"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	if home == "" {
+		return "", errors.New("neither $XDG_DATA_HOME nor $HOME are defined")
+	}
+	return filepath.Join(home, ".local", "share", "ym"), nil
+}
+
+// MigrateStore moves the database, play log and player position from the
+// old single store directory to the data directory. Files that already
+// exist in the data directory are left alone.
+func MigrateStore(from, to string) error {
+	if from == to {
+		return nil
+	}
+	for _, f := range persistent {
+		src, dst := filepath.Join(from, f), filepath.Join(to, f)
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		if _, err := os.Stat(dst); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(to, 0o755); err != nil {
+			return err
+		}
+		if err := os.Rename(src, dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package di
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/frizinak/libym/collection"
+	"github.com/frizinak/libym/jobs"
+	"github.com/frizinak/libym/player"
+	"github.com/frizinak/libym/ui"
+	"github.com/frizinak/libym/ui/base"
+	"github.com/frizinak/libym/youtube"
+)
+
+// Reload re-reads the configuration with Config.Reload and applies the
+// settings that can be changed without restarting playback:
+// the ratelimits, Language, FFmpegLimits, NameTemplate, Reporters, Schedules,
+// JobLimits, Fade, Buffer and the settings of the base UI (SeekStep, Lyrics,
+// DailyMix, Archive and ExportProfiles).
+// All other fields are ignored until the next start.
+func (di *DI) Reload() error {
+	if di.c.Reload == nil {
+		return fmt.Errorf("reload: not configured")
+	}
+	c, err := di.c.Reload()
+	if err != nil {
+		return fmt.Errorf("reload: %w", err)
+	}
+
+	// validate everything before applying anything.
+	tpl := c.NameTemplate
+	if tpl == "" {
+		tpl = collection.DefaultNameTemplate
+	}
+	names, err := collection.ParseNameTemplate(tpl)
+	if err != nil {
+		return fmt.Errorf("reload: %w", err)
+	}
+	for _, sc := range c.Schedules {
+		if _, err := jobs.ParseSpec(sc.Spec); err != nil {
+			return fmt.Errorf("reload: schedule %s: %w", sc.Name, err)
+		}
+	}
+	if err := c.FFmpegLimits.Validate(); err != nil {
+		return fmt.Errorf("reload: %w", err)
+	}
+
+	old := di.c
+	di.c.NameTemplate, di.nameTemplate = c.NameTemplate, names
+	di.c.Reporters = c.Reporters
+	di.c.Schedules = c.Schedules
+	di.c.JobLimits = c.JobLimits
+	di.c.Fade, di.c.Buffer = c.Fade, c.Buffer
+	di.c.SeekStep, di.c.Lyrics = c.SeekStep, c.Lyrics
+	di.c.DailyMix, di.c.Archive = c.DailyMix, c.Archive
+	di.c.ExportProfiles = c.ExportProfiles
+
+	di.reloadRates(old, c)
+
+	di.c.Language = c.Language
+	youtube.SetLanguage(c.Language)
+	di.c.FFmpegLimits = c.FFmpegLimits
+	collection.SetFFmpegLimits(c.FFmpegLimits)
+
+	if di.hooks != nil {
+		di.hooks.Reset()
+		for _, rep := range c.Reporters {
+			di.hooks.Add(rep.ErrorReporter, rep.Min)
+		}
+	}
+
+	if di.jobs != nil {
+		limits := c.JobLimits
+		if limits == nil {
+			limits = DefaultJobLimits
+		}
+		for kind := range jobLimits(old) {
+			if _, ok := limits[kind]; !ok {
+				di.jobs.SetLimit(kind, 0)
+			}
+		}
+		for kind, n := range limits {
+			di.jobs.SetLimit(kind, n)
+		}
+	}
+
+	if di.player != nil {
+		di.player.SetFade(c.Fade)
+		if c.Buffer != old.Buffer && c.Buffer != (player.BufferConfig{}) {
+			if err := di.player.SetBufferConfig(c.Buffer); err != nil {
+				return fmt.Errorf("reload: %w", err)
+			}
+		}
+	}
+
+	b := di.baseUI
+	if b == nil {
+		return nil
+	}
+	step := c.SeekStep
+	if step <= 0 {
+		step = base.DefaultSeekStep
+	}
+	b.SetSeekStep(step)
+	b.SetNameTemplate(names)
+	b.SetLyricsFetcher(c.Lyrics)
+	b.SetDailyMix(c.DailyMix)
+	b.SetArchivePolicy(c.Archive)
+	profiles := c.ExportProfiles
+	if profiles == nil {
+		profiles = collection.DefaultTranscodeProfiles
+	}
+	b.SetExportProfiles(profiles)
+
+	sched := di.Scheduler()
+	for _, sc := range old.Schedules {
+		sched.Remove(sc.Name)
+	}
+	for _, sc := range c.Schedules {
+		if err := b.Schedule(sc.Name, sc.Spec, sc.Command); err != nil {
+			return fmt.Errorf("reload: %w", err)
+		}
+	}
+
+	if n, ok := di.uiReporter.(ui.Notifier); ok {
+		n.Notify("configuration reloaded")
+	}
+	return nil
+}
+
+func jobLimits(c Config) map[jobs.Kind]int {
+	if c.JobLimits == nil {
+		return DefaultJobLimits
+	}
+	return c.JobLimits
+}
+
+func (di *DI) reloadRates(old, c Config) {
+	set := func(r *ratelimit, prev, src <-chan struct{}) {
+		if r == nil || src == prev {
+			return
+		}
+		if src == nil {
+			src = MakeRatelimit(1, time.Second*5)
+		}
+		r.set(src)
+	}
+	set(di.relays[0], old.RatelimitDownloads, c.RatelimitDownloads)
+	set(di.relays[1], old.RatelimitMeta, c.RatelimitMeta)
+	di.c.RatelimitDownloads, di.c.RatelimitMeta = c.RatelimitDownloads, c.RatelimitMeta
+}
+
+// ratelimit relays tokens from a source ratelimiter that can be replaced
+// while the collection is pulling from out.
+type ratelimit struct {
+	sem  sync.Mutex
+	src  <-chan struct{}
+	swap chan struct{}
+	out  chan struct{}
+}
+
+func newRatelimit(src <-chan struct{}) *ratelimit {
+	r := &ratelimit{src: src, swap: make(chan struct{}, 1), out: make(chan struct{})}
+	go r.run()
+	return r
+}
+
+func (r *ratelimit) set(src <-chan struct{}) {
+	r.sem.Lock()
+	r.src = src
+	r.sem.Unlock()
+	select {
+	case r.swap <- struct{}{}:
+	default:
+	}
+}
+
+func (r *ratelimit) run() {
+	for {
+		r.sem.Lock()
+		src := r.src
+		r.sem.Unlock()
+		select {
+		case <-src:
+			r.out <- struct{}{}
+		case <-r.swap:
+		}
+	}
+}
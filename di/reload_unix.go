@@ -0,0 +1,24 @@
+// +build !windows
+
+package di
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/frizinak/libym/ui"
+)
+
+// reloadOnSignal calls Reload on every SIGHUP.
+func (di *DI) reloadOnSignal(err ui.ErrorReporter) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for range ch {
+			if e := di.baseUI.Exec("reload"); e != nil {
+				err.Err(e)
+			}
+		}
+	}()
+}
@@ -0,0 +1,8 @@
+// +build windows
+
+package di
+
+import "github.com/frizinak/libym/ui"
+
+// reloadOnSignal is a noop, windows has no SIGHUP.
+func (di *DI) reloadOnSignal(err ui.ErrorReporter) {}
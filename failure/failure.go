@@ -0,0 +1,118 @@
+// Package failure provides a small taxonomy of the reasons fetching or
+// playing a song can fail.
+package failure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// Kind is the cause of a failure.
+// A Kind is an error itself so errors.Is(err, failure.RateLimited) can be
+// used.
+type Kind byte
+
+const (
+	Unknown Kind = iota
+	NotFound
+	GeoBlocked
+	AgeRestricted
+	RateLimited
+	NetworkTimeout
+	ParserBroken
+	Truncated
+)
+
+var names = map[Kind]string{
+	Unknown:        "unknown",
+	NotFound:       "not found",
+	GeoBlocked:     "geo blocked",
+	AgeRestricted:  "age restricted",
+	RateLimited:    "rate limited",
+	NetworkTimeout: "network timeout",
+	ParserBroken:   "parser broken",
+	Truncated:      "truncated",
+}
+
+func (k Kind) String() string { return names[k] }
+func (k Kind) Error() string  { return k.String() }
+
+// Temporary reports whether retrying later might succeed.
+func (k Kind) Temporary() bool {
+	switch k {
+	case RateLimited, NetworkTimeout, Truncated, Unknown:
+		return true
+	}
+	return false
+}
+
+// Error is an error with a Kind.
+type Error struct {
+	Kind Kind
+	Err  error
+}
+
+// Wrap annotates err with the given Kind. Returns nil if err is nil.
+func Wrap(kind Kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+func (e *Error) Unwrap() error { return e.Err }
+
+// Is reports whether target is the Kind of this error.
+func (e *Error) Is(target error) bool {
+	k, ok := target.(Kind)
+	return ok && k == e.Kind
+}
+
+// KindOf returns the Kind of the given error. Errors that were not
+// explicitly wrapped are classified as NetworkTimeout if they are timeouts
+// and Unknown otherwise.
+func KindOf(err error) Kind {
+	if err == nil {
+		return Unknown
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return NetworkTimeout
+	}
+
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return NetworkTimeout
+	}
+
+	return Unknown
+}
+
+// HTTPStatus returns an *Error for http status codes that signify the
+// resource can not be retrieved, nil otherwise.
+func HTTPStatus(code int) error {
+	var kind Kind
+	switch code {
+	case http.StatusNotFound, http.StatusGone:
+		kind = NotFound
+	case http.StatusTooManyRequests:
+		kind = RateLimited
+	case http.StatusUnavailableForLegalReasons:
+		kind = GeoBlocked
+	case http.StatusGatewayTimeout, http.StatusRequestTimeout:
+		kind = NetworkTimeout
+	default:
+		return nil
+	}
+
+	return Wrap(kind, fmt.Errorf("http status %d", code))
+}
@@ -0,0 +1,119 @@
+package fuzzymap
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Decode builds an M directly from the next json object in dec without
+// first decoding it into a map[string]interface{}.
+//
+// Object keys starting with any of the given prune prefixes are skipped
+// entirely, i.e.: no Value is allocated for them or any of their children.
+func Decode(dec *json.Decoder, prune ...string) (M, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := t.(json.Delim); !ok || d != '{' {
+		return nil, errors.New("expected a json object")
+	}
+
+	return decodeObject(dec, nil, prune)
+}
+
+func pruned(key string, prune []string) bool {
+	for _, p := range prune {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if d, ok := t.(json.Delim); ok {
+			switch d {
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+func decodeObject(dec *json.Decoder, parent *Value, prune []string) (M, error) {
+	m := make(M, 0)
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return m, err
+		}
+		key, ok := t.(string)
+		if !ok {
+			return m, errors.New("expected an object key")
+		}
+
+		if pruned(key, prune) {
+			if err := skipValue(dec); err != nil {
+				return m, err
+			}
+			continue
+		}
+
+		cur, err := decodeValue(dec, parent, prune)
+		if err != nil {
+			return m, err
+		}
+		cur.Key = key
+		m = append(m, cur)
+	}
+
+	_, err := dec.Token()
+	return m, err
+}
+
+func decodeValue(dec *json.Decoder, parent *Value, prune []string) (*Value, error) {
+	cur := &Value{Parent: parent}
+	t, err := dec.Token()
+	if err != nil {
+		return cur, err
+	}
+
+	d, ok := t.(json.Delim)
+	if !ok {
+		cur.Value = t
+		return cur, nil
+	}
+
+	switch d {
+	case '{':
+		cur.Children, err = decodeObject(dec, cur, prune)
+		return cur, err
+	case '[':
+		cur.array = true
+		cur.Children = make(M, 0)
+		for dec.More() {
+			child, err := decodeValue(dec, cur, prune)
+			if err != nil {
+				return cur, err
+			}
+			cur.Children = append(cur.Children, child)
+		}
+		_, err = dec.Token()
+		return cur, err
+	}
+
+	return cur, errors.New("unexpected json delimiter")
+}
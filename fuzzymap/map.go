@@ -13,6 +13,8 @@ type Value struct {
 	Parent   *Value
 	Children M
 	Value    interface{}
+
+	array bool
 }
 
 func (v *Value) String() string {
@@ -25,6 +27,7 @@ func createPlain(parent *Value, input interface{}) *Value {
 	case map[string]interface{}:
 		cur.Children = create(cur, rv)
 	case []interface{}:
+		cur.array = true
 		cur.Children = make(M, len(rv))
 		for i := range rv {
 			cur.Children[i] = createPlain(cur, rv[i])
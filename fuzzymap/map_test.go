@@ -1,6 +1,8 @@
 package fuzzymap_test
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/frizinak/libym/fuzzymap"
@@ -102,3 +104,159 @@ func TestIt(t *testing.T) {
 
 	rec(0, results)
 }
+
+func TestQuery(t *testing.T) {
+	value := map[string]interface{}{
+		"contents": []interface{}{
+			map[string]interface{}{
+				"videoRenderer": map[string]interface{}{
+					"videoId": "one",
+					"length":  float64(120),
+					"live":    false,
+				},
+			},
+			map[string]interface{}{
+				"videoRenderer": map[string]interface{}{
+					"videoId": "two",
+					"length":  float64(60),
+					"live":    true,
+				},
+			},
+			map[string]interface{}{
+				"shelf": map[string]interface{}{
+					"items": []interface{}{
+						map[string]interface{}{
+							"videoRenderer": map[string]interface{}{
+								"videoId": "three",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	m := fuzzymap.New(value)
+
+	ids := m.Query("contents.*.videoRenderer.videoId").Strings()
+	if len(ids) != 2 || ids[0] != "one" || ids[1] != "two" {
+		t.Fatal("unexpected wildcard result", ids)
+	}
+
+	ids = m.Query("contents.**.videoId").Strings()
+	if len(ids) != 3 || ids[2] != "three" {
+		t.Fatal("unexpected deep result", ids)
+	}
+
+	if id := m.Query("contents[1].videoRenderer.videoId").String(); id != "two" {
+		t.Fatal("unexpected index result", id)
+	}
+
+	if id := m.Query("contents[-1].**.videoId").String(); id != "three" {
+		t.Fatal("unexpected negative index result", id)
+	}
+
+	if n := m.Query("contents[0].videoRenderer.length").Int(); n != 120 {
+		t.Fatal("unexpected int", n)
+	}
+
+	if b := m.Query("contents.*.videoRenderer.live").Bools(); len(b) != 2 || b[0] || !b[1] {
+		t.Fatal("unexpected bools", b)
+	}
+
+	if l := m.Query("contents[5].videoRenderer").Len(); l != 0 {
+		t.Fatal("expected no results for out of range index", l)
+	}
+
+	for _, p := range []string{"contents..videoId", "contents[a]", "contents[0"} {
+		if _, err := m.QueryErr(p); err == nil {
+			t.Errorf("expected an error for '%s'", p)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	const data = `{
+		"text": "some text",
+		"items": ["item one", {"title": "two", "n": 2, "ok": true, "nil": null}],
+		"thumbnails": [{"url": "https://example.org/a.jpg"}],
+		"links": {"home": "https://www.homepage.org", "about": [], "empty": {}}
+	}`
+
+	var plain map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &plain); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := fuzzymap.New(plain)
+	m, err := fuzzymap.Decode(json.NewDecoder(strings.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect.SortRecursive()
+	m.SortRecursive()
+	if m.String() != expect.String() {
+		t.Fatalf("decode mismatch:\n%s\n!=\n%s", m, expect)
+	}
+
+	if m.Query("items[1].title")[0].Parent.Parent.Key != "items" {
+		t.Fatal("expected a different parent for items[1].title")
+	}
+
+	m, err = fuzzymap.Decode(json.NewDecoder(strings.NewReader(data)), "thumb", "link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 2 || m.Query("**.url").Len() != 0 {
+		t.Fatal("expected thumbnails and links to be pruned", m)
+	}
+
+	if _, err := fuzzymap.Decode(json.NewDecoder(strings.NewReader(`["no"]`))); err == nil {
+		t.Fatal("expected an error for a non object")
+	}
+}
+
+func TestMutate(t *testing.T) {
+	const data = `{"a":1,"list":[{"id":"x"},{"id":"y"}],"obj":{"k":"v","empty":{}},"arr":[]}`
+	m, err := fuzzymap.Decode(json.NewDecoder(strings.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != data {
+		t.Fatalf("roundtrip mismatch: %s != %s", out, data)
+	}
+
+	list := m.Query("list").First()
+	list.Set("2", map[string]interface{}{"id": "z"})
+	list.Children[0].Set("id", "w")
+	list.Delete("1")
+	if list.Set("9", "out of range") != nil {
+		t.Fatal("expected nil for an out of range index")
+	}
+
+	obj := m.Query("obj").First()
+	obj.Delete("empty")
+	obj.Set("n", []interface{}{true})
+	m.Delete("a")
+	m.Set("b", "c")
+	m.Query("arr").First().Set("0", nil)
+
+	if p := m.Query("obj.n").First(); p.Parent != obj || p.Children[0].Parent != p {
+		t.Fatal("parents not set")
+	}
+
+	out, err = json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expect = `{"list":[{"id":"w"},{"id":"z"}],"obj":{"k":"v","n":[true]},"arr":[null],"b":"c"}`
+	if string(out) != expect {
+		t.Fatalf("mutation mismatch: %s != %s", out, expect)
+	}
+}
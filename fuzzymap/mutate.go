@@ -0,0 +1,189 @@
+package fuzzymap
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+// IsArray reports whether v represents a json array / []interface{}.
+func (v *Value) IsArray() bool { return v.array }
+
+// IsObject reports whether v represents a json object /
+// map[string]interface{}.
+func (v *Value) IsObject() bool { return !v.array && v.Children != nil }
+
+// SetValue replaces the contents of v with the given value which can be
+// a map[string]interface{}, []interface{} or any scalar.
+func (v *Value) SetValue(value interface{}) {
+	n := createPlain(v.Parent, value)
+	for _, c := range n.Children {
+		c.Parent = v
+	}
+	v.Children, v.Value, v.array = n.Children, n.Value, n.array
+}
+
+// Set sets the child identified by key to the given value (see SetValue)
+// and returns it.
+// If v is an array, key must be an index in range or equal to its length
+// to append, nil is returned otherwise.
+// If v is a scalar it is converted to an object first.
+func (v *Value) Set(key string, value interface{}) *Value {
+	n := createPlain(v, value)
+	if v.array {
+		ix, err := strconv.Atoi(key)
+		if err != nil || ix < 0 || ix > len(v.Children) {
+			return nil
+		}
+		if ix == len(v.Children) {
+			v.Children = append(v.Children, n)
+			return n
+		}
+		v.Children[ix] = n
+		return n
+	}
+
+	if v.Children == nil {
+		v.Value = nil
+		v.Children = make(M, 0, 1)
+	}
+
+	n.Key = key
+	v.Children = v.Children.set(n)
+	return n
+}
+
+// Delete removes the child identified by key (an index for arrays) and
+// reports whether it existed.
+func (v *Value) Delete(key string) bool {
+	if !v.array {
+		return v.Children.Delete(key)
+	}
+
+	ix, err := strconv.Atoi(key)
+	if err != nil || ix < 0 || ix >= len(v.Children) {
+		return false
+	}
+	v.Children = append(v.Children[:ix], v.Children[ix+1:]...)
+	return true
+}
+
+// Set sets the top level value identified by key. See Value.Set.
+func (m *M) Set(key string, value interface{}) *Value {
+	n := createPlain(nil, value)
+	n.Key = key
+	*m = m.set(n)
+	return n
+}
+
+// Delete removes the top level value identified by key.
+func (m *M) Delete(key string) bool {
+	l := len(*m)
+	*m = m.delete(key)
+	return len(*m) != l
+}
+
+func (m M) set(n *Value) M {
+	for i, v := range m {
+		if v.Key == n.Key {
+			m[i] = n
+			return m
+		}
+	}
+	return append(m, n)
+}
+
+func (m M) delete(key string) M {
+	n := m[:0]
+	for _, v := range m {
+		if v.Key != key {
+			n = append(n, v)
+		}
+	}
+	return n
+}
+
+// Interface converts v back to plain go values, i.e.: the inverse of New.
+func (v *Value) Interface() interface{} {
+	switch {
+	case v.array:
+		l := make([]interface{}, len(v.Children))
+		for i, c := range v.Children {
+			l[i] = c.Interface()
+		}
+		return l
+	case v.Children != nil:
+		return v.Children.Interface()
+	}
+	return v.Value
+}
+
+// Interface converts m back to a map[string]interface{}, i.e.: the inverse
+// of New.
+func (m M) Interface() map[string]interface{} {
+	r := make(map[string]interface{}, len(m))
+	for _, v := range m {
+		r[v.Key] = v.Interface()
+	}
+	return r
+}
+
+// MarshalJSON encodes v as json preserving the order of its children.
+func (v *Value) MarshalJSON() ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	err := v.encode(buf)
+	return buf.Bytes(), err
+}
+
+// MarshalJSON encodes m as a json object preserving its order.
+func (m M) MarshalJSON() ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	err := m.encode(buf)
+	return buf.Bytes(), err
+}
+
+func (m M) encode(buf *bytes.Buffer) error {
+	buf.WriteByte('{')
+	for i, v := range m {
+		if i != 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(v.Key)
+		if err != nil {
+			return err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		if err := v.encode(buf); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func (v *Value) encode(buf *bytes.Buffer) error {
+	switch {
+	case v.array:
+		buf.WriteByte('[')
+		for i, c := range v.Children {
+			if i != 0 {
+				buf.WriteByte(',')
+			}
+			if err := c.encode(buf); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	case v.Children != nil:
+		return v.Children.encode(buf)
+	}
+
+	d, err := json.Marshal(v.Value)
+	if err != nil {
+		return err
+	}
+	buf.Write(d)
+	return nil
+}
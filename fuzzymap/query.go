@@ -0,0 +1,324 @@
+package fuzzymap
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Results is the set of values matched by a Query.
+type Results M
+
+type token struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+	deep     bool
+}
+
+func parsePath(path string) ([]token, error) {
+	tokens := make([]token, 0)
+	for _, seg := range strings.Split(path, ".") {
+		if seg == "" {
+			return nil, fmt.Errorf("empty segment in path '%s'", path)
+		}
+
+		key := seg
+		if ix := strings.IndexByte(seg, '['); ix != -1 {
+			key = seg[:ix]
+			seg = seg[ix:]
+		} else {
+			seg = ""
+		}
+
+		switch key {
+		case "":
+		case "*":
+			tokens = append(tokens, token{wildcard: true})
+		case "**":
+			tokens = append(tokens, token{deep: true})
+		default:
+			tokens = append(tokens, token{key: key})
+		}
+
+		for seg != "" {
+			end := strings.IndexByte(seg, ']')
+			if seg[0] != '[' || end == -1 {
+				return nil, fmt.Errorf("invalid index selector in path '%s'", path)
+			}
+			sel := seg[1:end]
+			seg = seg[end+1:]
+			if sel == "*" {
+				tokens = append(tokens, token{wildcard: true})
+				continue
+			}
+			n, err := strconv.Atoi(sel)
+			if err != nil {
+				return nil, fmt.Errorf("invalid index '%s' in path '%s'", sel, path)
+			}
+			tokens = append(tokens, token{index: n, isIndex: true})
+		}
+	}
+
+	return tokens, nil
+}
+
+func (t token) match(group M) M {
+	switch {
+	case t.wildcard:
+		return group
+	case t.isIndex:
+		ix := t.index
+		if ix < 0 {
+			ix += len(group)
+		}
+		if ix < 0 || ix >= len(group) {
+			return nil
+		}
+		return group[ix : ix+1]
+	}
+
+	r := make(M, 0, 1)
+	for _, v := range group {
+		if v.Key == t.key {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+func deep(groups []M) []M {
+	all := make([]M, 0, len(groups))
+	seen := make(map[*Value]struct{})
+	var rec func(M)
+	rec = func(m M) {
+		if len(m) == 0 {
+			return
+		}
+		if _, ok := seen[m[0]]; ok {
+			return
+		}
+		seen[m[0]] = struct{}{}
+		all = append(all, m)
+		for _, v := range m {
+			if len(v.Children) != 0 {
+				rec(v.Children)
+			}
+		}
+	}
+	for _, g := range groups {
+		rec(g)
+	}
+	return all
+}
+
+// Query returns all values matching the given path.
+//
+// A path consists of dot separated segments:
+//
+//	key    matches children named key
+//	*      matches any child
+//	**     matches zero or more levels of nesting
+//	[n]    matches the nth child (negative n counts from the end)
+//
+// e.g.: m.Query("contents.**.videoRenderer.videoId").Strings()
+// or m.Query("items[0].title").String()
+//
+// Query panics on a malformed path, use QueryErr if the path is not a
+// constant.
+func (m M) Query(path string) Results {
+	r, err := m.QueryErr(path)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// QueryErr is identical to Query but returns an error for malformed paths.
+func (m M) QueryErr(path string) (Results, error) {
+	tokens, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []M{m}
+	var sel M
+	for i, t := range tokens {
+		if t.deep {
+			groups = deep(groups)
+			if i == len(tokens)-1 {
+				sel = make(M, 0)
+				for _, g := range groups {
+					sel = append(sel, g...)
+				}
+			}
+			continue
+		}
+
+		sel = make(M, 0)
+		for _, g := range groups {
+			sel = append(sel, t.match(g)...)
+		}
+
+		groups = make([]M, 0, len(sel))
+		for _, v := range sel {
+			if len(v.Children) != 0 {
+				groups = append(groups, v.Children)
+			}
+		}
+	}
+
+	return Results(sel), nil
+}
+
+// Query returns all values matching the given path relative to v.
+func (v *Value) Query(path string) Results { return v.Children.Query(path) }
+
+// AsString returns the value as a string if it is one.
+func (v *Value) AsString() (string, bool) {
+	if len(v.Children) != 0 {
+		return "", false
+	}
+	s, ok := v.Value.(string)
+	return s, ok
+}
+
+// AsFloat returns the value as a float64 if it is numeric.
+func (v *Value) AsFloat() (float64, bool) {
+	if len(v.Children) != 0 {
+		return 0, false
+	}
+	switch n := v.Value.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// AsInt returns the value as an int if it is an integral number.
+func (v *Value) AsInt() (int, bool) {
+	if len(v.Children) != 0 {
+		return 0, false
+	}
+	switch n := v.Value.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case json.Number:
+		i, err := n.Int64()
+		return int(i), err == nil
+	}
+	f, ok := v.AsFloat()
+	if !ok || f != math.Trunc(f) {
+		return 0, false
+	}
+	return int(f), true
+}
+
+// AsBool returns the value as a bool if it is one.
+func (v *Value) AsBool() (bool, bool) {
+	if len(v.Children) != 0 {
+		return false, false
+	}
+	b, ok := v.Value.(bool)
+	return b, ok
+}
+
+// M converts the results back to a regular M.
+func (r Results) M() M { return M(r) }
+
+// Len returns the amount of matched values.
+func (r Results) Len() int { return len(r) }
+
+// First returns the first matched value or nil.
+func (r Results) First() *Value {
+	if len(r) == 0 {
+		return nil
+	}
+	return r[0]
+}
+
+// Query runs the given path on the children of every result.
+func (r Results) Query(path string) Results {
+	n := make(Results, 0)
+	for _, v := range r {
+		n = append(n, v.Query(path)...)
+	}
+	return n
+}
+
+// String returns the first string value or an empty string.
+func (r Results) String() string {
+	for _, v := range r {
+		if s, ok := v.AsString(); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// Int returns the first integer value or 0.
+func (r Results) Int() int {
+	for _, v := range r {
+		if i, ok := v.AsInt(); ok {
+			return i
+		}
+	}
+	return 0
+}
+
+// Bool returns the first bool value or false.
+func (r Results) Bool() bool {
+	for _, v := range r {
+		if b, ok := v.AsBool(); ok {
+			return b
+		}
+	}
+	return false
+}
+
+// Strings returns all string values.
+func (r Results) Strings() []string {
+	l := make([]string, 0, len(r))
+	for _, v := range r {
+		if s, ok := v.AsString(); ok {
+			l = append(l, s)
+		}
+	}
+	return l
+}
+
+// Ints returns all integer values.
+func (r Results) Ints() []int {
+	l := make([]int, 0, len(r))
+	for _, v := range r {
+		if i, ok := v.AsInt(); ok {
+			l = append(l, i)
+		}
+	}
+	return l
+}
+
+// Bools returns all bool values.
+func (r Results) Bools() []bool {
+	l := make([]bool, 0, len(r))
+	for _, v := range r {
+		if b, ok := v.AsBool(); ok {
+			l = append(l, b)
+		}
+	}
+	return l
+}
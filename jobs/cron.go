@@ -0,0 +1,166 @@
+package jobs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Spec determines when a scheduled job runs.
+type Spec interface {
+	// Next returns the first activation time strictly after t or the zero
+	// time if there is none.
+	Next(t time.Time) time.Time
+}
+
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time { return t.Add(time.Duration(e)) }
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseSpec parses a cron expression.
+//
+// Supported are the standard five fields (minute hour day-of-month month
+// day-of-week) with *, lists, ranges and steps, the macros @yearly,
+// @monthly, @weekly, @daily and @hourly and @every <duration>
+// e.g.: "*/15 * * * *", "0 3 * * 1-5" or "@every 90m".
+func ParseSpec(spec string) (Spec, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(spec[7:]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid spec '%s': %w", spec, err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("invalid spec '%s': interval too short", spec)
+		}
+		return every(d), nil
+	}
+
+	expr := spec
+	if m, ok := macros[spec]; ok {
+		expr = m
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid spec '%s': expected 5 fields", spec)
+	}
+
+	c := &cron{}
+	var err error
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	sets := [5]*uint64{&c.min, &c.hour, &c.dom, &c.month, &c.dow}
+	for i, f := range fields {
+		if *sets[i], err = parseField(f, bounds[i][0], bounds[i][1]); err != nil {
+			return nil, fmt.Errorf("invalid spec '%s': %w", spec, err)
+		}
+	}
+
+	// 7 is sunday as well.
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domStar = fields[2] == "*"
+	c.dowStar = fields[4] == "*"
+
+	return c, nil
+}
+
+func parseField(f string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(f, ",") {
+		step := 1
+		if ix := strings.IndexByte(part, '/'); ix != -1 {
+			n, err := strconv.Atoi(part[ix+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step in '%s'", f)
+			}
+			step = n
+			part = part[:ix]
+		}
+
+		from, to := min, max
+		switch {
+		case part == "*":
+		case strings.IndexByte(part, '-') != -1:
+			r := strings.SplitN(part, "-", 2)
+			var err1, err2 error
+			from, err1 = strconv.Atoi(r[0])
+			to, err2 = strconv.Atoi(r[1])
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("invalid range in '%s'", f)
+			}
+		default:
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value in '%s'", f)
+			}
+			from, to = n, n
+			if step != 1 {
+				to = max
+			}
+		}
+
+		if from < min || to > max || from > to {
+			return 0, fmt.Errorf("'%s' out of range %d-%d", f, min, max)
+		}
+
+		for i := from; i <= to; i += step {
+			set |= 1 << uint(i)
+		}
+	}
+
+	return set, nil
+}
+
+type cron struct {
+	min, hour, dom, month, dow uint64
+	domStar, dowStar           bool
+}
+
+func has(set uint64, n int) bool { return set&(1<<uint(n)) != 0 }
+
+func (c *cron) day(t time.Time) bool {
+	dom, dow := has(c.dom, t.Day()), has(c.dow, int(t.Weekday()))
+	switch {
+	case c.domStar && c.dowStar:
+		return true
+	case c.domStar:
+		return dow
+	case c.dowStar:
+		return dom
+	}
+	return dom || dow
+}
+
+func (c *cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(5, 0, 0)
+	for t.Before(end) {
+		switch {
+		case !has(c.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.day(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !has(c.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !has(c.min, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
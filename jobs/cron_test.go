@@ -0,0 +1,42 @@
+package jobs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/frizinak/libym/jobs"
+)
+
+func TestParseSpec(t *testing.T) {
+	start := time.Date(2021, time.March, 5, 10, 17, 30, 0, time.UTC) // friday
+	tests := []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2021, time.March, 5, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, time.March, 5, 10, 30, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2021, time.March, 6, 3, 0, 0, 0, time.UTC)},
+		{"30 9 * * 1-5", time.Date(2021, time.March, 8, 9, 30, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2021, time.March, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2021, time.March, 7, 12, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2021, time.March, 5, 11, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 90m", start.Add(90 * time.Minute)},
+	}
+
+	for _, test := range tests {
+		spec, err := jobs.ParseSpec(test.spec)
+		if err != nil {
+			t.Fatal(test.spec, err)
+		}
+		if next := spec.Next(start); !next.Equal(test.next) {
+			t.Errorf("%s: expected %s got %s", test.spec, test.next, next)
+		}
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "5-1 * * * *", "*/0 * * * *", "@every 1ms"} {
+		if _, err := jobs.ParseSpec(spec); err == nil {
+			t.Errorf("expected an error for '%s'", spec)
+		}
+	}
+}
@@ -0,0 +1,542 @@
+// Package jobs keeps track of long running background tasks like scrapes,
+// downloads and song identification.
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kind is the type of a job.
+type Kind string
+
+const (
+	KindScrape   Kind = "scrape"
+	KindDownload Kind = "download"
+	KindImport   Kind = "import"
+	KindIdentify Kind = "identify"
+	KindMeta     Kind = "meta"
+	KindSchedule Kind = "schedule"
+	// KindMaintenance are housekeeping tasks like removing unused files.
+	KindMaintenance Kind = "maintenance"
+	// KindExport writes the library to a directory outside of the store.
+	KindExport Kind = "export"
+	// KindGenerate creates playlists, e.g.: the daily mix.
+	KindGenerate Kind = "generate"
+)
+
+// State is the lifecycle state of a job.
+type State byte
+
+const (
+	StatePending State = iota
+	StateRunning
+	StateDone
+	StateFailed
+	StateCanceled
+)
+
+var stateNames = map[State]string{
+	StatePending:  "pending",
+	StateRunning:  "running",
+	StateDone:     "done",
+	StateFailed:   "failed",
+	StateCanceled: "canceled",
+}
+
+func (s State) String() string { return stateNames[s] }
+
+// Progress is a structured progress report.
+type Progress struct {
+	Stage   string
+	Item    int
+	Total   int
+	Message string
+}
+
+// Fraction returns the progress as a number between 0 and 1.
+func (p Progress) Fraction() float64 {
+	if p.Total <= 0 {
+		return 0
+	}
+	f := float64(p.Item) / float64(p.Total)
+	if f > 1 {
+		f = 1
+	}
+	return f
+}
+
+// Job is a single cancellable task.
+type Job struct {
+	id   string
+	seq  uint64
+	kind Kind
+	name string
+	m    *Manager
+	fn   Func
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mutex    sync.RWMutex
+	log      []string
+	progress Progress
+	state    State
+	result   interface{}
+	err      error
+	started  time.Time
+	finished time.Time
+}
+
+func (j *Job) ID() string   { return j.id }
+func (j *Job) Kind() Kind   { return j.kind }
+func (j *Job) Name() string { return j.name }
+
+// Context is canceled when the job is canceled.
+func (j *Job) Context() context.Context { return j.ctx }
+
+// Cancel cancels the job's context. Pending jobs are finished immediately.
+func (j *Job) Cancel() {
+	j.cancel()
+	j.m.finish(j, StatePending, nil, j.ctx.Err())
+}
+
+// Done is closed once the job has finished.
+func (j *Job) Done() <-chan struct{} { return j.done }
+
+// Progress returns the last reported progress.
+func (j *Job) Progress() Progress {
+	j.mutex.RLock()
+	p := j.progress
+	j.mutex.RUnlock()
+	return p
+}
+
+// SetProgress reports progress.
+func (j *Job) SetProgress(p Progress) {
+	j.mutex.Lock()
+	j.progress = p
+	j.mutex.Unlock()
+}
+
+// SetStage updates the stage and message, leaving item and total as is.
+func (j *Job) SetStage(stage, message string) {
+	j.mutex.Lock()
+	j.progress.Stage = stage
+	j.progress.Message = message
+	j.mutex.Unlock()
+}
+
+// SetItem updates item and total, leaving stage and message as is.
+func (j *Job) SetItem(item, total int) {
+	j.mutex.Lock()
+	j.progress.Item = item
+	j.progress.Total = total
+	j.mutex.Unlock()
+}
+
+// MaxLogLines is the maximum amount of log lines kept per job.
+const MaxLogLines = 1000
+
+// Logf appends a line to the job's log.
+func (j *Job) Logf(format string, args ...interface{}) {
+	line := fmt.Sprintf(
+		"%s %s",
+		time.Now().Format("15:04:05"),
+		fmt.Sprintf(format, args...),
+	)
+	j.mutex.Lock()
+	j.log = append(j.log, line)
+	if len(j.log) > MaxLogLines {
+		j.log = j.log[len(j.log)-MaxLogLines:]
+	}
+	j.mutex.Unlock()
+}
+
+// Log returns all captured log lines.
+func (j *Job) Log() []string {
+	j.mutex.RLock()
+	l := make([]string, len(j.log))
+	copy(l, j.log)
+	j.mutex.RUnlock()
+	return l
+}
+
+// State returns the current state.
+func (j *Job) State() State {
+	j.mutex.RLock()
+	s := j.state
+	j.mutex.RUnlock()
+	return s
+}
+
+// Started returns the time the job started running or was queued if it is
+// still pending.
+func (j *Job) Started() time.Time {
+	j.mutex.RLock()
+	t := j.started
+	j.mutex.RUnlock()
+	return t
+}
+
+// Finished returns the time the job finished or the zero time if it is
+// still running.
+func (j *Job) Finished() time.Time {
+	j.mutex.RLock()
+	t := j.finished
+	j.mutex.RUnlock()
+	return t
+}
+
+// Result returns the result and error of a finished job.
+func (j *Job) Result() (interface{}, error) {
+	j.mutex.RLock()
+	r, err := j.result, j.err
+	j.mutex.RUnlock()
+	return r, err
+}
+
+// Wait blocks until the job has finished and returns its result.
+func (j *Job) Wait() (interface{}, error) {
+	<-j.done
+	return j.Result()
+}
+
+func (j *Job) finish(from State, result interface{}, err error) bool {
+	j.mutex.Lock()
+	if j.state != from {
+		j.mutex.Unlock()
+		return false
+	}
+	j.result, j.err = result, err
+	j.finished = time.Now()
+	j.state = StateDone
+	switch {
+	case err != nil && j.ctx.Err() != nil:
+		j.state = StateCanceled
+	case err != nil:
+		j.state = StateFailed
+	}
+	j.mutex.Unlock()
+	if err != nil {
+		j.Logf("%s: %s", j.State(), err)
+	} else {
+		j.Logf("%s", j.State())
+	}
+	j.cancel()
+	close(j.done)
+	return true
+}
+
+// Func is the actual work of a job. ctx is canceled when the job is.
+type Func func(j *Job) (result interface{}, err error)
+
+// Manager keeps track of all running jobs and queues jobs that exceed the
+// concurrency limit of their Kind.
+type Manager struct {
+	mutex   sync.RWMutex
+	j       map[string]*Job
+	n       uint64
+	limits  map[Kind]int
+	running map[Kind]int
+	pending map[Kind][]*Job
+
+	history     []*Job
+	historySize int
+	onFinish    []func(*Job)
+
+	idle      func() bool
+	idleKinds map[Kind]struct{}
+	idleStop  chan struct{}
+}
+
+// IdlePollInterval is the interval at which jobs held back by the idle
+// policy are reconsidered.
+var IdlePollInterval = time.Second * 5
+
+// DefaultHistorySize is the amount of finished jobs a Manager remembers.
+const DefaultHistorySize = 50
+
+// NewManager creates a new Manager without any concurrency limits.
+func NewManager() *Manager {
+	return &Manager{
+		j:       make(map[string]*Job),
+		limits:  make(map[Kind]int),
+		running: make(map[Kind]int),
+		pending: make(map[Kind][]*Job),
+
+		history:     make([]*Job, 0),
+		historySize: DefaultHistorySize,
+	}
+}
+
+// SetHistorySize sets the amount of finished jobs to remember.
+func (m *Manager) SetHistorySize(n int) {
+	m.mutex.Lock()
+	m.historySize = n
+	m.trimHistory()
+	m.mutex.Unlock()
+}
+
+func (m *Manager) trimHistory() {
+	if m.historySize < 0 {
+		m.historySize = 0
+	}
+	if len(m.history) > m.historySize {
+		m.history = m.history[:m.historySize]
+	}
+}
+
+// History returns recently finished jobs, most recent first.
+func (m *Manager) History() []*Job {
+	m.mutex.RLock()
+	l := make([]*Job, len(m.history))
+	copy(l, m.history)
+	m.mutex.RUnlock()
+	return l
+}
+
+// OnFinish registers a callback that is called whenever a job finishes,
+// fails or is canceled.
+func (m *Manager) OnFinish(cb func(*Job)) {
+	m.mutex.Lock()
+	m.onFinish = append(m.onFinish, cb)
+	m.mutex.Unlock()
+}
+
+// SetIdlePolicy makes jobs of the given kinds only start while idle returns
+// true, e.g.: while playback is paused. A nil idle func disables the policy.
+func (m *Manager) SetIdlePolicy(idle func() bool, kinds ...Kind) {
+	m.mutex.Lock()
+	if m.idleStop != nil {
+		close(m.idleStop)
+		m.idleStop = nil
+	}
+	m.idle = idle
+	m.idleKinds = make(map[Kind]struct{}, len(kinds))
+	for _, k := range kinds {
+		m.idleKinds[k] = struct{}{}
+	}
+	if idle != nil && len(kinds) != 0 {
+		m.idleStop = make(chan struct{})
+		go m.pollIdle(m.idleStop)
+	}
+	m.mutex.Unlock()
+}
+
+func (m *Manager) pollIdle(stop <-chan struct{}) {
+	t := time.NewTicker(IdlePollInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+
+		m.mutex.Lock()
+		start := make([]*Job, 0)
+		for kind := range m.idleKinds {
+			start = append(start, m.dequeue(kind)...)
+		}
+		m.mutex.Unlock()
+		m.start(start)
+	}
+}
+
+// Allowed reports whether jobs of the given kind may start according to
+// the idle policy.
+func (m *Manager) Allowed(kind Kind) bool {
+	m.mutex.RLock()
+	ok := m.allowed(kind)
+	m.mutex.RUnlock()
+	return ok
+}
+
+func (m *Manager) allowed(kind Kind) bool {
+	if m.idle == nil {
+		return true
+	}
+	if _, ok := m.idleKinds[kind]; !ok {
+		return true
+	}
+	return m.idle()
+}
+
+// SetLimit sets the maximum amount of concurrently running jobs started
+// with Run of the given kind. n <= 0 means unlimited.
+func (m *Manager) SetLimit(kind Kind, n int) {
+	m.mutex.Lock()
+	m.limits[kind] = n
+	start := m.dequeue(kind)
+	m.mutex.Unlock()
+	m.start(start)
+}
+
+// Limit returns the concurrency limit for the given kind.
+func (m *Manager) Limit(kind Kind) int {
+	m.mutex.RLock()
+	n := m.limits[kind]
+	m.mutex.RUnlock()
+	return n
+}
+
+func (m *Manager) newJob(parent context.Context, kind Kind, name string, state State) *Job {
+	ctx, cancel := context.WithCancel(parent)
+	m.n++
+	job := &Job{
+		id:      fmt.Sprintf("%d-%s", m.n, name),
+		seq:     m.n,
+		kind:    kind,
+		name:    name,
+		m:       m,
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		state:   state,
+		started: time.Now(),
+	}
+	m.j[job.id] = job
+	return job
+}
+
+// Add registers a new running job regardless of the concurrency limit.
+// The caller is responsible for calling Finish.
+func (m *Manager) Add(kind Kind, name string) *Job {
+	return m.AddContext(context.Background(), kind, name)
+}
+
+// AddContext is identical to Add but the job's context is derived from ctx.
+func (m *Manager) AddContext(ctx context.Context, kind Kind, name string) *Job {
+	m.mutex.Lock()
+	job := m.newJob(ctx, kind, name, StateRunning)
+	m.running[kind]++
+	m.mutex.Unlock()
+	return job
+}
+
+// Finish marks a running job as finished and removes it from the manager.
+func (m *Manager) Finish(j *Job, result interface{}, err error) {
+	m.finish(j, StateRunning, result, err)
+}
+
+func (m *Manager) finish(j *Job, from State, result interface{}, err error) {
+	if !j.finish(from, result, err) {
+		return
+	}
+
+	m.mutex.Lock()
+	delete(m.j, j.id)
+	switch from {
+	case StateRunning:
+		m.running[j.kind]--
+	case StatePending:
+		l := m.pending[j.kind]
+		for i := range l {
+			if l[i] == j {
+				m.pending[j.kind] = append(l[:i:i], l[i+1:]...)
+				break
+			}
+		}
+	}
+	m.history = append([]*Job{j}, m.history...)
+	m.trimHistory()
+	cbs := m.onFinish
+	start := m.dequeue(j.kind)
+	m.mutex.Unlock()
+	m.start(start)
+
+	for _, cb := range cbs {
+		cb(j)
+	}
+}
+
+// dequeue marks as many pending jobs of the given kind as running as the
+// limit allows. Must be called with the lock held.
+func (m *Manager) dequeue(kind Kind) []*Job {
+	l := make([]*Job, 0)
+	if len(m.pending[kind]) == 0 || !m.allowed(kind) {
+		return l
+	}
+	limit := m.limits[kind]
+	for len(m.pending[kind]) != 0 && (limit <= 0 || m.running[kind] < limit) {
+		j := m.pending[kind][0]
+		m.pending[kind] = m.pending[kind][1:]
+		j.mutex.Lock()
+		if j.state != StatePending {
+			// canceled
+			j.mutex.Unlock()
+			continue
+		}
+		j.state = StateRunning
+		j.started = time.Now()
+		j.mutex.Unlock()
+		m.running[kind]++
+		l = append(l, j)
+	}
+	return l
+}
+
+func (m *Manager) start(l []*Job) {
+	for _, j := range l {
+		go func(j *Job) {
+			result, err := j.fn(j)
+			m.Finish(j, result, err)
+		}(j)
+	}
+}
+
+// Run adds a job and runs fn in a new goroutine as soon as the concurrency
+// limit and idle policy of its kind allow it. Until then the job is
+// StatePending.
+func (m *Manager) Run(kind Kind, name string, fn Func) *Job {
+	m.mutex.Lock()
+	job := m.newJob(context.Background(), kind, name, StatePending)
+	job.fn = fn
+	m.pending[kind] = append(m.pending[kind], job)
+	start := m.dequeue(kind)
+	m.mutex.Unlock()
+	m.start(start)
+	return job
+}
+
+// Remove removes a job without finishing it.
+func (m *Manager) Remove(id string) {
+	m.mutex.Lock()
+	delete(m.j, id)
+	m.mutex.Unlock()
+}
+
+// Get returns the job with the given id or nil.
+func (m *Manager) Get(id string) *Job {
+	m.mutex.RLock()
+	j := m.j[id]
+	m.mutex.RUnlock()
+	return j
+}
+
+// Len returns the amount of pending and running jobs.
+func (m *Manager) Len() int {
+	m.mutex.RLock()
+	l := len(m.j)
+	m.mutex.RUnlock()
+	return l
+}
+
+// List returns all pending and running jobs in the order they were added.
+func (m *Manager) List() []*Job {
+	m.mutex.RLock()
+	l := make([]*Job, 0, len(m.j))
+	for _, j := range m.j {
+		l = append(l, j)
+	}
+	m.mutex.RUnlock()
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].seq < l[j].seq
+	})
+	return l
+}
@@ -0,0 +1,150 @@
+package jobs_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/frizinak/libym/jobs"
+)
+
+func TestRun(t *testing.T) {
+	m := jobs.NewManager()
+	start := make(chan struct{})
+	j := m.Run(jobs.KindScrape, "one", func(j *jobs.Job) (interface{}, error) {
+		<-start
+		j.SetProgress(jobs.Progress{Stage: "working", Item: 1, Total: 2})
+		return 42, nil
+	})
+
+	if m.Len() != 1 || m.List()[0] != j || m.Get(j.ID()) != j {
+		t.Fatal("expected job to be listed")
+	}
+
+	close(start)
+	res, err := j.Wait()
+	if err != nil || res.(int) != 42 || j.State() != jobs.StateDone {
+		t.Fatal("unexpected result", res, err, j.State())
+	}
+	if f := j.Progress().Fraction(); f != 0.5 {
+		t.Fatal("unexpected progress", f)
+	}
+	if m.Len() != 0 {
+		t.Fatal("expected finished job to be removed")
+	}
+
+	j = m.Run(jobs.KindDownload, "two", func(j *jobs.Job) (interface{}, error) {
+		<-j.Context().Done()
+		return nil, j.Context().Err()
+	})
+	j.Cancel()
+	if _, err := j.Wait(); err == nil || j.State() != jobs.StateCanceled {
+		t.Fatal("expected job to be canceled", err, j.State())
+	}
+
+	j = m.Run(jobs.KindIdentify, "three", func(j *jobs.Job) (interface{}, error) {
+		return nil, errors.New("fail")
+	})
+	if _, err := j.Wait(); err == nil || j.State() != jobs.StateFailed {
+		t.Fatal("expected job to fail", err, j.State())
+	}
+}
+
+func TestLimit(t *testing.T) {
+	m := jobs.NewManager()
+	m.SetLimit(jobs.KindScrape, 2)
+
+	release := make(chan struct{})
+	l := make([]*jobs.Job, 4)
+	for i := range l {
+		l[i] = m.Run(jobs.KindScrape, "scrape", func(j *jobs.Job) (interface{}, error) {
+			<-release
+			return nil, nil
+		})
+	}
+	other := m.Run(jobs.KindIdentify, "other", func(j *jobs.Job) (interface{}, error) {
+		return nil, nil
+	})
+	other.Wait()
+
+	states := func() []jobs.State {
+		s := make([]jobs.State, len(l))
+		for i := range l {
+			s[i] = l[i].State()
+		}
+		return s
+	}
+
+	s := states()
+	if s[0] != jobs.StateRunning || s[1] != jobs.StateRunning ||
+		s[2] != jobs.StatePending || s[3] != jobs.StatePending {
+		t.Fatal("unexpected states", s)
+	}
+
+	l[3].Cancel()
+	if l[3].State() != jobs.StateCanceled {
+		t.Fatal("expected pending job to be canceled immediately", l[3].State())
+	}
+
+	release <- struct{}{}
+	for l[2].State() == jobs.StatePending {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	for _, j := range l {
+		j.Wait()
+	}
+	if m.Len() != 0 {
+		t.Fatal("expected all jobs to be finished", m.List())
+	}
+}
+
+func TestHistory(t *testing.T) {
+	m := jobs.NewManager()
+	m.SetHistorySize(2)
+	finished := make(chan *jobs.Job, 3)
+	m.OnFinish(func(j *jobs.Job) { finished <- j })
+
+	for i := 0; i < 3; i++ {
+		m.Run(jobs.KindScrape, "scrape", func(j *jobs.Job) (interface{}, error) {
+			j.Logf("item %d", 1)
+			return nil, nil
+		}).Wait()
+		<-finished
+	}
+
+	h := m.History()
+	if len(h) != 2 {
+		t.Fatal("expected history to be capped", len(h))
+	}
+	if log := h[0].Log(); len(log) != 2 || log[1][9:] != "done" {
+		t.Fatal("unexpected log", log)
+	}
+}
+
+func TestIdlePolicy(t *testing.T) {
+	jobs.IdlePollInterval = time.Millisecond
+	m := jobs.NewManager()
+	idle := make(chan bool, 1)
+	idle <- false
+	m.SetIdlePolicy(func() bool {
+		v := <-idle
+		idle <- v
+		return v
+	}, jobs.KindDownload)
+
+	fn := func(j *jobs.Job) (interface{}, error) { return nil, nil }
+	heavy := m.Run(jobs.KindDownload, "heavy", fn)
+	light := m.Run(jobs.KindScrape, "light", fn)
+	light.Wait()
+
+	time.Sleep(time.Millisecond * 20)
+	if heavy.State() != jobs.StatePending {
+		t.Fatal("heavy job started while not idle", heavy.State())
+	}
+
+	<-idle
+	idle <- true
+	heavy.Wait()
+	m.SetIdlePolicy(nil)
+}
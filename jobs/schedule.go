@@ -0,0 +1,200 @@
+package jobs
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Schedule describes a recurring job.
+type Schedule struct {
+	Name string
+	Kind Kind
+	Spec string
+
+	// Last is the time the job was last started, zero if never.
+	Last time.Time
+	// Next is the time the job will be started next, zero if never.
+	Next time.Time
+}
+
+type entry struct {
+	Schedule
+	spec Spec
+	fn   Func
+	job  *Job
+}
+
+// Scheduler runs jobs on a Manager according to a Spec.
+// A scheduled job is skipped if its previous run has not finished yet.
+type Scheduler struct {
+	m       *Manager
+	mutex   sync.Mutex
+	entries map[string]*entry
+	now     func() time.Time
+
+	wake    chan struct{}
+	stop    chan struct{}
+	running bool
+}
+
+// NewScheduler creates a new Scheduler that runs its jobs on m.
+func NewScheduler(m *Manager) *Scheduler {
+	return &Scheduler{
+		m:       m,
+		entries: make(map[string]*entry),
+		now:     time.Now,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Add registers a recurring job, see ParseSpec for the spec syntax.
+func (s *Scheduler) Add(name string, kind Kind, spec string, fn Func) error {
+	sp, err := ParseSpec(spec)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.entries[name]; ok {
+		return fmt.Errorf("schedule %s already exists", name)
+	}
+
+	e := &entry{
+		Schedule: Schedule{Name: name, Kind: kind, Spec: spec},
+		spec:     sp,
+		fn:       fn,
+	}
+	e.Next = sp.Next(s.now())
+	s.entries[name] = e
+	s.poke()
+	return nil
+}
+
+// Remove removes a recurring job, it does not cancel a running instance.
+func (s *Scheduler) Remove(name string) bool {
+	s.mutex.Lock()
+	_, ok := s.entries[name]
+	delete(s.entries, name)
+	s.mutex.Unlock()
+	return ok
+}
+
+// List returns all schedules sorted by name.
+func (s *Scheduler) List() []Schedule {
+	s.mutex.Lock()
+	l := make([]Schedule, 0, len(s.entries))
+	for _, e := range s.entries {
+		l = append(l, e.Schedule)
+	}
+	s.mutex.Unlock()
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].Name < l[j].Name
+	})
+	return l
+}
+
+// Trigger runs the named job right away regardless of its schedule.
+func (s *Scheduler) Trigger(name string) (*Job, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	e, ok := s.entries[name]
+	if !ok {
+		return nil, fmt.Errorf("schedule %s does not exist", name)
+	}
+	if !s.run(e) {
+		return nil, fmt.Errorf("schedule %s is still running", name)
+	}
+	return e.job, nil
+}
+
+// run must be called with the lock held.
+func (s *Scheduler) run(e *entry) bool {
+	if e.job != nil {
+		select {
+		case <-e.job.Done():
+		default:
+			return false
+		}
+	}
+
+	e.Last = s.now()
+	e.job = s.m.Run(e.Kind, "schedule: "+e.Name, e.fn)
+	return true
+}
+
+func (s *Scheduler) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// tick runs all due jobs and returns the time until the next one is due.
+func (s *Scheduler) tick() time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.now()
+	wait := time.Hour
+	for _, e := range s.entries {
+		if e.Next.IsZero() {
+			continue
+		}
+		if !e.Next.After(now) {
+			s.run(e)
+			e.Next = e.spec.Next(now)
+			if e.Next.IsZero() {
+				continue
+			}
+		}
+		if d := e.Next.Sub(now); d < wait {
+			wait = d
+		}
+	}
+
+	return wait
+}
+
+// Start starts the scheduler in a new goroutine. Noop if already started.
+func (s *Scheduler) Start() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.running {
+		return
+	}
+	s.running = true
+	s.stop = make(chan struct{})
+	stop := s.stop
+
+	go func() {
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-s.wake:
+			case <-timer.C:
+			}
+
+			timer.Stop()
+			select {
+			case <-timer.C:
+			default:
+			}
+			timer.Reset(s.tick())
+		}
+	}()
+}
+
+// Stop stops the scheduler, running jobs are not canceled.
+func (s *Scheduler) Stop() {
+	s.mutex.Lock()
+	if s.running {
+		close(s.stop)
+		s.running = false
+	}
+	s.mutex.Unlock()
+}
@@ -0,0 +1,170 @@
+// Package libym is a typed api for embedding libym, it wires up the same
+// components as package di without routing everything through text
+// commands and the ui package.
+package libym
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/frizinak/libym/collection"
+	"github.com/frizinak/libym/di"
+	"github.com/frizinak/libym/player"
+	"github.com/frizinak/libym/stats"
+	"github.com/frizinak/libym/youtube"
+)
+
+// Session is a collection, queue and player sharing a store.
+type Session struct {
+	di *di.DI
+	c  *collection.Collection
+	q  *collection.Queue
+	p  *player.Player
+}
+
+// New creates a session and starts the background downloads and title
+// lookups. Like di.DI, it panics on configuration errors.
+func New(c di.Config) *Session {
+	d := di.New(c)
+	s := &Session{di: d, c: d.Collection(), q: d.Queue(), p: d.Player()}
+	dl, meta := d.Rates()
+	s.c.Run(dl, meta)
+	return s
+}
+
+// DI returns the container, e.g.: to attach a ui alongside the session.
+func (s *Session) DI() *di.DI { return s.di }
+
+// Collection returns the underlying collection.
+func (s *Session) Collection() *collection.Collection { return s.c }
+
+// Player returns the underlying player.
+func (s *Session) Player() *player.Player { return s.p }
+
+// Status is a snapshot of the player and its position in the queue.
+type Status struct {
+	player.Status
+	// Index is the index of the current song in the queue, -1 if none.
+	Index int
+}
+
+// Status returns the current state of the player.
+func (s *Session) Status() Status {
+	return Status{Status: s.p.Status(), Index: s.q.CurrentIndex()}
+}
+
+// Play resumes or starts playback.
+func (s *Session) Play() { s.p.Play() }
+
+// Pause pauses playback.
+func (s *Session) Pause() { s.p.Pause() }
+
+// Next skips to the next song in the queue.
+func (s *Session) Next() { s.p.Next() }
+
+// Prev returns to the previous song in the queue.
+func (s *Session) Prev() { s.p.Prev() }
+
+// Seek seeks relative to whence, see io.Seeker.
+func (s *Session) Seek(d time.Duration, whence int) { s.p.Seek(d, whence) }
+
+// SeekTo seeks to an absolute position.
+func (s *Session) SeekTo(d time.Duration) { s.p.Seek(d, io.SeekStart) }
+
+// SetVolume sets the volume from 0 to 1.
+func (s *Session) SetVolume(v float64) { s.p.SetVolume(v) }
+
+// Duck temporarily lowers the volume, see player.Player.Duck.
+func (s *Session) Duck(level float64, d time.Duration) { s.p.Duck(level, d) }
+
+// PlaySong queues s after the current song and starts playing it.
+func (s *Session) PlaySong(song collection.Song) {
+	ix := s.q.CurrentIndex() + 1
+	s.c.QueueSong(ix+1, song)
+	s.q.SetCurrentIndex(ix)
+	s.p.ForcePlay()
+}
+
+// Queue returns the songs in the queue.
+func (s *Session) Queue() []collection.Song { return s.q.Slice() }
+
+// Enqueue appends songs to the queue.
+func (s *Session) Enqueue(songs ...collection.Song) { s.c.QueueSongs(-1, songs, nil) }
+
+// EnqueueFrom appends songs to the queue and records their origin, e.g.
+// the remote user that requested them.
+func (s *Session) EnqueueFrom(o collection.Origin, songs ...collection.Song) {
+	s.c.QueueSongsFrom(-1, songs, o, nil)
+}
+
+// EnqueueNext inserts songs right after the current song.
+func (s *Session) EnqueueNext(songs ...collection.Song) {
+	s.c.QueueSongs(s.q.CurrentIndex()+2, songs, nil)
+}
+
+// EnqueuePlaylist appends all songs of a playlist to the queue.
+func (s *Session) EnqueuePlaylist(name string) error { return s.c.Queue(-1, name) }
+
+// ClearQueue empties the queue.
+func (s *Session) ClearQueue() {
+	s.q.Reset()
+	s.p.ForcePlay()
+}
+
+// Search searches youtube, the results can be converted to songs using
+// Song.
+func (s *Session) Search(ctx context.Context, q string) ([]*youtube.Result, error) {
+	return youtube.SearchContext(ctx, q)
+}
+
+// Song converts a search result to a song.
+func (s *Session) Song(r *youtube.Result) collection.Song { return s.c.FromYoutube(r) }
+
+// SongFromURL creates a song from a youtube url.
+func (s *Session) SongFromURL(url string) (collection.Song, error) {
+	return s.c.FromYoutubeURL(url, "")
+}
+
+// SearchLocal searches the titles of songs in all playlists and the queue.
+func (s *Session) SearchLocal(q string) []*collection.SearchResult { return s.c.Search(q) }
+
+// Playlists returns the names of all playlists.
+func (s *Session) Playlists() []string { return s.c.List() }
+
+// Playlist returns the songs in a playlist.
+func (s *Session) Playlist(name string) ([]collection.Song, error) {
+	return s.c.PlaylistSongs(name)
+}
+
+// PlaylistStats returns the totals of a playlist, including the top 10
+// artists.
+func (s *Session) PlaylistStats(name string) (stats.PlaylistReport, error) {
+	return stats.NewPlaylistReport(s.c, s.di.PlayLog(), name, 10)
+}
+
+// CreatePlaylist creates an empty playlist.
+func (s *Session) CreatePlaylist(name string) error { return s.c.Create(name) }
+
+// DeletePlaylist deletes a playlist.
+func (s *Session) DeletePlaylist(name string) error { return s.c.Delete(name) }
+
+// AddToPlaylist adds songs to a playlist, moving existing ones to the end.
+func (s *Session) AddToPlaylist(name string, songs ...collection.Song) error {
+	return s.c.AddSongs(name, songs, true, nil)
+}
+
+// Close saves the store, stops the player and releases the store lock.
+func (s *Session) Close() error {
+	err := s.c.Save()
+	if err == collection.ErrReadOnly {
+		err = nil
+	}
+	if perr := s.p.Close(); err == nil {
+		err = perr
+	}
+	if cerr := s.c.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
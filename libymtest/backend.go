@@ -0,0 +1,206 @@
+package libymtest
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// Backend is a scripted fake player.Backend.
+// Songs never finish on their own, use Finish to simulate the end of a file.
+type Backend struct {
+	sem sync.Mutex
+
+	played   []string
+	done     chan struct{}
+	failNext []error
+
+	endErr   error
+	paused   bool
+	volume   float64
+	position time.Duration
+	duration time.Duration
+	closed   bool
+}
+
+// NewBackend creates a new fake backend with full volume.
+func NewBackend() *Backend {
+	return &Backend{volume: 1, duration: time.Minute * 3}
+}
+
+// FailNext makes the next calls to Play fail with the given errors, in order.
+func (b *Backend) FailNext(err ...error) {
+	b.sem.Lock()
+	b.failNext = append(b.failNext, err...)
+	b.sem.Unlock()
+}
+
+// SetDuration sets the duration reported for every file.
+func (b *Backend) SetDuration(d time.Duration) {
+	b.sem.Lock()
+	b.duration = d
+	b.sem.Unlock()
+}
+
+// Played returns everything passed to Play in order.
+func (b *Backend) Played() []string {
+	b.sem.Lock()
+	l := make([]string, len(b.played))
+	copy(l, b.played)
+	b.sem.Unlock()
+	return l
+}
+
+// Playing returns the currently playing file or an empty string.
+func (b *Backend) Playing() string {
+	b.sem.Lock()
+	defer b.sem.Unlock()
+	if b.done == nil || len(b.played) == 0 {
+		return ""
+	}
+	return b.played[len(b.played)-1]
+}
+
+// Finish simulates reaching the end of the current file.
+// Returns false if nothing was playing.
+func (b *Backend) Finish() bool { return b.end(nil) }
+
+// Fail simulates playback of the current file stopping due to err, e.g.: an
+// expired stream url. Returns false if nothing was playing.
+func (b *Backend) Fail(err error) bool { return b.end(err) }
+
+func (b *Backend) end(err error) bool {
+	b.sem.Lock()
+	b.endErr = err
+	done := b.done
+	b.done = nil
+	b.position = 0
+	b.sem.Unlock()
+	if done == nil {
+		return false
+	}
+	done <- struct{}{}
+	return true
+}
+
+// Closed reports whether Close was called.
+func (b *Backend) Closed() bool {
+	b.sem.Lock()
+	defer b.sem.Unlock()
+	return b.closed
+}
+
+func (b *Backend) Init() error { return nil }
+
+func (b *Backend) Play(file string) (chan struct{}, error) {
+	b.sem.Lock()
+	defer b.sem.Unlock()
+	if len(b.failNext) != 0 {
+		err := b.failNext[0]
+		b.failNext = b.failNext[1:]
+		if err == nil {
+			err = errors.New("scripted failure")
+		}
+		return nil, err
+	}
+
+	b.played = append(b.played, file)
+	b.done = make(chan struct{}, 1)
+	b.position = 0
+	b.paused = false
+	return b.done, nil
+}
+
+func (b *Backend) PlaybackErr() error {
+	b.sem.Lock()
+	defer b.sem.Unlock()
+	return b.endErr
+}
+
+func (b *Backend) Paused() bool {
+	b.sem.Lock()
+	defer b.sem.Unlock()
+	return b.paused
+}
+
+func (b *Backend) Pause(p bool) {
+	b.sem.Lock()
+	b.paused = p
+	b.sem.Unlock()
+}
+
+func (b *Backend) TogglePause() {
+	b.sem.Lock()
+	b.paused = !b.paused
+	b.sem.Unlock()
+}
+
+func (b *Backend) SetVolume(n float64) {
+	b.sem.Lock()
+	b.volume = clamp(n, 0, 1)
+	b.sem.Unlock()
+}
+
+func (b *Backend) IncreaseVolume(n float64) {
+	b.sem.Lock()
+	b.volume = clamp(b.volume+n, 0, 1)
+	b.sem.Unlock()
+}
+
+func (b *Backend) Volume() float64 {
+	b.sem.Lock()
+	defer b.sem.Unlock()
+	return b.volume
+}
+
+func (b *Backend) Seek(d time.Duration, whence int) {
+	b.sem.Lock()
+	if whence == io.SeekCurrent {
+		d += b.position
+	}
+	b.position = time.Duration(clamp(float64(d), 0, float64(b.duration)))
+	b.sem.Unlock()
+}
+
+func (b *Backend) SeekTo(n float64) {
+	b.sem.Lock()
+	b.position = time.Duration(clamp(n, 0, 1) * float64(b.duration))
+	b.sem.Unlock()
+}
+
+func (b *Backend) Position() time.Duration {
+	b.sem.Lock()
+	defer b.sem.Unlock()
+	return b.position
+}
+
+func (b *Backend) Duration() time.Duration {
+	b.sem.Lock()
+	defer b.sem.Unlock()
+	return b.duration
+}
+
+func (b *Backend) Stop() {
+	b.sem.Lock()
+	b.done = nil
+	b.position = 0
+	b.sem.Unlock()
+}
+
+func (b *Backend) Close() error {
+	b.sem.Lock()
+	b.closed = true
+	b.sem.Unlock()
+	return nil
+}
+
+func clamp(n, min, max float64) float64 {
+	if n < min {
+		return min
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
@@ -0,0 +1,88 @@
+package libymtest
+
+import (
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/frizinak/libym/collection"
+	"github.com/frizinak/libym/player"
+)
+
+// NewCollection creates an initialized in-memory collection and its queue
+// that knows about fake songs. Its store is never written, downloads go to
+// a temporary directory that is removed when the test ends.
+// See collection.NewEphemeral.
+func NewCollection(t testing.TB) (*collection.Collection, *collection.Queue) {
+	t.Helper()
+	l := log.New(ioutil.Discard, "", 0)
+	q := collection.NewQueue()
+	c, err := collection.NewEphemeral(l, q, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	Register(c)
+	if err := c.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return c, q
+}
+
+// Run starts the downloads and title lookups of c without ratelimits until
+// the test ends.
+func Run(t testing.TB, c *collection.Collection) {
+	rate, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(rate)
+		tick := time.NewTicker(time.Millisecond * 10)
+		defer tick.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-tick.C:
+			}
+			select {
+			case rate <- struct{}{}:
+			case <-done:
+				return
+			}
+		}
+	}()
+	t.Cleanup(func() { close(done) })
+	c.Run(rate, rate)
+}
+
+// ErrorReporter collects reported errors.
+type ErrorReporter struct {
+	sem  sync.Mutex
+	errs []error
+}
+
+func (e *ErrorReporter) Err(err error) {
+	e.sem.Lock()
+	e.errs = append(e.errs, err)
+	e.sem.Unlock()
+}
+
+// Errs returns all reported errors.
+func (e *ErrorReporter) Errs() []error {
+	e.sem.Lock()
+	l := make([]error, len(e.errs))
+	copy(l, e.errs)
+	e.sem.Unlock()
+	return l
+}
+
+// NewPlayer creates a player for q using a fake backend.
+func NewPlayer(t testing.TB, q *collection.Queue) (*player.Player, *Backend, *ErrorReporter) {
+	t.Helper()
+	b := NewBackend()
+	r := &ErrorReporter{}
+	p := player.NewPlayer(b, r, q, filepath.Join(t.TempDir(), "position"))
+	return p, b, r
+}
@@ -0,0 +1,45 @@
+package libymtest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fakeFFmpeg copies its input (-i) to its output (the last argument),
+// "-" meaning stdin and stdout.
+const fakeFFmpeg = `#!/bin/sh
+in=""
+out=""
+while [ $# -gt 0 ]; do
+	if [ "$1" = "-i" ]; then
+		shift
+		in="$1"
+	fi
+	out="$1"
+	shift
+done
+[ "$in" = "-" ] && in=/dev/stdin
+[ "$out" = "-" ] && out=/dev/stdout
+cat "$in" > "$out"
+`
+
+// FakeFFmpeg puts an ffmpeg on PATH that copies audio as is, so the fake
+// Audio of a Video can be downloaded without ffmpeg being installed.
+// PATH is restored when the test ends. Skips the test on windows.
+// PATH is process-wide, tests calling FakeFFmpeg must not use t.Parallel.
+func FakeFFmpeg(t testing.TB) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("FakeFFmpeg requires sh")
+	}
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(fakeFFmpeg), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	t.Cleanup(func() { os.Setenv("PATH", path) })
+}
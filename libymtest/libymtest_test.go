@@ -0,0 +1,851 @@
+package libymtest_test
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/frizinak/libym/collection"
+	"github.com/frizinak/libym/jobs"
+	"github.com/frizinak/libym/libymtest"
+	"github.com/frizinak/libym/player"
+	"github.com/frizinak/libym/scraper"
+	"github.com/frizinak/libym/stats"
+	"github.com/frizinak/libym/ui"
+	"github.com/frizinak/libym/ui/base"
+	"github.com/frizinak/libym/youtube"
+)
+
+func TestYoutubeServer(t *testing.T) {
+	s := libymtest.NewYoutubeServer(
+		libymtest.Video{ID: "a", Title: "Artist - First"},
+		libymtest.Video{ID: "b", Title: "Other - Second"},
+	)
+	defer s.Close()
+	defer s.Install()()
+
+	r, err := youtube.Search("first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 1 || r[0].ID() != "a" || r[0].Title() != "Artist - First" {
+		t.Fatalf("unexpected search results %+v", r)
+	}
+
+	title, err := youtube.Title("b")
+	if err != nil || title != "Other - Second" {
+		t.Fatal(title, err)
+	}
+
+	if _, err := youtube.Title("missing"); err == nil {
+		t.Fatal("expected an error for an unknown video")
+	}
+}
+
+func TestLocalizedTitles(t *testing.T) {
+	s := libymtest.NewYoutubeServer(
+		libymtest.Video{ID: "a", Title: "Artist - Original", Titles: map[string]string{"nl": "Artist - Vertaald"}},
+	)
+	defer s.Close()
+	defer s.Install()()
+
+	r := youtube.NewResult("a", "")
+	if err := r.UpdateTitle(); err != nil {
+		t.Fatal(err)
+	}
+	if r.Title() != "Artist - Original" || r.OriginalTitle() != r.Title() {
+		t.Fatal("expected the original title without a language", r.Title(), r.OriginalTitle())
+	}
+
+	youtube.SetLanguage("nl, en;q=0.8")
+	defer youtube.SetLanguage("")
+	if err := r.UpdateTitle(); err != nil {
+		t.Fatal(err)
+	}
+	if r.Title() != "Artist - Vertaald" || r.OriginalTitle() != "Artist - Original" {
+		t.Fatal("expected a localized and an original title", r.Title(), r.OriginalTitle())
+	}
+}
+
+func TestPlayer(t *testing.T) {
+	c, q := libymtest.NewCollection(t)
+	p, b, r := libymtest.NewPlayer(t, q)
+
+	songs := make([]collection.Song, 0, 2)
+	for _, id := range []string{"one", "two"} {
+		s := libymtest.NewSong(id, id)
+		s.Remote = &url.URL{Scheme: "http", Host: "example.com", Path: id}
+		songs = append(songs, s)
+	}
+	c.QueueSongs(-1, songs, nil)
+
+	p.Play()
+	if b.Playing() != "http://example.com/one" {
+		t.Fatal("expected first song to play", b.Played())
+	}
+
+	b.Finish()
+	deadline := time.Now().Add(time.Second)
+	for b.Playing() != "http://example.com/two" {
+		if time.Now().After(deadline) {
+			t.Fatal("expected second song to play", b.Played())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if len(r.Errs()) != 0 {
+		t.Fatal(r.Errs())
+	}
+}
+
+func TestSave(t *testing.T) {
+	dir := t.TempDir()
+	open := func() *collection.Collection {
+		c := collection.New(log.New(ioutil.Discard, "", 0), dir, collection.NewQueue(), 2, false)
+		libymtest.Register(c)
+		if err := c.Init(); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	c := open()
+	if err := c.Create("sorted"); err != nil {
+		t.Fatal(err)
+	}
+	songs := []collection.Song{libymtest.NewSong("b", "B"), libymtest.NewSong("a", "A")}
+	if err := c.AddSongs("sorted", songs, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetPlaylistSettings("sorted", collection.PlaylistSettings{Sort: collection.SortTitle}); err != nil {
+		t.Fatal(err)
+	}
+
+	// settings change while saving.
+	stop := make(chan struct{})
+	changed := make(chan struct{})
+	go func() {
+		defer close(changed)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			c.SetPlaylistSettings("sorted", collection.PlaylistSettings{Sort: collection.SortTitle})
+		}
+	}()
+
+	done := make(chan error, 1)
+	go func() { done <- c.Save() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("save deadlocked")
+	}
+	close(stop)
+	<-changed
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c = open()
+	defer c.Close()
+	if s, err := c.PlaylistSettings("sorted"); err != nil || s.Sort != collection.SortTitle {
+		t.Fatal("expected the sort key to be saved", s, err)
+	}
+}
+
+func TestLoadCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	f, err := os.Create(filepath.Join(dir, "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := gzip.NewWriter(f)
+	// no songs and a playlist with more songs than any store holds,
+	// followed by nothing.
+	w.Write([]byte{0, 0, 0, 0, 0, 0, 0, 0})
+	w.Write([]byte{3, 0, 'm', 'i', 'x'})
+	w.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	w.Close()
+	f.Close()
+
+	c := collection.New(log.New(ioutil.Discard, "", 0), dir, collection.NewQueue(), 1, false)
+	libymtest.Register(c)
+	if err := c.Init(); err == nil {
+		t.Fatal("expected a corrupt store to fail to load")
+	}
+}
+
+func TestSaveOriginalTitles(t *testing.T) {
+	dir := t.TempDir()
+	open := func() (*collection.Collection, *collection.Queue) {
+		q := collection.NewQueue()
+		c := collection.New(log.New(ioutil.Discard, "", 0), dir, q, 2, false)
+		libymtest.Register(c)
+		if err := c.Init(); err != nil {
+			t.Fatal(err)
+		}
+		return c, q
+	}
+
+	c, _ := open()
+	r := youtube.NewResult("a", "Artist - Vertaald")
+	r.SetOriginalTitle("Artist - Original")
+	c.QueueSong(-1, c.FromYoutube(r))
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, q := open()
+	defer c.Close()
+	l := q.Slice()
+	if len(l) != 1 {
+		t.Fatal("expected the queued song to be restored", l)
+	}
+	o, ok := l[0].(*collection.QueueItem).Song.(collection.OriginalTitler)
+	if !ok || l[0].Title() != "Artist - Vertaald" || o.OriginalTitle() != "Artist - Original" {
+		t.Fatal("expected the original title to be restored", l[0].Title(), ok)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	c, q := libymtest.NewCollection(t)
+	p, b, _ := libymtest.NewPlayer(t, q)
+
+	if st := p.Status(); st.Song != nil || st.Next != nil {
+		t.Fatal("expected an empty status", st)
+	}
+
+	one, two := libymtest.NewSong("one", "one"), libymtest.NewSong("two", "two")
+	one.Remote = &url.URL{Scheme: "http", Host: "example.com", Path: "one"}
+	c.QueueSongs(-1, []collection.Song{one, two}, nil)
+
+	p.Play()
+	p.Seek(time.Second*10, io.SeekStart)
+	st := p.Status()
+	if st.Song == nil || st.Song.ID() != "one" || st.Next == nil || st.Next.ID() != "two" {
+		t.Fatal("expected current and next song", st)
+	}
+	if !st.Stream || st.Paused || st.Position != time.Second*10 || st.Duration != b.Duration() {
+		t.Fatal("unexpected playback state", st)
+	}
+}
+
+func TestEndHandler(t *testing.T) {
+	c, q := libymtest.NewCollection(t)
+	p, b, _ := libymtest.NewPlayer(t, q)
+
+	one := libymtest.NewSong("one", "one")
+	one.Remote = &url.URL{Scheme: "http", Host: "example.com", Path: "one"}
+	c.QueueSongs(-1, []collection.Song{one}, nil)
+
+	ended := make(chan struct{}, 1)
+	p.SetEndHandler(func() bool {
+		ended <- struct{}{}
+		q.SetCurrentIndex(0)
+		return true
+	})
+
+	p.Play()
+	b.Finish()
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Fatal("expected the end handler to be called")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(b.Played()) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the queue to repeat", b.Played())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWake(t *testing.T) {
+	c, q := libymtest.NewCollection(t)
+	p, b, r := libymtest.NewPlayer(t, q)
+
+	s := libymtest.NewSong("one", "one")
+	s.Remote = &url.URL{Scheme: "http", Host: "example.com", Path: "one"}
+	c.QueueSong(-1, s)
+
+	p.Play()
+	p.Seek(time.Minute, io.SeekStart)
+	p.Wake()
+
+	if l := b.Played(); len(l) != 2 || l[1] != "http://example.com/one" {
+		t.Fatal("expected stream to be reloaded", l)
+	}
+	if b.Position() != time.Minute {
+		t.Fatal("expected playback to resume at the last position", b.Position())
+	}
+	if len(r.Errs()) != 0 {
+		t.Fatal(r.Errs())
+	}
+}
+
+func TestDuck(t *testing.T) {
+	_, q := libymtest.NewCollection(t)
+	p, b, _ := libymtest.NewPlayer(t, q)
+	b.SetVolume(0.8)
+
+	wait := func(vol float64, msg string) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second * 2)
+		for b.Volume() < vol-0.001 || b.Volume() > vol+0.001 {
+			if time.Now().After(deadline) {
+				t.Fatal(msg, b.Volume())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	p.Duck(0.25, time.Millisecond*100)
+	wait(0.2, "expected volume to be ducked")
+	if !p.Ducked() {
+		t.Fatal("expected player to be ducked")
+	}
+	wait(0.8, "expected volume to be restored")
+
+	p.Duck(0.5, time.Millisecond*100)
+	wait(0.4, "expected volume to be ducked")
+	p.SetVolume(0.6)
+	time.Sleep(player.DuckRamp*2 + time.Millisecond*100)
+	if b.Volume() != 0.6 || p.Ducked() {
+		t.Fatal("expected volume changed while ducked to be kept", b.Volume())
+	}
+}
+
+func TestResumeFade(t *testing.T) {
+	c, q := libymtest.NewCollection(t)
+	p, b, _ := libymtest.NewPlayer(t, q)
+	s := libymtest.NewSong("one", "one")
+	s.Remote = &url.URL{Scheme: "http", Host: "example.com", Path: "one"}
+	c.QueueSong(-1, s)
+	b.SetVolume(0.8)
+	p.SetFade(time.Millisecond * 500)
+	p.Play()
+	p.Pause()
+
+	start := time.Now()
+	p.Play()
+	if p.Current() == nil || time.Since(start) > time.Millisecond*250 {
+		t.Fatal("expected play to return before the fade ends", time.Since(start))
+	}
+	if v := b.Volume(); v >= 0.8 {
+		t.Fatal("expected the volume to fade in", v)
+	}
+
+	p.Pause()
+	if !p.Paused() || b.Volume() != 0.8 {
+		t.Fatal("expected pause to stop the fade and restore the volume", b.Volume())
+	}
+	time.Sleep(time.Millisecond * 100)
+	if b.Volume() != 0.8 {
+		t.Fatal("expected the fade to be canceled", b.Volume())
+	}
+
+	p.Play()
+	deadline := time.Now().Add(time.Second * 2)
+	for b.Volume() < 0.8-0.001 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the volume to be restored", b.Volume())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStreamRefresh(t *testing.T) {
+	c, q := libymtest.NewCollection(t)
+	p, b, r := libymtest.NewPlayer(t, q)
+
+	s := libymtest.NewSong("one", "one")
+	s.Remote = &url.URL{Scheme: "http", Host: "example.com", Path: "one"}
+	c.QueueSong(-1, s)
+
+	p.Play()
+	p.Seek(time.Minute, io.SeekStart)
+	time.Sleep(time.Millisecond * 1100)
+	b.Fail(errors.New("http error 403"))
+
+	deadline := time.Now().Add(time.Second)
+	for len(b.Played()) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected stream to be reloaded", b.Played())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if b.Position() != time.Minute {
+		t.Fatal("expected playback to resume at the last position", b.Position())
+	}
+	if len(r.Errs()) != 0 {
+		t.Fatal(r.Errs())
+	}
+
+	b.Fail(errors.New("http error 403"))
+	deadline = time.Now().Add(time.Second)
+	for len(r.Errs()) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected a failure without progress to be reported")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAlternatives(t *testing.T) {
+	c, q := libymtest.NewCollection(t)
+	p, b, r := libymtest.NewPlayer(t, q)
+	p.SetAlternatives(func(s collection.Song) []collection.Song { return c.Alternatives(s) })
+
+	s := libymtest.NewSong("one", "one")
+	alt := libymtest.NewSong("two", "one")
+	alt.Remote = &url.URL{Scheme: "http", Host: "example.com", Path: "two"}
+	if err := c.AddAlternative(s, alt); err != nil {
+		t.Fatal(err)
+	}
+	c.QueueSong(-1, s)
+
+	p.Play()
+	if b.Playing() != alt.Remote.String() {
+		t.Fatal("expected the alternative to be played", b.Played())
+	}
+	if len(r.Errs()) != 0 {
+		t.Fatal(r.Errs())
+	}
+}
+
+type mixer struct{ t player.Transition }
+
+func (m mixer) Transition(cur, next collection.Song, d time.Duration) (player.Transition, bool) {
+	return m.t, true
+}
+
+func TestMix(t *testing.T) {
+	c, q := libymtest.NewCollection(t)
+	p, b, _ := libymtest.NewPlayer(t, q)
+	p.SetMixer(mixer{player.Transition{Out: time.Minute, In: time.Second * 5, Fade: time.Millisecond * 100}})
+
+	dir := t.TempDir()
+	for _, id := range []string{"one", "two"} {
+		s := libymtest.NewSong(id, id)
+		s.Path = filepath.Join(dir, id)
+		if err := ioutil.WriteFile(s.Path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		c.QueueSong(-1, s)
+	}
+
+	p.Play()
+	b.Seek(time.Minute, io.SeekStart)
+	deadline := time.Now().Add(time.Second)
+	for len(b.Played()) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the next song to be mixed in", b.Played())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(time.Millisecond * 100)
+	if b.Position() != time.Second*5 {
+		t.Fatal("expected the next song to start at its transition point", b.Position())
+	}
+	if b.Volume() != 1 {
+		t.Fatal("expected the volume to be restored", b.Volume())
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	c, _ := libymtest.NewCollection(t)
+	var queue int
+	playlists := make([]string, 0)
+	c.OnQueueChanged(func() { queue++ })
+	c.OnPlaylistChanged(func(pl string) { playlists = append(playlists, pl) })
+
+	if err := c.Create("mix"); err != nil {
+		t.Fatal(err)
+	}
+	songs := []collection.Song{libymtest.NewSong("a", "a"), libymtest.NewSong("b", "b")}
+	if err := c.AddSongs("mix", songs, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	c.QueueSongs(-1, songs, nil)
+
+	if queue != 1 {
+		t.Errorf("expected a single queue notification, got %d", queue)
+	}
+	if len(playlists) != 2 || playlists[0] != "mix" || playlists[1] != "mix" {
+		t.Errorf("unexpected playlist notifications %v", playlists)
+	}
+}
+
+func newBaseUI(
+	t *testing.T,
+	parser ui.Parser,
+	p *player.Player,
+	c *collection.Collection,
+	q *collection.Queue,
+	m *jobs.Manager,
+) *base.UI {
+	return base.New(
+		base.NewSimpleOutput(ioutil.Discard),
+		&libymtest.ErrorReporter{},
+		parser,
+		p, c, q, nil, m,
+		jobs.NewScheduler(m),
+		stats.NewLog(filepath.Join(t.TempDir(), "plays.jsonl")),
+	)
+}
+
+func TestCancelPendingJob(t *testing.T) {
+	c, q := libymtest.NewCollection(t)
+	p, _, _ := libymtest.NewPlayer(t, q)
+	m := jobs.NewManager()
+	m.SetLimit(jobs.KindScrape, 1)
+
+	parser := ui.NewParser()
+	parser.Alias(ui.CmdJobs, ui.Zero, nil, "jobs")
+	parser.Alias(ui.CmdCancelJob, ui.One, nil, "cancel")
+	u := newBaseUI(t, parser, p, c, q, m)
+
+	block := func(j *jobs.Job) (interface{}, error) {
+		<-j.Context().Done()
+		return nil, j.Context().Err()
+	}
+	running := m.Run(jobs.KindScrape, "running", block)
+	defer running.Cancel()
+	pending := m.Run(jobs.KindScrape, "pending", block)
+	if pending.State() != jobs.StatePending {
+		t.Fatal("expected the second job to be pending", pending.State())
+	}
+
+	done := make(chan struct{})
+	go func() {
+		u.Input("jobs; cancel 2")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("canceling a pending job deadlocked")
+	}
+	if pending.State() != jobs.StateCanceled {
+		t.Fatal("expected the pending job to be canceled", pending.State())
+	}
+}
+
+func TestUISession(t *testing.T) {
+	c, q := libymtest.NewCollection(t)
+	p, _, _ := libymtest.NewPlayer(t, q)
+	if err := c.Create("mix"); err != nil {
+		t.Fatal(err)
+	}
+
+	parser := ui.NewParser()
+	parser.Alias(ui.CmdViewPlaylist, ui.One, nil, "playlist")
+	parser.Alias(ui.CmdScroll, ui.One, nil, "scroll")
+	file := filepath.Join(t.TempDir(), "ui-session")
+	newUI := func() *base.UI {
+		u := newBaseUI(t, parser, p, c, q, jobs.NewManager())
+		if err := u.SetSessionFile(file); err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	u := newUI()
+	u.Input("playlist mix; scroll 40")
+	if v := u.Snapshot(); v.View != ui.ViewPlaylist || v.Offset != 40 {
+		t.Fatalf("expected the playlist at offset 40, got %d at %d", v.View, v.Offset)
+	}
+
+	v := newUI().Snapshot()
+	if v.View != ui.ViewPlaylist || v.Title != "playlist: mix" || v.Offset != 40 {
+		t.Fatalf("expected the restored playlist at offset 40, got %d '%s' at %d", v.View, v.Title, v.Offset)
+	}
+
+	if err := c.Delete("mix"); err != nil {
+		t.Fatal(err)
+	}
+	if v := newUI().Snapshot(); v.View != ui.ViewQueue || v.Offset != 0 {
+		t.Fatalf("expected the queue for a deleted playlist, got %d at %d", v.View, v.Offset)
+	}
+}
+
+func TestEndToEnd(t *testing.T) {
+	libymtest.FakeFFmpeg(t)
+	s := libymtest.NewYoutubeServer(
+		libymtest.Video{ID: "a", Title: "Artist - First", Audio: []byte("first audio")},
+		libymtest.Video{ID: "b", Title: "Other - Second", Audio: []byte("second audio")},
+	)
+	defer s.Close()
+	defer s.Install()()
+
+	var found []string
+	scr := scraper.New(scraper.Config{})
+	err := youtube.NewScraper(scr, func(r *youtube.Result) {
+		found = append(found, r.ID())
+	}).Scrape("https://example.com/links")
+	if err != nil || len(found) != 2 {
+		t.Fatal("expected to scrape both videos", found, err)
+	}
+
+	c, q := libymtest.NewCollection(t)
+	p, b, _ := libymtest.NewPlayer(t, q)
+	libymtest.Run(t, c)
+
+	r, err := youtube.Search("first")
+	if err != nil || len(r) != 1 {
+		t.Fatal("expected a single search result", r, err)
+	}
+	song := c.FromYoutube(r[0])
+	if err := c.Create("mix"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddSong("mix", song, false); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !song.Local() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the song to be downloaded")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	file, err := song.File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil || string(data) != "first audio" {
+		t.Fatalf("unexpected download '%s': %v", data, err)
+	}
+
+	if err := c.Queue(-1, "mix"); err != nil {
+		t.Fatal(err)
+	}
+	p.Play()
+	deadline = time.Now().Add(time.Second)
+	for b.Playing() != file {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the download to be played", b.Played())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGCDuringDownload(t *testing.T) {
+	libymtest.FakeFFmpeg(t)
+	hold := make(chan struct{})
+	s := libymtest.NewYoutubeServer(
+		libymtest.Video{ID: "a", Title: "Artist - Slow", Audio: []byte("slow audio"), Hold: hold},
+	)
+	defer s.Close()
+	defer s.Install()()
+	released := false
+	defer func() {
+		if !released {
+			close(hold)
+		}
+	}()
+
+	c, _ := libymtest.NewCollection(t)
+	libymtest.Run(t, c)
+
+	r, err := youtube.Search("slow")
+	if err != nil || len(r) != 1 {
+		t.Fatal("expected a single search result", r, err)
+	}
+	song := c.FromYoutube(r[0])
+	if err := c.Create("mix"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddSong("mix", song, false); err != nil {
+		t.Fatal(err)
+	}
+	file, err := song.File()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if g, _ := filepath.Glob(file + ".*.tmp"); len(g) != 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the download to start")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	// the song is no longer referenced but still downloading.
+	if err := c.DelSong("mix", song); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := c.RemoveUnreferencedDownloads(); err != nil || n != 0 {
+		t.Fatalf("expected gc to skip the download, removed %d: %v", n, err)
+	}
+
+	released = true
+	close(hold)
+	deadline = time.Now().Add(5 * time.Second)
+	for !song.Local() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the download to finish")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestDownloadAfterCancel(t *testing.T) {
+	libymtest.FakeFFmpeg(t)
+	s := libymtest.NewYoutubeServer(
+		libymtest.Video{ID: "a", Title: "Artist - Queued", Audio: []byte("queued audio")},
+	)
+	defer s.Close()
+	defer s.Install()()
+
+	c, _ := libymtest.NewCollection(t)
+	var idle int32
+	c.Jobs().SetIdlePolicy(func() bool { return atomic.LoadInt32(&idle) == 1 }, jobs.KindDownload)
+	t.Cleanup(func() { c.Jobs().SetIdlePolicy(nil) })
+	libymtest.Run(t, c)
+
+	r, err := youtube.Search("queued")
+	if err != nil || len(r) != 1 {
+		t.Fatal("expected a single search result", r, err)
+	}
+	song := c.FromYoutube(r[0])
+	if err := c.Create("mix"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddSong("mix", song, false); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c.TaskStatus().Downloads.TotalPending() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the download to be queued")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if !c.CancelDownload(song) {
+		t.Fatal("expected the queued download to be canceled")
+	}
+
+	atomic.StoreInt32(&idle, 1)
+	c.Download(song)
+	deadline = time.Now().Add(5 * time.Second)
+	for !song.Local() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the canceled download to restart")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestEphemeralClose(t *testing.T) {
+	l := log.New(ioutil.Discard, "", 0)
+	dir := t.TempDir()
+	c := collection.New(l, dir, collection.NewQueue(), 1, false)
+	c.SetEphemeral(true)
+	if err := c.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatal("expected the directory passed to New to be kept", err)
+	}
+
+	c, err := collection.NewEphemeral(l, collection.NewQueue(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(c.Dir()); !os.IsNotExist(err) {
+		t.Fatal("expected the temporary directory to be removed", err)
+	}
+}
+
+func TestNewCollectionInMemory(t *testing.T) {
+	c, _ := libymtest.NewCollection(t)
+	if err := c.Create("mix"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	files, err := ioutil.ReadDir(c.Dir())
+	if err != nil || len(files) != 0 {
+		t.Fatal("expected nothing to be written", files, err)
+	}
+}
+
+func TestTitleRetry(t *testing.T) {
+	c, _ := libymtest.NewCollection(t)
+	c.SetTitleRetry(time.Millisecond * 50)
+	libymtest.Run(t, c)
+	if err := c.Create("mix"); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(t.TempDir(), "a")
+	if err := ioutil.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := libymtest.NewSong("a", "")
+	s.Path = file
+	s.NextTitle = "A"
+	s.TitleErr = errors.New("temporary failure")
+	if err := c.AddSong("mix", s, false); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, ok := c.Problematics().Get(s); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the title lookup to fail")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	s.SetTitleErr(nil)
+	time.Sleep(time.Millisecond * 60)
+	c.RefreshTitles()
+	deadline = time.Now().Add(time.Second)
+	for s.Title() != "A" {
+		if time.Now().After(deadline) {
+			t.Fatal("expected a failed title lookup to be retried before the TitleTTL")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,118 @@
+// Package libymtest provides fakes for writing tests against libym without
+// network access, mpv or a persistent store.
+package libymtest
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/frizinak/binary"
+	"github.com/frizinak/libym/collection"
+)
+
+// NS is the namespace of fake songs.
+const NS = "libymtest"
+
+// Song is a fake collection.Song.
+type Song struct {
+	sem   sync.RWMutex
+	id    string
+	title string
+
+	// Path is the local file of this song, Local reports whether it exists.
+	Path string
+	// Remote is returned by URL, an error is returned if nil.
+	Remote *url.URL
+	// NextTitle is the title UpdateTitle sets.
+	NextTitle string
+	// TitleErr is returned by UpdateTitle if non-nil, use SetTitleErr
+	// once the song is in use.
+	TitleErr error
+}
+
+// NewSong creates a new fake song.
+func NewSong(id, title string) *Song { return &Song{id: id, title: title} }
+
+func (s *Song) NS() string { return NS }
+func (s *Song) ID() string { return s.id }
+
+func (s *Song) Title() string {
+	s.sem.RLock()
+	t := s.title
+	s.sem.RUnlock()
+	return t
+}
+
+func (s *Song) SetTitle(title string) {
+	s.sem.Lock()
+	s.title = title
+	s.sem.Unlock()
+}
+
+func (s *Song) UpdateTitle() error { return s.UpdateTitleContext(context.Background()) }
+
+// SetTitleErr sets TitleErr.
+func (s *Song) SetTitleErr(err error) {
+	s.sem.Lock()
+	s.TitleErr = err
+	s.sem.Unlock()
+}
+
+func (s *Song) UpdateTitleContext(ctx context.Context) error {
+	s.sem.RLock()
+	err := s.TitleErr
+	s.sem.RUnlock()
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.SetTitle(s.NextTitle)
+	return nil
+}
+
+func (s *Song) Local() bool {
+	if s.Path == "" {
+		return false
+	}
+	_, err := os.Stat(s.Path)
+	return err == nil
+}
+
+func (s *Song) File() (string, error) { return s.Path, nil }
+
+func (s *Song) URL() (*url.URL, error) { return s.URLContext(context.Background()) }
+
+func (s *Song) URLContext(ctx context.Context) (*url.URL, error) {
+	if s.Remote == nil {
+		return nil, errors.New("song has no url")
+	}
+	return s.Remote, ctx.Err()
+}
+
+func (s *Song) PageURL() (*url.URL, error) { return s.URL() }
+
+func (s *Song) Marshal(w *binary.Writer) error {
+	w.WriteString(s.ID(), 8)
+	w.WriteString(s.Title(), 16)
+	return w.Err()
+}
+
+// Register registers the unmarshaler for fake songs with c.
+// Unmarshaled songs have their Path set to c.SongPath.
+func Register(c *collection.Collection) {
+	c.RegisterUnmarshaler(NS, func(dec *binary.Reader) (collection.Song, error) {
+		id := dec.ReadString(8)
+		title := dec.ReadString(16)
+		if err := dec.Err(); err != nil {
+			return nil, err
+		}
+		s := NewSong(id, title)
+		s.Path = c.SongPath(s)
+		return s, nil
+	})
+}
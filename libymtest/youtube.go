@@ -0,0 +1,165 @@
+package libymtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/frizinak/libym/failure"
+	"github.com/frizinak/libym/scraper"
+	"github.com/frizinak/libym/youtube"
+)
+
+// Video is a clip served by a YoutubeServer.
+type Video struct {
+	ID    string
+	Title string
+	// Titles are localized titles by language, served instead of Title
+	// if it is the first language in Accept-Language, e.g.: {"nl": "..."}.
+	Titles map[string]string
+	// Audio is served at /audio/<ID>.
+	Audio []byte
+	// Hold delays serving Audio until it is closed, e.g.: to act while a
+	// download is in progress.
+	Hold <-chan struct{}
+}
+
+// YoutubeServer is a fake youtube.com serving search result pages, watch
+// pages and audio for a fixed set of videos. /links is a page linking to
+// all videos, e.g.: to scrape.
+type YoutubeServer struct {
+	*httptest.Server
+	videos map[string]Video
+	order  []string
+}
+
+// NewYoutubeServer starts a new fake youtube.com, Close it when done.
+func NewYoutubeServer(videos ...Video) *YoutubeServer {
+	s := &YoutubeServer{videos: make(map[string]Video, len(videos))}
+	for _, v := range videos {
+		s.videos[v.ID] = v
+		s.order = append(s.order, v.ID)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/results", s.search)
+	mux.HandleFunc("/watch", s.watch)
+	mux.HandleFunc("/audio/", s.audio)
+	mux.HandleFunc("/links", s.links)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// AudioURL returns the url the audio of the given video is served at.
+func (s *YoutubeServer) AudioURL(id string) string {
+	return s.URL + "/audio/" + id
+}
+
+// Client returns an http client that sends all requests, regardless of
+// host, to this server.
+func (s *YoutubeServer) Client() *http.Client {
+	return &http.Client{Transport: &rewrite{s.Server}}
+}
+
+// Install makes the youtube package use this server, including to resolve
+// stream urls, see youtube.SetResolver, and the scraper, see
+// scraper.SetDefaultClient. Together with FakeFFmpeg and Run, songs added
+// to a collection are downloaded from AudioURL.
+// Call the returned func to restore the default clients and resolver.
+func (s *YoutubeServer) Install() (restore func()) {
+	youtube.SetHTTPClient(s.Client())
+	youtube.SetResolver(s.resolve)
+	scraper.SetDefaultClient(s.Client())
+	return func() {
+		youtube.SetHTTPClient(nil)
+		youtube.SetResolver(nil)
+		scraper.SetDefaultClient(nil)
+	}
+}
+
+func (s *YoutubeServer) resolve(ctx context.Context, id string) (*url.URL, error) {
+	if _, ok := s.videos[id]; !ok {
+		return nil, failure.Wrap(failure.NotFound, errors.New("video unavailable"))
+	}
+	return url.Parse(s.AudioURL(id))
+}
+
+type rewrite struct{ s *httptest.Server }
+
+func (r *rewrite) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = "http"
+	req.URL.Host = r.s.Listener.Addr().String()
+	return r.s.Client().Transport.RoundTrip(req)
+}
+
+func (s *YoutubeServer) search(w http.ResponseWriter, r *http.Request) {
+	q := strings.ToLower(r.URL.Query().Get("search_query"))
+	contents := make([]interface{}, 0)
+	for _, id := range s.order {
+		v := s.videos[id]
+		if !strings.Contains(strings.ToLower(v.Title), q) {
+			continue
+		}
+		contents = append(contents, map[string]interface{}{
+			"videoRenderer": map[string]interface{}{
+				"videoId": v.ID,
+				"title": map[string]interface{}{
+					"runs": []interface{}{map[string]interface{}{"text": v.Title}},
+				},
+			},
+		})
+	}
+
+	data, err := json.Marshal(map[string]interface{}{"contents": contents})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "<html><body><script>var ytInitialData = %s;</script></body></html>", data)
+}
+
+func (s *YoutubeServer) watch(w http.ResponseWriter, r *http.Request) {
+	v, ok := s.videos[r.URL.Query().Get("v")]
+	if !ok {
+		fmt.Fprint(w, "<html><head><title>YouTube</title></head></html>")
+		return
+	}
+	title := v.Title
+	lang := strings.TrimSpace(strings.SplitN(r.Header.Get("Accept-Language"), ",", 2)[0])
+	if t, ok := v.Titles[lang]; ok {
+		title = t
+	}
+	fmt.Fprintf(w, "<html><head><title>%s - YouTube</title></head></html>", html.EscapeString(title))
+}
+
+func (s *YoutubeServer) links(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, "<html><body>")
+	for _, id := range s.order {
+		fmt.Fprintf(w, `<a href="https://youtu.be/%s">%s</a>`, id, html.EscapeString(s.videos[id].Title))
+	}
+	fmt.Fprint(w, "</body></html>")
+}
+
+func (s *YoutubeServer) audio(w http.ResponseWriter, r *http.Request) {
+	v, ok := s.videos[strings.TrimPrefix(r.URL.Path, "/audio/")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if v.Hold != nil {
+		select {
+		case <-v.Hold:
+		case <-r.Context().Done():
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "audio/mpeg")
+	w.Write(v.Audio)
+}
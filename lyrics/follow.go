@@ -0,0 +1,61 @@
+package lyrics
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultInterval is how often Follow polls the position if no interval is
+// given.
+const DefaultInterval = time.Millisecond * 100
+
+// Positioner reports the playback position, e.g.: *player.Player.
+type Positioner interface {
+	Position() time.Duration
+}
+
+// Event is sent by Follow whenever the current line changes.
+type Event struct {
+	// Index is the index of the current line in Lyrics.Lines, -1 before
+	// the first line.
+	Index int
+	Line  Line
+}
+
+// Follow polls the position of p and sends an Event every time the current
+// line changes, including when seeking backwards.
+// The channel is closed when ctx is done.
+func Follow(ctx context.Context, p Positioner, l *Lyrics, interval time.Duration) <-chan Event {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ch := make(chan Event, 1)
+	go func() {
+		defer close(ch)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		last := -2
+		for {
+			if ix := l.Index(p.Position()); ix != last {
+				last = ix
+				ev := Event{Index: ix}
+				if ix >= 0 {
+					ev.Line = l.Lines[ix]
+				}
+				select {
+				case ch <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
@@ -0,0 +1,125 @@
+package lyrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/frizinak/libym/failure"
+)
+
+// Fetcher looks up lyrics of a song by its title, in the common
+// "Artist - Title" format, and duration (0 if unknown).
+// Returns the raw LRC (or plain) lyrics.
+type Fetcher interface {
+	Fetch(ctx context.Context, title string, duration time.Duration) (string, error)
+}
+
+// DefaultLRCLibURL is the public LRCLIB instance.
+const DefaultLRCLibURL = "https://lrclib.net"
+
+// LRCLib fetches lyrics from an LRCLIB server.
+type LRCLib struct {
+	base string
+	http *http.Client
+}
+
+// NewLRCLib creates a Fetcher for the LRCLIB server at the given url,
+// empty uses DefaultLRCLibURL.
+func NewLRCLib(base string) *LRCLib {
+	if base == "" {
+		base = DefaultLRCLibURL
+	}
+	return &LRCLib{base: strings.TrimRight(base, "/"), http: http.DefaultClient}
+}
+
+// SetHTTPClient sets the client used for api requests.
+// nil restores http.DefaultClient.
+func (l *LRCLib) SetHTTPClient(h *http.Client) {
+	if h == nil {
+		h = http.DefaultClient
+	}
+	l.http = h
+}
+
+type lrclibResult struct {
+	Duration     float64 `json:"duration"`
+	PlainLyrics  string  `json:"plainLyrics"`
+	SyncedLyrics string  `json:"syncedLyrics"`
+}
+
+func (r lrclibResult) lyrics() string {
+	if r.SyncedLyrics != "" {
+		return r.SyncedLyrics
+	}
+	return r.PlainLyrics
+}
+
+func (l *LRCLib) get(ctx context.Context, path string, query url.Values, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", l.base+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	res, err := l.http.Do(req)
+	if err != nil {
+		return failure.Wrap(failure.KindOf(err), err)
+	}
+	defer res.Body.Close()
+	if err := failure.HTTPStatus(res.StatusCode); err != nil {
+		return fmt.Errorf("lrclib: %w", err)
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return failure.Wrap(failure.ParserBroken, err)
+	}
+	return nil
+}
+
+// Fetch implements Fetcher. It searches by title and prefers synced lyrics
+// of the result closest in duration.
+func (l *LRCLib) Fetch(ctx context.Context, title string, duration time.Duration) (string, error) {
+	query := make(url.Values)
+	if p := strings.SplitN(title, " - ", 2); len(p) == 2 {
+		query.Set("artist_name", strings.TrimSpace(p[0]))
+		query.Set("track_name", strings.TrimSpace(p[1]))
+	} else {
+		query.Set("q", title)
+	}
+
+	var results []lrclibResult
+	if err := l.get(ctx, "/api/search", query, &results); err != nil {
+		return "", err
+	}
+
+	var best *lrclibResult
+	diff := func(r lrclibResult) float64 {
+		if duration <= 0 {
+			return 0
+		}
+		d := r.Duration - duration.Seconds()
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+	for i := range results {
+		r := results[i]
+		if r.lyrics() == "" {
+			continue
+		}
+		switch {
+		case best == nil,
+			r.SyncedLyrics != "" && best.SyncedLyrics == "",
+			(r.SyncedLyrics != "") == (best.SyncedLyrics != "") && diff(r) < diff(*best):
+			best = &results[i]
+		}
+	}
+
+	if best == nil {
+		return "", failure.Wrap(failure.NotFound, fmt.Errorf("lrclib: no lyrics for '%s'", title))
+	}
+	return best.lyrics(), nil
+}
@@ -0,0 +1,129 @@
+// Package lyrics parses time-synced lyrics in the LRC format and follows
+// them along with the playback position.
+package lyrics
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Line is a single line of lyrics.
+type Line struct {
+	// At is the position in the song the line starts at.
+	At   time.Duration
+	Text string
+}
+
+// Lyrics are the lines of a song sorted by time.
+type Lyrics struct {
+	// Meta holds the id tags, e.g.: ar (artist), ti (title) and al (album).
+	Meta  map[string]string
+	Lines []Line
+	// Synced is false if none of the lines had a timestamp,
+	// i.e.: plain lyrics.
+	Synced bool
+}
+
+var (
+	timeTagRE = regexp.MustCompile(`^\[(\d+):(\d{1,2})(?:[.:](\d{1,3}))?\]`)
+	idTagRE   = regexp.MustCompile(`^\[([a-zA-Z#]+):(.*)\]\s*$`)
+)
+
+func parseTimeTag(m []string) time.Duration {
+	min, _ := strconv.Atoi(m[1])
+	sec, _ := strconv.Atoi(m[2])
+	d := time.Duration(min)*time.Minute + time.Duration(sec)*time.Second
+	if m[3] != "" {
+		frac, _ := strconv.Atoi(m[3])
+		for i := len(m[3]); i < 3; i++ {
+			frac *= 10
+		}
+		d += time.Duration(frac) * time.Millisecond
+	}
+	return d
+}
+
+// Parse reads LRC formatted lyrics, lines with multiple timestamps are
+// repeated and the offset tag is applied.
+// Lyrics without any timestamps are returned as is with Synced false.
+func Parse(r io.Reader) (*Lyrics, error) {
+	l := &Lyrics{Meta: make(map[string]string)}
+	var offset time.Duration
+	scan := bufio.NewScanner(r)
+	scan.Buffer(nil, 1024*1024)
+	for scan.Scan() {
+		line := strings.TrimSpace(scan.Text())
+		line = strings.TrimPrefix(line, "\ufeff")
+
+		var at []time.Duration
+		for {
+			m := timeTagRE.FindStringSubmatch(line)
+			if m == nil {
+				break
+			}
+			at = append(at, parseTimeTag(m))
+			line = line[len(m[0]):]
+		}
+
+		if len(at) == 0 {
+			if m := idTagRE.FindStringSubmatch(line); m != nil {
+				key, value := strings.ToLower(m[1]), strings.TrimSpace(m[2])
+				l.Meta[key] = value
+				if key == "offset" {
+					ms, _ := strconv.Atoi(value)
+					offset = time.Duration(ms) * time.Millisecond
+				}
+				continue
+			}
+			if !l.Synced && line != "" {
+				l.Lines = append(l.Lines, Line{Text: line})
+			}
+			continue
+		}
+
+		if !l.Synced {
+			l.Synced, l.Lines = true, l.Lines[:0]
+		}
+		text := strings.TrimSpace(line)
+		for _, t := range at {
+			l.Lines = append(l.Lines, Line{At: t, Text: text})
+		}
+	}
+	if err := scan.Err(); err != nil {
+		return nil, err
+	}
+
+	if l.Synced {
+		for i := range l.Lines {
+			// a positive offset shifts lyrics up, i.e.: earlier.
+			l.Lines[i].At -= offset
+			if l.Lines[i].At < 0 {
+				l.Lines[i].At = 0
+			}
+		}
+		sort.SliceStable(l.Lines, func(i, j int) bool {
+			return l.Lines[i].At < l.Lines[j].At
+		})
+	}
+
+	return l, nil
+}
+
+// ParseString is a convenience wrapper around Parse.
+func ParseString(s string) (*Lyrics, error) { return Parse(strings.NewReader(s)) }
+
+// Index returns the index of the line that is sung at the given position,
+// -1 if no line has started yet or the lyrics are not synced.
+func (l *Lyrics) Index(pos time.Duration) int {
+	if !l.Synced {
+		return -1
+	}
+	return sort.Search(len(l.Lines), func(i int) bool {
+		return l.Lines[i].At > pos
+	}) - 1
+}
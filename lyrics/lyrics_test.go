@@ -0,0 +1,58 @@
+package lyrics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/frizinak/libym/lyrics"
+)
+
+func TestParse(t *testing.T) {
+	l, err := lyrics.ParseString(`[ar:Someone]
+[ti:Something]
+[offset:500]
+[00:12.00]first
+[00:05.5][00:20.25]chorus
+[01:02.123]last
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.Synced || l.Meta["ar"] != "Someone" || l.Meta["ti"] != "Something" {
+		t.Fatal("unexpected meta", l.Synced, l.Meta)
+	}
+
+	exp := []lyrics.Line{
+		{time.Second * 5, "chorus"},
+		{time.Millisecond * 11500, "first"},
+		{time.Millisecond * 19750, "chorus"},
+		{time.Millisecond * 61623, "last"},
+	}
+	if len(l.Lines) != len(exp) {
+		t.Fatalf("expected %d lines got %d", len(exp), len(l.Lines))
+	}
+	for i := range exp {
+		if l.Lines[i] != exp[i] {
+			t.Errorf("line %d: expected %v got %v", i, exp[i], l.Lines[i])
+		}
+	}
+
+	for pos, ix := range map[time.Duration]int{
+		0:                -1,
+		time.Second * 5:  0,
+		time.Second * 12: 1,
+		time.Minute * 5:  3,
+	} {
+		if n := l.Index(pos); n != ix {
+			t.Errorf("%s: expected line %d got %d", pos, ix, n)
+		}
+	}
+
+	plain, err := lyrics.ParseString("one\n\ntwo\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain.Synced || len(plain.Lines) != 2 || plain.Index(time.Minute) != -1 {
+		t.Fatal("unexpected plain lyrics", plain)
+	}
+}
@@ -0,0 +1,158 @@
+// Package mix provides beat-aligned automatic transitions between songs
+// for github.com/frizinak/libym/player.
+package mix
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/frizinak/libym/collection"
+	"github.com/frizinak/libym/player"
+)
+
+// DefaultBars is the default length of a transition in bars of 4 beats.
+const DefaultBars = 8
+
+// analyzeTimeout limits a single beat analysis.
+const analyzeTimeout = time.Minute * 2
+
+// Analysis holds the beats of a song.
+type Analysis struct {
+	BPM   float64
+	Beats []time.Duration
+}
+
+// Analyze detects the beats in the given file using aubio.
+func Analyze(ctx context.Context, file string) (Analysis, error) {
+	var a Analysis
+	cmd := exec.CommandContext(ctx, "aubio", "beat", "-i", file)
+	bufe := bytes.NewBuffer(nil)
+	bufo := bytes.NewBuffer(nil)
+	cmd.Stdout = bufo
+	cmd.Stderr = bufe
+	if err := cmd.Run(); err != nil {
+		return a, fmt.Errorf("%w: %s", err, bufe.String())
+	}
+
+	scan := bufio.NewScanner(bufo)
+	for scan.Scan() {
+		f, err := strconv.ParseFloat(strings.TrimSpace(scan.Text()), 64)
+		if err != nil {
+			continue
+		}
+		a.Beats = append(a.Beats, time.Duration(f*float64(time.Second)))
+	}
+	if len(a.Beats) < 2 {
+		return a, fmt.Errorf("no beats found in %s", file)
+	}
+
+	intervals := make([]float64, len(a.Beats)-1)
+	for i := range intervals {
+		intervals[i] = (a.Beats[i+1] - a.Beats[i]).Seconds()
+	}
+	sort.Float64s(intervals)
+	if median := intervals[len(intervals)/2]; median > 0 {
+		a.BPM = 60 / median
+	}
+
+	return a, nil
+}
+
+// period returns the duration of a single beat.
+func (a Analysis) period() time.Duration {
+	return time.Duration(float64(time.Minute) / a.BPM)
+}
+
+type entry struct {
+	a    Analysis
+	err  error
+	done bool
+}
+
+// AutoMix is a player.Mixer that fades out a song on a beat a number of
+// bars before its last beat and starts the next song on its first beat.
+// Songs are analyzed in the background the first time they are needed.
+type AutoMix struct {
+	bars int
+
+	sem   sync.Mutex
+	cache map[string]*entry
+}
+
+// New creates a new AutoMix with transitions of the given length in bars.
+func New(bars int) *AutoMix {
+	if bars <= 0 {
+		bars = DefaultBars
+	}
+	return &AutoMix{bars: bars, cache: make(map[string]*entry)}
+}
+
+// analysis returns the cached analysis of s or starts analyzing it.
+func (m *AutoMix) analysis(s collection.Song) (Analysis, bool) {
+	gid := collection.GlobalID(s)
+	m.sem.Lock()
+	e, ok := m.cache[gid]
+	if !ok {
+		e = &entry{}
+		m.cache[gid] = e
+		go m.analyze(s, e)
+	}
+	a, done := e.a, e.done && e.err == nil
+	m.sem.Unlock()
+	return a, done
+}
+
+func (m *AutoMix) analyze(s collection.Song, e *entry) {
+	var a Analysis
+	file, err := s.File()
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), analyzeTimeout)
+		a, err = Analyze(ctx, file)
+		cancel()
+	}
+	m.sem.Lock()
+	e.a, e.err, e.done = a, err, true
+	m.sem.Unlock()
+}
+
+// Transition implements player.Mixer.
+func (m *AutoMix) Transition(cur, next collection.Song, duration time.Duration) (player.Transition, bool) {
+	a, ok := m.analysis(cur)
+	b, okNext := m.analysis(next)
+	if !ok || !okNext || a.BPM <= 0 || b.BPM <= 0 {
+		return player.Transition{}, false
+	}
+
+	// tempos that differ a lot clash, keep their overlap short.
+	bars := m.bars
+	if math.Abs(a.BPM-b.BPM)/a.BPM > 0.08 {
+		bars = 1
+	}
+
+	beats := bars * 4
+	last := len(a.Beats) - 1
+	if duration > 0 {
+		for last > 0 && a.Beats[last] > duration {
+			last--
+		}
+	}
+	out := last - beats
+	if out < 0 {
+		return player.Transition{}, false
+	}
+
+	return player.Transition{
+		Out:  a.Beats[out],
+		In:   b.Beats[0],
+		Fade: a.Beats[last] - a.Beats[out] + a.period(),
+	}, true
+}
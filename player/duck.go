@@ -0,0 +1,99 @@
+package player
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// DuckRamp is how long Duck takes to lower and to restore the volume.
+const DuckRamp = time.Millisecond * 300
+
+// duckTolerance is the maximum difference between the ducked and current
+// volume for the volume to be considered unchanged while ducked.
+const duckTolerance = 0.01
+
+type ducking struct {
+	sem    sync.Mutex
+	seq    uint64
+	active bool
+	timer  *time.Timer
+	// volume before ducking and while ducked.
+	volume, ducked float64
+}
+
+// Duck temporarily lowers the volume to level (0-1) times the current
+// volume for duration, e.g.: while an embedding application plays a voice
+// prompt, then restores it. Both changes are ramped over DuckRamp.
+// Ducking while already ducked extends or adjusts the current duck.
+// If the volume is changed while ducked it is left as is.
+// Duck returns immediately.
+func (p *Player) Duck(level float64, duration time.Duration) {
+	if level < 0 {
+		level = 0
+	} else if level > 1 {
+		level = 1
+	}
+
+	d := &p.ducking
+	d.sem.Lock()
+	from := p.backend.Volume()
+	if !d.active {
+		d.volume, d.active = from, true
+	}
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.seq++
+	seq := d.seq
+	d.ducked = d.volume * level
+	to := d.ducked
+	d.timer = time.AfterFunc(DuckRamp+duration, func() { p.unduck(seq) })
+	d.sem.Unlock()
+
+	go p.duckRamp(seq, from, to)
+}
+
+// Ducked returns true while the volume is lowered by Duck.
+func (p *Player) Ducked() bool {
+	p.ducking.sem.Lock()
+	defer p.ducking.sem.Unlock()
+	return p.ducking.active
+}
+
+func (p *Player) unduck(seq uint64) {
+	d := &p.ducking
+	d.sem.Lock()
+	if d.seq != seq {
+		d.sem.Unlock()
+		return
+	}
+	d.active = false
+	from, to := p.backend.Volume(), d.volume
+	if math.Abs(from-d.ducked) > duckTolerance {
+		d.sem.Unlock()
+		return
+	}
+	d.seq++
+	seq = d.seq
+	d.sem.Unlock()
+
+	p.duckRamp(seq, from, to)
+}
+
+// duckRamp is rampOver but stops as soon as another Duck or restore
+// started.
+func (p *Player) duckRamp(seq uint64, from, to float64) {
+	const steps = 10
+	d := &p.ducking
+	for i := 1; i <= steps; i++ {
+		d.sem.Lock()
+		if d.seq != seq {
+			d.sem.Unlock()
+			return
+		}
+		p.backend.SetVolume(from + (to-from)*float64(i)/steps)
+		d.sem.Unlock()
+		time.Sleep(DuckRamp / steps)
+	}
+}
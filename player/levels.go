@@ -0,0 +1,71 @@
+package player
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNoLevels is returned when the Backend does not implement LevelMeter.
+var ErrNoLevels = errors.New("backend does not support level metering")
+
+// Levels are the audio levels since the previous measurement in dBFS,
+// math.Inf(-1) for silence.
+type Levels struct {
+	RMS  float64
+	Peak float64
+	// Channels holds the RMS level of each channel.
+	Channels []float64
+}
+
+// LevelMeter can optionally be implemented by a Backend to report the
+// levels of the audio that is playing, e.g.: to draw VU meters.
+type LevelMeter interface {
+	Levels() (Levels, error)
+}
+
+// Levels returns the current audio levels.
+func (p *Player) Levels() (Levels, error) {
+	m, ok := p.backend.(LevelMeter)
+	if !ok {
+		return Levels{}, ErrNoLevels
+	}
+	return m.Levels()
+}
+
+// WatchLevels sends the audio levels every interval until ctx is done,
+// after which the channel is closed. Levels are not sent while paused.
+func (p *Player) WatchLevels(ctx context.Context, interval time.Duration) (<-chan Levels, error) {
+	m, ok := p.backend.(LevelMeter)
+	if !ok {
+		return nil, ErrNoLevels
+	}
+
+	ch := make(chan Levels, 1)
+	go func() {
+		defer close(ch)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			if p.Paused() {
+				continue
+			}
+			l, err := m.Levels()
+			if err != nil {
+				continue
+			}
+			select {
+			case ch <- l:
+			default:
+				// drop levels the receiver is too slow for.
+			}
+		}
+	}()
+
+	return ch, nil
+}
@@ -0,0 +1,68 @@
+package player
+
+import (
+	"time"
+
+	"github.com/frizinak/libym/collection"
+)
+
+// mixInterval is how often the position is checked for the start of an
+// automatic transition.
+const mixInterval = time.Millisecond * 250
+
+// Transition describes an automatic transition from one song to the next.
+type Transition struct {
+	// Out is the position in the current song at which it starts to fade
+	// out.
+	Out time.Duration
+	// In is the position in the next song at which it starts playing.
+	In time.Duration
+	// Fade is the duration of the fade out, the next song fades in in half
+	// that time.
+	Fade time.Duration
+}
+
+// Mixer decides how to transition between two local songs, e.g.: aligned
+// to their beats. duration is the duration of cur.
+type Mixer interface {
+	// Transition returns false if there should be no transition (yet),
+	// it is called repeatedly while cur is playing and must not block.
+	Transition(cur, next collection.Song, duration time.Duration) (Transition, bool)
+}
+
+// mixOut fades out the current item and starts the next one if the Mixer
+// says it is time. Returns true if it did.
+func (p *Player) mixOut(item *collection.QueueItem, seq byte) bool {
+	next := item.Next()
+	if next == nil || next.IsBeyondLast() || !next.Local() || p.Paused() {
+		return false
+	}
+	t, ok := p.mixer.Transition(item.Song, next.Song, p.backend.Duration())
+	if !ok || p.backend.Position() < t.Out {
+		return false
+	}
+
+	p.sem.Lock()
+	p.mixing = p.seq == seq
+	p.sem.Unlock()
+
+	volume := p.backend.Volume()
+	p.rampOver(volume, 0, t.Fade)
+
+	p.sem.Lock()
+	p.mixing = false
+	if p.seq != seq || p.Paused() {
+		// skipped or paused while fading.
+		p.sem.Unlock()
+		p.backend.SetVolume(volume)
+		return true
+	}
+	p.current = nil
+	n := p.q.Next()
+	p.resume = resume{n, t.In}
+	p.play()
+	p.sem.Unlock()
+
+	p.rampOver(0, volume, t.Fade/2)
+	return true
+}
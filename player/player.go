@@ -4,6 +4,7 @@ package player
 
 import (
 	"fmt"
+	"net/url"
 	"sync"
 	"time"
 
@@ -62,6 +63,13 @@ type Backend interface {
 	Close() error
 }
 
+// PlaybackErrorer can optionally be implemented by a Backend to report that
+// the last file stopped playing due to an error instead of reaching its end.
+type PlaybackErrorer interface {
+	// PlaybackErr returns the error that ended the last file or nil.
+	PlaybackErr() error
+}
+
 // Player provides an interface to play songs from a collection.Queue
 // given a Backend.
 type Player struct {
@@ -73,9 +81,25 @@ type Player struct {
 	posFile string
 
 	current *collection.QueueItem
+	stream  bool
+	mixing  bool
 
 	seq     byte
 	stopped bool
+
+	fade      time.Duration
+	fading    fading
+	resume    resume
+	refreshed resume
+
+	alternatives func(collection.Song) []collection.Song
+	mixer        Mixer
+	onEnd        func() bool
+
+	ducking ducking
+
+	obsem  sync.RWMutex
+	onSkip []func(collection.Song)
 }
 
 // NewPlayer constructs a new player.
@@ -88,11 +112,145 @@ func NewPlayer(backend Backend, reporter ErrorReporter, queue *collection.Queue,
 	}
 }
 
+// SetAlternatives sets the func used to find other sources of a song when
+// playing it fails, e.g.: Collection.Alternatives. Must be called before
+// playing.
+func (p *Player) SetAlternatives(alts func(collection.Song) []collection.Song) {
+	p.alternatives = alts
+}
+
+// SetEndHandler sets a func that is called once the last song in the
+// queue finished playing. If it returns true, playback continues with the
+// current queue item, e.g.: after rewinding or filling the queue.
+// Must be called before playing.
+func (p *Player) SetEndHandler(h func() bool) { p.onEnd = h }
+
+// SetMixer enables automatic transitions between songs, nil disables it.
+// Must be called before playing.
+func (p *Player) SetMixer(m Mixer) { p.mixer = m }
+
+// SetFade enables volume fades of the given duration on pause, resume and
+// manual next/prev. 0 disables fading.
+func (p *Player) SetFade(d time.Duration) { p.fade = d }
+
+// ramp gradually changes the volume from one value to another over the
+// fade duration.
+func (p *Player) ramp(from, to float64) { p.rampOver(from, to, p.fade) }
+
+// rampOver gradually changes the volume from one value to another over the
+// given duration.
+func (p *Player) rampOver(from, to float64, d time.Duration) {
+	steps := int(d / (time.Millisecond * 50))
+	if steps < 15 {
+		steps = 15
+	}
+	interval := d / time.Duration(steps)
+	for i := 1; i <= steps; i++ {
+		p.backend.SetVolume(from + (to-from)*float64(i)/float64(steps))
+		time.Sleep(interval)
+	}
+}
+
+// fading is the fade in started by play when resuming, it runs without
+// holding Player.sem and is canceled by anything that changes the volume.
+type fading struct {
+	sem    sync.Mutex
+	seq    uint64
+	active bool
+	// volume the fade ends at.
+	volume float64
+}
+
+// start cancels the current fade and registers a new one to volume.
+func (f *fading) start(volume float64) uint64 {
+	f.sem.Lock()
+	defer f.sem.Unlock()
+	f.seq++
+	f.active, f.volume = true, volume
+	return f.seq
+}
+
+// fadeResume fades in to volume until canceled, seq is returned by
+// fading.start.
+func (p *Player) fadeResume(seq uint64, volume float64) {
+	f := &p.fading
+	steps := int(p.fade / (time.Millisecond * 50))
+	if steps < 15 {
+		steps = 15
+	}
+	interval := p.fade / time.Duration(steps)
+	for i := 1; i <= steps; i++ {
+		f.sem.Lock()
+		if f.seq != seq {
+			f.sem.Unlock()
+			return
+		}
+		p.backend.SetVolume(volume * float64(i) / float64(steps))
+		if i == steps {
+			f.active = false
+		}
+		f.sem.Unlock()
+		time.Sleep(interval)
+	}
+}
+
+// cancelFade stops a fade in started by fadeResume and returns the volume
+// it would have ended at.
+func (p *Player) cancelFade() (volume float64, canceled bool) {
+	f := &p.fading
+	f.sem.Lock()
+	defer f.sem.Unlock()
+	if !f.active {
+		return 0, false
+	}
+	f.seq++
+	f.active = false
+	return f.volume, true
+}
+
+// fadeOut fades out the current song if playing and returns the original
+// volume.
+func (p *Player) fadeOut() (volume float64, faded bool) {
+	target, fading := p.cancelFade()
+	if p.fade <= 0 || p.Paused() {
+		if fading {
+			p.backend.SetVolume(target)
+		}
+		return 0, false
+	}
+	volume = p.backend.Volume()
+	p.ramp(volume, 0)
+	if fading {
+		volume = target
+	}
+	return volume, true
+}
+
+// fadeIn restores the volume returned by fadeOut.
+func (p *Player) fadeIn(volume float64, faded bool) {
+	if !faded {
+		return
+	}
+	if p.Paused() {
+		p.backend.SetVolume(volume)
+		return
+	}
+	p.ramp(0, volume)
+}
+
 // SetVolume sets the Backend volume to the given value (0-1).
-func (p *Player) SetVolume(n float64) { p.backend.SetVolume(n) }
+func (p *Player) SetVolume(n float64) {
+	p.cancelFade()
+	p.backend.SetVolume(n)
+}
 
 // IncreaseVolume changes the volume by the given delta (-1-1).
-func (p *Player) IncreaseVolume(n float64) { p.backend.IncreaseVolume(n) }
+func (p *Player) IncreaseVolume(n float64) {
+	if v, ok := p.cancelFade(); ok {
+		p.backend.SetVolume(v)
+	}
+	p.backend.IncreaseVolume(n)
+}
 
 // Seek seeks in the current file.
 // whence == io.SeekStart: absolute seek
@@ -111,8 +269,37 @@ func (p *Player) Position() time.Duration { return p.backend.Position() }
 // Duration returns the estimated duration of the current file.
 func (p *Player) Duration() time.Duration { return p.backend.Duration() }
 
+// SkipThreshold is the fraction of a song after which Next is no longer
+// considered a skip.
+const SkipThreshold = 0.25
+
+// OnSkip registers a callback that is called when Next is called within the
+// first SkipThreshold of the current song, before the next song is played.
+func (p *Player) OnSkip(cb func(collection.Song)) {
+	p.obsem.Lock()
+	p.onSkip = append(p.onSkip, cb)
+	p.obsem.Unlock()
+}
+
+func (p *Player) skipped() {
+	cur := p.Current()
+	dur := p.Duration()
+	if cur == nil || dur <= 0 || p.Position() >= time.Duration(float64(dur)*SkipThreshold) {
+		return
+	}
+	p.obsem.RLock()
+	obs := p.onSkip
+	p.obsem.RUnlock()
+	for _, cb := range obs {
+		cb(cur)
+	}
+}
+
 // Next plays the next song in the queue
 func (p *Player) Next() {
+	p.skipped()
+	v, faded := p.fadeOut()
+	defer p.fadeIn(v, faded)
 	p.sem.Lock()
 	p.current = nil
 	n := p.q.Next()
@@ -126,6 +313,8 @@ func (p *Player) Next() {
 
 // Prev plays the previous song in the queue
 func (p *Player) Prev() {
+	v, faded := p.fadeOut()
+	defer p.fadeIn(v, faded)
 	p.sem.Lock()
 	p.current = nil
 	n := p.q.Prev()
@@ -167,7 +356,18 @@ func (p *Player) Play() {
 func (p *Player) play() {
 	if p.Paused() {
 		p.stopped = false
-		p.backend.Pause(false)
+		if p.current == nil || p.fade <= 0 {
+			p.backend.Pause(false)
+		} else {
+			v := p.backend.Volume()
+			if target, ok := p.cancelFade(); ok {
+				v = target
+			}
+			p.backend.SetVolume(0)
+			p.backend.Pause(false)
+			// don't hold p.sem while fading in.
+			go p.fadeResume(p.fading.start(v), v)
+		}
 	}
 
 	if p.current != nil {
@@ -184,7 +384,7 @@ func (p *Player) play() {
 		return
 	}
 
-	n, err := p.current.File()
+	done, stream, err := p.start(p.current.Song)
 	if err != nil {
 		p.songErr(p.current, err)
 		p.current = nil
@@ -192,46 +392,124 @@ func (p *Player) play() {
 		p.play()
 		return
 	}
+	p.stream = stream
+	p.resumed()
+
+	go func(item *collection.QueueItem, stream bool) {
+		var tick, mixTick <-chan time.Time
+		if stream {
+			t := time.NewTicker(time.Second)
+			defer t.Stop()
+			tick = t.C
+		}
+		if p.mixer != nil && !stream {
+			t := time.NewTicker(mixInterval)
+			defer t.Stop()
+			mixTick = t.C
+		}
+		var pos time.Duration
+	wait:
+		for {
+			select {
+			case <-done:
+				break wait
+			case <-tick:
+				pos = p.backend.Position()
+			case <-mixTick:
+				if p.mixOut(item, seq) {
+					// the next song is playing, keep waiting for done
+					// so the backend is never blocked.
+					mixTick = nil
+				}
+			}
+		}
 
-	if !p.current.Local() {
-		u, err := p.current.URL()
-		if err != nil {
-			p.songErr(p.current, err)
+		var err error
+		if e, ok := p.backend.(PlaybackErrorer); ok {
+			err = e.PlaybackErr()
+		}
+
+		p.sem.Lock()
+		if p.seq == seq && err != nil && stream && (p.refreshed.item != item || pos > p.refreshed.pos) {
+			// Stream urls expire, resolve it again and continue where we
+			// left off.
+			p.refreshed = resume{item, pos}
+			p.resume = p.refreshed
 			p.current = nil
-			p.q.Next()
 			p.play()
+			p.sem.Unlock()
 			return
 		}
-		n = u.String()
-	}
 
-	done, err := p.backend.Play(n)
-	if err != nil {
-		p.songErr(p.current, err)
-		p.current = nil
-		p.q.Next()
-		p.play()
-		return
-	}
-
-	go func() {
-		<-done
-		p.sem.Lock()
-		play := false
+		play, end := false, false
 		if p.seq == seq {
+			if err != nil {
+				p.songErr(item, err)
+			}
 			p.current = nil
 			n := p.q.Next()
 			play = !n.IsBeyondLast()
+			end = !play
 		}
 		p.sem.Unlock()
+		if end && p.onEnd != nil && !p.Paused() {
+			play = p.onEnd()
+		}
 		if play && !p.Paused() {
 			p.Play()
 		}
-	}()
+	}(p.current, stream)
+}
+
+// start plays the local file or stream of s, falling back to its
+// alternatives if that fails. Returns the error of s if all fail.
+func (p *Player) start(s collection.Song) (done chan struct{}, stream bool, err error) {
+	candidates := []collection.Song{s}
+	if p.alternatives != nil {
+		candidates = append(candidates, p.alternatives(s)...)
+	}
+
+	var first error
+	for _, c := range candidates {
+		stream = !c.Local()
+		var n string
+		if n, err = c.File(); err == nil && stream {
+			var u *url.URL
+			if u, err = c.URL(); err == nil {
+				n = u.String()
+			}
+		}
+		if err == nil {
+			if done, err = p.backend.Play(n); err == nil {
+				return done, stream, nil
+			}
+		}
+		if first == nil {
+			first = err
+		}
+	}
+
+	return nil, stream, first
+}
+
+// Current returns the queue item that is currently playing or nil.
+func (p *Player) Current() collection.Song {
+	p.sem.Lock()
+	defer p.sem.Unlock()
+	if p.current == nil {
+		return nil
+	}
+	return p.current
 }
 
 // Pause pauses the player.
-func (p *Player) Pause() { p.backend.Pause(true) }
+func (p *Player) Pause() {
+	v, faded := p.fadeOut()
+	p.backend.Pause(true)
+	if faded {
+		p.backend.SetVolume(v)
+	}
+}
 
 // Paused reports the paused state.
 func (p *Player) Paused() bool { return p.stopped || p.backend.Paused() }
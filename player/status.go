@@ -0,0 +1,109 @@
+package player
+
+import (
+	"errors"
+	"time"
+
+	"github.com/frizinak/libym/collection"
+)
+
+// Bufferer can optionally be implemented by a Backend to report that
+// playback is stalled waiting for data.
+type Bufferer interface {
+	Buffering() bool
+}
+
+// BufferNotifier can optionally be implemented by a Bufferer to report
+// changes of the buffering state as they happen.
+type BufferNotifier interface {
+	// OnBuffering registers a callback, callbacks must not block.
+	OnBuffering(func(buffering bool))
+}
+
+// BufferConfig tunes how a Backend buffers streams, zero values keep the
+// defaults of the Backend.
+type BufferConfig struct {
+	// Cache is how much of a stream is read ahead.
+	Cache time.Duration
+	// NetworkTimeout is how long to wait for data before a stream fails.
+	NetworkTimeout time.Duration
+}
+
+// BufferConfigurer can optionally be implemented by a Backend to support
+// SetBufferConfig.
+type BufferConfigurer interface {
+	SetBufferConfig(BufferConfig) error
+}
+
+// ErrNoBufferConfig is returned by SetBufferConfig if the Backend does not
+// implement BufferConfigurer.
+var ErrNoBufferConfig = errors.New("backend does not support buffer settings")
+
+// SetBufferConfig configures the buffering of the Backend.
+func (p *Player) SetBufferConfig(c BufferConfig) error {
+	b, ok := p.backend.(BufferConfigurer)
+	if !ok {
+		return ErrNoBufferConfig
+	}
+	return b.SetBufferConfig(c)
+}
+
+// OnBuffering registers a callback that is called when the Backend starts
+// or stops waiting for data, e.g.: to show "buffering..." on slow
+// connections. Returns false if the Backend does not implement
+// BufferNotifier.
+func (p *Player) OnBuffering(cb func(buffering bool)) bool {
+	b, ok := p.backend.(BufferNotifier)
+	if ok {
+		b.OnBuffering(cb)
+	}
+	return ok
+}
+
+// Status is a snapshot of the player.
+type Status struct {
+	// Song is nil if nothing is playing.
+	Song collection.Song
+	// Next is the song after Song in the queue, nil if none.
+	Next collection.Song
+
+	Position time.Duration
+	Duration time.Duration
+	Volume   float64
+	Paused   bool
+
+	// Stream is true if Song is streamed instead of played from a file.
+	Stream bool
+	// Buffering is true if the Backend is waiting for data, only reported
+	// by backends that implement Bufferer.
+	Buffering bool
+	// Mixing is true while the Mixer fades out Song, Position and Duration
+	// are those of Song until Next starts playing.
+	Mixing bool
+}
+
+// Status returns the current state of the player in one call.
+func (p *Player) Status() Status {
+	p.sem.Lock()
+	cur, stream, mixing := p.current, p.stream, p.mixing
+	p.sem.Unlock()
+
+	st := Status{
+		Volume: p.backend.Volume(),
+		Paused: p.Paused(),
+	}
+	if cur == nil {
+		return st
+	}
+
+	st.Song, st.Stream, st.Mixing = cur, stream, mixing
+	if next := cur.Next(); next != nil && !next.IsBeyondLast() {
+		st.Next = next
+	}
+	st.Position = p.backend.Position()
+	st.Duration = p.backend.Duration()
+	if b, ok := p.backend.(Bufferer); ok {
+		st.Buffering = b.Buffering()
+	}
+	return st
+}
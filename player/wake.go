@@ -0,0 +1,98 @@
+package player
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/frizinak/libym/collection"
+)
+
+// Reconnecter can optionally be implemented by a Backend that needs to
+// rebuild its state after the system was suspended, e.g.: re-dial an ipc
+// socket.
+type Reconnecter interface {
+	Reconnect() error
+}
+
+// DefaultWakeInterval is the interval at which WatchSleep compares clocks.
+const DefaultWakeInterval = time.Second * 5
+
+// WakeThreshold is the minimum amount of time the wall clock has to jump
+// ahead of the monotonic clock for it to be considered a suspend.
+const WakeThreshold = time.Second * 30
+
+type resume struct {
+	item *collection.QueueItem
+	pos  time.Duration
+}
+
+// WatchSleep detects system suspend and resume by comparing the progression
+// of the wall clock to that of the monotonic clock (which does not advance
+// while suspended on most systems) every interval, and calls Wake after a
+// resume. A tick arriving much later than interval is treated the same.
+// Call the returned func to stop watching.
+func (p *Player) WatchSleep(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		last := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+			}
+			now := time.Now()
+			wall := now.Round(0).Sub(last.Round(0))
+			mono := now.Sub(last)
+			last = now
+			if wall-mono > WakeThreshold || mono > interval+WakeThreshold {
+				p.Wake()
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// Wake rebuilds backend state after a system suspend: the backend is
+// reconnected if it implements Reconnecter, the pause state is re-asserted
+// and a streamed song is reloaded at its last position as its url has
+// likely expired. If paused, the reload happens on the next Play.
+func (p *Player) Wake() {
+	paused := p.backend.Paused()
+	pos := p.backend.Position()
+	if r, ok := p.backend.(Reconnecter); ok {
+		if err := r.Reconnect(); err != nil {
+			p.reporter.Err(fmt.Errorf("reconnecting backend after wake: %w", err))
+		}
+	}
+	p.backend.Pause(paused)
+
+	p.sem.Lock()
+	defer p.sem.Unlock()
+	if p.current == nil || p.current.Local() {
+		return
+	}
+
+	p.seq++
+	p.resume = resume{p.current, pos}
+	p.current = nil
+	if paused {
+		p.backend.Stop()
+		return
+	}
+	p.play()
+}
+
+// resumed seeks to the position stored by Wake if the current item is the
+// one that was interrupted.
+func (p *Player) resumed() {
+	r := p.resume
+	p.resume = resume{}
+	if r.item != nil && r.item == p.current && r.pos > 0 {
+		p.backend.Seek(r.pos, io.SeekStart)
+	}
+}
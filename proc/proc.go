@@ -0,0 +1,88 @@
+// Package proc runs external processes, e.g.: mpv and ffmpeg, with resource
+// limits so heavy transcoding does not cause playback glitches on small
+// machines.
+package proc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Limits are applied by prefixing the command with systemd-run, nice and
+// taskset, each of which executes the next so ctx cancellation still kills
+// the actual process. The zero value applies no limits.
+type Limits struct {
+	// Nice is the niceness, from -20 (highest priority) to 19 (lowest).
+	// 0 leaves it untouched, negative values usually require privileges.
+	Nice int
+	// CPUs pins the process to a list of cpus in taskset(1) format,
+	// e.g.: "0" or "1-3". Linux only.
+	CPUs string
+	// Slice runs the process in a scope of this slice of the user's
+	// systemd instance, e.g.: "ym-transcode.slice", where cpu and memory
+	// limits can be configured. See systemd-run(1). Linux only.
+	Slice string
+}
+
+// Validate returns an error if l is invalid or not supported on this
+// platform.
+func (l Limits) Validate() error {
+	if l.Nice < -20 || l.Nice > 19 {
+		return fmt.Errorf("invalid nice level %d, must be between -20 and 19", l.Nice)
+	}
+	if l.CPUs != "" && strings.Trim(l.CPUs, "0123456789,-") != "" {
+		return fmt.Errorf("invalid cpu list '%s', e.g.: 0,2-3", l.CPUs)
+	}
+	if l.Slice != "" && !strings.HasSuffix(l.Slice, ".slice") {
+		return fmt.Errorf("invalid slice '%s', e.g.: ym.slice", l.Slice)
+	}
+	switch runtime.GOOS {
+	case "linux":
+	case "windows":
+		if l != (Limits{}) {
+			return errors.New("process limits are not supported on windows")
+		}
+	default:
+		if l.CPUs != "" || l.Slice != "" {
+			return fmt.Errorf("cpu affinity and slices are not supported on %s", runtime.GOOS)
+		}
+	}
+	return nil
+}
+
+// Wrap returns the command that runs name with args within l.
+func (l Limits) Wrap(name string, args ...string) (string, []string) {
+	cmd := make([]string, 0, len(args)+10)
+	if l.Slice != "" {
+		cmd = append(cmd, "systemd-run", "--user", "--scope", "--quiet", "--slice="+l.Slice, "--")
+	}
+	if l.Nice != 0 {
+		cmd = append(cmd, "nice", "-n", strconv.Itoa(l.Nice))
+	}
+	if l.CPUs != "" {
+		cmd = append(cmd, "taskset", "-c", l.CPUs)
+	}
+	if len(cmd) == 0 {
+		return name, args
+	}
+	cmd = append(cmd, name)
+	cmd = append(cmd, args...)
+	return cmd[0], cmd[1:]
+}
+
+// Command is exec.Command within l.
+func (l Limits) Command(name string, args ...string) *exec.Cmd {
+	name, args = l.Wrap(name, args...)
+	return exec.Command(name, args...)
+}
+
+// CommandContext is exec.CommandContext within l.
+func (l Limits) CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	name, args = l.Wrap(name, args...)
+	return exec.CommandContext(ctx, name, args...)
+}
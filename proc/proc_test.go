@@ -0,0 +1,40 @@
+package proc_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/frizinak/libym/proc"
+)
+
+func TestWrap(t *testing.T) {
+	name, args := proc.Limits{}.Wrap("ffmpeg", "-i", "-")
+	if name != "ffmpeg" || !reflect.DeepEqual(args, []string{"-i", "-"}) {
+		t.Fatal("expected no wrapping without limits", name, args)
+	}
+
+	l := proc.Limits{Nice: 19, CPUs: "1-3", Slice: "ym.slice"}
+	name, args = l.Wrap("ffmpeg", "-i", "-")
+	exp := []string{
+		"--user", "--scope", "--quiet", "--slice=ym.slice", "--",
+		"nice", "-n", "19",
+		"taskset", "-c", "1-3",
+		"ffmpeg", "-i", "-",
+	}
+	if name != "systemd-run" || !reflect.DeepEqual(args, exp) {
+		t.Fatal("unexpected command", name, args)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, l := range []proc.Limits{
+		{Nice: 20},
+		{Nice: -21},
+		{CPUs: "all"},
+		{Slice: "ym"},
+	} {
+		if err := l.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", l)
+		}
+	}
+}
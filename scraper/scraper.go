@@ -49,7 +49,22 @@ type Config struct {
 	Concurrency int
 	MaxDepth    int
 	Callback    Callback
-	Client      *http.Client
+	// Defaults to a copy of the client set with SetDefaultClient.
+	Client *http.Client
+}
+
+var defaultClient struct {
+	sync.RWMutex
+	c *http.Client
+}
+
+// SetDefaultClient sets the client copied by New if Config.Client is nil,
+// e.g.: to redirect scrapes to a test server.
+// nil restores a plain http.Client.
+func SetDefaultClient(c *http.Client) {
+	defaultClient.Lock()
+	defaultClient.c = c
+	defaultClient.Unlock()
 }
 
 type Scraper struct {
@@ -59,6 +74,13 @@ type Scraper struct {
 func New(c Config) *Scraper {
 	if c.Client == nil {
 		c.Client = &http.Client{}
+		defaultClient.RLock()
+		if defaultClient.c != nil {
+			// copy, CheckRedirect is overwritten on each request.
+			cl := *defaultClient.c
+			c.Client = &cl
+		}
+		defaultClient.RUnlock()
 	}
 	if c.Concurrency <= 0 {
 		c.Concurrency = 1
@@ -0,0 +1,249 @@
+// Package serve exposes downloaded songs over http with unguessable,
+// expiring urls so remote renderers, e.g.: Chromecast or DLNA, can fetch
+// them from the store.
+package serve
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/frizinak/libym/player"
+)
+
+// DefaultTTL is how long a url stays valid if none is given to New.
+const DefaultTTL = 6 * time.Hour
+
+type entry struct {
+	file    string
+	expires time.Time
+}
+
+// Server serves files for which a url was created using URL.
+type Server struct {
+	base *url.URL
+	ttl  time.Duration
+
+	sem   sync.Mutex
+	files map[string]entry
+
+	ln  net.Listener
+	srv *http.Server
+}
+
+// New listens on addr, e.g.: ":8686" or ":0" for a random port.
+// Urls use the host advertise, which should be reachable by the renderer,
+// e.g.: the lan ip of this machine. Defaults to the listener address.
+// ttl <= 0 uses DefaultTTL.
+func New(addr, advertise string, ttl time.Duration) (*Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	host := ln.Addr().String()
+	if advertise != "" {
+		_, port, err := net.SplitHostPort(host)
+		if err != nil {
+			ln.Close()
+			return nil, err
+		}
+		host = net.JoinHostPort(advertise, port)
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	s := &Server{
+		base:  &url.URL{Scheme: "http", Host: host, Path: "/"},
+		ttl:   ttl,
+		files: make(map[string]entry),
+		ln:    ln,
+	}
+	s.srv = &http.Server{Handler: s}
+	return s, nil
+}
+
+// Serve serves requests until Close is called.
+func (s *Server) Serve() error {
+	err := s.srv.Serve(s.ln)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Close stops the server, all urls become invalid.
+func (s *Server) Close() error {
+	s.sem.Lock()
+	s.files = make(map[string]entry)
+	s.sem.Unlock()
+	return s.srv.Close()
+}
+
+// URL returns a url at which file can be fetched until the ttl passed to New
+// expires.
+func (s *Server) URL(file string) (string, error) {
+	st, err := os.Stat(file)
+	if err != nil {
+		return "", err
+	}
+	if st.IsDir() {
+		return "", fmt.Errorf("%s is a directory", file)
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	now := time.Now()
+	s.sem.Lock()
+	for t, e := range s.files {
+		if now.After(e.expires) {
+			delete(s.files, t)
+		}
+	}
+	s.files[token] = entry{file: file, expires: now.Add(s.ttl)}
+	s.sem.Unlock()
+
+	// the extension helps renderers that sniff the file type from the url.
+	u := *s.base
+	u.Path = path.Join("/", token, "song"+filepath.Ext(file))
+	return u.String(), nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)[0]
+	s.sem.Lock()
+	e, ok := s.files[token]
+	s.sem.Unlock()
+	if !ok || time.Now().After(e.expires) {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := os.Open(e.file)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(w, r, filepath.Base(e.file), st.ModTime(), f)
+}
+
+// Backend wraps a remote player.Backend so local files are played through
+// urls of s instead of their path.
+// The optional interfaces b implements, e.g.: player.PlaybackErrorer, are
+// forwarded.
+func Backend(b player.Backend, s *Server) player.Backend {
+	w := &backend{Backend: b, s: s}
+	_, levels := b.(player.LevelMeter)
+	_, notify := b.(player.BufferNotifier)
+	switch {
+	case levels && notify:
+		return &levelNotifyBackend{w}
+	case levels:
+		return &levelBackend{w}
+	case notify:
+		return &notifyBackend{w}
+	}
+	return w
+}
+
+// backend implements the optional interfaces for which not implementing
+// them and a zero result are the same to the player. LevelMeter and
+// BufferNotifier are not, see the types below.
+type backend struct {
+	player.Backend
+	s *Server
+}
+
+func (b *backend) Play(file string) (chan struct{}, error) {
+	if strings.Contains(file, "://") {
+		return b.Backend.Play(file)
+	}
+	u, err := b.s.URL(file)
+	if err != nil {
+		return nil, err
+	}
+	return b.Backend.Play(u)
+}
+
+// Init initializes b if needed, e.g.: a di.Backend.
+func (b *backend) Init() error {
+	if i, ok := b.Backend.(interface{ Init() error }); ok {
+		return i.Init()
+	}
+	return nil
+}
+
+func (b *backend) PlaybackErr() error {
+	if e, ok := b.Backend.(player.PlaybackErrorer); ok {
+		return e.PlaybackErr()
+	}
+	return nil
+}
+
+func (b *backend) Buffering() bool {
+	if e, ok := b.Backend.(player.Bufferer); ok {
+		return e.Buffering()
+	}
+	return false
+}
+
+func (b *backend) SetBufferConfig(c player.BufferConfig) error {
+	if e, ok := b.Backend.(player.BufferConfigurer); ok {
+		return e.SetBufferConfig(c)
+	}
+	return player.ErrNoBufferConfig
+}
+
+func (b *backend) Reconnect() error {
+	if e, ok := b.Backend.(player.Reconnecter); ok {
+		return e.Reconnect()
+	}
+	return nil
+}
+
+type levelBackend struct{ *backend }
+
+func (b *levelBackend) Levels() (player.Levels, error) {
+	return b.Backend.(player.LevelMeter).Levels()
+}
+
+type notifyBackend struct{ *backend }
+
+func (b *notifyBackend) OnBuffering(cb func(buffering bool)) {
+	b.Backend.(player.BufferNotifier).OnBuffering(cb)
+}
+
+type levelNotifyBackend struct{ *backend }
+
+func (b *levelNotifyBackend) Levels() (player.Levels, error) {
+	return b.Backend.(player.LevelMeter).Levels()
+}
+
+func (b *levelNotifyBackend) OnBuffering(cb func(buffering bool)) {
+	b.Backend.(player.BufferNotifier).OnBuffering(cb)
+}
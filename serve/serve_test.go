@@ -0,0 +1,93 @@
+package serve_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frizinak/libym/libymtest"
+	"github.com/frizinak/libym/player"
+	"github.com/frizinak/libym/serve"
+)
+
+func TestServe(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "song.m4a")
+	if err := ioutil.WriteFile(file, []byte("audio"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := serve.New("127.0.0.1:0", "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	go s.Serve()
+
+	u, err := s.URL(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(u string) (int, string) {
+		res, err := http.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.StatusCode, string(b)
+	}
+
+	if code, body := get(u); code != http.StatusOK || body != "audio" {
+		t.Fatalf("expected the file, got %d %q", code, body)
+	}
+	if code, _ := get(u[:len(u)-len("/song.m4a")-1] + "/song.m4a"); code != http.StatusNotFound {
+		t.Fatalf("expected a modified token to be rejected, got %d", code)
+	}
+}
+
+func TestBackend(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "song.m4a")
+	if err := ioutil.WriteFile(file, []byte("audio"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := serve.New("127.0.0.1:0", "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	b := libymtest.NewBackend()
+	w := serve.Backend(b, s)
+	if _, err := w.Play(file); err != nil {
+		t.Fatal(err)
+	}
+	if u := b.Playing(); !strings.HasPrefix(u, "http://") {
+		t.Fatalf("expected a url to be played, got %s", u)
+	}
+
+	e, ok := w.(player.PlaybackErrorer)
+	if !ok {
+		t.Fatal("expected PlaybackErrorer to be forwarded")
+	}
+	fail := errors.New("stream ended")
+	b.Fail(fail)
+	if err := e.PlaybackErr(); err != fail {
+		t.Fatalf("expected %v, got %v", fail, err)
+	}
+
+	if _, ok := w.(player.LevelMeter); ok {
+		t.Fatal("did not expect LevelMeter, the backend does not implement it")
+	}
+	if err := w.(player.BufferConfigurer).SetBufferConfig(player.BufferConfig{}); err != player.ErrNoBufferConfig {
+		t.Fatalf("expected %v, got %v", player.ErrNoBufferConfig, err)
+	}
+}
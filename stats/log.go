@@ -0,0 +1,253 @@
+// Package stats records what was played and computes listening reports.
+package stats
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/frizinak/libym/collection"
+)
+
+// Event is a single play of a song.
+type Event struct {
+	NS     string        `json:"ns"`
+	ID     string        `json:"id"`
+	Title  string        `json:"title"`
+	Start  time.Time     `json:"start"`
+	Played time.Duration `json:"played"`
+	// Length of the song if known.
+	Length time.Duration `json:"length,omitempty"`
+	// Skipped is true if the song was skipped early on.
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// Log is an append-only file of play Events, one json object per line.
+type Log struct {
+	mutex sync.Mutex
+	path  string
+}
+
+// NewLog creates a Log backed by the file at path.
+func NewLog(path string) *Log {
+	return &Log{path: path}
+}
+
+// Add appends an event.
+func (l *Log) Add(e Event) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(e); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Events returns all events that started in [from, to).
+// A zero from or to is unbounded.
+func (l *Log) Events(from, to time.Time) ([]Event, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	list := make([]Event, 0)
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return list, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		var e Event
+		if err := json.Unmarshal(scan.Bytes(), &e); err != nil {
+			// skip partially written lines
+			continue
+		}
+		if !from.IsZero() && e.Start.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !e.Start.Before(to) {
+			continue
+		}
+		list = append(list, e)
+	}
+
+	return list, scan.Err()
+}
+
+// Lengths returns the most recently recorded length of every song in the
+// log by collection.GlobalID.
+func (l *Log) Lengths() (map[string]time.Duration, error) {
+	events, err := l.Events(time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]time.Duration)
+	for _, e := range events {
+		if e.Length > 0 {
+			m[e.NS+"-"+e.ID] = e.Length
+		}
+	}
+	return m, nil
+}
+
+// PlayCounts returns how often every song in the log was played through,
+// i.e.: not skipped, by collection.GlobalID.
+func (l *Log) PlayCounts() (map[string]int, error) {
+	events, err := l.Events(time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]int)
+	for _, e := range events {
+		if !e.Skipped {
+			m[e.NS+"-"+e.ID]++
+		}
+	}
+	return m, nil
+}
+
+// LastPlayed returns when every song in the log was last played through,
+// i.e.: not skipped, by collection.GlobalID.
+func (l *Log) LastPlayed() (map[string]time.Time, error) {
+	events, err := l.Events(time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]time.Time)
+	for _, e := range events {
+		key := e.NS + "-" + e.ID
+		if !e.Skipped && e.Start.After(m[key]) {
+			m[key] = e.Start
+		}
+	}
+	return m, nil
+}
+
+// Source is what a Recorder samples, usually a *player.Player.
+type Source interface {
+	Current() collection.Song
+	Paused() bool
+	Duration() time.Duration
+}
+
+// ErrorReporter should log errors.
+type ErrorReporter interface {
+	Err(error)
+}
+
+// Recorder samples a Source every second and writes an Event to a Log
+// whenever the current song changes.
+// Paused time is not counted as played.
+type Recorder struct {
+	log      *Log
+	src      Source
+	reporter ErrorReporter
+
+	stop chan struct{}
+	done chan struct{}
+
+	cur   collection.Song
+	event Event
+
+	sem     sync.Mutex
+	skipped collection.Song
+}
+
+// MinPlayed is the minimum duration a song has to be played to be logged.
+const MinPlayed = time.Second * 5
+
+// NewRecorder creates a new Recorder, call Start to start recording.
+func NewRecorder(log *Log, src Source, reporter ErrorReporter) *Recorder {
+	return &Recorder{log: log, src: src, reporter: reporter}
+}
+
+// Skip marks the current play of s as skipped, see player.Player.OnSkip.
+// Skipped songs are logged regardless of MinPlayed.
+func (r *Recorder) Skip(s collection.Song) {
+	r.sem.Lock()
+	r.skipped = s
+	r.sem.Unlock()
+}
+
+// Start starts sampling in a new goroutine.
+func (r *Recorder) Start() {
+	r.stop, r.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		const interval = time.Second
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		defer close(r.done)
+		for {
+			select {
+			case <-r.stop:
+				r.flush()
+				return
+			case <-t.C:
+			}
+
+			cur := r.src.Current()
+			if cur != r.cur {
+				r.flush()
+				r.cur = cur
+				if cur != nil {
+					r.event = Event{
+						NS:    cur.NS(),
+						ID:    cur.ID(),
+						Title: cur.Title(),
+						Start: time.Now(),
+					}
+				}
+			}
+			if cur != nil && !r.src.Paused() {
+				r.event.Played += interval
+				if d := r.src.Duration(); d > 0 {
+					r.event.Length = d
+				}
+			}
+		}
+	}()
+}
+
+func (r *Recorder) flush() {
+	r.sem.Lock()
+	if r.cur != nil && r.skipped == r.cur {
+		r.event.Skipped = true
+	}
+	r.skipped = nil
+	r.sem.Unlock()
+
+	if r.cur == nil || (r.event.Played < MinPlayed && !r.event.Skipped) {
+		return
+	}
+	if r.event.Title == "" {
+		r.event.Title = r.cur.Title()
+	}
+	if err := r.log.Add(r.event); err != nil {
+		r.reporter.Err(err)
+	}
+	r.cur = nil
+}
+
+// Close stops recording and logs the current song.
+func (r *Recorder) Close() {
+	if r.stop == nil {
+		return
+	}
+	close(r.stop)
+	<-r.done
+	r.stop = nil
+}
@@ -0,0 +1,135 @@
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/frizinak/libym/collection"
+)
+
+// PlaylistStatsEnds is the amount of songs listed as newest and oldest
+// additions in a PlaylistReport.
+const PlaylistStatsEnds = 5
+
+// ArtistSongs is an entry in the top artists of a PlaylistReport.
+type ArtistSongs struct {
+	Name  string `json:"name"`
+	Songs int    `json:"songs"`
+}
+
+// PlaylistReport contains the totals of a single playlist.
+type PlaylistReport struct {
+	Playlist string `json:"playlist"`
+	Songs    int    `json:"songs"`
+	// Duration is the sum of the lengths of all songs that were played at
+	// least once, Unknown is the amount of songs without a known length.
+	Duration    time.Duration `json:"duration"`
+	Unknown     int           `json:"unknown"`
+	Downloaded  int           `json:"downloaded"`
+	Problematic int           `json:"problematic"`
+
+	TopArtists []ArtistSongs `json:"top_artists"`
+	// Newest and Oldest are the titles of the last and first songs added,
+	// newest first. Not meaningful for auto-sorted playlists.
+	Newest []string `json:"newest"`
+	Oldest []string `json:"oldest"`
+}
+
+// NewPlaylistReport computes a PlaylistReport for the given playlist.
+// top limits the length of the top artists.
+func NewPlaylistReport(c *collection.Collection, l *Log, playlist string, top int) (PlaylistReport, error) {
+	r := PlaylistReport{Playlist: playlist}
+	songs, err := c.PlaylistSongs(playlist)
+	if err != nil {
+		return r, err
+	}
+	lengths, err := l.Lengths()
+	if err != nil {
+		return r, err
+	}
+
+	r.Songs = len(songs)
+	artists := make(map[string]*ArtistSongs)
+	for _, s := range songs {
+		if d, ok := lengths[collection.GlobalID(s)]; ok {
+			r.Duration += d
+		} else {
+			r.Unknown++
+		}
+		if s.Local() {
+			r.Downloaded++
+		}
+		if _, ok := c.Problematics().Get(s); ok {
+			r.Problematic++
+		}
+		if a := Artist(s.Title()); a != "" {
+			key := strings.ToLower(a)
+			if _, ok := artists[key]; !ok {
+				artists[key] = &ArtistSongs{Name: a}
+			}
+			artists[key].Songs++
+		}
+	}
+
+	r.TopArtists = make([]ArtistSongs, 0, len(artists))
+	for _, a := range artists {
+		r.TopArtists = append(r.TopArtists, *a)
+	}
+	sort.Slice(r.TopArtists, func(i, j int) bool {
+		if r.TopArtists[i].Songs != r.TopArtists[j].Songs {
+			return r.TopArtists[i].Songs > r.TopArtists[j].Songs
+		}
+		return r.TopArtists[i].Name < r.TopArtists[j].Name
+	})
+	if top > 0 && len(r.TopArtists) > top {
+		r.TopArtists = r.TopArtists[:top]
+	}
+
+	n := PlaylistStatsEnds
+	if n > len(songs) {
+		n = len(songs)
+	}
+	r.Newest = make([]string, 0, n)
+	r.Oldest = make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		r.Newest = append(r.Newest, songs[len(songs)-1-i].Title())
+		r.Oldest = append(r.Oldest, songs[n-1-i].Title())
+	}
+	return r, nil
+}
+
+// String renders the report as text.
+func (r PlaylistReport) String() string {
+	b := &strings.Builder{}
+	pct := 0.0
+	if r.Songs != 0 {
+		pct = float64(r.Downloaded) * 100 / float64(r.Songs)
+	}
+	fmt.Fprintf(b, "songs:       %d, %d problematic\n", r.Songs, r.Problematic)
+	fmt.Fprintf(b, "duration:    %s", hours(r.Duration))
+	if r.Unknown != 0 {
+		fmt.Fprintf(b, " (+%d songs never played)", r.Unknown)
+	}
+	fmt.Fprintf(b, "\ndownloaded:  %d (%.0f%%)\n", r.Downloaded, pct)
+
+	if len(r.TopArtists) != 0 {
+		fmt.Fprintf(b, "\ntop artists\n")
+		for i, a := range r.TopArtists {
+			fmt.Fprintf(b, "%2d %4d %s\n", i+1, a.Songs, a.Name)
+		}
+	}
+	list := func(title string, l []string) {
+		if len(l) == 0 {
+			return
+		}
+		fmt.Fprintf(b, "\n%s\n", title)
+		for _, t := range l {
+			fmt.Fprintf(b, "   %s\n", t)
+		}
+	}
+	list("newest", r.Newest)
+	list("oldest", r.Oldest)
+	return b.String()
+}
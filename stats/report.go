@@ -0,0 +1,188 @@
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/frizinak/libym/collection"
+)
+
+// Period is a named reporting period.
+type Period string
+
+const (
+	PeriodDay   Period = "day"
+	PeriodWeek  Period = "week"
+	PeriodMonth Period = "month"
+	PeriodYear  Period = "year"
+	PeriodAll   Period = "all"
+)
+
+// Since returns the start of the period ending at now.
+// The zero time for PeriodAll.
+func (p Period) Since(now time.Time) (time.Time, error) {
+	switch p {
+	case PeriodDay:
+		return now.AddDate(0, 0, -1), nil
+	case PeriodWeek:
+		return now.AddDate(0, 0, -7), nil
+	case PeriodMonth:
+		return now.AddDate(0, -1, 0), nil
+	case PeriodYear:
+		return now.AddDate(-1, 0, 0), nil
+	case PeriodAll:
+		return time.Time{}, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid period '%s'", p)
+}
+
+// Count is an entry in a top list.
+type Count struct {
+	Name   string        `json:"name"`
+	Plays  int           `json:"plays"`
+	Played time.Duration `json:"played"`
+}
+
+// Report contains library totals and listening statistics for a period.
+type Report struct {
+	Period Period    `json:"period"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+
+	Playlists  int   `json:"playlists"`
+	Songs      int   `json:"songs"`
+	Downloaded int   `json:"downloaded"`
+	DiskUsage  int64 `json:"disk_usage"`
+
+	Plays    int           `json:"plays"`
+	Skips    int           `json:"skips"`
+	Listened time.Duration `json:"listened"`
+
+	TopSongs   []Count `json:"top_songs"`
+	TopArtists []Count `json:"top_artists"`
+}
+
+// Artist guesses the artist from the common "Artist - Title" format.
+// Returns an empty string if there is none.
+func Artist(title string) string {
+	p := strings.SplitN(title, " - ", 2)
+	if len(p) != 2 {
+		return ""
+	}
+	return strings.TrimSpace(p[0])
+}
+
+// NewReport computes a Report for the given period from the play log.
+// top limits the length of the top lists.
+func NewReport(c *collection.Collection, l *Log, period Period, top int) (Report, error) {
+	now := time.Now()
+	from, err := period.Since(now)
+	if err != nil {
+		return Report{}, err
+	}
+	events, err := l.Events(from, now)
+	if err != nil {
+		return Report{}, err
+	}
+
+	r := Report{Period: period, From: from, To: now}
+	r.Playlists = len(c.List())
+	songs := c.Songs()
+	r.Songs = len(songs)
+	for _, s := range songs {
+		if s.Local() {
+			r.Downloaded++
+		}
+	}
+	if r.DiskUsage, err = c.DiskUsage(); err != nil {
+		return r, err
+	}
+
+	songCounts := make(map[string]*Count)
+	artistCounts := make(map[string]*Count)
+	count := func(m map[string]*Count, key, name string, e Event) {
+		if _, ok := m[key]; !ok {
+			m[key] = &Count{Name: name}
+		}
+		m[key].Plays++
+		m[key].Played += e.Played
+	}
+
+	for _, e := range events {
+		r.Listened += e.Played
+		if e.Skipped {
+			r.Skips++
+			continue
+		}
+		r.Plays++
+		count(songCounts, e.NS+"-"+e.ID, e.Title, e)
+		if a := Artist(e.Title); a != "" {
+			count(artistCounts, strings.ToLower(a), a, e)
+		}
+	}
+
+	r.TopSongs = topList(songCounts, top)
+	r.TopArtists = topList(artistCounts, top)
+	return r, nil
+}
+
+func topList(m map[string]*Count, n int) []Count {
+	l := make([]Count, 0, len(m))
+	for _, c := range m {
+		l = append(l, *c)
+	}
+	sort.Slice(l, func(i, j int) bool {
+		if l[i].Plays != l[j].Plays {
+			return l[i].Plays > l[j].Plays
+		}
+		if l[i].Played != l[j].Played {
+			return l[i].Played > l[j].Played
+		}
+		return l[i].Name < l[j].Name
+	})
+	if n > 0 && len(l) > n {
+		l = l[:n]
+	}
+	return l
+}
+
+func hours(d time.Duration) string { return fmt.Sprintf("%.1fh", d.Hours()) }
+
+func bytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// String renders the report as text.
+func (r Report) String() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "library:  %d playlists, %d songs, %d downloaded (%s)\n", r.Playlists, r.Songs, r.Downloaded, bytes(r.DiskUsage))
+	fmt.Fprintf(b, "listened: %s in %d plays, %d skipped\n", hours(r.Listened), r.Plays, r.Skips)
+
+	list := func(title string, l []Count) {
+		if len(l) == 0 {
+			return
+		}
+		fmt.Fprintf(b, "\n%s\n", title)
+		for i, c := range l {
+			name := c.Name
+			if name == "" {
+				name = "- unknown -"
+			}
+			fmt.Fprintf(b, "%2d %4dx %6s %s\n", i+1, c.Plays, hours(c.Played), name)
+		}
+	}
+	list("top songs", r.TopSongs)
+	list("top artists", r.TopArtists)
+	return b.String()
+}
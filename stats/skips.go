@@ -0,0 +1,66 @@
+package stats
+
+import (
+	"sort"
+	"time"
+)
+
+// SkipCount is the number of times a song was played and skipped.
+type SkipCount struct {
+	NS    string
+	ID    string
+	Title string
+	Plays int
+	Skips int
+}
+
+// Ratio returns the fraction of plays that were skipped.
+func (s SkipCount) Ratio() float64 {
+	return float64(s.Skips) / float64(s.Plays+s.Skips)
+}
+
+// DefaultMinSkips is the default minimum amount of skips before a song is
+// suggested for removal.
+const DefaultMinSkips = 3
+
+// Suggestions returns the songs that were skipped at least minSkips times
+// and more often than they were played through, most skipped first.
+func Suggestions(l *Log, minSkips int) ([]SkipCount, error) {
+	events, err := l.Events(time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]*SkipCount)
+	for _, e := range events {
+		key := e.NS + "-" + e.ID
+		c, ok := m[key]
+		if !ok {
+			c = &SkipCount{NS: e.NS, ID: e.ID}
+			m[key] = c
+		}
+		c.Title = e.Title
+		if e.Skipped {
+			c.Skips++
+			continue
+		}
+		c.Plays++
+	}
+
+	list := make([]SkipCount, 0)
+	for _, c := range m {
+		if c.Skips >= minSkips && c.Skips > c.Plays {
+			list = append(list, *c)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Ratio() != list[j].Ratio() {
+			return list[i].Ratio() > list[j].Ratio()
+		}
+		if list[i].Skips != list[j].Skips {
+			return list[i].Skips > list[j].Skips
+		}
+		return list[i].Title < list[j].Title
+	})
+	return list, nil
+}
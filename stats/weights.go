@@ -0,0 +1,105 @@
+package stats
+
+import (
+	"time"
+
+	"github.com/frizinak/libym/collection"
+)
+
+// DefaultRecency is the time after which a played song is weighted as if
+// it was never played.
+const DefaultRecency = time.Hour * 24 * 7
+
+// minRecency is the recency factor of a song that just finished playing,
+// it is never 0 so recently played songs can still be picked.
+const minRecency = 0.05
+
+// Weights weighs songs for collection.WeightedShuffle based on their
+// rating and when they were last played.
+type Weights struct {
+	recency  time.Duration
+	now      time.Time
+	favorite func(collection.IDer) bool
+
+	last   map[string]time.Time
+	counts map[string]*SkipCount
+}
+
+// NewWeights creates Weights from the play history in l.
+// favorite reports whether a song was favorited and may be nil.
+// recency <= 0 uses DefaultRecency.
+func NewWeights(l *Log, favorite func(collection.IDer) bool, recency time.Duration) (*Weights, error) {
+	events, err := l.Events(time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	if recency <= 0 {
+		recency = DefaultRecency
+	}
+
+	w := &Weights{
+		recency:  recency,
+		now:      time.Now(),
+		favorite: favorite,
+		last:     make(map[string]time.Time),
+		counts:   make(map[string]*SkipCount),
+	}
+	for _, e := range events {
+		key := e.NS + "-" + e.ID
+		if e.Start.After(w.last[key]) {
+			w.last[key] = e.Start
+		}
+		c, ok := w.counts[key]
+		if !ok {
+			c = &SkipCount{NS: e.NS, ID: e.ID}
+			w.counts[key] = c
+		}
+		c.Title = e.Title
+		if e.Skipped {
+			c.Skips++
+			continue
+		}
+		c.Plays++
+	}
+
+	return w, nil
+}
+
+// Rating returns the rating of s in (0, 1].
+// Songs start at 0.5, playing them through raises it, skipping lowers it.
+// Favorites are rated twice as high, capped at 1.
+func (w *Weights) Rating(s collection.IDer) float64 {
+	r := 0.5
+	if c, ok := w.counts[collection.GlobalID(s)]; ok {
+		r = float64(c.Plays+1) / float64(c.Plays+c.Skips+2)
+	}
+	if w.favorite != nil && w.favorite(s) {
+		r *= 2
+		if r > 1 {
+			r = 1
+		}
+	}
+	return r
+}
+
+// Recency returns a factor in (0, 1] that is lower for recently played
+// songs and 1 for songs that were not played within the recency window.
+func (w *Weights) Recency(s collection.IDer) float64 {
+	last, ok := w.last[collection.GlobalID(s)]
+	if !ok {
+		return 1
+	}
+	f := float64(w.now.Sub(last)) / float64(w.recency)
+	switch {
+	case f < minRecency:
+		return minRecency
+	case f > 1:
+		return 1
+	}
+	return f
+}
+
+// Weight returns the weight of s, i.e.: its rating times its recency.
+func (w *Weights) Weight(s collection.Song) float64 {
+	return w.Rating(s) * w.Recency(s)
+}
@@ -0,0 +1,248 @@
+// Package subsonic provides a minimal client for the Subsonic API as
+// implemented by e.g.: Navidrome and Airsonic.
+package subsonic
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/frizinak/libym/failure"
+)
+
+const (
+	// DefaultTimeout is used for api requests when the given context has no
+	// deadline.
+	DefaultTimeout = time.Second * 30
+
+	apiVersion = "1.16.1"
+	clientName = "libym"
+)
+
+// Config holds the server url and credentials.
+type Config struct {
+	// URL is the base url of the server, e.g.: https://music.example.com
+	URL      string
+	User     string
+	Password string
+}
+
+// Client talks to a single Subsonic server.
+type Client struct {
+	base *url.URL
+	c    Config
+	http *http.Client
+}
+
+// New creates a new client.
+func New(c Config) (*Client, error) {
+	base, err := url.Parse(strings.TrimRight(c.URL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("invalid subsonic url '%s'", c.URL)
+	}
+	return &Client{base: base, c: c, http: http.DefaultClient}, nil
+}
+
+// SetHTTPClient sets the client used for api requests.
+// nil restores http.DefaultClient.
+func (c *Client) SetHTTPClient(h *http.Client) {
+	if h == nil {
+		h = http.DefaultClient
+	}
+	c.http = h
+}
+
+// Server returns the base url of the server.
+func (c *Client) Server() *url.URL {
+	u := *c.base
+	return &u
+}
+
+// Song is a track on the server.
+type Song struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Artist   string `json:"artist"`
+	Album    string `json:"album"`
+	Duration int    `json:"duration"`
+}
+
+// FullTitle returns the title in the common "Artist - Title" format.
+func (s *Song) FullTitle() string {
+	if s.Artist == "" {
+		return s.Title
+	}
+	return s.Artist + " - " + s.Title
+}
+
+// Playlist is a playlist on the server.
+type Playlist struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	SongCount int    `json:"songCount"`
+}
+
+// Error is an error returned by the api.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string { return fmt.Sprintf("subsonic: %s (%d)", e.Message, e.Code) }
+
+type response struct {
+	Response struct {
+		Status string `json:"status"`
+		Error  *Error `json:"error"`
+
+		SearchResult3 struct {
+			Song []*Song `json:"song"`
+		} `json:"searchResult3"`
+		Song      *Song `json:"song"`
+		Playlists struct {
+			Playlist []Playlist `json:"playlist"`
+		} `json:"playlists"`
+		Playlist struct {
+			Name  string  `json:"name"`
+			Entry []*Song `json:"entry"`
+		} `json:"playlist"`
+	} `json:"subsonic-response"`
+}
+
+// endpoint returns the authenticated url of the given api method.
+func (c *Client) endpoint(method string, query url.Values) (*url.URL, error) {
+	salt := make([]byte, 8)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	s := hex.EncodeToString(salt)
+	sum := md5.Sum([]byte(c.c.Password + s))
+
+	if query == nil {
+		query = make(url.Values)
+	}
+	query.Set("u", c.c.User)
+	query.Set("t", hex.EncodeToString(sum[:]))
+	query.Set("s", s)
+	query.Set("v", apiVersion)
+	query.Set("c", clientName)
+	query.Set("f", "json")
+
+	u := c.Server()
+	u.Path += "/rest/" + method
+	u.RawQuery = query.Encode()
+	return u, nil
+}
+
+func (c *Client) call(ctx context.Context, method string, query url.Values) (*response, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultTimeout)
+		defer cancel()
+	}
+
+	u, err := c.endpoint(method, query)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, failure.Wrap(failure.KindOf(err), err)
+	}
+	defer res.Body.Close()
+	if err := failure.HTTPStatus(res.StatusCode); err != nil {
+		return nil, fmt.Errorf("subsonic %s: %w", method, err)
+	}
+
+	r := &response{}
+	if err := json.NewDecoder(res.Body).Decode(r); err != nil {
+		return nil, failure.Wrap(failure.ParserBroken, err)
+	}
+	if r.Response.Status != "ok" {
+		if r.Response.Error != nil {
+			if r.Response.Error.Code == 70 {
+				return nil, failure.Wrap(failure.NotFound, r.Response.Error)
+			}
+			return nil, r.Response.Error
+		}
+		return nil, errors.New("subsonic: request failed")
+	}
+
+	return r, nil
+}
+
+// Ping checks connectivity and credentials.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.call(ctx, "ping", nil)
+	return err
+}
+
+// Search searches the server for songs.
+func (c *Client) Search(ctx context.Context, q string) ([]*Song, error) {
+	query := make(url.Values)
+	query.Set("query", q)
+	query.Set("songCount", "100")
+	query.Set("artistCount", "0")
+	query.Set("albumCount", "0")
+	r, err := c.call(ctx, "search3", query)
+	if err != nil {
+		return nil, err
+	}
+	return r.Response.SearchResult3.Song, nil
+}
+
+// Song fetches a single song.
+func (c *Client) Song(ctx context.Context, id string) (*Song, error) {
+	query := make(url.Values)
+	query.Set("id", id)
+	r, err := c.call(ctx, "getSong", query)
+	if err != nil {
+		return nil, err
+	}
+	if r.Response.Song == nil {
+		return nil, failure.Wrap(failure.NotFound, fmt.Errorf("subsonic: no song with id %s", id))
+	}
+	return r.Response.Song, nil
+}
+
+// Playlists lists the playlists on the server.
+func (c *Client) Playlists(ctx context.Context) ([]Playlist, error) {
+	r, err := c.call(ctx, "getPlaylists", nil)
+	if err != nil {
+		return nil, err
+	}
+	return r.Response.Playlists.Playlist, nil
+}
+
+// Playlist returns the songs in the given playlist.
+func (c *Client) Playlist(ctx context.Context, id string) ([]*Song, error) {
+	query := make(url.Values)
+	query.Set("id", id)
+	r, err := c.call(ctx, "getPlaylist", query)
+	if err != nil {
+		return nil, err
+	}
+	return r.Response.Playlist.Entry, nil
+}
+
+// StreamURL returns an authenticated url that streams the given song.
+func (c *Client) StreamURL(id string) (*url.URL, error) {
+	query := make(url.Values)
+	query.Set("id", id)
+	return c.endpoint("stream", query)
+}
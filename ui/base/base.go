@@ -2,9 +2,11 @@ package base
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math/rand"
 	"net/url"
 	"os"
@@ -17,22 +19,38 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"github.com/frizinak/libym/acoustid"
 	"github.com/frizinak/libym/collection"
+	"github.com/frizinak/libym/jobs"
+	"github.com/frizinak/libym/lyrics"
 	"github.com/frizinak/libym/player"
 	"github.com/frizinak/libym/scraper"
+	"github.com/frizinak/libym/stats"
+	"github.com/frizinak/libym/subsonic"
 	"github.com/frizinak/libym/ui"
 	"github.com/frizinak/libym/youtube"
 )
 
 var viewNames = map[ui.View]string{
-	ui.ViewQueue:     "queue",
-	ui.ViewSearch:    "search",
-	ui.ViewSearchOwn: "search for local songs",
-	ui.ViewPlaylist:  "playlist",
-	ui.ViewPlaylists: "playlists",
-	ui.ViewHelp:      "help",
-	ui.ViewJobs:      "jobs",
-	ui.ViewExternal:  "external",
-	ui.ViewRename:    "rename",
+	ui.ViewQueue:      "queue",
+	ui.ViewSearch:     "search",
+	ui.ViewSearchOwn:  "search for local songs",
+	ui.ViewPlaylist:   "playlist",
+	ui.ViewPlaylists:  "playlists",
+	ui.ViewHelp:       "help",
+	ui.ViewJobs:       "jobs",
+	ui.ViewExternal:   "external",
+	ui.ViewRename:     "rename",
+	ui.ViewJobLog:     "job log",
+	ui.ViewSchedules:  "schedules",
+	ui.ViewStats:      "stats",
+	ui.ViewBanned:     "banned",
+	ui.ViewSkips:      "often skipped",
+	ui.ViewMarkers:    "markers",
+	ui.ViewSubsonic:   "subsonic playlists",
+	ui.ViewShare:      "share",
+	ui.ViewDuplicates: "possible duplicates",
+	ui.ViewLyrics:     "lyrics",
+	ui.ViewCheck:      "dead songs",
+	ui.ViewImport:     "import",
 }
 
 type Can byte
@@ -45,63 +63,11 @@ const (
 	CanCancelJob
 )
 
-type Job struct {
-	id       string
-	Name     string
-	Progress float64
-	cancel   func()
-}
-
-func (j *Job) ID() string              { return j.id }
-func (j *Job) SetCancel(cancel func()) { j.cancel = cancel }
-func (j *Job) Cancel() {
-	c := j.cancel
-	if c != nil {
-		c()
-		j.cancel = nil
-	}
-}
-
-type Jobs struct {
-	mutex sync.RWMutex
-	j     map[string]*Job
-	n     uint64
-}
-
-func (j *Jobs) Add(name string) *Job {
-	j.mutex.Lock()
-	j.n++
-	jobid := fmt.Sprintf("%d-%s", j.n, name)
-	job := &Job{id: jobid, Name: name}
-	j.j[jobid] = job
-	j.mutex.Unlock()
-	return job
-}
-
-func (j *Jobs) Remove(id string) {
-	j.mutex.Lock()
-	delete(j.j, id)
-	j.mutex.Unlock()
-}
-
-func (j *Jobs) Len() int {
-	j.mutex.RLock()
-	l := len(j.j)
-	j.mutex.RUnlock()
-	return l
-}
-
-func (j *Jobs) List() []*Job {
-	l := make([]*Job, 0, len(j.j))
-	j.mutex.RLock()
-	for _, j := range j.j {
-		l = append(l, j)
-	}
-	j.mutex.RUnlock()
-	sort.Slice(l, func(i, j int) bool {
-		return l[i].id < l[j].id
-	})
-	return l
+// Duplicates are songs that were not added to Playlist because they look
+// like a song it already contains.
+type Duplicates struct {
+	Playlist string
+	List     []collection.Duplicate
 }
 
 type Rename struct {
@@ -122,7 +88,17 @@ type StateData struct {
 
 	Playlist string
 
-	jobs *Jobs
+	// Offset is the scroll offset of the current view as reported by the
+	// frontend, see ui.Scroller.
+	Offset int
+
+	jobs   *jobs.Manager
+	JobLog *jobs.Job
+
+	StatsPeriod stats.Period
+	// StatsPlaylist is the playlist shown in the stats view, empty for the
+	// play history.
+	StatsPlaylist string
 
 	Songs      []collection.Song
 	External   []collection.Song
@@ -131,6 +107,16 @@ type StateData struct {
 
 	Rename *Rename
 
+	SubsonicPlaylists []subsonic.Playlist
+
+	Share string
+
+	Duplicates *Duplicates
+
+	Check *collection.CheckReport
+
+	Import *collection.MergeSummary
+
 	confirm struct {
 		sec  string
 		cb   func()
@@ -138,6 +124,99 @@ type StateData struct {
 	}
 
 	can map[Can]struct{}
+
+	history []viewState
+
+	pane  int
+	panes [Panes]pane
+}
+
+// Panes is the amount of panes, each holding its own view and history.
+const Panes = 2
+
+type pane struct {
+	state   viewState
+	history []viewState
+}
+
+// MaxViewHistory is the amount of views remembered for the back command.
+const MaxViewHistory = 50
+
+// viewState is everything needed to show a previous view again.
+type viewState struct {
+	view  ui.View
+	title string
+
+	query, queryOfResult       string
+	queryOwn, queryOfOwnResult string
+	playlist                   string
+	jobLog                     *jobs.Job
+	statsPeriod                stats.Period
+	statsPlaylist              string
+	offset                     int
+
+	search     []*youtube.Result
+	localSongs []*collection.SearchResult
+}
+
+func (s *StateData) viewState() viewState {
+	return viewState{
+		view:             s.view,
+		title:            s.title,
+		query:            s.Query,
+		queryOfResult:    s.QueryOfResult,
+		queryOwn:         s.QueryOwn,
+		queryOfOwnResult: s.QueryOfOwnResult,
+		playlist:         s.Playlist,
+		jobLog:           s.JobLog,
+		statsPeriod:      s.StatsPeriod,
+		statsPlaylist:    s.StatsPlaylist,
+		offset:           s.Offset,
+		search:           s.Search,
+		localSongs:       s.LocalSongs,
+	}
+}
+
+// Back returns to the previous view, keeping its search results.
+// Returns false if there is no previous view.
+func (s *StateData) Back() bool {
+	if len(s.history) == 0 {
+		return false
+	}
+	v := s.history[len(s.history)-1]
+	s.history = s.history[:len(s.history)-1]
+	s.restore(v)
+	return true
+}
+
+// Pane returns the active pane.
+func (s *StateData) Pane() int { return s.pane }
+
+// SetPane switches to pane n, remembering the view of the current pane.
+func (s *StateData) SetPane(n int) error {
+	if n < 0 || n >= Panes {
+		return fmt.Errorf("invalid pane %d", n+1)
+	}
+	if n == s.pane {
+		return nil
+	}
+	s.panes[s.pane] = pane{s.viewState(), s.history}
+	p := s.panes[n]
+	s.pane = n
+	s.restore(p.state)
+	s.history = p.history
+	return nil
+}
+
+func (s *StateData) restore(v viewState) {
+	s.view, s.title = v.view, v.title
+	s.Query, s.QueryOfResult = v.query, v.queryOfResult
+	s.QueryOwn, s.QueryOfOwnResult = v.queryOwn, v.queryOfOwnResult
+	s.Playlist = v.playlist
+	s.JobLog = v.jobLog
+	s.StatsPeriod, s.StatsPlaylist = v.statsPeriod, v.statsPlaylist
+	s.Offset = v.offset
+	s.Search, s.LocalSongs = v.search, v.localSongs
 }
 
 const chars = "abcdefghijklmnopqrstuvwxyz"
@@ -176,6 +255,13 @@ func (s *StateData) SetConfirm(view ui.View, cb func()) (sec string) {
 func (s *StateData) View() ui.View { return s.view }
 
 func (s *StateData) SetView(v ui.View, title string) {
+	if v != s.view || title != s.title {
+		s.history = append(s.history, s.viewState())
+		if len(s.history) > MaxViewHistory {
+			s.history = s.history[len(s.history)-MaxViewHistory:]
+		}
+		s.Offset = 0
+	}
 	s.view = v
 	s.title = title
 
@@ -201,11 +287,20 @@ func (s *StateData) Can(what Can) bool {
 	return ok
 }
 
+// Jobs returns all pending and running jobs followed by recently finished
+// ones, this is the order used for numbering jobs in the ui.
+func (s *StateData) Jobs() []*jobs.Job {
+	return append(s.jobs.List(), s.jobs.History()...)
+}
+
 func (s *StateData) Title() string {
 	title := viewNames[s.view]
 	if s.title != "" {
 		title = fmt.Sprintf("%s: %s", title, s.title)
 	}
+	if s.pane != 0 {
+		title = fmt.Sprintf("[%d] %s", s.pane+1, title)
+	}
 	return title
 }
 
@@ -215,8 +310,8 @@ type State struct {
 	s *StateData
 }
 
-func NewState() *State {
-	return &State{s: &StateData{jobs: &Jobs{j: make(map[string]*Job)}}}
+func NewState(jobs *jobs.Manager) *State {
+	return &State{s: &StateData{jobs: jobs}}
 }
 
 func (s *State) Do(cb func(*StateData) error) error {
@@ -235,10 +330,124 @@ type UI struct {
 	c        *collection.Collection
 	q        *collection.Queue
 	acoustid *acoustid.Client
+	sched    *jobs.Scheduler
+	plays    *stats.Log
+
+	changes  chan struct{}
+	seekStep time.Duration
+	names    *collection.NameTemplate
+	intents  []ui.Intent
+	share    func(string) error
+	dupes    float64
+	lyrics   lyrics.Fetcher
+	profiles map[string]collection.TranscodeProfile
+	daily    collection.DailyMix
+	archive  *collection.ArchivePolicy
+	snapshot *ui.ViewState
+	offset   int
+	end      EndMode
+	endHook  func() error
+
+	ssem    sync.Mutex
+	session string
+	saved   Session
+
+	msem       sync.RWMutex
+	middleware []ui.Middleware
+	handlers   map[ui.CommandType]Handler
 
 	s *State
 }
 
+// DefaultSeekStep is the default step of the ff and rw commands.
+const DefaultSeekStep = time.Second * 10
+
+// SetSeekStep sets the step of the ff and rw commands.
+func (u *UI) SetSeekStep(d time.Duration) { u.seekStep = d }
+
+// SetNameTemplate sets the template used to name exported files.
+// Defaults to collection.DefaultNameTemplate.
+func (u *UI) SetNameTemplate(t *collection.NameTemplate) { u.names = t }
+
+// SetShareHook sets a function that receives the output of the share
+// command, e.g.: to copy it to the clipboard. If nil, it is shown in
+// ui.ViewShare.
+func (u *UI) SetShareHook(hook func(text string) error) { u.share = hook }
+
+// SetDuplicateThreshold sets the title similarity above which added songs
+// need to be confirmed with the dup command, see collection.TitleSimilarity.
+// Defaults to collection.DefaultDuplicateThreshold, <= 0 disables the check.
+func (u *UI) SetDuplicateThreshold(t float64) { u.dupes = t }
+
+// SetLyricsFetcher sets where "lyrics fetch" looks up lyrics,
+// e.g.: lyrics.NewLRCLib(""). Defaults to nil, i.e.: lyrics can only be
+// loaded from files.
+func (u *UI) SetLyricsFetcher(f lyrics.Fetcher) { u.lyrics = f }
+
+// SetExportProfiles sets the transcode profiles selectable in the export
+// command. Defaults to collection.DefaultTranscodeProfiles.
+func (u *UI) SetExportProfiles(p map[string]collection.TranscodeProfile) { u.profiles = p }
+
+// SetDailyMix configures the daily command.
+func (u *UI) SetDailyMix(d collection.DailyMix) { u.daily = d }
+
+// SetArchivePolicy configures the archive command, nil disables it.
+func (u *UI) SetArchivePolicy(p *collection.ArchivePolicy) { u.archive = p }
+
+// EndMode is what happens once the last song in the queue finished playing.
+type EndMode string
+
+const (
+	// EndStop stops playback.
+	EndStop EndMode = "stop"
+	// EndRepeat plays the queue again from the start.
+	EndRepeat EndMode = "repeat"
+	// EndRadio fills the queue with an hour of songs from the playlist
+	// of the last song, or from all playlists.
+	EndRadio EndMode = "radio"
+	// EndShuffle shuffles the queue and plays it again.
+	EndShuffle EndMode = "shuffle"
+	// EndHook calls the hook set with SetEndHook and continues with the
+	// songs it queued, if any.
+	EndHook EndMode = "hook"
+)
+
+// EndModes are all valid EndModes.
+var EndModes = []EndMode{EndStop, EndRepeat, EndRadio, EndShuffle, EndHook}
+
+// ParseEndMode parses the name of an EndMode.
+func ParseEndMode(s string) (EndMode, error) {
+	for _, m := range EndModes {
+		if string(m) == s {
+			return m, nil
+		}
+	}
+	return EndStop, fmt.Errorf("invalid end of queue mode '%s'", s)
+}
+
+// radioLength is the length of the songs queued by EndRadio.
+const radioLength = time.Hour
+
+// SetEndMode sets what happens at the end of the queue.
+// Defaults to EndStop.
+func (u *UI) SetEndMode(m EndMode) {
+	u.msem.Lock()
+	u.end = m
+	u.msem.Unlock()
+}
+
+// SetEndHook sets the hook called at the end of the queue in EndHook mode,
+// e.g.: to queue songs from elsewhere.
+func (u *UI) SetEndHook(hook func() error) {
+	u.msem.Lock()
+	u.endHook = hook
+	u.msem.Unlock()
+}
+
+// SetIntents sets the phrases understood by InputNatural.
+// Defaults to ui.DefaultIntents.
+func (u *UI) SetIntents(i []ui.Intent) { u.intents = i }
+
 func New(
 	output ui.Output,
 	log ui.ErrorReporter,
@@ -247,33 +456,231 @@ func New(
 	c *collection.Collection,
 	q *collection.Queue,
 	acoustid *acoustid.Client,
+	jobs *jobs.Manager,
+	sched *jobs.Scheduler,
+	plays *stats.Log,
 ) *UI {
-	return &UI{
+	u := &UI{
 		Output:   output,
 		l:        log,
-		s:        NewState(),
+		s:        NewState(jobs),
 		parser:   parser,
 		p:        p,
 		c:        c,
 		q:        q,
 		acoustid: acoustid,
+		sched:    sched,
+		plays:    plays,
+		changes:  make(chan struct{}, 1),
+		seekStep: DefaultSeekStep,
+		intents:  ui.DefaultIntents,
+		dupes:    collection.DefaultDuplicateThreshold,
+		end:      EndStop,
+	}
+	u.names, _ = collection.ParseNameTemplate(collection.DefaultNameTemplate)
+	u.profiles = collection.DefaultTranscodeProfiles
+	jobs.OnFinish(u.jobFinished)
+	c.OnLowDisk(u.lowDisk)
+	p.SetEndHandler(u.queueEnded)
+	c.OnPlaylistChanged(func(string) { u.changed() })
+	q.OnChange(u.changed)
+	go u.watch()
+	return u
+}
+
+// changed schedules a refresh without blocking, multiple changes before the
+// refresh happens are coalesced.
+func (u *UI) changed() {
+	select {
+	case u.changes <- struct{}{}:
+	default:
+	}
+}
+
+func (u *UI) watch() {
+	for range u.changes {
+		var refresh bool
+		u.s.Do(func(s *StateData) error {
+			switch s.View() {
+			case ui.ViewQueue, ui.ViewPlaylist, ui.ViewPlaylists:
+				refresh = true
+			}
+			return nil
+		})
+		if refresh {
+			u.Refresh()
+		}
+	}
+}
+
+func (u *UI) lowDisk(low bool, free uint64) {
+	if low {
+		u.l.Err(ui.WithSeverity(
+			ui.SeverityWarning,
+			fmt.Errorf("low disk space (%d MiB free), downloads are paused", free>>20),
+		))
+		return
+	}
+	if n, ok := u.l.(ui.Notifier); ok {
+		n.Notify(fmt.Sprintf("downloads resumed, %d MiB free", free>>20))
+	}
+}
+
+func (u *UI) jobFinished(j *jobs.Job) {
+	// Downloads are reported through problematics.
+	if j.Kind() == jobs.KindDownload {
+		return
+	}
+
+	_, err := j.Result()
+	switch {
+	case j.State() == jobs.StateFailed:
+		u.l.Err(fmt.Errorf("job %s failed: %w", j.Name(), err))
+	default:
+		if n, ok := u.l.(ui.Notifier); ok {
+			n.Notify(fmt.Sprintf("job %s %s", j.Name(), j.State()))
+		}
+	}
+
+	var refresh bool
+	u.s.Do(func(s *StateData) error {
+		refresh = s.View() == ui.ViewJobs || s.View() == ui.ViewJobLog
+		return nil
+	})
+	if refresh {
+		u.Refresh()
 	}
 }
 
 func (u *UI) Input(input string) {
-	cmds := u.parser.Parse(input)
-	for _, cmd := range cmds {
-		u.Handle(cmd)
+	var err error
+	for _, cmd := range u.parser.Parse(input) {
+		if cmd.Runs(err) {
+			err = u.handleReport(cmd)
+		}
 	}
 }
 
-func (u *UI) Handle(cmd ui.Command) {
-	if err := u.handle(cmd); err != nil {
-		u.l.Err(err)
+// InputNatural handles a free-text phrase, e.g.: from a voice frontend.
+// Besides the configured intents "play (some|something) <x>" fills the
+// queue from playlist <x> if it exists or searches the collection for <x>.
+func (u *UI) InputNatural(input string) {
+	intents := make([]ui.Intent, 0, len(u.intents)+1)
+	intents = append(intents, u.intents...)
+	intents = append(intents, ui.Intent{
+		Phrases: []string{"play something *", "play some *", "play me some *", "play *"},
+		Command: u.playIntent,
+	})
+
+	cmd, ok := ui.MatchIntent(intents, input)
+	if !ok {
+		u.l.Err(fmt.Errorf("did not understand '%s'", input))
 		return
 	}
+	u.Handle(cmd)
+}
+
+func (u *UI) playIntent(what string) (ui.Command, bool) {
+	if what == "" {
+		return ui.Command{}, false
+	}
+	for _, n := range u.c.List() {
+		if ui.NormalizePhrase(n) == what {
+			return ui.NewCommand(ui.CmdFill, n, "1h"), true
+		}
+	}
+	return ui.NewCommand(ui.CmdSearchOwn, strings.Fields(what)...), true
+}
+
+// Use registers a middleware that wraps the handling of every command,
+// the first registered middleware is called first.
+func (u *UI) Use(m ui.Middleware) {
+	u.msem.Lock()
+	u.middleware = append(u.middleware, m)
+	u.msem.Unlock()
+}
+
+// Handler handles an app-specific command, see ui.NewCommandType.
+type Handler func(cmd ui.Command) error
+
+// SetHandler registers the handler for an app-specific command type.
+func (u *UI) SetHandler(t ui.CommandType, h Handler) {
+	u.msem.Lock()
+	if u.handlers == nil {
+		u.handlers = make(map[ui.CommandType]Handler)
+	}
+	u.handlers[t] = h
+	u.msem.Unlock()
+}
+
+func (u *UI) run(cmd ui.Command) error {
+	u.msem.RLock()
+	mw := u.middleware
+	u.msem.RUnlock()
+
+	var next func(int, ui.Command) error
+	next = func(i int, cmd ui.Command) error {
+		if i == len(mw) {
+			return u.handle(cmd)
+		}
+		return mw[i](cmd, func(cmd ui.Command) error { return next(i+1, cmd) })
+	}
+	return next(0, cmd)
+}
+
+func (u *UI) Handle(cmd ui.Command) { u.handleReport(cmd) }
+
+// handleReport is Handle but also returns the reported error.
+func (u *UI) handleReport(cmd ui.Command) error {
+	err := u.run(cmd)
+	u.saveSession()
+	if err != nil {
+		u.l.Err(err)
+		return err
+	}
+
+	u.Refresh()
+	return nil
+}
+
+// Exec runs the given input like Input but returns the first error instead
+// of reporting it and leaves the current view untouched.
+// Commands after a failure only run if chained with '||'.
+func (u *UI) Exec(input string) error {
+	var view ui.View
+	var title string
+	var history []viewState
+	u.s.Do(func(s *StateData) error {
+		view, title, history = s.view, s.title, s.history
+		return nil
+	})
+
+	var err error
+	for _, cmd := range u.parser.Parse(input) {
+		if !cmd.Runs(err) {
+			continue
+		}
+		if err != nil && cmd.Chain() == ui.ChainAlways {
+			break
+		}
+		err = u.run(cmd)
+	}
 
+	u.s.Do(func(s *StateData) error {
+		s.view, s.title, s.history = view, title, history
+		return nil
+	})
 	u.Refresh()
+	return err
+}
+
+// Schedule registers input to be run periodically using Exec.
+// See jobs.ParseSpec for the spec syntax.
+func (u *UI) Schedule(name, spec, input string) error {
+	return u.sched.Add(name, jobs.KindSchedule, spec, func(j *jobs.Job) (interface{}, error) {
+		j.Logf("running '%s'", input)
+		return nil, u.Exec(input)
+	})
 }
 
 func (u *UI) SetExternal(title string, ext []collection.Song) {
@@ -293,32 +700,95 @@ func (u *UI) Refresh() {
 func (u *UI) refresh() error {
 	return u.s.Do(func(s *StateData) error {
 		s.SetCan()
-		v := s.View()
-		switch v {
-		case ui.ViewHelp:
-			return u.viewHelp(v, s)
-		case ui.ViewSearch:
-			return u.viewSearch(v, s)
-		case ui.ViewSearchOwn:
-			return u.viewSearchOwn(v, s)
-		case ui.ViewPlaylists:
-			return u.viewPlaylists(v, s)
-		case ui.ViewPlaylist:
-			return u.viewPlaylist(v, s)
-		case ui.ViewQueue:
-			return u.viewQueue(v, s)
-		case ui.ViewJobs:
-			return u.viewJobs(v, s)
-		case ui.ViewExternal:
-			return u.viewExternal(v, s)
-		case ui.ViewRename:
-			return u.viewRename(v, s)
-		case ui.ViewProblematics:
-			return u.viewProblematics(v, s)
-		}
+		return u.render(s)
+	})
+}
 
-		return nil
+// Snapshot renders the current view without flushing it to the Output,
+// e.g.: to respond to a command in a request/response style frontend.
+func (u *UI) Snapshot() ui.ViewState {
+	var v ui.ViewState
+	err := u.s.Do(func(s *StateData) error {
+		s.SetCan()
+		u.snapshot = &v
+		defer func() { u.snapshot = nil }()
+		return u.render(s)
 	})
+	if err != nil {
+		u.l.Err(err)
+	}
+	return v
+}
+
+// AtomicFlush flushes to the Output passed to New unless a Snapshot is
+// being rendered.
+func (u *UI) AtomicFlush(cb func(ui.AtomicOutput)) {
+	offset := u.offset
+	flush := func(a ui.AtomicOutput) {
+		cb(a)
+		if sc, ok := a.(ui.Scroller); ok {
+			sc.SetOffset(offset)
+		}
+	}
+	if u.snapshot != nil {
+		flush(u.snapshot)
+		return
+	}
+	u.Output.AtomicFlush(flush)
+}
+
+// render must be called from within State.Do.
+func (u *UI) render(s *StateData) error {
+	u.offset = s.Offset
+	v := s.View()
+	switch v {
+	case ui.ViewHelp:
+		return u.viewHelp(v, s)
+	case ui.ViewSearch:
+		return u.viewSearch(v, s)
+	case ui.ViewSearchOwn:
+		return u.viewSearchOwn(v, s)
+	case ui.ViewPlaylists:
+		return u.viewPlaylists(v, s)
+	case ui.ViewPlaylist:
+		return u.viewPlaylist(v, s)
+	case ui.ViewQueue:
+		return u.viewQueue(v, s)
+	case ui.ViewJobs:
+		return u.viewJobs(v, s)
+	case ui.ViewJobLog:
+		return u.viewJobLog(v, s)
+	case ui.ViewSchedules:
+		return u.viewSchedules(v, s)
+	case ui.ViewStats:
+		return u.viewStats(v, s)
+	case ui.ViewExternal:
+		return u.viewExternal(v, s)
+	case ui.ViewRename:
+		return u.viewRename(v, s)
+	case ui.ViewProblematics:
+		return u.viewProblematics(v, s)
+	case ui.ViewBanned:
+		return u.viewBanned(v, s)
+	case ui.ViewSkips:
+		return u.viewSkips(v, s)
+	case ui.ViewMarkers:
+		return u.viewMarkers(v, s)
+	case ui.ViewSubsonic:
+		return u.viewSubsonic(v, s)
+	case ui.ViewShare:
+		return u.viewShare(v, s)
+	case ui.ViewDuplicates:
+		return u.viewDuplicates(v, s)
+	case ui.ViewLyrics:
+		return u.viewLyrics(v, s)
+	case ui.ViewCheck:
+		return u.viewCheck(v, s)
+	case ui.ViewImport:
+		return u.viewImport(v, s)
+	}
+
+	return nil
 }
 
 func (u *UI) viewHelp(view ui.View, s *StateData) error {
@@ -343,9 +813,18 @@ func (u *UI) viewSearch(view ui.View, s *StateData) error {
 		s.QueryOfResult = s.Query
 	}
 
+	in := u.c.Membership()
 	songs := make([]ui.Song, 0, len(s.Search))
 	for _, s := range s.Search {
-		songs = append(songs, ui.NewUISong(u.c.FromYoutube(s), "", false))
+		song := u.c.FromYoutube(s)
+		flags := u.flags(song)
+		if m := s.Meta().String(); m != "" {
+			flags += " [" + m + "]"
+		}
+		if l := in[collection.GlobalID(song)]; len(l) != 0 {
+			flags += " [in: " + strings.Join(l, ", ") + "]"
+		}
+		songs = append(songs, ui.NewUISong(song, flags, false))
 	}
 
 	u.AtomicFlush(func(a ui.AtomicOutput) {
@@ -395,16 +874,42 @@ func (u *UI) viewRename(view ui.View, s *StateData) error {
 
 func (u *UI) viewJobs(view ui.View, s *StateData) error {
 	s.SetCan(CanCancelJob)
-	jobs := s.jobs.List()
-	l := make([]string, len(jobs))
-	for i, j := range jobs {
-		l[i] = fmt.Sprintf("%2d %s %3d%%", i+1, j.Name, int(100*j.Progress))
+	list := s.Jobs()
+	l := make([]string, len(list))
+	for i, j := range list {
+		p := j.Progress()
+		l[i] = fmt.Sprintf(
+			"%2d [%s] %-8s %s %3d%%",
+			i+1,
+			j.Kind(),
+			j.State(),
+			j.Name(),
+			int(100*p.Fraction()),
+		)
+		if p.Stage != "" {
+			l[i] += " " + p.Stage
+		}
+		if p.Message != "" {
+			l[i] += ": " + p.Message
+		}
 	}
 
+	st := u.c.TaskStatus()
+	summary := fmt.Sprintf(
+		"downloads: %d pending, %d in flight, %d recently failed\n"+
+			"titles:    %d pending, %d in flight, %d recently failed\n",
+		st.Downloads.TotalPending(),
+		st.Downloads.InFlight,
+		st.Downloads.Failed(),
+		st.Meta.TotalPending(),
+		st.Meta.InFlight,
+		st.Meta.Failed(),
+	)
+
 	u.AtomicFlush(func(a ui.AtomicOutput) {
 		a.SetView(view)
 		a.SetTitle(s.Title())
-		a.SetText(strings.Join(l, "\n"))
+		a.SetText(summary + strings.Join(l, "\n"))
 	})
 
 	return nil
@@ -426,7 +931,7 @@ func (u *UI) viewSearchOwn(view ui.View, s *StateData) error {
 
 	songs := make([]ui.Song, 0, len(s.Songs))
 	for i, song := range s.Songs {
-		extra := fmt.Sprintf(" [%s]", strings.Join(s.LocalSongs[i].Playlists, " "))
+		extra := fmt.Sprintf(" [%s]%s", strings.Join(s.LocalSongs[i].Sources(), " "), u.flags(song))
 		songs = append(songs, ui.NewUISong(song, extra, false))
 	}
 
@@ -444,6 +949,25 @@ func (u *UI) viewPlaylists(view ui.View, s *StateData) error {
 
 	l := u.c.List()
 	sort.Strings(l)
+	for i, n := range l {
+		settings, err := u.c.PlaylistSettings(n)
+		if err != nil {
+			continue
+		}
+		flags := make([]string, 0, 3)
+		if settings.Shuffle {
+			flags = append(flags, "shuffle")
+		}
+		if settings.Repeat != 0 {
+			flags = append(flags, fmt.Sprintf("repeat %d", settings.Repeat))
+		}
+		if settings.Sort != collection.SortManual {
+			flags = append(flags, fmt.Sprintf("sort %s", settings.Sort))
+		}
+		if len(flags) != 0 {
+			l[i] = fmt.Sprintf("%s [%s]", n, strings.Join(flags, ", "))
+		}
+	}
 	u.AtomicFlush(func(a ui.AtomicOutput) {
 		a.SetView(view)
 		a.SetTitle(s.Title())
@@ -463,7 +987,7 @@ func (u *UI) viewPlaylist(view ui.View, s *StateData) error {
 
 	songs := make([]ui.Song, 0, len(result))
 	for _, s := range result {
-		songs = append(songs, ui.NewUISong(s, "", false))
+		songs = append(songs, ui.NewUISong(s, u.flags(s), false))
 	}
 	s.Songs = result
 
@@ -482,7 +1006,82 @@ func (u *UI) viewQueue(view ui.View, s *StateData) error {
 	result := u.q.Slice()
 	songs := make([]ui.Song, 0, len(result))
 	for i, s := range result {
-		songs = append(songs, ui.NewUISong(s, "", ix == i))
+		f := u.flags(s)
+		if o := s.(*collection.QueueItem).Origin().String(); o != "" {
+			f += fmt.Sprintf(" [%s]", o)
+		}
+		songs = append(songs, ui.NewUISong(s, f, ix == i))
+	}
+	s.Songs = result
+
+	u.AtomicFlush(func(a ui.AtomicOutput) {
+		a.SetView(view)
+		a.SetTitle(s.Title())
+		a.SetSongs(songs)
+	})
+	return nil
+}
+
+// flags returns the Extra column markers for a song.
+func (u *UI) flags(s collection.Song) string {
+	var f string
+	if u.c.IsFavorite(s) {
+		f += " [fav]"
+	}
+	if u.c.Banned(s) {
+		f += " [banned]"
+	}
+	if u.c.Pinned(s) {
+		f += " [pin]"
+	}
+	if n := len(u.c.Alternatives(s)); n != 0 {
+		f += fmt.Sprintf(" [+%d]", n)
+	}
+	return f
+}
+
+func (u *UI) viewBanned(view ui.View, s *StateData) error {
+	s.SetCan(CanSong)
+
+	result := u.c.BannedSongs()
+	songs := make([]ui.Song, 0, len(result))
+	for _, s := range result {
+		songs = append(songs, ui.NewUISong(s, "", false))
+	}
+	s.Songs = result
+
+	u.AtomicFlush(func(a ui.AtomicOutput) {
+		a.SetView(view)
+		a.SetTitle(s.Title())
+		a.SetSongs(songs)
+	})
+	return nil
+}
+
+func (u *UI) viewSkips(view ui.View, s *StateData) error {
+	s.SetCan(CanSong)
+
+	list, err := stats.Suggestions(u.plays, stats.DefaultMinSkips)
+	if err != nil {
+		return err
+	}
+
+	result := make([]collection.Song, 0, len(list))
+	songs := make([]ui.Song, 0, len(list))
+	for _, sc := range list {
+		song, playlists, err := u.c.FindAll(sc.NS, sc.ID)
+		if err != nil || len(playlists) == 0 {
+			continue
+		}
+		extra := fmt.Sprintf(
+			" %d skips, %d plays [%s]%s",
+			sc.Skips,
+			sc.Plays,
+			strings.Join(playlists, " "),
+			u.flags(song),
+		)
+		result = append(result, song)
+		songs = append(songs, ui.NewUISong(song, extra, false))
 	}
 	s.Songs = result
 
@@ -507,11 +1106,15 @@ func (u *UI) viewProblematics(view ui.View, s *StateData) error {
 		}
 
 		songs[i] = fmt.Sprintf(
-			"%s-%s: %s%s\n%s\n\n",
+			"%s-%s: %s%s\n[%s] %d attempt(s) since %s, last %s\n%s\n\n",
 			s.NS(),
 			s.ID(),
 			s.Title(),
 			pls,
+			pr.Kind(),
+			pr.Attempts(),
+			pr.FirstSeen().Format("2006-01-02 15:04"),
+			pr.LastAttempt().Format("2006-01-02 15:04"),
 			pr.Reason().Error(),
 		)
 	}
@@ -528,17 +1131,7 @@ func (u *UI) help() string {
 	text := make([]string, 0)
 	n := u.parser.Help()
 	cmdStr := func(h ui.HelpEntry) (string, string) {
-		args := make([]string, 0)
-		switch h.Args {
-		case ui.Varadic:
-			args = append(args, "...args")
-		default:
-			for i := 0; i < int(h.Args); i++ {
-				args = append(args, fmt.Sprintf("arg%d", i+1))
-			}
-		}
-
-		return strings.Join(h.Cmds, ", "), strings.Join(args, " ")
+		return strings.Join(h.Cmds, ", "), h.ArgsUsage()
 	}
 
 	lCmd := 0
@@ -621,6 +1214,10 @@ func (u *UI) handle(cmd ui.Command) error {
 		return u.handleSongDelete(cmd)
 	case ui.CmdSeek:
 		return u.handleSeek(cmd)
+	case ui.CmdForward:
+		return u.handleStep(cmd, 1)
+	case ui.CmdRewind:
+		return u.handleStep(cmd, -1)
 	case ui.CmdQueue:
 		return u.handleQueue(cmd)
 	case ui.CmdQueueAfter:
@@ -643,13 +1240,107 @@ func (u *UI) handle(cmd ui.Command) error {
 		return u.handleJobs(cmd)
 	case ui.CmdCancelJob:
 		return u.handleCancelJobs(cmd)
+	case ui.CmdJobLog:
+		return u.handleJobLog(cmd)
+	case ui.CmdSchedules:
+		return u.handleSchedules(cmd)
+	case ui.CmdSchedule:
+		return u.handleSchedule(cmd)
+	case ui.CmdVerify:
+		return u.handleVerify(cmd)
+	case ui.CmdRefreshTitles:
+		return u.handleRefreshTitles(cmd)
+	case ui.CmdGC:
+		return u.handleGC(cmd)
+	case ui.CmdDownload:
+		return u.handleDownload(cmd)
+	case ui.CmdStats:
+		return u.handleStats(cmd)
 	case ui.CmdMeta:
 		return u.handleMeta(cmd)
 	case ui.CmdConfirm:
 		return u.handleConfirm(cmd)
 	case ui.CmdProblematics:
 		return u.handleProblematics(cmd)
+	case ui.CmdBan:
+		return u.handleBan(cmd, true)
+	case ui.CmdUnban:
+		return u.handleBan(cmd, false)
+	case ui.CmdBanned:
+		return u.handleBanned(cmd)
+	case ui.CmdFill:
+		return u.handleFill(cmd)
+	case ui.CmdSkips:
+		return u.handleSkips(cmd)
+	case ui.CmdPlaylistPrefs:
+		return u.handlePlaylistPrefs(cmd)
+	case ui.CmdBack:
+		return u.handleBack(cmd)
+	case ui.CmdPane:
+		return u.handlePane(cmd)
+	case ui.CmdCopy:
+		return u.handleCopy(cmd)
+	case ui.CmdFav:
+		return u.handleFav(cmd, true)
+	case ui.CmdUnfav:
+		return u.handleFav(cmd, false)
+	case ui.CmdFavs:
+		return u.handleFavs(cmd)
+	case ui.CmdMark:
+		return u.handleMark(cmd)
+	case ui.CmdUnmark:
+		return u.handleUnmark(cmd)
+	case ui.CmdMarkers:
+		return u.handleMarkers(cmd)
+	case ui.CmdAlt:
+		return u.handleAlt(cmd, true)
+	case ui.CmdUnalt:
+		return u.handleAlt(cmd, false)
+	case ui.CmdExport:
+		return u.handleExport(cmd)
+	case ui.CmdSubsonic:
+		return u.handleSubsonic(cmd)
+	case ui.CmdSubPlaylists:
+		return u.handleSubPlaylists(cmd)
+	case ui.CmdShare:
+		return u.handleShare(cmd)
+	case ui.CmdCleanupTitles:
+		return u.handleCleanupTitles(cmd)
+	case ui.CmdDuplicates:
+		return u.handleDuplicates(cmd)
+	case ui.CmdLyrics:
+		return u.handleLyrics(cmd)
+	case ui.CmdPin:
+		return u.handlePin(cmd, true)
+	case ui.CmdUnpin:
+		return u.handlePin(cmd, false)
+	case ui.CmdDaily:
+		return u.handleDaily(cmd)
+	case ui.CmdCheck:
+		return u.handleCheck(cmd)
+	case ui.CmdDead:
+		return u.handleDead(cmd)
+	case ui.CmdScrub:
+		return u.handleScrub(cmd)
+	case ui.CmdUnqueue:
+		return u.handleUnqueue(cmd)
+	case ui.CmdImport:
+		return u.handleImport(cmd)
+	case ui.CmdArchive:
+		return u.handleArchive(cmd)
+	case ui.CmdOnEnd:
+		return u.handleOnEnd(cmd)
+	case ui.CmdRetitle:
+		return u.handleRetitle(cmd)
+	case ui.CmdScroll:
+		return u.handleScroll(cmd)
 	default:
+		u.msem.RLock()
+		h, ok := u.handlers[cmdType]
+		u.msem.RUnlock()
+		if ok {
+			return h(cmd)
+		}
 		return fmt.Errorf("%s is not implemented", cmd.Cmd())
 	}
 }
@@ -694,7 +1385,13 @@ func (u *UI) handlePauseToggle(cmd ui.Command) error {
 func (u *UI) handleSetSongIndex(cmd ui.Command) error {
 	ix, ok := cmd.Args()[0].Int()
 	if !ok {
-		return fmt.Errorf("%s requires an integer argument, i.e.: index in queue", cmd.Cmd())
+		if cur := u.p.Current(); cur != nil {
+			if at, ok := u.c.Marker(cur, cmd.Args()[0].String()); ok {
+				u.p.Seek(at, io.SeekStart)
+				return nil
+			}
+		}
+		return fmt.Errorf("%s requires an integer argument, i.e.: index in queue, or a marker", cmd.Cmd())
 	}
 
 	u.q.SetCurrentIndex(ix - 1)
@@ -705,7 +1402,7 @@ func (u *UI) handleSetSongIndex(cmd ui.Command) error {
 
 func (u *UI) handleMove(cmd ui.Command) error {
 	args := cmd.Args()
-	f, ok := args[0].IntRange()
+	f, ok := u.songRange(args[0])
 	if !ok {
 		return fmt.Errorf("%s requires arg1 to be an integer", cmd.Cmd())
 	}
@@ -746,16 +1443,7 @@ func (u *UI) handleSongAdd(cmd ui.Command) error {
 	args := cmd.Args()
 	p := args[0].String()
 
-	add := func(songs []collection.Song) error {
-		for _, s := range songs {
-			if err := u.c.AddSong(p, s, true); err != nil {
-				return err
-			}
-		}
-		return nil
-	}
-
-	ints, ok := args[1].IntRange()
+	ints, ok := u.songRange(args[1])
 	if ok || args[1].String() == "all" {
 		return u.s.Do(func(s *StateData) error {
 			songs, err := u.fromResults(ints, s)
@@ -769,20 +1457,48 @@ func (u *UI) handleSongAdd(cmd ui.Command) error {
 			if err != nil {
 				return err
 			}
-			return add(songs)
+			return u.addSongs(s, p, songs)
 		})
 	}
 
 	songs, err := u.fromURLs(args[1:].Strings())
-	if err := add(songs); err != nil {
+	if err := u.s.Do(func(s *StateData) error { return u.addSongs(s, p, songs) }); err != nil {
 		return err
 	}
 	return err
 }
 
+// addSongs adds songs to playlist p and shows ViewDuplicates for those
+// that look like a song already in p.
+func (u *UI) addSongs(s *StateData, p string, songs []collection.Song) error {
+	if u.dupes <= 0 {
+		return u.c.AddSongs(p, songs, true, nil)
+	}
+	dups, rest, err := u.c.Duplicates(p, songs, u.dupes)
+	if err != nil {
+		return err
+	}
+	if err := u.c.AddSongs(p, rest, true, nil); err != nil {
+		return err
+	}
+	u.offerDuplicates(s, p, dups)
+	return nil
+}
+
+func (u *UI) offerDuplicates(s *StateData, p string, dups []collection.Duplicate) {
+	if len(dups) == 0 {
+		return
+	}
+	if s.Duplicates != nil && s.Duplicates.Playlist == p {
+		dups = append(s.Duplicates.List, dups...)
+	}
+	s.Duplicates = &Duplicates{Playlist: p, List: dups}
+	s.SetView(ui.ViewDuplicates, p)
+}
+
 func (u *UI) handleSongDelete(cmd ui.Command) error {
 	args := cmd.Args()
-	ints, ok := args[0].IntRange()
+	ints, ok := u.songRange(args[0])
 	if !ok {
 		return fmt.Errorf("%s requires arg2 to be an int or int range", cmd.Cmd())
 	}
@@ -805,7 +1521,7 @@ func (u *UI) handleSongDelete(cmd ui.Command) error {
 
 func (u *UI) handleSeek(cmd ui.Command) error {
 	n := cmd.Args()[0].String()
-	generic := fmt.Errorf("%s requires arg1 to be an integer or duration", cmd.Cmd())
+	generic := fmt.Errorf("%s requires arg1 to be an integer, duration or percentage", cmd.Cmd())
 	if len(n) == 0 {
 		return generic
 	}
@@ -819,33 +1535,148 @@ func (u *UI) handleSeek(cmd ui.Command) error {
 		n = n[1:]
 	}
 
-	var h, m, s int
-	err := func() error {
-		if _, err := fmt.Sscanf(n, "%d:%d:%d", &h, &m, &s); err == nil {
-			return nil
+	if strings.HasSuffix(n, "%") {
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(n, "%"), 64)
+		if err != nil {
+			return generic
 		}
-		h, m, s = 0, 0, 0
-		if _, err := fmt.Sscanf(n, "%d:%d", &m, &s); err == nil {
-			return nil
+		pct = float64(sign) * pct / 100
+		if !relative {
+			return u.scrub(pct)
 		}
-		h, m, s = 0, 0, 0
-		if _, err := fmt.Sscanf(n, "%d", &s); err == nil {
-			return nil
+		dur := u.p.Duration()
+		if dur <= 0 {
+			return errors.New("duration of the current song is unknown")
 		}
-		return generic
-	}()
+		return u.scrub(float64(u.p.Position())/float64(dur) + pct)
+	}
+
+	d, err := parseTimestamp(n)
 	if err != nil {
-		return err
+		return generic
 	}
 
-	s += m*60 + h*3600
-	s *= sign
+	d *= time.Duration(sign)
 	whence := io.SeekStart
 	if relative {
 		whence = io.SeekCurrent
 	}
 
-	u.p.Seek(time.Second*time.Duration(s), whence)
+	u.p.Seek(d, whence)
+	return nil
+}
+
+func (u *UI) handleScrub(cmd ui.Command) error {
+	n := strings.TrimSuffix(cmd.Args()[0].String(), "%")
+	pct, err := strconv.ParseFloat(n, 64)
+	if err != nil {
+		return fmt.Errorf("%s requires a percentage, e.g.: 42.5", cmd.Cmd())
+	}
+	return u.scrub(pct / 100)
+}
+
+// scrub seeks to a fraction of the current song, clamped to [0, 1].
+func (u *UI) scrub(n float64) error {
+	if u.p.Current() == nil {
+		return errors.New("nothing is playing")
+	}
+	if n < 0 {
+		n = 0
+	} else if n > 1 {
+		n = 1
+	}
+	u.p.SeekTo(n)
+	return nil
+}
+
+// parseTimestamp parses [[hh:]mm:]ss.
+func parseTimestamp(n string) (time.Duration, error) {
+	var h, m, s int
+	if _, err := fmt.Sscanf(n, "%d:%d:%d", &h, &m, &s); err == nil {
+		return time.Second * time.Duration(s+m*60+h*3600), nil
+	}
+	h, m, s = 0, 0, 0
+	if _, err := fmt.Sscanf(n, "%d:%d", &m, &s); err == nil {
+		return time.Second * time.Duration(s+m*60), nil
+	}
+	h, m, s = 0, 0, 0
+	if _, err := fmt.Sscanf(n, "%d", &s); err == nil {
+		return time.Second * time.Duration(s), nil
+	}
+	return 0, fmt.Errorf("invalid timestamp '%s'", n)
+}
+
+func (u *UI) handleMark(cmd ui.Command) error {
+	cur := u.p.Current()
+	if cur == nil {
+		return errors.New("nothing is playing")
+	}
+	args := cmd.Args()
+	name := args[0].String()
+	if _, err := strconv.Atoi(name); err == nil || len(name) > 255 {
+		return errors.New("marker name must not be a number or longer than 255 characters")
+	}
+
+	at := u.p.Position()
+	if len(args) > 1 {
+		var err error
+		if at, err = parseTimestamp(args[1].String()); err != nil {
+			return err
+		}
+	}
+	u.c.SetMarker(cur, name, at)
+	return nil
+}
+
+func (u *UI) handleUnmark(cmd ui.Command) error {
+	cur := u.p.Current()
+	if cur == nil {
+		return errors.New("nothing is playing")
+	}
+	if !u.c.DelMarker(cur, cmd.Args()[0].String()) {
+		return fmt.Errorf("no marker named '%s'", cmd.Args()[0])
+	}
+	return nil
+}
+
+func (u *UI) handleMarkers(cmd ui.Command) error {
+	return u.s.Do(func(s *StateData) error {
+		s.SetView(ui.ViewMarkers, "")
+		return nil
+	})
+}
+
+func (u *UI) viewMarkers(view ui.View, s *StateData) error {
+	var text string
+	if cur := u.p.Current(); cur != nil {
+		l := u.c.Markers(cur)
+		lines := make([]string, 0, len(l)+2)
+		lines = append(lines, cur.Title(), "")
+		for _, m := range l {
+			lines = append(lines, fmt.Sprintf("%8s %s", m.At, m.Name))
+		}
+		text = strings.Join(lines, "\n")
+	}
+
+	u.AtomicFlush(func(a ui.AtomicOutput) {
+		a.SetView(view)
+		a.SetTitle(s.Title())
+		a.SetText(text)
+	})
+	return nil
+}
+
+func (u *UI) handleStep(cmd ui.Command, dir int) error {
+	steps := 1
+	if args := cmd.Args(); len(args) != 0 {
+		n, ok := args[0].Int()
+		if !ok || n < 1 {
+			return fmt.Errorf("%s requires a positive amount of steps", cmd.Cmd())
+		}
+		steps = n
+	}
+
+	u.p.Seek(time.Duration(dir*steps)*u.seekStep, io.SeekCurrent)
 	return nil
 }
 
@@ -858,6 +1689,165 @@ func (u *UI) handleViewPlaylist(cmd ui.Command) error {
 	})
 }
 
+func (u *UI) handleBack(cmd ui.Command) error {
+	return u.s.Do(func(s *StateData) error {
+		if !s.Back() {
+			return errors.New("no previous view")
+		}
+		return nil
+	})
+}
+
+func (u *UI) handlePane(cmd ui.Command) error {
+	return u.s.Do(func(s *StateData) error {
+		if len(cmd.Args()) == 0 {
+			return s.SetPane((s.Pane() + 1) % Panes)
+		}
+		n, err := strconv.Atoi(cmd.Args()[0].String())
+		if err != nil {
+			return fmt.Errorf("%s requires a pane number", cmd.Cmd())
+		}
+		return s.SetPane(n - 1)
+	})
+}
+
+// handleCopy adds songs of the current view to the playlist shown in the
+// other pane.
+func (u *UI) handleCopy(cmd ui.Command) error {
+	ints, ok := u.songRange(cmd.Args()[0])
+	if !ok && cmd.Args()[0].String() != "all" {
+		return fmt.Errorf("%s requires a range of songs", cmd.Cmd())
+	}
+
+	return u.s.Do(func(s *StateData) error {
+		other := s.panes[(s.pane+1)%Panes].state
+		if other.view != ui.ViewPlaylist {
+			return fmt.Errorf("%s requires a playlist in the other pane", cmd.Cmd())
+		}
+
+		songs, err := u.fromResults(ints, s)
+		if err != nil {
+			var can *canError
+			if !errors.As(err, &can) {
+				return err
+			}
+			songs, err = u.fromSongs(ints, s)
+		}
+		if err != nil {
+			return err
+		}
+		return u.addSongs(s, other.playlist, songs)
+	})
+}
+
+func (u *UI) handleFav(cmd ui.Command, fav bool) error {
+	do := u.c.Favorite
+	if !fav {
+		do = u.c.Unfavorite
+	}
+	return u.eachSelected(cmd, do)
+}
+
+func (u *UI) handlePin(cmd ui.Command, pin bool) error {
+	return u.eachSelected(cmd, func(s collection.Song) error {
+		if pin {
+			u.c.Pin(s)
+			return nil
+		}
+		u.c.Unpin(s)
+		return nil
+	})
+}
+
+// eachSelected calls do for the current song or, if given, the range of
+// songs in the first argument.
+func (u *UI) eachSelected(cmd ui.Command, do func(collection.Song) error) error {
+	if len(cmd.Args()) == 0 {
+		cur := u.p.Current()
+		if cur == nil {
+			return errors.New("nothing is playing")
+		}
+		return do(cur)
+	}
+
+	ints, ok := u.songRange(cmd.Args()[0])
+	if !ok {
+		return fmt.Errorf("%s requires a range of songs", cmd.Cmd())
+	}
+
+	return u.s.Do(func(s *StateData) error {
+		songs, err := u.fromResults(ints, s)
+		if err != nil {
+			var can *canError
+			if !errors.As(err, &can) {
+				return err
+			}
+			songs, err = u.fromSongs(ints, s)
+		}
+		if err != nil {
+			return err
+		}
+		for _, song := range songs {
+			if err := do(song); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (u *UI) handleAlt(cmd ui.Command, add bool) error {
+	ints, ok := u.songRange(cmd.Args()[0])
+	if !ok || len(ints) != 1 {
+		return fmt.Errorf("%s requires a single song", cmd.Cmd())
+	}
+
+	arg := cmd.Args()[1].String()
+	var alt collection.Song
+	if strings.ContainsAny(arg, "./") {
+		y, err := u.c.FromYoutubeURL(arg, "")
+		if err != nil {
+			return err
+		}
+		alt = y
+	} else {
+		alt = u.c.FromYoutube(youtube.NewResult(arg, ""))
+	}
+
+	return u.s.Do(func(s *StateData) error {
+		songs, err := u.fromResults(ints, s)
+		if err != nil {
+			var can *canError
+			if !errors.As(err, &can) {
+				return err
+			}
+			songs, err = u.fromSongs(ints, s)
+		}
+		if err != nil {
+			return err
+		}
+
+		if add {
+			return u.c.AddAlternative(songs[0], alt)
+		}
+		if !u.c.DelAlternative(songs[0], alt) {
+			return fmt.Errorf("%s is not an alternative of %s", alt.ID(), songs[0].Title())
+		}
+		return nil
+	})
+}
+
+func (u *UI) handleFavs(cmd ui.Command) error {
+	if err := u.c.Create(collection.Favorites); err != nil && !collection.IsErrExists(err) {
+		return err
+	}
+	return u.s.Do(func(s *StateData) error {
+		s.SetView(ui.ViewPlaylist, collection.Favorites)
+		s.Playlist = collection.Favorites
+		return nil
+	})
+}
+
 func (u *UI) handleViewPlaylists(cmd ui.Command) error {
 	return u.s.Do(func(s *StateData) error {
 		s.SetView(ui.ViewPlaylists, "")
@@ -885,6 +1875,18 @@ func (u *UI) handleSearch(cmd ui.Command) error {
 	})
 }
 
+func (u *UI) handleScroll(cmd ui.Command) error {
+	n, ok := cmd.Args()[0].Int()
+	if !ok || n < 0 {
+		return fmt.Errorf("invalid scroll offset '%s'", cmd.Args()[0])
+	}
+
+	return u.s.Do(func(s *StateData) error {
+		s.Offset = n
+		return nil
+	})
+}
+
 func (u *UI) handleSearchOwn(cmd ui.Command) error {
 	q := cmd.Args().String()
 	if q == "" {
@@ -898,34 +1900,957 @@ func (u *UI) handleSearchOwn(cmd ui.Command) error {
 	})
 }
 
-func (u *UI) handleJobs(cmd ui.Command) error {
-	return u.s.Do(func(s *StateData) error {
+func (u *UI) viewJobLog(view ui.View, s *StateData) error {
+	text := ""
+	if s.JobLog != nil {
+		text = strings.Join(s.JobLog.Log(), "\n")
+	}
+
+	u.AtomicFlush(func(a ui.AtomicOutput) {
+		a.SetView(view)
+		a.SetTitle(s.Title())
+		a.SetText(text)
+	})
+
+	return nil
+}
+
+func (u *UI) viewSchedules(view ui.View, s *StateData) error {
+	list := u.sched.List()
+	l := make([]string, len(list))
+	date := func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return t.Format("2006-01-02 15:04")
+	}
+	for i, sc := range list {
+		l[i] = fmt.Sprintf(
+			"%s [%s] next: %s last: %s",
+			sc.Name,
+			sc.Spec,
+			date(sc.Next),
+			date(sc.Last),
+		)
+	}
+
+	u.AtomicFlush(func(a ui.AtomicOutput) {
+		a.SetView(view)
+		a.SetTitle(s.Title())
+		a.SetText(strings.Join(l, "\n"))
+	})
+
+	return nil
+}
+
+// statsTop is the length of the top lists in the stats view.
+const statsTop = 10
+
+func (u *UI) viewStats(view ui.View, s *StateData) error {
+	var r fmt.Stringer
+	var err error
+	if s.StatsPlaylist != "" {
+		r, err = stats.NewPlaylistReport(u.c, u.plays, s.StatsPlaylist, statsTop)
+	} else {
+		r, err = stats.NewReport(u.c, u.plays, s.StatsPeriod, statsTop)
+	}
+	if err != nil {
+		return err
+	}
+
+	u.AtomicFlush(func(a ui.AtomicOutput) {
+		a.SetView(view)
+		a.SetTitle(s.Title())
+		a.SetText(r.String())
+	})
+
+	return nil
+}
+
+func (u *UI) handleJobs(cmd ui.Command) error {
+	return u.s.Do(func(s *StateData) error {
+		s.SetView(ui.ViewJobs, "")
+		return nil
+	})
+}
+
+func (u *UI) handleCancelJobs(cmd ui.Command) error {
+	ints, ok := cmd.Args()[0].IntRange()
+	if !ok {
+		return fmt.Errorf("%s requires a range of jobs to cancel", cmd.Cmd())
+	}
+
+	cancel := make([]*jobs.Job, 0)
+	err := u.s.Do(func(s *StateData) error {
+		list := s.Jobs()
+		for _, n := range ints {
+			n--
+			if n < 0 || n >= len(list) {
+				return fmt.Errorf("invalid range")
+			}
+			cancel = append(cancel, list[n])
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// canceling a pending job finishes it immediately, which calls
+	// jobFinished and thus needs the state.
+	for _, c := range cancel {
+		c.Cancel()
+	}
+	return nil
+}
+
+func (u *UI) handleJobLog(cmd ui.Command) error {
+	args := cmd.Args()
+	if args[0] != "log" {
+		return fmt.Errorf("%s: unknown subcommand '%s'", cmd.Cmd(), args[0])
+	}
+	n, ok := args[1].Int()
+	if !ok {
+		return fmt.Errorf("%s log requires a single job", cmd.Cmd())
+	}
+
+	return u.s.Do(func(s *StateData) error {
+		list := s.Jobs()
+		n--
+		if n < 0 || n >= len(list) {
+			return fmt.Errorf("invalid job")
+		}
+		s.SetView(ui.ViewJobLog, list[n].Name())
+		s.JobLog = list[n]
+		return nil
+	})
+}
+
+func (u *UI) handleSchedules(cmd ui.Command) error {
+	return u.s.Do(func(s *StateData) error {
+		s.SetView(ui.ViewSchedules, "")
+		return nil
+	})
+}
+
+func (u *UI) handleSchedule(cmd ui.Command) error {
+	args := cmd.Args()
+	if len(args) < 2 {
+		return fmt.Errorf("%s requires a subcommand and a name", cmd.Cmd())
+	}
+
+	name := args[1].String()
+	switch args[0] {
+	case "add":
+		args = args[2:]
+		n := 5
+		if len(args) != 0 && strings.HasPrefix(args[0].String(), "@") {
+			n = 1
+			if args[0] == "@every" {
+				n = 2
+			}
+		}
+		if len(args) <= n {
+			return fmt.Errorf("%s add requires a name, a spec and a command", cmd.Cmd())
+		}
+		spec, input := args[:n].String(), args[n:].String()
+		if err := u.Schedule(name, spec, input); err != nil {
+			return err
+		}
+	case "del":
+		if !u.sched.Remove(name) {
+			return fmt.Errorf("schedule %s does not exist", name)
+		}
+	case "run":
+		if _, err := u.sched.Trigger(name); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%s: unknown subcommand '%s'", cmd.Cmd(), args[0])
+	}
+
+	return u.s.Do(func(s *StateData) error {
+		s.SetView(ui.ViewSchedules, "")
+		return nil
+	})
+}
+
+func (u *UI) handleVerify(cmd ui.Command) error {
+	u.c.VerifyDownloads()
+	return nil
+}
+
+func (u *UI) handleRefreshTitles(cmd ui.Command) error {
+	u.c.RefreshTitles()
+	return nil
+}
+
+func (u *UI) handleExport(cmd ui.Command) error {
+	args := cmd.Args()
+	profile, name := collection.DefaultTranscodeProfile, ""
+	if len(args) == 2 {
+		name = args[0].String()
+		p, ok := u.profiles[name]
+		if !ok {
+			names := make([]string, 0, len(u.profiles))
+			for n := range u.profiles {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("no such export profile '%s', available: %s", name, strings.Join(names, ", "))
+		}
+		profile, name = p, " ("+name+")"
+		args = args[1:]
+	}
+
+	dir := args[0].String()
+	return u.s.Do(func(s *StateData) error {
+		s.jobs.Run(jobs.KindExport, "export: "+dir+name, func(job *jobs.Job) (interface{}, error) {
+			res, err := u.c.ExportProfile(job.Context(), dir, u.names, profile, func(item, total int, song collection.Song) {
+				job.SetProgress(jobs.Progress{Stage: "exporting", Item: item, Total: total, Message: song.Title()})
+			})
+			job.Logf(
+				"wrote %d songs and %d playlists, %d unchanged, %d not downloaded",
+				res.Written,
+				res.Playlists,
+				res.Unchanged,
+				res.Skipped,
+			)
+			return res, err
+		})
+		return nil
+	})
+}
+
+func (u *UI) subsonic() (*subsonic.Client, error) {
+	client := u.c.Subsonic()
+	if client == nil {
+		return nil, errors.New("no subsonic server configured")
+	}
+	return client, nil
+}
+
+func (u *UI) fromSubsonic(l []*subsonic.Song) ([]collection.Song, error) {
+	songs := make([]collection.Song, 0, len(l))
+	for _, s := range l {
+		song, err := u.c.FromSubsonic(s)
+		if err != nil {
+			return nil, err
+		}
+		songs = append(songs, song)
+	}
+	return songs, nil
+}
+
+func (u *UI) handleSubsonic(cmd ui.Command) error {
+	q := cmd.Args().String()
+	if q == "" {
+		return fmt.Errorf("%s requires a search query parameter", cmd.Cmd())
+	}
+	client, err := u.subsonic()
+	if err != nil {
+		return err
+	}
+	result, err := client.Search(context.Background(), q)
+	if err != nil {
+		return err
+	}
+	songs, err := u.fromSubsonic(result)
+	if err != nil {
+		return err
+	}
+	u.SetExternal("subsonic: "+q, songs)
+	return nil
+}
+
+func (u *UI) handleSubPlaylists(cmd ui.Command) error {
+	client, err := u.subsonic()
+	if err != nil {
+		return err
+	}
+	playlists, err := client.Playlists(context.Background())
+	if err != nil {
+		return err
+	}
+
+	q := cmd.Args().String()
+	if q == "" {
+		return u.s.Do(func(s *StateData) error {
+			s.SetView(ui.ViewSubsonic, "")
+			s.SubsonicPlaylists = playlists
+			return nil
+		})
+	}
+
+	var pl *subsonic.Playlist
+	ix, isIndex := cmd.Args()[0].Int()
+	for i := range playlists {
+		if (isIndex && len(cmd.Args()) == 1 && i+1 == ix) || strings.EqualFold(playlists[i].Name, q) {
+			pl = &playlists[i]
+			break
+		}
+	}
+	if pl == nil {
+		return fmt.Errorf("no subsonic playlist '%s'", q)
+	}
+
+	result, err := client.Playlist(context.Background(), pl.ID)
+	if err != nil {
+		return err
+	}
+	songs, err := u.fromSubsonic(result)
+	if err != nil {
+		return err
+	}
+	u.SetExternal("subsonic: "+pl.Name, songs)
+	return nil
+}
+
+func (u *UI) viewSubsonic(view ui.View, s *StateData) error {
+	lines := make([]string, len(s.SubsonicPlaylists))
+	for i, p := range s.SubsonicPlaylists {
+		lines[i] = fmt.Sprintf("%3d %s (%d)", i+1, p.Name, p.SongCount)
+	}
+
+	u.AtomicFlush(func(a ui.AtomicOutput) {
+		a.SetView(view)
+		a.SetTitle(s.Title())
+		a.SetText(strings.Join(lines, "\n"))
+	})
+	return nil
+}
+
+func (u *UI) viewShare(view ui.View, s *StateData) error {
+	u.AtomicFlush(func(a ui.AtomicOutput) {
+		a.SetView(view)
+		a.SetTitle(s.Title())
+		a.SetText(s.Share)
+	})
+	return nil
+}
+
+func (u *UI) handleShare(cmd ui.Command) error {
+	args := cmd.Args()
+	format := collection.ShareText
+	if len(args) != 0 {
+		switch args[0].String() {
+		case "md", "markdown":
+			format = collection.ShareMarkdown
+			args = args[1:]
+		}
+	}
+
+	title := "queue"
+	songs := u.q.Slice()
+	if len(args) != 0 {
+		title = args.String()
+		var err error
+		if songs, err = u.c.PlaylistSongs(title); err != nil {
+			return err
+		}
+	}
+	if len(songs) == 0 {
+		return fmt.Errorf("%s is empty", title)
+	}
+
+	buf := &strings.Builder{}
+	if err := collection.Share(buf, songs, format); err != nil {
+		return err
+	}
+
+	if u.share != nil {
+		return u.share(buf.String())
+	}
+
+	return u.s.Do(func(s *StateData) error {
+		s.SetView(ui.ViewShare, title)
+		s.Share = buf.String()
+		return nil
+	})
+}
+
+func (u *UI) handleCleanupTitles(cmd ui.Command) error {
+	return u.s.Do(func(s *StateData) error {
+		s.jobs.Run(jobs.KindMaintenance, "cleanup titles", func(job *jobs.Job) (interface{}, error) {
+			n := u.c.CleanupTitles()
+			job.Logf("cleaned up %d titles", n)
+			return n, nil
+		})
+		return nil
+	})
+}
+
+func (u *UI) handleRetitle(cmd ui.Command) error {
+	args := cmd.Args()
+	dry := len(args) > 1 && args[len(args)-1].String() == "dry"
+	if dry {
+		args = args[:len(args)-1]
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("%s requires a substitution, e.g.: s/ - junk$//", cmd.Cmd())
+	}
+
+	// the substitution might contain spaces, the playlist can't.
+	expr, playlist := strings.Join(args.Strings(), " "), ""
+	rule, err := collection.ParseTitleRule(expr)
+	if err != nil && len(args) > 1 {
+		pl := args[len(args)-1].String()
+		if r, e := collection.ParseTitleRule(strings.Join(args[:len(args)-1].Strings(), " ")); e == nil {
+			rule, playlist, err = r, pl, nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if playlist != "" && !u.c.Exists(playlist) {
+		return fmt.Errorf("playlist %s does not exist", playlist)
+	}
+
+	name := "retitle"
+	if playlist != "" {
+		name = fmt.Sprintf("retitle %s", playlist)
+	}
+	return u.s.Do(func(s *StateData) error {
+		s.jobs.Run(jobs.KindMaintenance, name, func(job *jobs.Job) (interface{}, error) {
+			changes, err := u.c.Retitle(playlist, rule, dry)
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range changes {
+				job.Logf("%s => %s", c.From, c.To)
+			}
+			verb := "renamed"
+			if dry {
+				verb = "dry run, would rename"
+			}
+			job.Logf("%s %d titles", verb, len(changes))
+			return len(changes), nil
+		})
+		return nil
+	})
+}
+
+func (u *UI) handleDaily(cmd ui.Command) error {
+	d := u.daily
+	if cmd.ArgAmount() != 0 {
+		n, ok := cmd.Args()[0].Int()
+		if !ok || n <= 0 {
+			return fmt.Errorf("%s takes an optional amount of songs", cmd.Cmd())
+		}
+		d.Size = n
+	}
+
+	return u.s.Do(func(s *StateData) error {
+		s.jobs.Run(jobs.KindGenerate, "daily mix", func(job *jobs.Job) (interface{}, error) {
+			counts, err := u.plays.PlayCounts()
+			if err != nil {
+				return nil, err
+			}
+			r := rand.New(rand.NewSource(time.Now().UnixNano()))
+			mix, err := u.c.GenerateDaily(r, d, func(s collection.Song) int {
+				return counts[collection.GlobalID(s)]
+			})
+			if err != nil {
+				return nil, err
+			}
+			job.Logf("generated a daily mix of %d songs", len(mix))
+			return len(mix), nil
+		})
+		return nil
+	})
+}
+
+func (u *UI) handleArchive(cmd ui.Command) error {
+	if u.archive == nil {
+		return fmt.Errorf("%s: no archive policy configured", cmd.Cmd())
+	}
+	policy := *u.archive
+
+	return u.s.Do(func(s *StateData) error {
+		s.jobs.Run(jobs.KindMaintenance, "archive", func(job *jobs.Job) (interface{}, error) {
+			last, err := u.plays.LastPlayed()
+			if err != nil {
+				return nil, err
+			}
+			r, err := u.c.Archive(policy, func(s collection.Song) (time.Time, bool) {
+				t, ok := last[collection.GlobalID(s)]
+				return t, ok
+			}, time.Now())
+			for pl, songs := range r.Moved {
+				for _, s := range songs {
+					job.Logf("%s: %s", pl, s.Title())
+				}
+			}
+			job.Logf("moved %d songs to %s", r.Total(), r.Archive)
+			if err != nil {
+				return nil, err
+			}
+			return r.Total(), nil
+		})
+		return nil
+	})
+}
+
+func (u *UI) handleCheck(cmd ui.Command) error {
+	pl := cmd.Args()[0].String()
+	if !u.c.Exists(pl) {
+		return fmt.Errorf("no such playlist '%s'", pl)
+	}
+
+	return u.s.Do(func(s *StateData) error {
+		s.jobs.Run(jobs.KindMaintenance, "check: "+pl, func(job *jobs.Job) (interface{}, error) {
+			r, err := u.c.CheckPlaylist(job.Context(), pl, func(done, total int) {
+				job.SetItem(done, total)
+			})
+			for _, d := range r.Dead {
+				job.Logf("%s: %s", d.Song.Title(), d.Reason)
+			}
+			job.Logf(
+				"checked %d songs, %d dead, %d failed temporarily",
+				r.Checked,
+				len(r.Dead),
+				r.Failed,
+			)
+			if err != nil {
+				return r, err
+			}
+			if len(r.Dead) != 0 {
+				u.s.Do(func(s *StateData) error {
+					s.Check = &r
+					s.SetView(ui.ViewCheck, pl)
+					return nil
+				})
+			}
+			return r, nil
+		})
+		return nil
+	})
+}
+
+func (u *UI) handleDead(cmd ui.Command) error {
+	args := cmd.Args()
+	action := args[0].String()
+	switch action {
+	case "remove", "resolve":
+	default:
+		return fmt.Errorf("%s requires remove or resolve", cmd.Cmd())
+	}
+
+	var ints []int
+	if len(args) > 1 {
+		var ok bool
+		if ints, ok = u.songRange(args[1]); !ok {
+			return fmt.Errorf("%s requires a range of dead songs", cmd.Cmd())
+		}
+	}
+
+	return u.s.Do(func(s *StateData) error {
+		if s.Check == nil || len(s.Check.Dead) == 0 {
+			return errors.New("no dead songs, run check <playlist> first")
+		}
+		r := s.Check
+		sel := make(map[int]struct{}, len(ints))
+		for _, i := range ints {
+			i--
+			if i < 0 || i >= len(r.Dead) {
+				return fmt.Errorf("invalid index given: %d", i+1)
+			}
+			sel[i] = struct{}{}
+		}
+
+		keep := make([]collection.DeadSong, 0, len(r.Dead))
+		dead := make([]collection.Song, 0, len(r.Dead))
+		for i, d := range r.Dead {
+			if _, ok := sel[i]; len(sel) != 0 && !ok {
+				keep = append(keep, d)
+				continue
+			}
+			dead = append(dead, d.Song)
+		}
+
+		r.Dead = keep
+		if len(keep) == 0 {
+			s.Check = nil
+			s.Back()
+		}
+
+		pl := r.Playlist
+		if action == "remove" {
+			for _, song := range dead {
+				if err := u.c.DelSong(pl, song); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		s.jobs.Run(jobs.KindMaintenance, "resolve: "+pl, func(job *jobs.Job) (interface{}, error) {
+			n := 0
+			for i, song := range dead {
+				job.SetItem(i, len(dead))
+				alt, err := u.c.Resolve(job.Context(), pl, song)
+				if err != nil {
+					job.Logf("%s: %s", song.Title(), err)
+					continue
+				}
+				n++
+				job.Logf("replaced %s with %s", song.Title(), alt.Title())
+			}
+			job.Logf("resolved %d of %d songs", n, len(dead))
+			return n, nil
+		})
+		return nil
+	})
+}
+
+func (u *UI) viewCheck(view ui.View, s *StateData) error {
+	var lines []string
+	if s.Check != nil {
+		lines = make([]string, 0, len(s.Check.Dead)*2+1)
+		lines = append(lines, "fix with: dead remove|resolve [range]", "")
+		for i, d := range s.Check.Dead {
+			lines = append(
+				lines,
+				fmt.Sprintf("%3d %s", i+1, d.Song.Title()),
+				fmt.Sprintf("    %s", d.Reason),
+			)
+		}
+	}
+
+	u.AtomicFlush(func(a ui.AtomicOutput) {
+		a.SetView(view)
+		a.SetTitle(s.Title())
+		a.SetText(strings.Join(lines, "\n"))
+	})
+	return nil
+}
+
+func (u *UI) handleImport(cmd ui.Command) error {
+	args := cmd.Args()
+	dry := len(args) > 2 && args[len(args)-1].String() == "dry"
+	if dry {
+		args = args[:len(args)-1]
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("%s requires a strategy and a playlist", cmd.Cmd())
+	}
+	m, err := collection.ParseMergeStrategy(args[0].String())
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.Cmd(), err)
+	}
+	pl := args[1:].String()
+	if _, err := strconv.Atoi(pl); err == nil {
+		return fmt.Errorf("playlist name mustn't be a number")
+	}
+
+	return u.s.Do(func(s *StateData) error {
+		songs, err := u.fromResults(nil, s)
+		if err != nil {
+			var can *canError
+			if !errors.As(err, &can) {
+				return err
+			}
+			songs, err = u.fromSongs(nil, s)
+		}
+		if err != nil {
+			return err
+		}
+
+		sum, err := u.c.Merge(pl, songs, m, dry)
+		if err != nil {
+			return err
+		}
+		s.SetView(ui.ViewImport, pl)
+		s.Import = &sum
+		return nil
+	})
+}
+
+func (u *UI) viewImport(view ui.View, s *StateData) error {
+	var lines []string
+	if sum := s.Import; sum != nil {
+		verb := "imported"
+		if sum.DryRun {
+			verb = "dry run, would import"
+		}
+		lines = append(
+			lines,
+			fmt.Sprintf(
+				"%s into %s using %s: %d added, %d moved, %d removed, %d skipped",
+				verb,
+				sum.Playlist,
+				sum.Strategy,
+				len(sum.Added),
+				len(sum.Moved),
+				len(sum.Removed),
+				len(sum.Skipped),
+			),
+			"",
+		)
+		list := func(prefix string, songs []collection.Song) {
+			for _, song := range songs {
+				lines = append(lines, fmt.Sprintf("%s %s", prefix, song.Title()))
+			}
+		}
+		list("+", sum.Added)
+		list(">", sum.Moved)
+		list("-", sum.Removed)
+		list("=", sum.Skipped)
+	}
+
+	u.AtomicFlush(func(a ui.AtomicOutput) {
+		a.SetView(view)
+		a.SetTitle(s.Title())
+		a.SetText(strings.Join(lines, "\n"))
+	})
+	return nil
+}
+
+func (u *UI) viewDuplicates(view ui.View, s *StateData) error {
+	var lines []string
+	if s.Duplicates != nil {
+		lines = make([]string, 0, len(s.Duplicates.List)*2+1)
+		lines = append(lines, "resolve with: dup add|skip|replace [range]", "")
+		for i, d := range s.Duplicates.List {
+			lines = append(
+				lines,
+				fmt.Sprintf("%3d %s", i+1, d.Song.Title()),
+				fmt.Sprintf("    looks like %s (%.0f%%)", d.Existing.Title(), d.Similarity*100),
+			)
+		}
+	}
+
+	u.AtomicFlush(func(a ui.AtomicOutput) {
+		a.SetView(view)
+		a.SetTitle(s.Title())
+		a.SetText(strings.Join(lines, "\n"))
+	})
+	return nil
+}
+
+func (u *UI) handleDuplicates(cmd ui.Command) error {
+	args := cmd.Args()
+	action := args[0].String()
+	switch action {
+	case "add", "skip", "replace":
+	default:
+		return fmt.Errorf("%s requires add, skip or replace", cmd.Cmd())
+	}
+
+	var ints []int
+	if len(args) > 1 {
+		var ok bool
+		if ints, ok = u.songRange(args[1]); !ok {
+			return fmt.Errorf("%s requires a range of duplicates", cmd.Cmd())
+		}
+	}
+
+	return u.s.Do(func(s *StateData) error {
+		if s.Duplicates == nil || len(s.Duplicates.List) == 0 {
+			return errors.New("no duplicates to resolve")
+		}
+		d := s.Duplicates
+		sel := make(map[int]struct{}, len(ints))
+		for _, i := range ints {
+			i--
+			if i < 0 || i >= len(d.List) {
+				return fmt.Errorf("invalid index given: %d", i+1)
+			}
+			sel[i] = struct{}{}
+		}
+
+		keep := make([]collection.Duplicate, 0, len(d.List))
+		add := make([]collection.Song, 0, len(d.List))
+		for i, dup := range d.List {
+			if _, ok := sel[i]; len(sel) != 0 && !ok {
+				keep = append(keep, dup)
+				continue
+			}
+			switch action {
+			case "add":
+				add = append(add, dup.Song)
+			case "replace":
+				if err := u.c.ReplaceSong(d.Playlist, dup.Existing, dup.Song); err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(add) != 0 {
+			if err := u.c.AddSongs(d.Playlist, add, true, nil); err != nil {
+				return err
+			}
+		}
+
+		d.List = keep
+		if len(keep) == 0 {
+			s.Duplicates = nil
+			s.Back()
+		}
+		return nil
+	})
+}
+
+func (u *UI) viewLyrics(view ui.View, s *StateData) error {
+	var text string
+	song := u.p.Current()
+	if song != nil {
+		l, err := u.c.Lyrics(song)
+		switch {
+		case os.IsNotExist(err):
+			text = "no lyrics, try: lyrics fetch"
+		case err != nil:
+			text = err.Error()
+		default:
+			cur := l.Index(u.p.Position())
+			lines := make([]string, len(l.Lines))
+			for i, line := range l.Lines {
+				prefix := "  "
+				if i == cur {
+					prefix = "> "
+				}
+				if l.Synced {
+					prefix += fmt.Sprintf("%8s ", line.At.Truncate(time.Second))
+				}
+				lines[i] = prefix + line.Text
+			}
+			text = strings.Join(lines, "\n")
+		}
+	}
+
+	u.AtomicFlush(func(a ui.AtomicOutput) {
+		a.SetView(view)
+		a.SetTitle(s.Title())
+		a.SetText(text)
+	})
+	return nil
+}
+
+func (u *UI) handleLyrics(cmd ui.Command) error {
+	song := u.p.Current()
+	if song == nil {
+		return errors.New("nothing is playing")
+	}
+	show := func() error {
+		return u.s.Do(func(s *StateData) error {
+			s.SetView(ui.ViewLyrics, song.Title())
+			return nil
+		})
+	}
+
+	arg := cmd.Args().String()
+	switch arg {
+	case "":
+		return show()
+	case "clear":
+		return u.c.DelLyrics(song)
+	case "fetch":
+		if u.lyrics == nil {
+			return errors.New("no lyrics source configured")
+		}
+		duration := u.p.Duration()
+		return u.s.Do(func(s *StateData) error {
+			s.jobs.Run(jobs.KindMeta, "lyrics: "+song.Title(), func(job *jobs.Job) (interface{}, error) {
+				l, err := u.c.FetchLyrics(job.Context(), u.lyrics, song, duration)
+				if err != nil {
+					return nil, err
+				}
+				job.Logf("found %d lines", len(l.Lines))
+				u.changed()
+				return len(l.Lines), nil
+			})
+			return nil
+		})
+	}
+
+	f, err := os.Open(arg)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := u.c.SetLyrics(song, f); err != nil {
+		return err
+	}
+	return show()
+}
+
+func (u *UI) handleGC(cmd ui.Command) error {
+	return u.s.Do(func(s *StateData) error {
+		s.jobs.Run(jobs.KindMaintenance, "gc", func(job *jobs.Job) (interface{}, error) {
+			n, err := u.c.RemoveUnreferencedDownloads()
+			job.Logf("removed %d unreferenced downloads", n)
+			return n, err
+		})
+		return nil
+	})
+}
+
+func (u *UI) handleDownload(cmd ui.Command) error {
+	ints, ok := u.songRange(cmd.Args()[0])
+	if !ok && cmd.Args()[0].String() != "all" {
+		return fmt.Errorf("%s requires a range of songs", cmd.Cmd())
+	}
+
+	return u.s.Do(func(s *StateData) error {
+		songs, err := u.fromResults(ints, s)
+		if err != nil {
+			var can *canError
+			if !errors.As(err, &can) {
+				return err
+			}
+			songs, err = u.fromSongs(ints, s)
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, song := range songs {
+			u.c.Download(song)
+		}
 		s.SetView(ui.ViewJobs, "")
 		return nil
 	})
 }
 
-func (u *UI) handleCancelJobs(cmd ui.Command) error {
-	ints, ok := cmd.Args()[0].IntRange()
-	if !ok {
-		return fmt.Errorf("%s requires a range of jobs to cancel", cmd.Cmd())
+func (u *UI) handleStats(cmd ui.Command) error {
+	args := cmd.Args()
+	period := stats.PeriodAll
+	if len(args) > 0 {
+		period = stats.Period(args[0])
 	}
-
-	return u.s.Do(func(s *StateData) error {
-		jobs := s.jobs.List()
-		cancel := make([]*Job, 0)
-		for _, n := range ints {
-			n--
-			if n < 0 || n >= len(jobs) {
-				return fmt.Errorf("invalid range")
-			}
-			cancel = append(cancel, jobs[n])
+	if _, err := period.Since(time.Now()); err != nil {
+		if !u.c.Exists(args[0].String()) {
+			return err
 		}
+		return u.handlePlaylistStats(args[0].String())
+	}
 
-		for _, c := range cancel {
-			c.Cancel()
+	if len(args) > 1 {
+		r, err := stats.NewReport(u.c, u.plays, period, statsTop)
+		if err != nil {
+			return err
 		}
+		data, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(args[1].String(), data, 0o644); err != nil {
+			return err
+		}
+	}
+
+	return u.s.Do(func(s *StateData) error {
+		s.SetView(ui.ViewStats, string(period))
+		s.StatsPeriod = period
+		s.StatsPlaylist = ""
+		return nil
+	})
+}
 
+func (u *UI) handlePlaylistStats(name string) error {
+	return u.s.Do(func(s *StateData) error {
+		s.SetView(ui.ViewStats, name)
+		s.StatsPlaylist = name
 		return nil
 	})
 }
@@ -957,70 +2882,168 @@ func (u *UI) handleMeta(cmd ui.Command) error {
 			return err
 		}
 
-		ctx, cancel := context.WithCancel(context.Background())
-		job := s.jobs.Add(fmt.Sprintf("fingerprint: %s-%s", song.NS(), song.ID()))
-		job.SetCancel(cancel)
 		s.SetView(ui.ViewJobs, "")
+		s.jobs.Run(
+			jobs.KindIdentify,
+			fmt.Sprintf("fingerprint: %s-%s", song.NS(), song.ID()),
+			func(job *jobs.Job) (interface{}, error) {
+				name, err := u.fingerprint(job, file)
+				if err != nil {
+					return nil, err
+				}
+				job.Logf("identified as '%s'", name)
 
-		go func() {
-			defer u.s.Do(func(s *StateData) error {
-				s.jobs.Remove(job.ID())
-				return nil
-			})
+				u.s.Do(func(s *StateData) error {
+					sec := s.SetConfirm(oview, func() {
+						u.c.RenameSong(song, name)
+					})
 
-			f, err := os.Open(file)
-			job.Progress = 0.1
-			if err != nil {
-				u.l.Err(err)
-				return
-			}
-			defer f.Close()
+					s.Rename = &Rename{
+						Song: song,
+						Name: name,
+						Sec:  sec,
+					}
+					s.SetView(ui.ViewRename, "")
+					return nil
+				})
+				u.Refresh()
+				return name, nil
+			},
+		)
 
-			fp, dur, err := u.acoustid.Fingerprint(ctx, f)
-			job.Progress = 0.5
-			if err != nil {
-				u.l.Err(err)
-				return
-			}
+		return nil
+	})
+}
 
-			result, err := u.acoustid.Lookup(ctx, fp, dur)
-			job.Progress = 0.95
+func (u *UI) fingerprint(job *jobs.Job, file string) (string, error) {
+	const total = 3
+	ctx := job.Context()
+	job.SetProgress(jobs.Progress{Stage: "reading", Item: 0, Total: total})
+	job.Logf("reading %s", file)
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
 
-			if err != nil {
-				u.l.Err(err)
-				return
+	job.SetProgress(jobs.Progress{Stage: "fingerprinting", Item: 1, Total: total})
+	fp, dur, err := u.acoustid.Fingerprint(ctx, f)
+	if err != nil {
+		return "", err
+	}
+
+	job.SetProgress(jobs.Progress{Stage: "lookup", Item: 2, Total: total})
+	job.Logf("looking up fingerprint of %ds of audio", dur)
+	result, err := u.acoustid.Lookup(ctx, fp, dur)
+	if err != nil {
+		return "", err
+	}
+
+	job.SetProgress(jobs.Progress{Stage: "done", Item: total, Total: total})
+	name := result.BestString(0.5)
+	if name == "" {
+		return "", errors.New("fingerprinting failed: no results")
+	}
+
+	return name, nil
+}
+
+func (u *UI) handleProblematics(cmd ui.Command) error {
+	return u.s.Do(func(s *StateData) error {
+		s.SetView(ui.ViewProblematics, "")
+		return nil
+	})
+}
+
+func (u *UI) handleBan(cmd ui.Command, ban bool) error {
+	ints, ok := u.songRange(cmd.Args()[0])
+	if !ok {
+		return fmt.Errorf("%s requires a range of songs", cmd.Cmd())
+	}
+
+	return u.s.Do(func(s *StateData) error {
+		songs, err := u.fromResults(ints, s)
+		if err != nil {
+			var can *canError
+			if !errors.As(err, &can) {
+				return err
 			}
+			songs, err = u.fromSongs(ints, s)
+		}
+		if err != nil {
+			return err
+		}
 
-			name := result.BestString(0.5)
-			job.Progress = 1.0
-			if name == "" {
-				u.l.Err(errors.New("fingerprinting failed: no results"))
-				return
+		for _, song := range songs {
+			if ban {
+				u.c.Ban(song)
+				continue
 			}
+			u.c.Unban(song)
+		}
+		return nil
+	})
+}
 
-			u.s.Do(func(s *StateData) error {
-				sec := s.SetConfirm(oview, func() {
-					u.c.RenameSong(song, name)
-				})
+func (u *UI) handleBanned(cmd ui.Command) error {
+	return u.s.Do(func(s *StateData) error {
+		s.SetView(ui.ViewBanned, "")
+		return nil
+	})
+}
 
-				s.Rename = &Rename{
-					Song: song,
-					Name: name,
-					Sec:  sec,
-				}
-				s.SetView(ui.ViewRename, "")
-				return nil
-			})
-			u.Refresh()
-		}()
+func (u *UI) handlePlaylistPrefs(cmd ui.Command) error {
+	args := cmd.Args()
+	if len(args) < 3 {
+		return fmt.Errorf("%s requires a playlist, a setting and a value", cmd.Cmd())
+	}
+	name := args[:len(args)-2].String()
+	key, value := args[len(args)-2].String(), args[len(args)-1].String()
+
+	settings, err := u.c.PlaylistSettings(name)
+	if err != nil {
+		return err
+	}
+
+	switch key {
+	case "shuffle":
+		switch value {
+		case "on", "yes", "1":
+			settings.Shuffle = true
+		case "off", "no", "0":
+			settings.Shuffle = false
+		default:
+			return fmt.Errorf("%s shuffle requires on or off", cmd.Cmd())
+		}
+	case "repeat":
+		n, err := strconv.ParseUint(value, 10, 8)
+		if err != nil {
+			return fmt.Errorf("%s repeat requires a number between 0 and 255", cmd.Cmd())
+		}
+		settings.Repeat = uint8(n)
+	case "sort":
+		k, err := collection.ParseSortKey(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", cmd.Cmd(), err)
+		}
+		settings.Sort = k
+	default:
+		return fmt.Errorf("%s: unknown setting '%s'", cmd.Cmd(), key)
+	}
+
+	if err := u.c.SetPlaylistSettings(name, settings); err != nil {
+		return err
+	}
 
+	return u.s.Do(func(s *StateData) error {
+		s.SetView(ui.ViewPlaylists, "")
 		return nil
 	})
 }
 
-func (u *UI) handleProblematics(cmd ui.Command) error {
+func (u *UI) handleSkips(cmd ui.Command) error {
 	return u.s.Do(func(s *StateData) error {
-		s.SetView(ui.ViewProblematics, "")
+		s.SetView(ui.ViewSkips, "")
 		return nil
 	})
 }
@@ -1065,61 +3088,112 @@ func (u *UI) handleScrape(cmd ui.Command) error {
 		concurrency = 1
 	}
 
-	for _, uri := range uris {
-		var job *Job
-		u.s.Do(func(s *StateData) error {
-			job = s.jobs.Add(fmt.Sprintf("scrape: %s %s", pl, uri))
-			s.SetView(ui.ViewJobs, "")
-			return nil
-		})
-
-		go func(uri string) {
-			defer u.s.Do(func(s *StateData) error {
-				s.jobs.Remove(job.ID())
-				return nil
-			})
+	u.s.Do(func(s *StateData) error {
+		s.SetView(ui.ViewJobs, "")
+		for _, uri := range uris {
+			s.jobs.Run(
+				jobs.KindScrape,
+				fmt.Sprintf("scrape: %s %s", pl, uri),
+				u.scrapeJob(cmd, pl, uri, depth, concurrency),
+			)
+		}
+		return nil
+	})
 
-			scr := scraper.New(scraper.Config{
-				Concurrency: concurrency,
-				MaxDepth:    depth,
-				Callback: func(uri *url.URL, doc *goquery.Document, depth, item, total int) error {
-					if total == 0 {
-						return nil
-					}
-					job.Progress = float64(item) / float64(total)
-					return nil
-				},
-			})
+	return nil
+}
 
-			ctx, cancel := context.WithCancel(context.Background())
-			job.SetCancel(cancel)
+func (u *UI) scrapeJob(cmd ui.Command, pl, uri string, depth, concurrency int) jobs.Func {
+	return func(job *jobs.Job) (interface{}, error) {
+		job.SetStage("scraping", uri)
+		job.Logf("scraping %s (depth %d, concurrency %d)", uri, depth, concurrency)
+		scr := scraper.New(scraper.Config{
+			Concurrency: concurrency,
+			MaxDepth:    depth,
+			Callback: func(uri *url.URL, doc *goquery.Document, depth, item, total int) error {
+				job.SetProgress(jobs.Progress{
+					Stage:   "scraping",
+					Item:    item,
+					Total:   total,
+					Message: uri.String(),
+				})
+				job.Logf("visited %s", uri)
+				return nil
+			},
+		})
 
-			err := youtube.NewScraper(scr, func(r *youtube.Result) {
-				if err := u.c.AddSong(pl, u.c.FromYoutube(r), false); err != nil {
-					u.l.Err(fmt.Errorf("%s error: %w", cmd.Cmd(), err))
+		found := 0
+		var dups []collection.Duplicate
+		err := youtube.NewScraper(scr, func(r *youtube.Result) {
+			found++
+			song := u.c.FromYoutube(r)
+			if u.dupes > 0 {
+				d, _, err := u.c.Duplicates(pl, []collection.Song{song}, u.dupes)
+				if err == nil && len(d) != 0 {
+					job.Logf("%s looks like %s", r.ID(), d[0].Existing.Title())
+					dups = append(dups, d...)
+					return
 				}
-			}).ScrapeWithContext(ctx, uri)
-			if err != nil {
+			}
+			if err := u.c.AddSong(pl, song, false); err != nil {
+				job.Logf("failed to add %s: %s", r.ID(), err)
 				u.l.Err(fmt.Errorf("%s error: %w", cmd.Cmd(), err))
 				return
 			}
-		}(uri)
+			job.Logf("added %s to %s", r.ID(), pl)
+		}).ScrapeWithContext(job.Context(), uri)
+		job.Logf("found %d songs", found)
+		if len(dups) != 0 {
+			u.s.Do(func(s *StateData) error {
+				u.offerDuplicates(s, pl, dups)
+				return nil
+			})
+		}
+		if err != nil {
+			return found, err
+		}
+		return found, nil
+	}
+}
+
+// weights returns the weight function used by weighted shuffles.
+func (u *UI) weights() (func(collection.Song) float64, error) {
+	w, err := stats.NewWeights(u.plays, u.c.IsFavorite, 0)
+	if err != nil {
+		return nil, err
 	}
+	return w.Weight, nil
+}
 
-	return nil
+// weighted strips a leading 'weighted' argument.
+func weighted(args ui.Args) (ui.Args, bool) {
+	if len(args) != 0 && args[0].String() == "weighted" {
+		return args[1:], true
+	}
+	return args, false
 }
 
 func (u *UI) handleQueueShuffle(cmd ui.Command) error {
-	args := cmd.ArgAmount()
-	if args > 2 {
+	args, weigh := weighted(cmd.Args())
+	if len(args) > 2 {
 		return fmt.Errorf("%s takes no arguments or a start and end index of queue items", cmd.Cmd())
 	}
-	if args == 0 || cmd.Args().String() == "all" {
-		u.q.Shuffle()
+
+	shuffle := u.q.ShuffleRange
+	if weigh {
+		weight, err := u.weights()
+		if err != nil {
+			return err
+		}
+		shuffle = func(start, end int) { u.q.ShuffleRangeWeighted(start, end, weight) }
+	}
+
+	if len(args) == 0 || args.String() == "all" {
+		shuffle(0, -1)
 		return nil
 	}
 
-	rng, ok := cmd.Args().Ints()
+	rng, ok := args.Ints()
 	if !ok || len(rng) < 2 {
 		return fmt.Errorf("%s requires a range of queue items", cmd.Cmd())
 	}
@@ -1134,7 +3208,170 @@ func (u *UI) handleQueueShuffle(cmd ui.Command) error {
 		rng = rng[:2]
 	}
 
-	u.q.ShuffleRange(rng[0]-1, rng[1]-1)
+	shuffle(rng[0]-1, rng[1]-1)
+	return nil
+}
+
+// defaultSongLength is the estimated length of songs that were never played.
+const defaultSongLength = time.Minute * 4
+
+func (u *UI) handleFill(cmd ui.Command) error {
+	args, weigh := weighted(cmd.Args())
+	if len(args) != 2 {
+		return fmt.Errorf("%s requires a playlist and a duration", cmd.Cmd())
+	}
+	target, err := time.ParseDuration(args[1].String())
+	if err != nil || target <= 0 {
+		return fmt.Errorf("%s requires a duration, e.g.: 45m", cmd.Cmd())
+	}
+
+	songs, err := u.c.PlaylistSongs(args[0].String())
+	if err != nil {
+		return err
+	}
+
+	var weight func(collection.Song) float64
+	if weigh {
+		if weight, err = u.weights(); err != nil {
+			return err
+		}
+	}
+
+	return u.fill(args[0].String(), songs, target, weight)
+}
+
+// fill queues a random selection of songs that are not banned of
+// roughly target length.
+func (u *UI) fill(name string, result []collection.Song, target time.Duration, weight func(collection.Song) float64) error {
+	songs := make([]collection.Song, 0, len(result))
+	for _, s := range result {
+		if !u.c.Banned(s) {
+			songs = append(songs, s)
+		}
+	}
+
+	lengths, err := u.plays.Lengths()
+	if err != nil {
+		return err
+	}
+	estimate, n := time.Duration(0), 0
+	for _, s := range songs {
+		if d, ok := lengths[collection.GlobalID(s)]; ok {
+			estimate += d
+			n++
+		}
+	}
+	if n == 0 {
+		estimate = defaultSongLength
+	} else {
+		estimate /= time.Duration(n)
+	}
+
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	sel := collection.FillWeighted(r, songs, target, func(s collection.Song) time.Duration {
+		if d, ok := lengths[collection.GlobalID(s)]; ok {
+			return d
+		}
+		return estimate
+	}, weight)
+	u.c.QueueSongsFrom(-1, sel, collection.Origin{Kind: collection.OriginFill, Name: name}, nil)
+	return nil
+}
+
+// queueEnded is the player.Player end handler, see EndMode.
+func (u *UI) queueEnded() bool {
+	u.msem.RLock()
+	mode, hook := u.end, u.endHook
+	u.msem.RUnlock()
+
+	n := len(u.q.Slice())
+	switch mode {
+	case EndRepeat:
+		u.q.SetCurrentIndex(0)
+		return n != 0
+	case EndShuffle:
+		u.q.Shuffle()
+		u.q.SetCurrentIndex(0)
+		return n != 0
+	case EndRadio:
+		if err := u.radio(); err != nil {
+			u.l.Err(fmt.Errorf("radio: %w", err))
+			return false
+		}
+	case EndHook:
+		if hook == nil {
+			u.l.Err(errors.New("end of queue: no hook configured"))
+			return false
+		}
+		if err := hook(); err != nil {
+			u.l.Err(fmt.Errorf("end of queue hook: %w", err))
+			return false
+		}
+	default:
+		return false
+	}
+
+	if len(u.q.Slice()) == n {
+		return false
+	}
+	u.q.SetCurrentIndex(n)
+	return true
+}
+
+// radio fills the queue from the playlist the last song in the queue was
+// queued from, or from all playlists.
+func (u *UI) radio() error {
+	name := ""
+	if o := u.q.Origins(); len(o) != 0 {
+		last := o[len(o)-1]
+		if (last.Kind == collection.OriginPlaylist || last.Kind == collection.OriginFill) && u.c.Exists(last.Name) {
+			name = last.Name
+		}
+	}
+	if name != "" {
+		songs, err := u.c.PlaylistSongs(name)
+		if err != nil {
+			return err
+		}
+		return u.fill(name, songs, radioLength, nil)
+	}
+
+	seen := make(map[string]struct{})
+	songs := make([]collection.Song, 0)
+	for _, pl := range u.c.List() {
+		l, err := u.c.PlaylistSongs(pl)
+		if err != nil {
+			return err
+		}
+		for _, s := range l {
+			id := collection.GlobalID(s)
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			songs = append(songs, s)
+		}
+	}
+	return u.fill("radio", songs, radioLength, nil)
+}
+
+func (u *UI) handleOnEnd(cmd ui.Command) error {
+	m, err := ParseEndMode(cmd.Args()[0].String())
+	if err != nil {
+		return err
+	}
+	if m == EndHook {
+		u.msem.RLock()
+		hook := u.endHook
+		u.msem.RUnlock()
+		if hook == nil {
+			return fmt.Errorf("%s: no hook configured", cmd.Cmd())
+		}
+	}
+	u.SetEndMode(m)
+	if n, ok := u.l.(ui.Notifier); ok {
+		n.Notify(fmt.Sprintf("end of queue: %s", m))
+	}
 	return nil
 }
 
@@ -1156,12 +3393,13 @@ func (u *UI) queue(cmd string, arg ui.Arg, ix int) error {
 		return nil
 	}
 
-	ints, ok := arg.IntRange()
+	ints, ok := u.songRange(arg)
 	if !ok && arg.String() != "all" {
 		return fmt.Errorf("%s requires a range of songs", cmd)
 	}
 
 	return u.s.Do(func(s *StateData) error {
+		origin := collection.Origin{Kind: collection.OriginSearch, Name: s.QueryOfResult}
 		songs, err := u.fromResults(ints, s)
 		if err != nil {
 			var can *canError
@@ -1169,22 +3407,41 @@ func (u *UI) queue(cmd string, arg ui.Arg, ix int) error {
 				return err
 			}
 			songs, err = u.fromSongs(ints, s)
+			origin = s.origin()
 		}
 		if err != nil {
 			return err
 		}
 
-		for _, s := range songs {
-			u.c.QueueSong(ix, s)
-			if ix >= 0 {
-				ix++
-			}
-		}
-
+		u.c.QueueSongsFrom(ix, songs, origin, nil)
 		return nil
 	})
 }
 
+// origin returns the queue origin of songs picked from the current view.
+func (s *StateData) origin() collection.Origin {
+	switch s.view {
+	case ui.ViewPlaylist:
+		return collection.Origin{Kind: collection.OriginPlaylist, Name: s.Playlist}
+	case ui.ViewSearchOwn:
+		return collection.Origin{Kind: collection.OriginSearch, Name: s.QueryOfOwnResult}
+	}
+	return collection.Origin{}
+}
+
+func (u *UI) handleUnqueue(cmd ui.Command) error {
+	name := cmd.Args().String()
+	if !u.c.Exists(name) {
+		return fmt.Errorf("%s: playlist %s does not exist", cmd.Cmd(), name)
+	}
+	n := u.c.Unqueue(collection.Origin{Kind: collection.OriginPlaylist, Name: name})
+	n += u.c.Unqueue(collection.Origin{Kind: collection.OriginFill, Name: name})
+	if nf, ok := u.l.(ui.Notifier); ok {
+		nf.Notify(fmt.Sprintf("removed %d songs queued from %s", n, name))
+	}
+	return nil
+}
+
 func (u *UI) handleQueue(cmd ui.Command) error {
 	args := cmd.Args()
 	return u.queue(cmd.Cmd(), args[0], -1)
@@ -1206,6 +3463,34 @@ func (u *UI) handleQueueAfter(cmd ui.Command) error {
 	return u.queue(cmd.Cmd(), args[1], ix+1)
 }
 
+// token resolves a song token to its index in the current view.
+func (s *StateData) token(t string) (int, bool) {
+	if s.Can(CanSearchResult) {
+		for i, r := range s.Search {
+			if ui.Token(&collection.YoutubeSong{Result: r}) == t {
+				return i + 1, true
+			}
+		}
+		return 0, false
+	}
+	for i, song := range s.Songs {
+		if ui.Token(song) == t {
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
+
+// songRange parses a range of indexes and/or tokens of songs in the current
+// view.
+func (u *UI) songRange(a ui.Arg) (ints []int, ok bool) {
+	u.s.Do(func(s *StateData) error {
+		ints, ok = a.Range(s.token)
+		return nil
+	})
+	return
+}
+
 func (u *UI) fromResults(ints []int, s *StateData) ([]collection.Song, error) {
 	if !s.Can(CanSearchResult) {
 		return nil, &canError{"can only be used from a search result view"}
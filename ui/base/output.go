@@ -65,7 +65,7 @@ func (s *SimpleOutput) flush() {
 	fmt.Fprintln(s.w, s.title)
 	switch s.mode {
 	case modeSongs:
-		f := "%" + strconv.Itoa(len(strconv.Itoa(len(s.songs)))) + "d: %s\n"
+		f := "%" + strconv.Itoa(len(strconv.Itoa(len(s.songs)))) + "d %s: %s\n"
 		for i, song := range s.songs {
 			t := song.Title()
 			e := song.Extra()
@@ -75,7 +75,7 @@ func (s *SimpleOutput) flush() {
 			if e != "" {
 				t += e
 			}
-			fmt.Fprintf(s.w, f, i+1, t)
+			fmt.Fprintf(s.w, f, i+1, ui.Token(song), t)
 		}
 	case modeText:
 		fmt.Fprintln(s.w, s.text)
@@ -85,3 +85,7 @@ func (s *SimpleOutput) flush() {
 func (s *SimpleOutput) Err(e error) {
 	fmt.Fprintln(s.w, e.Error())
 }
+
+func (s *SimpleOutput) Notify(msg string) {
+	fmt.Fprintln(s.w, msg)
+}
@@ -0,0 +1,144 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/frizinak/libym/stats"
+	"github.com/frizinak/libym/ui"
+)
+
+// Session is what is needed to show the current view again, e.g.: after a
+// restart or when a remote client reconnects. See SetSessionFile.
+type Session struct {
+	Pane          int
+	View          ui.View
+	Title         string
+	Query         string
+	QueryOwn      string
+	Playlist      string
+	StatsPeriod   stats.Period
+	StatsPlaylist string
+	Offset        int
+}
+
+// restorable are the views that can be rendered from a Session, the others
+// show the result of a single command, e.g.: ui.ViewRename.
+var restorable = map[ui.View]struct{}{
+	ui.ViewQueue:        {},
+	ui.ViewSearch:       {},
+	ui.ViewSearchOwn:    {},
+	ui.ViewPlaylist:     {},
+	ui.ViewPlaylists:    {},
+	ui.ViewHelp:         {},
+	ui.ViewJobs:         {},
+	ui.ViewProblematics: {},
+	ui.ViewSchedules:    {},
+	ui.ViewStats:        {},
+	ui.ViewBanned:       {},
+	ui.ViewSkips:        {},
+	ui.ViewMarkers:      {},
+	ui.ViewLyrics:       {},
+}
+
+// Session returns the Session of the current view.
+func (u *UI) Session() Session {
+	var sess Session
+	u.s.Do(func(s *StateData) error {
+		sess = Session{
+			Pane:          s.pane,
+			View:          s.view,
+			Title:         s.title,
+			Query:         s.Query,
+			QueryOwn:      s.QueryOwn,
+			Playlist:      s.Playlist,
+			StatsPeriod:   s.StatsPeriod,
+			StatsPlaylist: s.StatsPlaylist,
+			Offset:        s.Offset,
+		}
+		return nil
+	})
+	return sess
+}
+
+// RestoreSession switches to the view of sess without rendering it.
+// Search results are fetched again once rendered. Views that can't be
+// restored, e.g.: a deleted playlist, fall back to the queue.
+func (u *UI) RestoreSession(sess Session) {
+	if sess.Pane < 0 || sess.Pane >= Panes {
+		sess.Pane = 0
+	}
+	_, ok := restorable[sess.View]
+	if !ok || (sess.View == ui.ViewPlaylist && !u.c.Exists(sess.Playlist)) {
+		sess = Session{Pane: sess.Pane, View: ui.ViewQueue}
+	}
+
+	u.s.Do(func(s *StateData) error {
+		s.SetPane(sess.Pane)
+		s.restore(viewState{
+			view:          sess.View,
+			title:         sess.Title,
+			query:         sess.Query,
+			queryOwn:      sess.QueryOwn,
+			playlist:      sess.Playlist,
+			statsPeriod:   sess.StatsPeriod,
+			statsPlaylist: sess.StatsPlaylist,
+			offset:        sess.Offset,
+		})
+		s.history = nil
+		return nil
+	})
+}
+
+// SetSessionFile restores the Session stored in file, if any, and stores
+// the Session there whenever a command changes it.
+func (u *UI) SetSessionFile(file string) error {
+	u.ssem.Lock()
+	defer u.ssem.Unlock()
+	u.session = file
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return fmt.Errorf("corrupt ui session %s: %w", file, err)
+	}
+	u.RestoreSession(sess)
+	u.saved = u.Session()
+	return nil
+}
+
+func (u *UI) saveSession() {
+	u.ssem.Lock()
+	defer u.ssem.Unlock()
+	if u.session == "" {
+		return
+	}
+	sess := u.Session()
+	if sess == u.saved {
+		return
+	}
+
+	data, err := json.Marshal(sess)
+	if err != nil {
+		u.l.Err(err)
+		return
+	}
+	tmp := u.session + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0o644); err != nil {
+		u.l.Err(err)
+		return
+	}
+	if err := os.Rename(tmp, u.session); err != nil {
+		u.l.Err(err)
+		return
+	}
+	u.saved = sess
+}
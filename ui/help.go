@@ -0,0 +1,151 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ArgHelp describes a single argument of a command.
+type ArgHelp struct {
+	// Name is shown in the usage, e.g.: "pct" in "scrub <pct>".
+	Name string
+	Help string
+	// Optional arguments are shown as "[name]".
+	Optional bool
+}
+
+func (a ArgHelp) String() string {
+	if a.Optional {
+		return fmt.Sprintf("[%s]", a.Name)
+	}
+	return fmt.Sprintf("<%s>", a.Name)
+}
+
+// Example is an example input of a command.
+type Example struct {
+	Input string
+	// Result explains what Input does, if it is not obvious.
+	Result string
+}
+
+func newExample(s string) Example {
+	in, res := strings.TrimSpace(s), ""
+	if ix := strings.Index(s, "=>"); ix != -1 {
+		in, res = strings.TrimSpace(s[:ix]), strings.TrimSpace(s[ix+2:])
+	}
+	return Example{Input: in, Result: res}
+}
+
+// splitHelp splits the free-form help lines of Alias in notes and
+// examples.
+func splitHelp(help []string) (notes []string, examples []Example) {
+	for _, h := range help {
+		h = strings.TrimSpace(h)
+		if strings.HasPrefix(h, "or:") {
+			examples = append(examples, newExample(h[3:]))
+			continue
+		}
+		ix := strings.Index(h, "e.g.:")
+		if ix == -1 {
+			notes = append(notes, h)
+			continue
+		}
+		if ex := strings.TrimSpace(h[ix+5:]); ex != "" {
+			examples = append(examples, newExample(ex))
+		}
+		if n := strings.TrimRight(strings.TrimSpace(h[:ix]), ","); n != "" {
+			notes = append(notes, n)
+		}
+	}
+	return
+}
+
+// ArgsUsage returns the arguments part of Usage, e.g.: "<pct>", or
+// "arg1 arg2" and "...args" if the arguments were not described.
+func (h HelpEntry) ArgsUsage() string {
+	if len(h.ArgHelp) != 0 {
+		l := make([]string, len(h.ArgHelp))
+		for i, a := range h.ArgHelp {
+			l[i] = a.String()
+		}
+		return strings.Join(l, " ")
+	}
+	if h.Args == Varadic {
+		return "...args"
+	}
+	l := make([]string, int(h.Args))
+	for i := range l {
+		l[i] = fmt.Sprintf("arg%d", i+1)
+	}
+	return strings.Join(l, " ")
+}
+
+// Usage returns the synopsis of the entry using its first alias,
+// e.g.: "scrub <pct>".
+func (h HelpEntry) Usage() string {
+	var cmd string
+	if len(h.Cmds) != 0 {
+		cmd = h.Cmds[0]
+	}
+	if args := h.ArgsUsage(); args != "" {
+		return cmd + " " + args
+	}
+	return cmd
+}
+
+// Lookup returns the entries that have cmd as one of their aliases.
+func (h Help) Lookup(cmd string) Help {
+	l := make(Help, 0, 1)
+	for _, e := range h {
+		for _, c := range e.Cmds {
+			if c == cmd {
+				l = append(l, e)
+				break
+			}
+		}
+	}
+	return l
+}
+
+// Type returns the entries of the given command type.
+func (h Help) Type(t CommandType) Help {
+	l := make(Help, 0, 1)
+	for _, e := range h {
+		if e.Type == t {
+			l = append(l, e)
+		}
+	}
+	return l
+}
+
+// Describe sets the argument descriptions of the entry added by Alias for
+// the given type and amount of arguments.
+func (c *CommandParser) Describe(t CommandType, a ArgAmount, args ...ArgHelp) {
+	for i := range c.help {
+		if c.help[i].Type == t && c.help[i].Args == a {
+			c.help[i].ArgHelp = args
+			return
+		}
+	}
+	panic(fmt.Sprintf("no alias for %s:%d", text(t), a))
+}
+
+// HelpFor returns the entry that (partial) input would run, e.g.: to show
+// a usage popup while typing. Of chained commands only the last one is
+// considered.
+func (c *CommandParser) HelpFor(input string) (HelpEntry, bool) {
+	cmds := c.Parse(input)
+	if len(cmds) == 0 {
+		return HelpEntry{}, false
+	}
+	cmd := cmds[len(cmds)-1]
+	if cmd.Type() == CmdNone {
+		return HelpEntry{}, false
+	}
+	for _, e := range c.help.Lookup(cmd.Cmd()) {
+		if e.Type == cmd.Type() && (e.Args == cmd.ArgAmount() || e.Args == Varadic) {
+			return e, true
+		}
+	}
+	return HelpEntry{}, false
+}
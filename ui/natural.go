@@ -0,0 +1,126 @@
+package ui
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NewCommand creates a command of the given type without going through a
+// Parser, e.g.: for input that is not typed by a user.
+// Cmd() returns the command's help text.
+func NewCommand(t CommandType, args ...string) Command {
+	a := make(Args, len(args))
+	for i, arg := range args {
+		a[i] = Arg(arg)
+	}
+	return Command{t: t, a: a, aAmount: ArgAmount(len(a)), cmd: text(t)}
+}
+
+// Intent maps free-text phrases, e.g.: from speech recognition, to a
+// command.
+type Intent struct {
+	// Phrases are matched against the normalized input: lower case without
+	// punctuation or filler words like "please".
+	// A phrase ending in " *" matches any input starting with the phrase,
+	// the rest of the input is passed to Command.
+	Phrases []string
+	// Command returns the command for the matched input, rest is empty
+	// for phrases without a wildcard.
+	Command func(rest string) (Command, bool)
+}
+
+// Static returns an Intent.Command that always results in the given
+// command.
+func Static(t CommandType, args ...string) func(string) (Command, bool) {
+	return func(string) (Command, bool) { return NewCommand(t, args...), true }
+}
+
+// Rest returns an Intent.Command that passes the words matched by the
+// wildcard as arguments to a command of the given type.
+func Rest(t CommandType) func(string) (Command, bool) {
+	return func(rest string) (Command, bool) {
+		if rest == "" {
+			return Command{}, false
+		}
+		return NewCommand(t, strings.Fields(rest)...), true
+	}
+}
+
+// DefaultIntents cover common playback phrases.
+var DefaultIntents = []Intent{
+	{[]string{"skip", "next", "next song", "skip song", "skip this", "skip this song"}, Static(CmdNext)},
+	{[]string{"previous", "previous song", "last song", "go back", "play that again"}, Static(CmdPrev)},
+	{[]string{"pause", "stop", "stop playing", "pause the music", "stop the music"}, Static(CmdPause)},
+	{[]string{"play", "resume", "continue", "unpause", "start playing", "keep playing"}, Static(CmdPlay)},
+	{[]string{"volume up", "louder", "turn it up", "turn up the volume"}, Static(CmdVolume, "10")},
+	{[]string{"volume down", "quieter", "softer", "turn it down", "turn down the volume"}, Static(CmdVolume, "-10")},
+	{[]string{"shuffle", "shuffle queue", "shuffle the queue"}, Static(CmdQueueShuffle)},
+	{[]string{"queue", "show queue", "show the queue", "whats next"}, Static(CmdViewQueue)},
+	{[]string{"i like this", "i love this", "like this song", "love this song", "favorite this"}, Static(CmdFav)},
+	{[]string{"search for *", "search *", "find *", "look for *"}, Rest(CmdSearchOwn)},
+}
+
+var fillers = []string{"please", "hey", "ok", "okay", "can you", "could you", "would you", "i want to", "lets"}
+
+// NormalizePhrase lower cases s and strips punctuation and filler words.
+func NormalizePhrase(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\'':
+			return -1
+		case unicode.IsLetter(r), unicode.IsDigit(r):
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, s)
+	s = " " + strings.Join(strings.Fields(s), " ") + " "
+
+	for changed := true; changed; {
+		changed = false
+		for _, f := range fillers {
+			if strings.HasPrefix(s, " "+f+" ") {
+				s, changed = s[len(f)+1:], true
+			}
+			if strings.HasSuffix(s, " "+f+" ") {
+				s, changed = s[:len(s)-len(f)-1], true
+			}
+		}
+	}
+
+	return strings.TrimSpace(s)
+}
+
+// MatchIntent returns the command for the first intent matching input.
+// Exact phrases take precedence over wildcards. Cmd() of the resulting
+// command returns the matched phrase.
+func MatchIntent(intents []Intent, input string) (Command, bool) {
+	input = NormalizePhrase(input)
+	if input == "" {
+		return Command{}, false
+	}
+
+	for _, i := range intents {
+		for _, p := range i.Phrases {
+			if p == input {
+				cmd, ok := i.Command("")
+				cmd.cmd = p
+				return cmd, ok
+			}
+		}
+	}
+
+	for _, i := range intents {
+		for _, p := range i.Phrases {
+			prefix := strings.TrimSuffix(p, "*")
+			if prefix == p || !strings.HasPrefix(input, prefix) {
+				continue
+			}
+			if cmd, ok := i.Command(strings.TrimSpace(input[len(prefix):])); ok {
+				cmd.cmd = strings.TrimSpace(prefix)
+				return cmd, true
+			}
+		}
+	}
+
+	return Command{}, false
+}
@@ -0,0 +1,110 @@
+package ui
+
+import (
+	"errors"
+	"sync"
+)
+
+// Severity is how important a reported message is.
+type Severity byte
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+var severityNames = map[Severity]string{
+	SeverityInfo:    "INFO",
+	SeverityWarning: "WARN",
+	SeverityError:   "ERR",
+}
+
+func (s Severity) String() string { return severityNames[s] }
+
+type severityError struct {
+	sev Severity
+	err error
+}
+
+func (s *severityError) Error() string { return s.err.Error() }
+func (s *severityError) Unwrap() error { return s.err }
+
+// WithSeverity annotates err with a Severity other than SeverityError,
+// e.g.: WithSeverity(SeverityWarning, err). Returns nil if err is nil.
+func WithSeverity(s Severity, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &severityError{s, err}
+}
+
+// SeverityOf returns the Severity err was annotated with by WithSeverity,
+// SeverityError otherwise.
+func SeverityOf(err error) Severity {
+	var s *severityError
+	if errors.As(err, &s) {
+		return s.sev
+	}
+	return SeverityError
+}
+
+// MultiReporter passes errors and notifications on to multiple reporters,
+// e.g.: the ui, a log file and a desktop notification hook.
+type MultiReporter struct {
+	sem   sync.RWMutex
+	sinks []reporterSink
+}
+
+type reporterSink struct {
+	r   ErrorReporter
+	min Severity
+}
+
+// NewMultiReporter creates a MultiReporter, see Add.
+func NewMultiReporter() *MultiReporter { return &MultiReporter{} }
+
+// Add adds r to receive all messages of at least severity min.
+// Notifications are SeverityInfo and only passed on if r implements
+// Notifier.
+func (m *MultiReporter) Add(r ErrorReporter, min Severity) *MultiReporter {
+	m.sem.Lock()
+	m.sinks = append(m.sinks, reporterSink{r, min})
+	m.sem.Unlock()
+	return m
+}
+
+// Reset removes all reporters.
+func (m *MultiReporter) Reset() {
+	m.sem.Lock()
+	m.sinks = nil
+	m.sem.Unlock()
+}
+
+func (m *MultiReporter) Err(err error) {
+	sev := SeverityOf(err)
+	m.sem.RLock()
+	defer m.sem.RUnlock()
+	for _, s := range m.sinks {
+		if sev >= s.min {
+			s.r.Err(err)
+		}
+	}
+}
+
+func (m *MultiReporter) Notify(msg string) {
+	m.sem.RLock()
+	defer m.sem.RUnlock()
+	for _, s := range m.sinks {
+		if n, ok := s.r.(Notifier); ok && s.min <= SeverityInfo {
+			n.Notify(msg)
+		}
+	}
+}
+
+// HookReporter is an ErrorReporter and Notifier that calls itself with
+// every message.
+type HookReporter func(sev Severity, msg string)
+
+func (h HookReporter) Err(err error)     { h(SeverityOf(err), err.Error()) }
+func (h HookReporter) Notify(msg string) { h(SeverityInfo, msg) }
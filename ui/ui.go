@@ -2,12 +2,18 @@ package ui
 
 import (
 	"fmt"
+	"hash/fnv"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 type UI interface {
 	Input(string)
+	// InputNatural handles free-text phrases, e.g.: "skip" or "volume up".
+	InputNatural(string)
+	// Snapshot returns the current view without flushing it to an Output.
+	Snapshot() ViewState
 	Refresh()
 }
 
@@ -51,6 +57,18 @@ const (
 	ViewExternal
 	ViewRename
 	ViewProblematics
+	ViewJobLog
+	ViewSchedules
+	ViewStats
+	ViewBanned
+	ViewSkips
+	ViewMarkers
+	ViewSubsonic
+	ViewShare
+	ViewDuplicates
+	ViewLyrics
+	ViewCheck
+	ViewImport
 )
 
 type AtomicOutput interface {
@@ -60,6 +78,28 @@ type AtomicOutput interface {
 	SetText(string)
 }
 
+// Scroller can optionally be implemented by an AtomicOutput to be told
+// the scroll offset last reported for the view with the scroll command,
+// e.g.: to land on the same page after a restart.
+type Scroller interface {
+	SetOffset(int)
+}
+
+// ViewState is a rendered view, it implements AtomicOutput and Scroller.
+type ViewState struct {
+	View   View
+	Title  string
+	Songs  []Song
+	Text   string
+	Offset int
+}
+
+func (v *ViewState) SetView(view View)   { v.View = view }
+func (v *ViewState) SetTitle(t string)   { v.Title = t }
+func (v *ViewState) SetSongs(l []Song)   { v.Songs = l }
+func (v *ViewState) SetText(text string) { v.Text = text }
+func (v *ViewState) SetOffset(n int)     { v.Offset = n }
+
 type Output interface {
 	AtomicFlush(func(AtomicOutput))
 }
@@ -77,7 +117,14 @@ type LogErrorReporter struct {
 	Printlner
 }
 
-func (l *LogErrorReporter) Err(err error) { l.Println("ERR", err) }
+// Notifier can optionally be implemented by an ErrorReporter to also
+// receive informational messages, e.g.: a background job finishing.
+type Notifier interface {
+	Notify(string)
+}
+
+func (l *LogErrorReporter) Err(err error)     { l.Println(SeverityOf(err), err) }
+func (l *LogErrorReporter) Notify(msg string) { l.Println("INFO", msg) }
 
 func NewLogErrorReporter(l Printlner) ErrorReporter {
 	return &LogErrorReporter{l}
@@ -116,8 +163,81 @@ const (
 	CmdMeta
 	CmdConfirm
 	CmdProblematics
+	CmdJobLog
+	CmdSchedules
+	CmdSchedule
+	CmdVerify
+	CmdRefreshTitles
+	CmdGC
+	CmdDownload
+	CmdStats
+	CmdForward
+	CmdRewind
+	CmdBan
+	CmdUnban
+	CmdBanned
+	CmdFill
+	CmdSkips
+	CmdPlaylistPrefs
+	CmdBack
+	CmdPane
+	CmdCopy
+	CmdFav
+	CmdUnfav
+	CmdFavs
+	CmdMark
+	CmdUnmark
+	CmdMarkers
+	CmdAlt
+	CmdUnalt
+	CmdExport
+	CmdSubsonic
+	CmdSubPlaylists
+	CmdShare
+	CmdCleanupTitles
+	CmdDuplicates
+	CmdLyrics
+	CmdPin
+	CmdUnpin
+	CmdDaily
+	CmdCheck
+	CmdDead
+	CmdScrub
+	CmdUnqueue
+	CmdImport
+	CmdArchive
+	CmdReload
+	CmdOnEnd
+	CmdRetitle
+	CmdScroll
 )
 
+// CmdCustom is the first CommandType handed out by NewCommandType.
+const CmdCustom CommandType = 1 << 16
+
+var (
+	textsSem   sync.RWMutex
+	nextCustom = CmdCustom
+)
+
+// NewCommandType allocates a new CommandType for an app-specific command,
+// help is its description in the help view.
+// Register aliases for it with CommandParser.Alias and a handler with the UI.
+func NewCommandType(help string) CommandType {
+	textsSem.Lock()
+	defer textsSem.Unlock()
+	t := nextCustom
+	nextCustom++
+	texts[t] = help
+	return t
+}
+
+func text(t CommandType) string {
+	textsSem.RLock()
+	defer textsSem.RUnlock()
+	return texts[t]
+}
+
 type ArgAmount byte
 
 const (
@@ -134,7 +254,7 @@ var texts = map[CommandType]string{
 	CmdPauseToggle:    "toggle pause",
 	CmdNext:           "next song in queue",
 	CmdPrev:           "previous song in queue",
-	CmdSetSongIndex:   "play a specific song by index in the queue",
+	CmdSetSongIndex:   "play a specific song by index in the queue or seek to a marker",
 	CmdMove:           "move a song in a playlist",
 	CmdSearch:         "search for a song",
 	CmdPlaylistAdd:    "add a new playlist",
@@ -149,13 +269,60 @@ var texts = map[CommandType]string{
 	CmdViewQueue:      "switch to queue view",
 	CmdViewPlaylist:   "switch to a playlist view",
 	CmdViewPlaylists:  "list all playlists",
-	CmdSearchOwn:      "search for songs across playlists",
+	CmdSearchOwn:      "search for songs across playlists and the queue",
 	CmdScrape:         "scrape a url and add all songs to the given playlist",
 	CmdJobs:           "list jobs in progress",
 	CmdCancelJob:      "cancel a job",
 	CmdMeta:           "update title using acoustid and musicbrainz",
 	CmdConfirm:        "confirm an operation",
 	CmdProblematics:   "view song problems",
+	CmdJobLog:         "show the log of a running or finished job",
+	CmdSchedules:      "list scheduled commands",
+	CmdSchedule:       "add, delete or run a scheduled command",
+	CmdVerify:         "download all songs that are not available locally",
+	CmdRefreshTitles:  "look up titles of songs without one",
+	CmdGC:             "delete downloads no longer referenced by a playlist or the queue",
+	CmdDownload:       "(re)start downloading songs, cancel a download from the jobs view",
+	CmdStats:          "show library and listening statistics",
+	CmdForward:        "seek forward by the seek step or n steps",
+	CmdRewind:         "seek backward by the seek step or n steps",
+	CmdBan:            "never queue songs automatically",
+	CmdUnban:          "allow banned songs to be queued automatically again",
+	CmdBanned:         "list banned songs",
+	CmdFill:           "queue random songs from a playlist for roughly the given time",
+	CmdSkips:          "list often skipped songs that might be removed from playlists",
+	CmdPlaylistPrefs:  "set whether a playlist is shuffled and how many times it is repeated when queued",
+	CmdBack:           "go back to the previous view",
+	CmdPane:           "switch to the other or a specific pane, each pane keeps its own view",
+	CmdCopy:           "add songs to the playlist shown in the other pane",
+	CmdFav:            "add the current song or songs in the current view to favorites",
+	CmdUnfav:          "remove the current song or songs in the current view from favorites",
+	CmdFavs:           "switch to the favorites playlist",
+	CmdMark:           "save a named position in the current song, seek to it with goto <name>",
+	CmdUnmark:         "delete a named position in the current song",
+	CmdMarkers:        "list the named positions in the current song",
+	CmdAlt:            "add another youtube upload that is tried when a song fails to download or play",
+	CmdUnalt:          "remove an alternative source of a song",
+	CmdExport:         "write tagged downloads and m3u playlists to a directory, e.g.: for beets or navidrome",
+	CmdSubsonic:       "search the subsonic server",
+	CmdSubPlaylists:   "list the playlists on the subsonic server or show the songs in one",
+	CmdShare:          "list the titles and urls of the queue or a playlist, optionally as markdown",
+	CmdCleanupTitles:  "strip noise like (Official Video) from all titles",
+	CmdDuplicates:     "resolve possible duplicates: add anyway, skip or replace the existing song",
+	CmdLyrics:         "show, fetch, load (from an .lrc file) or clear the lyrics of the current song",
+	CmdPin:            "always keep the current or given songs downloaded",
+	CmdUnpin:          "stop keeping the current or given songs downloaded",
+	CmdDaily:          "regenerate the daily mix playlist from under-played favorites and recent additions",
+	CmdCheck:          "check whether all songs in a playlist can still be played",
+	CmdDead:           "remove dead songs found by check or replace them with a new search result",
+	CmdScrub:          "seek to a percentage of the current song",
+	CmdUnqueue:        "remove all songs queued from a playlist",
+	CmdImport:         "add all songs of the current view to a playlist using a merge strategy, optionally as a dry run",
+	CmdArchive:        "move songs that have not been played for a while to the archive playlist",
+	CmdReload:         "re-read the configuration and apply what can be changed without restarting",
+	CmdOnEnd:          "set what happens at the end of the queue",
+	CmdRetitle:        "rename titles in a playlist or the whole library using a regex substitution, optionally as a dry run",
+	CmdScroll:         "remember the scroll offset of the current view, e.g.: to land on the same page after a restart",
 }
 
 type Args []Arg
@@ -188,13 +355,49 @@ func (a Args) Ints() ([]int, bool) {
 
 type Arg string
 
-func (a Arg) IntRange() ([]int, bool) {
+// TokenLength is the length of a song token.
+const TokenLength = 4
+
+// Token returns a short id of a song that, unlike its index, does not change
+// when a list is reordered. Views accept tokens wherever a range of songs is
+// expected, see Arg.Range.
+func Token(s BaseSong) string {
+	h := fnv.New32a()
+	h.Write([]byte(s.NS()))
+	h.Write([]byte{0})
+	h.Write([]byte(s.ID()))
+	n := h.Sum32()
+	t := make([]byte, TokenLength)
+	for i := range t {
+		t[i] = 'a' + byte(n%26)
+		n /= 26
+	}
+	return string(t)
+}
+
+func (a Arg) IntRange() ([]int, bool) { return a.Range(nil) }
+
+// Range is like IntRange but also accepts tokens, which are resolved to an
+// index using the given func, e.g.: "abcd,5-7" or "abcd-efgh".
+func (a Arg) Range(token func(string) (int, bool)) ([]int, bool) {
+	atoi := func(str string) (int, bool) {
+		str = strings.TrimSpace(str)
+		v, err := strconv.Atoi(str)
+		if err == nil {
+			return v, true
+		}
+		if token == nil || len(str) != TokenLength {
+			return 0, false
+		}
+		return token(str)
+	}
+
 	comma := strings.Split(string(a), ",")
 	r := make([]int, 0, len(comma))
 	for _, n := range comma {
 		dash := strings.SplitN(n, "-", 2)
-		v, err := strconv.Atoi(strings.TrimSpace(dash[0]))
-		if err != nil {
+		v, ok := atoi(dash[0])
+		if !ok {
 			return r, false
 		}
 		if len(dash) != 2 {
@@ -203,8 +406,8 @@ func (a Arg) IntRange() ([]int, bool) {
 		}
 
 		if len(dash) == 2 {
-			v2, err := strconv.Atoi(strings.TrimSpace(dash[1]))
-			if err != nil {
+			v2, ok := atoi(dash[1])
+			if !ok {
 				return r, false
 			}
 			if v2 < v {
@@ -226,17 +429,49 @@ func (a Arg) Int() (int, bool) {
 
 func (a Arg) String() string { return string(a) }
 
+// Chain is how a command is chained to the previous one.
+type Chain byte
+
+const (
+	// ChainAlways runs the command regardless of the previous one: ';'
+	ChainAlways Chain = iota
+	// ChainAnd runs the command only if the previous one succeeded: '&&'
+	ChainAnd
+	// ChainOr runs the command only if the previous one failed: '||'
+	ChainOr
+)
+
 type Command struct {
 	t       CommandType
 	a       Args
 	aAmount ArgAmount
 	cmd     string
+	chain   Chain
 }
 
 func (c Command) Type() CommandType    { return c.t }
 func (c Command) Args() Args           { return c.a }
 func (c Command) ArgAmount() ArgAmount { return c.aAmount }
 func (c Command) Cmd() string          { return c.cmd }
+func (c Command) Chain() Chain         { return c.chain }
+
+// Runs reports whether the command should run given the error of the
+// last command that ran, nil if none did. Like in a shell, skipped commands
+// do not change the outcome, i.e.: in 'a && b || c', c runs if a fails.
+func (c Command) Runs(prev error) bool {
+	switch c.chain {
+	case ChainAnd:
+		return prev == nil
+	case ChainOr:
+		return prev != nil
+	}
+	return true
+}
+
+// Middleware wraps the handling of a command. It can inspect or replace cmd
+// before passing it on to next, inspect the resulting error or not call next
+// at all to reject the command.
+type Middleware func(cmd Command, next func(Command) error) error
 
 type Help []HelpEntry
 
@@ -244,7 +479,17 @@ type HelpEntry struct {
 	Type CommandType
 	Args ArgAmount
 	Cmds []string
+	// Help is the Summary followed by the free-form lines passed to Alias.
 	Help []string
+
+	// Summary describes what the command does.
+	Summary string
+	// Examples are taken from the "e.g.: ..." and "or: ..." parts of Help.
+	Examples []Example
+	// Notes are the remaining parts of Help.
+	Notes []string
+	// ArgHelp describes the arguments, see CommandParser.Describe.
+	ArgHelp []ArgHelp
 }
 
 type CommandParser struct {
@@ -259,16 +504,49 @@ func NewParser() *CommandParser {
 	}
 }
 
+// Parse parses one or more commands separated by ';', '&&' or '||',
+// see Command.Chain. '&&' and '||' only separate commands when surrounded
+// by whitespace, a leading backslash escapes them,
+// e.g.: "search rock \&& roll".
 func (c *CommandParser) Parse(input string) []Command {
-	n := strings.Split(input, ";")
-	cmds := make([]Command, 0, len(n))
-	for _, str := range n {
-		str = strings.TrimSpace(str)
-		if str == "" {
-			continue
+	cmds := make([]Command, 0, 1)
+	chain := ChainAlways
+	var cur strings.Builder
+	add := func(next Chain) {
+		str := strings.TrimSpace(cur.String())
+		cur.Reset()
+		if str != "" {
+			cmd := c.parse(str)
+			cmd.chain = chain
+			cmds = append(cmds, cmd)
+		}
+		chain = next
+	}
+	op := func(i int, op string) bool {
+		end := i + len(op)
+		return strings.HasPrefix(input[i:], op) &&
+			(i == 0 || input[i-1] == ' ') &&
+			(end == len(input) || input[end] == ' ')
+	}
+
+	for i := 0; i < len(input); i++ {
+		switch {
+		case input[i] == ';':
+			add(ChainAlways)
+		case op(i, "&&"):
+			add(ChainAnd)
+			i++
+		case op(i, "||"):
+			add(ChainOr)
+			i++
+		case op(i, `\&&`), op(i, `\||`):
+			cur.WriteString(input[i+1 : i+3])
+			i += 2
+		default:
+			cur.WriteByte(input[i])
 		}
-		cmds = append(cmds, c.parse(str))
 	}
+	add(ChainAlways)
 
 	return cmds
 }
@@ -285,16 +563,47 @@ func (c *CommandParser) Alias(t CommandType, a ArgAmount, help []string, command
 	}
 
 	h := make([]string, 1, 1+len(help))
-	h[0] = texts[t]
+	h[0] = text(t)
 	h = append(h, help...)
 
-	c.help = append(c.help, HelpEntry{t, a, command, h})
+	entry := HelpEntry{Type: t, Args: a, Cmds: command, Help: h, Summary: h[0]}
+	entry.Notes, entry.Examples = splitHelp(help)
+	c.help = append(c.help, entry)
 }
 
 func (c *CommandParser) Help() Help {
 	return c.help
 }
 
+// Without returns a copy of this parser that does not know about the given
+// command types, their aliases parse as unknown commands.
+func (c *CommandParser) Without(types ...CommandType) *CommandParser {
+	deny := make(map[CommandType]struct{}, len(types))
+	for _, t := range types {
+		deny[t] = struct{}{}
+	}
+
+	n := NewParser()
+	for cmd, list := range c.alias {
+		for a, t := range list {
+			if _, ok := deny[t]; ok {
+				continue
+			}
+			if _, ok := n.alias[cmd]; !ok {
+				n.alias[cmd] = make(map[ArgAmount]CommandType)
+			}
+			n.alias[cmd][a] = t
+		}
+	}
+	for _, h := range c.help {
+		if _, ok := deny[h.Type]; !ok {
+			n.help = append(n.help, h)
+		}
+	}
+
+	return n
+}
+
 func (c *CommandParser) parse(input string) (cmd Command) {
 	t := c.tokens(input)
 	if len(t) == 0 {
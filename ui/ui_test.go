@@ -0,0 +1,47 @@
+package ui_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/frizinak/libym/ui"
+)
+
+func TestParseChain(t *testing.T) {
+	p := ui.NewParser()
+	p.Alias(ui.CmdSearch, ui.Varadic, nil, "search")
+	p.Alias(ui.CmdRetitle, ui.Varadic, nil, "retitle")
+	p.Alias(ui.CmdPlay, ui.Zero, nil, "play")
+
+	type cmd struct {
+		args  []string
+		chain ui.Chain
+	}
+	tests := map[string][]cmd{
+		"search rock&&roll":             {{[]string{"rock&&roll"}, ui.ChainAlways}},
+		"retitle s/a||b// mix":          {{[]string{"s/a||b//", "mix"}, ui.ChainAlways}},
+		"search rock \\&& roll":         {{[]string{"rock", "&&", "roll"}, ui.ChainAlways}},
+		"search a \\|| b":               {{[]string{"a", "||", "b"}, ui.ChainAlways}},
+		"search rock && play":           {{[]string{"rock"}, ui.ChainAlways}, {[]string{}, ui.ChainAnd}},
+		"search rock || play; search x": {{[]string{"rock"}, ui.ChainAlways}, {[]string{}, ui.ChainOr}, {[]string{"x"}, ui.ChainAlways}},
+	}
+
+	for input, exp := range tests {
+		l := p.Parse(input)
+		if len(l) != len(exp) {
+			t.Errorf("%s: expected %d commands, got %d", input, len(exp), len(l))
+			continue
+		}
+		for i, c := range l {
+			if c.Type() == ui.CmdNone {
+				t.Errorf("%s: unknown command %d", input, i)
+			}
+			if args := c.Args().Strings(); !reflect.DeepEqual(args, exp[i].args) {
+				t.Errorf("%s: expected args %q, got %q", input, exp[i].args, args)
+			}
+			if c.Chain() != exp[i].chain {
+				t.Errorf("%s: expected chain %d, got %d", input, exp[i].chain, c.Chain())
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package youtube
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FixtureKind identifies the type of page a Fixture was recorded from.
+type FixtureKind string
+
+const (
+	FixtureSearch FixtureKind = "search"
+	FixtureWatch  FixtureKind = "watch"
+)
+
+const fixtureExt = ".html"
+
+var fixtures struct {
+	sync.RWMutex
+	dir string
+}
+
+// SetFixtureDir enables recording of raw youtube responses that could not
+// be parsed to the given directory. An empty dir disables recording.
+func SetFixtureDir(dir string) {
+	fixtures.Lock()
+	fixtures.dir = dir
+	fixtures.Unlock()
+}
+
+func fixtureDir() string {
+	fixtures.RLock()
+	d := fixtures.dir
+	fixtures.RUnlock()
+	return d
+}
+
+// Fixture is a recorded youtube response.
+type Fixture struct {
+	Kind FixtureKind
+	Path string
+}
+
+// Fixtures lists all recorded fixtures in dir, oldest first.
+func Fixtures(dir string) ([]Fixture, error) {
+	g, err := filepath.Glob(filepath.Join(dir, "*"+fixtureExt))
+	if err != nil {
+		return nil, err
+	}
+
+	l := make([]Fixture, 0, len(g))
+	stamps := make(map[string]int64, len(g))
+	for _, p := range g {
+		parts := strings.SplitN(strings.TrimSuffix(filepath.Base(p), fixtureExt), "-", 2)
+		switch FixtureKind(parts[0]) {
+		case FixtureSearch, FixtureWatch:
+		default:
+			continue
+		}
+		if len(parts) == 2 {
+			stamps[p], _ = strconv.ParseInt(parts[1], 36, 64)
+		}
+		l = append(l, Fixture{FixtureKind(parts[0]), p})
+	}
+
+	// file names start with the kind, sort by the timestamp that follows.
+	sort.SliceStable(l, func(i, j int) bool {
+		return stamps[l[i].Path] < stamps[l[j].Path]
+	})
+
+	return l, nil
+}
+
+// Open opens the raw response.
+func (f Fixture) Open() (io.ReadCloser, error) { return os.Open(f.Path) }
+
+// Search replays a FixtureSearch through the search results parser.
+func (f Fixture) Search() ([]*Result, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ReplaySearch(r)
+}
+
+// Title replays a FixtureWatch through the page title parser.
+func (f Fixture) Title() (string, error) {
+	r, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	return ReplayTitle(r)
+}
+
+// ReplaySearch parses a raw youtube search results page.
+func ReplaySearch(r io.Reader) ([]*Result, error) {
+	results, _, err := parseSearch(r)
+	return results, err
+}
+
+// ReplayTitle parses the title of a raw youtube watch page.
+func ReplayTitle(r io.Reader) (string, error) { return pageTitle(r) }
+
+type recorder struct {
+	io.Reader
+	src io.Reader
+	buf *bytes.Buffer
+}
+
+// record wraps r so its contents can be saved later using save.
+// Is a noop if no fixture dir was set.
+func record(r io.Reader) *recorder {
+	if fixtureDir() == "" {
+		return &recorder{Reader: r}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	return &recorder{Reader: io.TeeReader(r, buf), src: r, buf: buf}
+}
+
+func (r *recorder) save(kind FixtureKind) error {
+	dir := fixtureDir()
+	if r.buf == nil || dir == "" {
+		return nil
+	}
+
+	if _, err := io.Copy(r.buf, r.src); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	stamp := strconv.FormatInt(time.Now().UnixNano(), 36)
+	file := filepath.Join(dir, fmt.Sprintf("%s-%s%s", kind, stamp, fixtureExt))
+	return ioutil.WriteFile(file, r.buf.Bytes(), 0o644)
+}
@@ -0,0 +1,95 @@
+package youtube
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFixtures(t *testing.T) {
+	dir := t.TempDir()
+	SetFixtureDir(dir)
+	defer SetFixtureDir("")
+
+	const page = `<html><head></head><body>nothing here</body></html>`
+	rec := record(strings.NewReader(page))
+	if _, err := pageTitle(rec); err == nil {
+		t.Fatal("expected a parse error")
+	}
+	if err := rec.save(FixtureWatch); err != nil {
+		t.Fatal(err)
+	}
+
+	const search = `<script>var ytInitialData = {"contents":[{"videoRenderer":{"videoId":"abc","title":{"runs":[{"text":"a title"}]}}}]};</script>`
+	rec = record(strings.NewReader(search))
+	if err := rec.save(FixtureSearch); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Fixtures(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 2 {
+		t.Fatalf("expected 2 fixtures, got %d", len(l))
+	}
+
+	for _, f := range l {
+		switch f.Kind {
+		case FixtureWatch:
+			if _, err := f.Title(); err == nil {
+				t.Error("expected replayed watch page to fail")
+			}
+		case FixtureSearch:
+			r, err := f.Search()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(r) != 1 || r[0].ID() != "abc" || r[0].Title() != "a title" {
+				t.Errorf("unexpected replayed search result %+v", r)
+			}
+		}
+	}
+}
+
+func TestFixturesOrder(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	files := []struct {
+		kind FixtureKind
+		at   time.Time
+	}{
+		{FixtureSearch, now},
+		{FixtureWatch, now.Add(-time.Hour)},
+		{FixtureSearch, now.Add(-2 * time.Hour)},
+		{FixtureWatch, now.Add(time.Hour)},
+	}
+	for _, f := range files {
+		stamp := strconv.FormatInt(f.at.UnixNano(), 36)
+		file := filepath.Join(dir, fmt.Sprintf("%s-%s%s", f.kind, stamp, fixtureExt))
+		if err := ioutil.WriteFile(file, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l, err := Fixtures(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []FixtureKind{FixtureSearch, FixtureWatch, FixtureSearch, FixtureWatch}
+	if len(l) != len(exp) {
+		t.Fatalf("expected %d fixtures, got %d", len(exp), len(l))
+	}
+	for i, f := range l {
+		if f.Kind != exp[i] {
+			t.Fatalf("expected fixtures oldest first, got %+v", l)
+		}
+	}
+	if !strings.Contains(l[0].Path, strconv.FormatInt(files[2].at.UnixNano(), 36)) {
+		t.Fatalf("expected the oldest fixture first, got %s", l[0].Path)
+	}
+}
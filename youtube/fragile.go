@@ -7,9 +7,12 @@ import (
 	"html"
 	"io"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 
+	"github.com/frizinak/libym/failure"
 	"github.com/frizinak/libym/fuzzymap"
 )
 
@@ -71,12 +74,13 @@ func pageTitle(r io.Reader) (string, error) {
 	rrr := reRuneReader{maxlen: buf, r: rr, re: re}
 	s, err := rrr.String()
 	if err != nil || len(s) < 2 {
-		return "", errors.New("no title found in page")
+		return "", failure.Wrap(failure.ParserBroken, errors.New("no title found in page"))
 	}
 
 	title := strings.TrimSpace(html.UnescapeString(s[1]))
 	if len(title) < 10 && strings.Contains(title, "YouTube") {
-		return "", errors.New("invalid page")
+		// unavailable clips have a generic title
+		return "", failure.Wrap(failure.NotFound, errors.New("invalid page"))
 	}
 
 	const suff = " - YouTube"
@@ -87,6 +91,16 @@ func pageTitle(r io.Reader) (string, error) {
 	return title, nil
 }
 
+// ytInitialPrune are key prefixes in ytInitialData we never look at but
+// make up a large part of its size.
+var ytInitialPrune = []string{
+	"thumbnail",
+	"trackingParams",
+	"accessibility",
+	"loggingDirectives",
+	"frameworkUpdates",
+}
+
 func parseYTInitialData(r io.Reader) (fuzzymap.M, io.Reader, error) {
 	ytInitial := []rune("ytInitialData =")
 	ytInitialPos := 0
@@ -108,21 +122,20 @@ func parseYTInitialData(r io.Reader) (fuzzymap.M, io.Reader, error) {
 		ytInitialPos = 0
 	}
 
-	m := make(map[string]interface{})
 	dec := json.NewDecoder(rr)
-	err := dec.Decode(&m)
+	m, err := fuzzymap.Decode(dec, ytInitialPrune...)
 	nr := io.MultiReader(dec.Buffered(), r)
 	if err != nil {
 		return nil, nr, err
 	}
 
-	return fuzzymap.New(m), nr, err
+	return m, nr, err
 }
 
 func parseSearch(r io.Reader) ([]*Result, io.Reader, error) {
 	m, nr, err := parseYTInitialData(r)
 	if err != nil {
-		return nil, nr, err
+		return nil, nr, failure.Wrap(failure.ParserBroken, err)
 	}
 
 	res, err := decodeSearch(m)
@@ -131,30 +144,26 @@ func parseSearch(r io.Reader) ([]*Result, io.Reader, error) {
 
 func decodeSearch(m fuzzymap.M) ([]*Result, error) {
 	rs := make([]*Result, 0)
-	els := m.Filter("videoId")
+	els := m.Query("**.videoId")
 	for _, e := range els {
 		if e.Parent == nil {
 			continue
 		}
 
-		vid, ok := e.Value.(string)
-		if !ok || len(e.Children) != 0 {
+		vid, ok := e.AsString()
+		if !ok {
 			continue
 		}
 
-		titles := e.Parent.Children.Filter("title", "text")
+		titles := e.Parent.Query("**.title.**.text").Strings()
 		if len(titles) != 1 {
 			continue
 		}
-		title, ok := titles[0].Value.(string)
-		if !ok || len(titles[0].Children) != 0 {
-			continue
-		}
+		title := titles[0]
 
 		hasLiveBadge := false
-		badgeStyles := e.Parent.Children.Filter("badges", "style")
-		for _, bs := range badgeStyles {
-			if style, ok := bs.Value.(string); ok && strings.Contains(style, "_LIVE") {
+		for _, style := range e.Parent.Query("**.badges.**.style").Strings() {
+			if strings.Contains(style, "_LIVE") {
 				hasLiveBadge = true
 				break
 			}
@@ -164,11 +173,117 @@ func decodeSearch(m fuzzymap.M) ([]*Result, error) {
 			continue
 		}
 
-		rs = append(
-			rs,
-			NewResult(vid, title),
-		)
+		r := NewResult(vid, title)
+		r.meta = decodeMeta(e.Parent, time.Now())
+		rs = append(rs, r)
 	}
 
 	return rs, nil
 }
+
+// decodeMeta reads the metadata of a videoRenderer, fields it can't parse
+// are left empty.
+func decodeMeta(v *fuzzymap.Value, now time.Time) Meta {
+	var m Meta
+	for _, path := range []string{"ownerText.**.text", "longBylineText.**.text", "shortBylineText.**.text"} {
+		if m.Channel = v.Query(path).String(); m.Channel != "" {
+			break
+		}
+	}
+	m.Duration = parseLength(v.Query("lengthText.simpleText").String())
+	m.Views = parseViews(v.Query("viewCountText.simpleText").String())
+	m.Published = parseAge(v.Query("publishedTimeText.simpleText").String(), now)
+	return m
+}
+
+// parseLength parses texts like "3:45" or "1:02:03".
+func parseLength(s string) time.Duration {
+	if s == "" {
+		return 0
+	}
+	var d time.Duration
+	for _, p := range strings.Split(strings.TrimSpace(s), ":") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return 0
+		}
+		d = d*60 + time.Duration(n)
+	}
+	return d * time.Second
+}
+
+// parseViews parses texts like "1,234,567 views".
+func parseViews(s string) int64 {
+	var n int64
+	digits := false
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			n = n*10 + int64(r-'0')
+			digits = true
+			continue
+		}
+		if digits && r != ',' && r != '.' {
+			break
+		}
+	}
+	return n
+}
+
+var ageUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+}
+
+// parseAge approximates texts like "3 years ago" or "Streamed 2 weeks ago".
+func parseAge(s string, now time.Time) time.Time {
+	f := strings.Fields(strings.ToLower(s))
+	for i := 0; i+2 < len(f); i++ {
+		if f[i+2] != "ago" {
+			continue
+		}
+		n, err := strconv.Atoi(f[i])
+		if err != nil {
+			return time.Time{}
+		}
+		unit := strings.TrimSuffix(f[i+1], "s")
+		switch unit {
+		case "month":
+			return now.AddDate(0, -n, 0)
+		case "year":
+			return now.AddDate(-n, 0, 0)
+		}
+		if d, ok := ageUnits[unit]; ok {
+			return now.Add(-time.Duration(n) * d)
+		}
+		return time.Time{}
+	}
+	return time.Time{}
+}
+
+var youtubeDLKinds = []struct {
+	kind  failure.Kind
+	match []string
+}{
+	{failure.RateLimited, []string{"http error 429", "too many requests"}},
+	{failure.GeoBlocked, []string{"available in your country", "geo restriction", "geo-restricted"}},
+	{failure.AgeRestricted, []string{"confirm your age", "age-restricted", "inappropriate for some users"}},
+	{failure.NotFound, []string{"video unavailable", "is unavailable", "private video", "has been removed", "http error 404"}},
+	{failure.NetworkTimeout, []string{"timed out", "timeout"}},
+	{failure.ParserBroken, []string{"unable to extract", "please report this issue"}},
+}
+
+// youtubeDLKind classifies youtube-dl stderr output.
+func youtubeDLKind(stderr string) failure.Kind {
+	stderr = strings.ToLower(stderr)
+	for _, k := range youtubeDLKinds {
+		for _, m := range k.match {
+			if strings.Contains(stderr, m) {
+				return k.kind
+			}
+		}
+	}
+	return failure.Unknown
+}
@@ -0,0 +1,73 @@
+package youtube
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frizinak/libym/failure"
+)
+
+func TestYoutubeDLKind(t *testing.T) {
+	tests := map[string]failure.Kind{
+		"ERROR: Video unavailable":                                              failure.NotFound,
+		"ERROR: Private video. Sign in if you've been granted access":           failure.NotFound,
+		"ERROR: The uploader has not made this video available in your country": failure.GeoBlocked,
+		"ERROR: This video is not available in your country":                    failure.GeoBlocked,
+		"ERROR: Sign in to confirm your age":                                    failure.AgeRestricted,
+		"ERROR: Unable to download webpage: HTTP Error 429: Too Many Requests":  failure.RateLimited,
+		"ERROR: Unable to extract video data; please report this issue":         failure.ParserBroken,
+		"ERROR: something new":                                                  failure.Unknown,
+	}
+
+	for stderr, kind := range tests {
+		if k := youtubeDLKind(stderr); k != kind {
+			t.Errorf("'%s': expected %s got %s", stderr, kind, k)
+		}
+	}
+}
+
+func TestPageTitleKind(t *testing.T) {
+	_, err := pageTitle(strings.NewReader("<html><title> - YouTube</title></html>"))
+	err = fmt.Errorf("wrapped: %w", err)
+	if !errors.Is(err, failure.NotFound) || failure.KindOf(err) != failure.NotFound {
+		t.Errorf("expected not found, got %s", failure.KindOf(err))
+	}
+
+	_, err = pageTitle(strings.NewReader("<html></html>"))
+	if !errors.Is(err, failure.ParserBroken) || errors.Is(err, failure.NotFound) {
+		t.Errorf("expected parser broken, got %s", failure.KindOf(err))
+	}
+}
+
+func TestSearchMeta(t *testing.T) {
+	const page = `<script>var ytInitialData = {"contents":[{"videoRenderer":{
+		"videoId":"abc",
+		"title":{"runs":[{"text":"a title"}]},
+		"ownerText":{"runs":[{"text":"Artist - Topic"}]},
+		"lengthText":{"simpleText":"1:02:03"},
+		"viewCountText":{"simpleText":"1,234,567 views"},
+		"publishedTimeText":{"simpleText":"Streamed 3 years ago"}
+	}}]};</script>`
+
+	res, _, err := parseSearch(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 {
+		t.Fatalf("expected 1 result got %d", len(res))
+	}
+
+	m := res[0].Meta()
+	if m.Channel != "Artist - Topic" || m.Duration != time.Hour+2*time.Minute+3*time.Second || m.Views != 1234567 {
+		t.Errorf("unexpected meta %+v", m)
+	}
+	if y := time.Now().Year() - m.Published.Year(); y != 3 {
+		t.Errorf("expected a 3 year old clip, got %s", m.Published)
+	}
+	if s := m.String(); s != "Artist - Topic, 1:02:03, 1.2M views, 3y ago" {
+		t.Errorf("unexpected summary '%s'", s)
+	}
+}
@@ -1,6 +1,7 @@
 package youtube
 
 import (
+	"context"
 	"net/http"
 	"net/url"
 )
@@ -17,22 +18,51 @@ func Page(id string) (*url.URL, error) {
 	return u, err
 }
 
-// Title extracts the page title of the given youtube clip id.
+// Title calls TitleContext without context.
 func Title(id string) (string, error) {
+	return TitleContext(context.Background(), id)
+}
+
+// TitleContext extracts the page title of the given youtube clip id.
+// DefaultTimeout is applied if ctx has no deadline.
+func TitleContext(ctx context.Context, id string) (string, error) {
+	return title(ctx, id, Language())
+}
+
+// OriginalTitle calls OriginalTitleContext without context.
+func OriginalTitle(id string) (string, error) {
+	return OriginalTitleContext(context.Background(), id)
+}
+
+// OriginalTitleContext is TitleContext without the language preference set
+// with SetLanguage, i.e.: usually the title the clip was uploaded with.
+func OriginalTitleContext(ctx context.Context, id string) (string, error) {
+	return title(ctx, id, "")
+}
+
+func title(ctx context.Context, id, lang string) (string, error) {
+	ctx, cancel := withDefaultTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
 	u, err := Page(id)
 	if err != nil {
 		return "", err
 	}
 
-	req, err := http.NewRequest("GET", u.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
 	if err != nil {
 		return "", err
 	}
 
-	res, err := doReq(req)
+	res, err := doReqLang(req, lang)
 	if err != nil {
 		return "", err
 	}
 	defer res.Body.Close()
-	return pageTitle(res.Body)
+	rec := record(res.Body)
+	title, err := pageTitle(rec)
+	if err != nil {
+		_ = rec.save(FixtureWatch)
+	}
+	return title, err
 }
@@ -5,21 +5,93 @@ package youtube
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"net/url"
 	"os/exec"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/frizinak/libym/failure"
 	"github.com/frizinak/libym/scraper"
 )
 
 // Result represents a youtube.com search result, i.e.: a youtube clip.
 type Result struct {
-	videoID string
-	title   string
-	u       *url.URL
+	videoID  string
+	title    string
+	original string
+	u        *url.URL
+	meta     Meta
+}
+
+// Meta is what youtube shows next to the title of a search result, it is
+// only known for results of Search.
+type Meta struct {
+	Channel  string
+	Duration time.Duration
+	Views    int64
+	// Published is approximated from texts like "3 years ago", zero if
+	// unknown.
+	Published time.Time
+}
+
+// String returns a short summary, e.g.: "Artist - Topic, 3:45, 1.2M views,
+// 3y ago". Unknown fields are left out.
+func (m Meta) String() string {
+	l := make([]string, 0, 4)
+	if m.Channel != "" {
+		l = append(l, m.Channel)
+	}
+	if m.Duration > 0 {
+		l = append(l, formatDuration(m.Duration))
+	}
+	if m.Views > 0 {
+		l = append(l, formatCount(m.Views)+" views")
+	}
+	if !m.Published.IsZero() {
+		l = append(l, formatAge(time.Since(m.Published)))
+	}
+	return strings.Join(l, ", ")
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	h, m, s := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+func formatCount(n int64) string {
+	switch {
+	case n >= 1e9:
+		return fmt.Sprintf("%.1fB", float64(n)/1e9)
+	case n >= 1e6:
+		return fmt.Sprintf("%.1fM", float64(n)/1e6)
+	case n >= 1e3:
+		return fmt.Sprintf("%.1fK", float64(n)/1e3)
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func formatAge(d time.Duration) string {
+	const day = 24 * time.Hour
+	switch {
+	case d >= 365*day:
+		return fmt.Sprintf("%dy ago", d/(365*day))
+	case d >= 30*day:
+		return fmt.Sprintf("%dmo ago", d/(30*day))
+	case d >= 7*day:
+		return fmt.Sprintf("%dw ago", d/(7*day))
+	case d >= day:
+		return fmt.Sprintf("%dd ago", d/day)
+	}
+	return "today"
 }
 
 // NewResult creates a new youtube result.
@@ -30,6 +102,9 @@ func NewResult(id, title string) *Result {
 	return &Result{videoID: id, title: title}
 }
 
+// Meta returns the search result metadata.
+func (r *Result) Meta() Meta { return r.meta }
+
 // ID returns a the clip id.
 func (r *Result) ID() string { return r.videoID }
 
@@ -53,31 +128,85 @@ func (r *Result) URL() *url.URL {
 // SetTitle updates the title.
 func (r *Result) SetTitle(title string) { r.title = title }
 
-// DownloadURL asks youtube-dl to create a (temporary) download / stream url
-// of the clip's contents.
+// OriginalTitle returns the title the clip was uploaded with if it differs
+// from the localized Title, see SetLanguage. Returns Title otherwise.
+func (r *Result) OriginalTitle() string {
+	if r.original == "" {
+		return r.title
+	}
+	return r.original
+}
+
+// SetOriginalTitle sets the title returned by OriginalTitle.
+func (r *Result) SetOriginalTitle(title string) {
+	r.original = ""
+	if title != r.title {
+		r.original = title
+	}
+}
+
+// DownloadURL calls DownloadURLContext without context.
 func (r *Result) DownloadURL() (*url.URL, error) {
-	cmd := exec.Command("youtube-dl", "-g", "-f", "bestaudio", "--no-playlist", r.URL().String())
+	return r.DownloadURLContext(context.Background())
+}
+
+// DownloadURLContext asks youtube-dl, or the function set with
+// SetResolver, to create a (temporary) download / stream url of the clip's
+// contents.
+// DefaultDownloadURLTimeout is applied if ctx has no deadline.
+func (r *Result) DownloadURLContext(ctx context.Context) (*url.URL, error) {
+	ctx, cancel := withDefaultTimeout(ctx, DefaultDownloadURLTimeout)
+	defer cancel()
+
+	resolver.RLock()
+	resolve := resolver.r
+	resolver.RUnlock()
+	if resolve != nil {
+		return resolve(ctx, r.ID())
+	}
+
+	cmd := exec.CommandContext(ctx, "youtube-dl", "-g", "-f", "bestaudio", "--no-playlist", r.URL().String())
 	buf := bytes.NewBuffer(nil)
 	bufe := bytes.NewBuffer(nil)
 	cmd.Stdout = buf
 	cmd.Stderr = bufe
 	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(bufe.String()))
+		stderr := strings.TrimSpace(bufe.String())
+		kind := youtubeDLKind(stderr)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			kind = failure.NetworkTimeout
+		}
+		return nil, failure.Wrap(kind, fmt.Errorf("%w: %s", err, stderr))
 	}
 
 	return url.Parse(strings.TrimSpace(buf.String()))
 }
 
-// UpdateTitle uses Title to update the clips title using its id.
+// UpdateTitle calls UpdateTitleContext without context.
 func (r *Result) UpdateTitle() error {
-	n, err := Title(r.ID())
+	return r.UpdateTitleContext(context.Background())
+}
+
+// UpdateTitleContext uses TitleContext to update the clips title using its
+// id. If a language was set with SetLanguage, the original title is
+// fetched as well, see OriginalTitle.
+func (r *Result) UpdateTitleContext(ctx context.Context) error {
+	n, err := TitleContext(ctx, r.ID())
 	if err != nil {
 		return fmt.Errorf("%s: %w", r.ID(), err)
 	}
 	if n == "" {
-		return fmt.Errorf("%s: received empty title", r.ID())
+		return failure.Wrap(failure.ParserBroken, fmt.Errorf("%s: received empty title", r.ID()))
+	}
+	original := n
+	if Language() != "" {
+		if original, err = OriginalTitleContext(ctx, r.ID()); err != nil || original == "" {
+			// the localized title is all we need.
+			original = n
+		}
 	}
 	r.title = n
+	r.SetOriginalTitle(original)
 	return nil
 }
 
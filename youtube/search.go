@@ -1,12 +1,22 @@
 package youtube
 
 import (
+	"context"
 	"net/http"
 	"net/url"
 )
 
-// Search queries youtube.com for search results matching the given query.
+// Search calls SearchContext without context.
 func Search(q string) ([]*Result, error) {
+	return SearchContext(context.Background(), q)
+}
+
+// SearchContext queries youtube.com for search results matching the given
+// query. DefaultTimeout is applied if ctx has no deadline.
+func SearchContext(ctx context.Context, q string) ([]*Result, error) {
+	ctx, cancel := withDefaultTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
 	u, err := url.Parse("https://www.youtube.com/results")
 	if err != nil {
 		return nil, err
@@ -17,7 +27,7 @@ func Search(q string) ([]*Result, error) {
 	qry.Set("search_query", q)
 	u.RawQuery = qry.Encode()
 
-	req, err := http.NewRequest("GET", u.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -27,6 +37,10 @@ func Search(q string) ([]*Result, error) {
 		return nil, err
 	}
 	defer res.Body.Close()
-	results, _, err := parseSearch(res.Body)
+	rec := record(res.Body)
+	results, _, err := parseSearch(rec)
+	if err != nil || len(results) == 0 {
+		_ = rec.save(FixtureSearch)
+	}
 	return results, err
 }
@@ -1,9 +1,34 @@
 package youtube
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/frizinak/libym/failure"
+)
+
+const (
+	// DefaultTimeout is used for youtube.com requests when the given context
+	// has no deadline.
+	DefaultTimeout = time.Second * 30
+
+	// DefaultDownloadURLTimeout is used for youtube-dl invocations when the
+	// given context has no deadline.
+	DefaultDownloadURLTimeout = time.Minute * 2
 )
 
+// withDefaultTimeout applies timeout to ctx if it has no deadline yet.
+func withDefaultTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
 const ua = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/87.0.4280.88 Safari/537.36"
 
 func safeReq(req *http.Request) *http.Request {
@@ -11,6 +36,83 @@ func safeReq(req *http.Request) *http.Request {
 	return req
 }
 
+var client struct {
+	sync.RWMutex
+	c *http.Client
+}
+
+// SetHTTPClient sets the client used for youtube.com requests.
+// nil restores http.DefaultClient.
+func SetHTTPClient(c *http.Client) {
+	client.Lock()
+	client.c = c
+	client.Unlock()
+}
+
+func httpClient() *http.Client {
+	client.RLock()
+	c := client.c
+	client.RUnlock()
+	if c == nil {
+		return http.DefaultClient
+	}
+	return c
+}
+
+var resolver struct {
+	sync.RWMutex
+	r func(ctx context.Context, id string) (*url.URL, error)
+}
+
+// SetResolver sets the function DownloadURLContext uses to resolve the
+// stream url of a clip, e.g.: to serve audio from a test server.
+// nil restores youtube-dl.
+func SetResolver(r func(ctx context.Context, id string) (*url.URL, error)) {
+	resolver.Lock()
+	resolver.r = r
+	resolver.Unlock()
+}
+
+var language struct {
+	sync.RWMutex
+	l string
+}
+
+// SetLanguage sets the preferred language of titles and other metadata,
+// sent as Accept-Language with youtube.com requests, e.g.: "nl, en;q=0.8".
+// Empty (the default) leaves it up to youtube.
+func SetLanguage(l string) {
+	language.Lock()
+	language.l = l
+	language.Unlock()
+}
+
+// Language returns the language set with SetLanguage.
+func Language() string {
+	language.RLock()
+	l := language.l
+	language.RUnlock()
+	return l
+}
+
 func doReq(req *http.Request) (*http.Response, error) {
-	return http.DefaultClient.Do(safeReq(req))
+	return doReqLang(req, Language())
+}
+
+// doReqLang is doReq with the given Accept-Language, empty sends none.
+func doReqLang(req *http.Request, lang string) (*http.Response, error) {
+	if lang != "" {
+		req.Header.Set("Accept-Language", lang)
+	}
+	res, err := httpClient().Do(safeReq(req))
+	if err != nil {
+		return nil, failure.Wrap(failure.KindOf(err), err)
+	}
+
+	if err := failure.HTTPStatus(res.StatusCode); err != nil {
+		res.Body.Close()
+		return nil, fmt.Errorf("%s: %w", req.URL, err)
+	}
+
+	return res, nil
 }